@@ -44,6 +44,7 @@ let socket = null;
 let currentCallId = null;
 let isCaller = false;
 let pendingCandidates = [];
+let signalingUrl = null;
 
 // HTML elements
 const webcamButton = document.getElementById('webcamButton');
@@ -60,6 +61,7 @@ const toggleVideoBtn = document.getElementById('toggleVideoBtn');
 const statusText = document.getElementById('statusText');
 const connectionStatus = document.getElementById('connectionStatus');
 const userCount = document.getElementById('userCount');
+const inviteButton = document.getElementById('inviteButton');
 
 let audioMuted = false;
 let videoOff = false;
@@ -123,11 +125,11 @@ function createPeerConnection() {
     pc.onicecandidate = event => {
         if (event.candidate && socket?.readyState === WebSocket.OPEN && currentCallId) {
             console.log("Sending ICE candidate:", event.candidate);
-            socket.send(JSON.stringify({
+            sendMessage({
                 type: "ice-candidate",
                 callId: currentCallId,
                 data: JSON.stringify(event.candidate),
-            }));
+            });
         }
     };
 
@@ -135,8 +137,13 @@ function createPeerConnection() {
         console.log(`ICE state: ${pc.iceConnectionState}`);
         updateConnectionStatus(pc.iceConnectionState);
         if (pc.iceConnectionState === 'failed') {
-            updateStatus("Connection failed");
-            resetCallState();
+            if (socket?.readyState === WebSocket.OPEN && currentCallId) {
+                updateStatus("Connection failed, restarting ICE");
+                sendMessage({ type: "ice_failed", callId: currentCallId });
+            } else {
+                updateStatus("Connection failed");
+                resetCallState();
+            }
         }
     };
 
@@ -159,6 +166,11 @@ webcamButton.onclick = async () => {
     }
 };
 
+// sendMessage sends a signaling message with a fresh nonce and the time it was sent, so the server can reject replays
+function sendMessage(msg) {
+    socket.send(JSON.stringify({ sentAt: new Date().toISOString(), ...msg, nonce: crypto.randomUUID() }));
+}
+
 function connectSocket(onOpenCallback = () => {}) {
     if (socket?.readyState === WebSocket.OPEN) {
         onOpenCallback();
@@ -166,10 +178,13 @@ function connectSocket(onOpenCallback = () => {}) {
     }
 
     const wsProtocol = location.protocol === 'https:' ? 'wss' : 'ws';
-    socket = new WebSocket(`${wsProtocol}://${location.host}/ws`);
+    socket = new WebSocket(signalingUrl || `${wsProtocol}://${location.host}/ws`);
 
     socket.onopen = () => {
         console.log("WebSocket connected");
+        sendMessage({ type: "register", language: navigator.language });
+        sendMessage({ type: "capabilities", supported: [] });
+        sendMessage({ type: "client_info", platform: "web" });
         updateStatus("Connected to signaling server");
         updateConnectionStatus("Connected");
         pc = createPeerConnection();
@@ -189,93 +204,129 @@ function connectSocket(onOpenCallback = () => {}) {
     };
 
     socket.onmessage = async event => {
-        let msg;
+        let frame;
         try {
-            msg = JSON.parse(event.data);
-            console.log("Received message:", msg);
+            frame = JSON.parse(event.data);
         } catch (e) {
             console.error("Invalid message:", event.data, e);
             updateStatus("Error: Invalid message");
             return;
         }
 
-        if (msg.type === "user_count") {
-            updateUserCount(msg.count || 0);
-            return;
+        // Messages written close together arrive batched as one array frame
+        for (const msg of Array.isArray(frame) ? frame : [frame]) {
+            console.log("Received message:", msg);
+            await handleMessage(msg);
         }
+    };
+}
 
-        if (msg.type === "incoming_call" && !isCaller) {
-            currentCallId = msg.callId;
-            showIncomingModal(msg.callId, msg.from || "Unknown");
-            return;
-        }
+async function handleMessage(msg) {
+    if (msg.type === "user_count") {
+        updateUserCount(msg.count || 0);
+        return;
+    }
 
-        if (msg.type === "call_taken" && !isCaller) {
-            hideIncomingModal();
-            updateStatus("Call taken by another user");
-            resetCallState();
-            return;
+    if (msg.type === "reconnect_hint") {
+        if (!currentCallId && msg.url) {
+            console.log(`Server busy, reconnecting to ${msg.url}`);
+            signalingUrl = msg.url;
+            socket.close();
         }
+        return;
+    }
 
-        if (msg.callId && msg.callId !== currentCallId) {
-            console.warn(`Ignoring message with callId ${msg.callId}, expected ${currentCallId}`);
-            return;
-        }
+    if (msg.type === "incoming_call" && !isCaller) {
+        currentCallId = msg.callId;
+        showIncomingModal(msg.callId, msg.from || "Unknown");
+        return;
+    }
 
-        try {
-            if (msg.type === "offer" && !isCaller) {
-                pc = createPeerConnection();
-                await pc.setRemoteDescription(new RTCSessionDescription(JSON.parse(msg.data)));
-                const answer = await pc.createAnswer();
-                await pc.setLocalDescription(answer);
-                socket.send(JSON.stringify({
-                    type: "answer",
-                    callId: currentCallId,
-                    data: JSON.stringify(pc.localDescription),
-                }));
-                updateStatus("Sent answer");
-                for (const candidate of pendingCandidates) {
-                    await pc.addIceCandidate(candidate);
-                }
-                pendingCandidates = [];
-                hangupButton.disabled = false;
-
-            } else if (msg.type === "answer" && isCaller) {
-                await pc.setRemoteDescription(new RTCSessionDescription(JSON.parse(msg.data)));
-                for (const candidate of pendingCandidates) {
-                    await pc.addIceCandidate(candidate);
-                }
-                pendingCandidates = [];
-                hangupButton.disabled = false;
-                updateStatus("Received answer");
-
-            } else if (msg.type === "ice-candidate") {
-                const candidate = new RTCIceCandidate(JSON.parse(msg.data));
-                if (pc.remoteDescription) {
-                    await pc.addIceCandidate(candidate);
-                    updateStatus("Added ICE candidate");
-                } else {
-                    pendingCandidates.push(candidate);
-                    updateStatus("Stored ICE candidate");
-                }
-
-            } else if (msg.type === "call_joined") {
-                updateStatus("Joined call");
-                hangupButton.disabled = false;
-
-            } else if (msg.type === "peer_disconnected") {
-                updateStatus("Peer disconnected");
-                resetCallState();
+    if (msg.type === "ring_timeout" && !isCaller && msg.callId === currentCallId) {
+        hideIncomingModal();
+        updateStatus("Missed call");
+        resetCallState();
+        return;
+    }
 
-            } else if (msg.type === "error") {
-                updateStatus(`Error: ${msg.data}`);
-                resetCallState();
+    if (msg.type === "call_taken" && !isCaller) {
+        hideIncomingModal();
+        updateStatus("Call taken by another user");
+        resetCallState();
+        return;
+    }
+
+    if (msg.callId && msg.callId !== currentCallId) {
+        console.warn(`Ignoring message with callId ${msg.callId}, expected ${currentCallId}`);
+        return;
+    }
+
+    try {
+        if (msg.type === "offer" && !isCaller) {
+            pc = createPeerConnection();
+            await pc.setRemoteDescription(new RTCSessionDescription(JSON.parse(msg.data)));
+            const answer = await pc.createAnswer();
+            await pc.setLocalDescription(answer);
+            sendMessage({
+                type: "answer",
+                callId: currentCallId,
+                data: JSON.stringify(pc.localDescription),
+            });
+            updateStatus("Sent answer");
+            for (const candidate of pendingCandidates) {
+                await pc.addIceCandidate(candidate);
             }
-        } catch (e) {
-            console.error("Message processing error:", e, msg);
-            updateStatus(`Error processing ${msg.type}`);
+            pendingCandidates = [];
+            hangupButton.disabled = false;
+
+        } else if (msg.type === "answer" && isCaller) {
+            await pc.setRemoteDescription(new RTCSessionDescription(JSON.parse(msg.data)));
+            for (const candidate of pendingCandidates) {
+                await pc.addIceCandidate(candidate);
+            }
+            pendingCandidates = [];
+            hangupButton.disabled = false;
+            updateStatus("Received answer");
+
+        } else if (msg.type === "ice-candidate") {
+            const candidate = new RTCIceCandidate(JSON.parse(msg.data));
+            if (pc.remoteDescription) {
+                await pc.addIceCandidate(candidate);
+                updateStatus("Added ICE candidate");
+            } else {
+                pendingCandidates.push(candidate);
+                updateStatus("Stored ICE candidate");
+            }
+
+        } else if (msg.type === "ice_restart" && isCaller) {
+            const offer = await pc.createOffer({ iceRestart: true });
+            await pc.setLocalDescription(offer);
+            sendMessage({
+                type: "offer",
+                callId: currentCallId,
+                data: JSON.stringify(pc.localDescription),
+            });
+            updateStatus("Restarting ICE");
+
+        } else if (msg.type === "call_joined") {
+            updateStatus("Joined call");
+            hangupButton.disabled = false;
+
+        } else if (msg.type === "invite_link") {
+            prompt("Share this link to invite someone to the call", msg.url);
+
+        } else if (msg.type === "peer_disconnected") {
+            updateStatus("Peer disconnected");
+            resetCallState();
+
+        } else if (msg.type === "error") {
+            updateStatus(`Error: ${msg.data}`);
+            resetCallState();
         }
-    };
+    } catch (e) {
+        console.error("Message processing error:", e, msg);
+        updateStatus(`Error processing ${msg.type}`);
+    }
 }
 
 callButton.onclick = async () => {
@@ -286,19 +337,20 @@ callButton.onclick = async () => {
 
     const sendOffer = async () => {
         try {
-            socket.send(JSON.stringify({
+            sendMessage({
                 type: "incoming_call",
                 callId: currentCallId,
                 from: "Caller"
-            }));
+            });
             const offer = await pc.createOffer();
             await pc.setLocalDescription(offer);
-            socket.send(JSON.stringify({
+            sendMessage({
                 type: "offer",
                 callId: currentCallId,
                 data: JSON.stringify(pc.localDescription),
-            }));
+            });
             updateStatus("Sent offer");
+            inviteButton.disabled = false;
         } catch (e) {
             console.error("Offer error:", e);
             updateStatus("Error sending offer");
@@ -312,25 +364,50 @@ callButton.onclick = async () => {
 acceptCallBtn.onclick = async () => {
     hideIncomingModal();
     if (!localStream) await webcamButton.onclick();
-    socket.send(JSON.stringify({
+    sendMessage({
         type: "accept_call",
         callId: currentCallId,
-    }));
+    });
     updateStatus("Accepted call");
 };
 
 rejectCallBtn.onclick = () => {
     hideIncomingModal();
+    if (socket?.readyState === WebSocket.OPEN && currentCallId) {
+        sendMessage({ type: "decline_call", callId: currentCallId });
+    }
     updateStatus("Rejected call");
     resetCallState();
 };
 
+inviteButton.onclick = () => {
+    if (socket?.readyState === WebSocket.OPEN && currentCallId) {
+        sendMessage({
+            type: "get_invite_link",
+            callId: currentCallId,
+        });
+    }
+};
+
+async function joinFromInvite(callId) {
+    if (!localStream) await webcamButton.onclick();
+    currentCallId = callId;
+    history.replaceState(null, "", "/");
+    connectSocket(() => {
+        sendMessage({
+            type: "join_call",
+            callId: currentCallId,
+        });
+        updateStatus("Joining call");
+    });
+}
+
 hangupButton.onclick = () => {
     if (socket?.readyState === WebSocket.OPEN && currentCallId) {
-        socket.send(JSON.stringify({
+        sendMessage({
             type: "hangup",
             callId: currentCallId,
-        }));
+        });
     }
     resetCallState();
 };
@@ -355,6 +432,7 @@ function resetCallState() {
     pendingCandidates = [];
     callButton.disabled = !localStream;
     hangupButton.disabled = true;
+    inviteButton.disabled = true;
     webcamButton.disabled = false;
     hideIncomingModal();
     updateStatus("Call ended");
@@ -374,4 +452,11 @@ function hideIncomingModal() {
     ringtone.currentTime = 0;
 }
 
-window.addEventListener('load', () => connectSocket());
+window.addEventListener('load', () => {
+    const inviteCallId = new URLSearchParams(location.search).get('callId');
+    if (inviteCallId) {
+        joinFromInvite(inviteCallId);
+    } else {
+        connectSocket();
+    }
+});
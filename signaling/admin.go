@@ -0,0 +1,204 @@
+package signaling
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// adminToken guards the admin API, which is disabled when it is empty
+var adminToken = envString("ADMIN_TOKEN", "")
+
+// requireAdmin rejects requests that do not carry the admin bearer token
+func requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if adminToken == "" {
+			http.NotFound(w, r)
+			return
+		}
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			log.Printf("Unauthorized admin request from %v to %s", r.RemoteAddr, r.URL.Path)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// writeJSONResponse encodes v as the JSON body of an HTTP response
+func writeJSONResponse(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error writing JSON response: %v", err)
+	}
+}
+
+// ServerSnapshot is a point-in-time dump of the server state
+type ServerSnapshot struct {
+	UptimeSeconds float64          `json:"uptimeSeconds"`
+	Goroutines    int              `json:"goroutines"`
+	Memory        MemorySnapshot   `json:"memory"`
+	Rooms         []RoomSnapshot   `json:"rooms"`
+	Clients       []ClientSnapshot `json:"clients"`
+	Platforms     map[string]int   `json:"platforms"`
+}
+
+// MemorySnapshot holds the interesting parts of runtime.MemStats
+type MemorySnapshot struct {
+	Alloc       uint64 `json:"alloc"`
+	TotalAlloc  uint64 `json:"totalAlloc"`
+	Sys         uint64 `json:"sys"`
+	HeapObjects uint64 `json:"heapObjects"`
+	NumGC       uint32 `json:"numGC"`
+}
+
+// RoomSnapshot describes a room in a ServerSnapshot
+type RoomSnapshot struct {
+	CallID              string     `json:"callId"`
+	Clients             int        `json:"clients"`
+	AgeSeconds          float64    `json:"ageSeconds"`
+	HasOffer            bool       `json:"hasOffer"`
+	Transcriptions      int        `json:"transcriptions"`
+	LastTranscriptionAt *time.Time `json:"lastTranscriptionAt,omitempty"`
+}
+
+// ClientSnapshot describes a client in a ServerSnapshot
+type ClientSnapshot struct {
+	ID          string    `json:"id"`
+	IP          string    `json:"ip"`
+	CallIDs     []string  `json:"callIds,omitempty"`
+	Idle        bool      `json:"idle"`
+	ConnectedAt time.Time `json:"connectedAt"`
+	PingRTTMs   int64     `json:"pingRttMs"`
+	HighLatency bool      `json:"highLatency"`
+	EchoP50Ms   int64     `json:"echoP50Ms"`
+	EchoP95Ms   int64     `json:"echoP95Ms"`
+	EchoP99Ms   int64     `json:"echoP99Ms"`
+
+	ConnectionTestP50Ms int64  `json:"connectionTestP50Ms"`
+	Role                string `json:"role,omitempty"`
+	SlowLink            bool   `json:"slowLink"`
+	Platform            string `json:"platform"`
+	AppVersion          string `json:"appVersion,omitempty"`
+	SDKVersion          string `json:"sdkVersion,omitempty"`
+}
+
+// lockAll takes clientsMu and roomsMu together, backing off instead of blocking on the second lock
+func lockAll(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		clientsMu.Lock()
+		if roomsMu.TryLock() {
+			return true
+		}
+		clientsMu.Unlock()
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// unlockAll releases the locks taken by lockAll
+func unlockAll() {
+	roomsMu.Unlock()
+	clientsMu.Unlock()
+}
+
+// handleAdminSnapshot returns a JSON dump of all rooms and clients
+func handleAdminSnapshot(w http.ResponseWriter, r *http.Request) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	snapshot := ServerSnapshot{
+		UptimeSeconds: time.Since(startTime).Seconds(),
+		Goroutines:    runtime.NumGoroutine(),
+		Memory: MemorySnapshot{
+			Alloc:       mem.Alloc,
+			TotalAlloc:  mem.TotalAlloc,
+			Sys:         mem.Sys,
+			HeapObjects: mem.HeapObjects,
+			NumGC:       mem.NumGC,
+		},
+		Rooms:     []RoomSnapshot{},
+		Clients:   []ClientSnapshot{},
+		Platforms: platformCounts(),
+	}
+
+	if !lockAll(time.Second) {
+		http.Error(w, "Server busy, try again", http.StatusServiceUnavailable)
+		return
+	}
+	for callID, room := range rooms {
+		rs := RoomSnapshot{
+			CallID:         callID,
+			Clients:        len(room.clients),
+			AgeSeconds:     time.Since(room.createdAt).Seconds(),
+			HasOffer:       room.offer != nil,
+			Transcriptions: room.transcriptions,
+		}
+		if !room.lastTranscriptionAt.IsZero() {
+			last := room.lastTranscriptionAt
+			rs.LastTranscriptionAt = &last
+		}
+		snapshot.Rooms = append(snapshot.Rooms, rs)
+	}
+	clients.Range(func(k, v interface{}) bool {
+		snapshot.Clients = append(snapshot.Clients, describeClient(k.(*wsConn), v.(*Client)))
+		return true
+	})
+	unlockAll()
+
+	log.Printf("Admin snapshot taken by %v: %d rooms, %d clients", r.RemoteAddr, len(snapshot.Rooms), len(snapshot.Clients))
+	writeJSONResponse(w, http.StatusOK, snapshot)
+}
+
+// describeClient builds the ClientSnapshot for a client; callers hold clientsMu
+func describeClient(ws *wsConn, client *Client) ClientSnapshot {
+	client.mu.Lock()
+	rtt := client.pongLatency
+	echoes := client.echoDelays.values()
+	tests := client.connectionTests.values()
+	appVersion, sdkVersion := client.appVersion, client.sdkVersion
+	client.mu.Unlock()
+	desc := ClientSnapshot{
+		ID:                  client.id,
+		IP:                  client.ip,
+		CallIDs:             client.activeCalls(),
+		Idle:                idleClients[ws],
+		ConnectedAt:         client.connectedAt,
+		PingRTTMs:           rtt.Milliseconds(),
+		HighLatency:         client.highLatency(),
+		EchoP50Ms:           percentile(echoes, 50).Milliseconds(),
+		EchoP95Ms:           percentile(echoes, 95).Milliseconds(),
+		EchoP99Ms:           percentile(echoes, 99).Milliseconds(),
+		ConnectionTestP50Ms: percentile(tests, 50).Milliseconds(),
+		SlowLink:            client.isSlowLink(),
+		Platform:            client.platformName(),
+		AppVersion:          appVersion,
+		SDKVersion:          sdkVersion,
+	}
+	if auth := client.claims(); auth != nil {
+		desc.Role = auth.Role
+	}
+	return desc
+}
+
+// handleGetClient describes one connected client
+func handleGetClient(w http.ResponseWriter, r *http.Request) {
+	client := findClient(r.PathValue("clientId"))
+	if client == nil {
+		http.Error(w, "Client not found", http.StatusNotFound)
+		return
+	}
+	clientsMu.Lock()
+	desc := describeClient(client.conn, client)
+	clientsMu.Unlock()
+	writeJSONResponse(w, http.StatusOK, desc)
+}
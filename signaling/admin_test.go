@@ -0,0 +1,119 @@
+package signaling
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// setAdminToken replaces ADMIN_TOKEN for the rest of the test
+func setAdminToken(t *testing.T, token string) {
+	previous := adminToken
+	adminToken = token
+	t.Cleanup(func() { adminToken = previous })
+}
+
+// adminRequest sends an admin API request with the bearer token, which may be empty, and returns the response
+func (ts *TestServer) adminRequest(method, path, token string) *http.Response {
+	ts.t.Helper()
+	req, err := http.NewRequest(method, ts.URL()+path, nil)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	ts.t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestAdminSnapshotSchema(t *testing.T) {
+	ts := NewTestServer(t)
+	setAdminToken(t, "snapshot-admin")
+	caller, callee := ts.ConnectWithClientID("snapshot-caller"), ts.ConnectWithClientID("snapshot-callee")
+	ts.ConnectWithClientID("snapshot-idle")
+	ts.startCall(caller, callee, "snapshot-call")
+
+	resp := ts.adminRequest("POST", "/api/v1/admin/snapshot", "snapshot-admin")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("snapshot status %d", resp.StatusCode)
+	}
+	var raw map[string]json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"uptimeSeconds", "goroutines", "memory", "rooms", "clients", "platforms"} {
+		if _, ok := raw[key]; !ok {
+			t.Errorf("snapshot has no %q", key)
+		}
+	}
+	var memory map[string]json.Number
+	if err := json.Unmarshal(raw["memory"], &memory); err != nil {
+		t.Fatalf("memory is not an object of numbers: %v", err)
+	}
+	for _, key := range []string{"alloc", "totalAlloc", "sys", "heapObjects", "numGC"} {
+		if _, ok := memory[key]; !ok {
+			t.Errorf("memory has no %q", key)
+		}
+	}
+
+	var snapshot ServerSnapshot
+	for key, into := range map[string]interface{}{"goroutines": &snapshot.Goroutines, "rooms": &snapshot.Rooms, "clients": &snapshot.Clients} {
+		if err := json.Unmarshal(raw[key], into); err != nil {
+			t.Fatalf("decoding %s: %v", key, err)
+		}
+	}
+	if snapshot.Goroutines <= 0 {
+		t.Errorf("goroutines %d", snapshot.Goroutines)
+	}
+	var room *RoomSnapshot
+	for i := range snapshot.Rooms {
+		if snapshot.Rooms[i].CallID == "snapshot-call" {
+			room = &snapshot.Rooms[i]
+		}
+	}
+	if room == nil || room.Clients != 2 || !room.HasOffer || room.AgeSeconds < 0 {
+		t.Fatalf("snapshot-call in snapshot %+v", room)
+	}
+	byID := make(map[string]ClientSnapshot)
+	for _, c := range snapshot.Clients {
+		byID[c.ID] = c
+	}
+	if c := byID["snapshot-caller"]; len(c.CallIDs) != 1 || c.CallIDs[0] != "snapshot-call" || c.Idle || c.IP == "" {
+		t.Errorf("snapshot-caller %+v", c)
+	}
+	if c, ok := byID["snapshot-idle"]; !ok || !c.Idle || len(c.CallIDs) != 0 {
+		t.Errorf("snapshot-idle %+v", c)
+	}
+}
+
+func TestAdminSnapshotNeedsToken(t *testing.T) {
+	ts := NewTestServer(t)
+	setAdminToken(t, "")
+	if resp := ts.adminRequest("POST", "/api/v1/admin/snapshot", "anything"); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("status %d with the admin API disabled, want 404", resp.StatusCode)
+	}
+	setAdminToken(t, "snapshot-admin")
+	if resp := ts.adminRequest("POST", "/api/v1/admin/snapshot", "wrong"); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("status %d with a wrong token, want 401", resp.StatusCode)
+	}
+}
+
+func TestLockAllBacksOffWhileRoomsLocked(t *testing.T) {
+	roomsMu.Lock()
+	locked := lockAll(20 * time.Millisecond)
+	roomsMu.Unlock()
+	if locked {
+		unlockAll()
+		t.Fatal("lockAll took roomsMu while it was held")
+	}
+	if !lockAll(time.Second) {
+		t.Fatal("lockAll failed with nothing held")
+	}
+	unlockAll()
+}
@@ -0,0 +1,89 @@
+package signaling
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// analyticsWindow is the period GET /api/v1/analytics/queue reports on
+const analyticsWindow = time.Hour
+
+// callRecord is the queue history of one call; exactly one of AcceptedAt and AbandonedAt is set
+type callRecord struct {
+	CallID      string
+	EnqueuedAt  time.Time
+	AcceptedAt  time.Time
+	AbandonedAt time.Time
+}
+
+// Finished calls, kept in a ring buffer of ANALYTICS_BUFFER_SIZE records
+var (
+	callRecords     = make([]callRecord, max(envInt("ANALYTICS_BUFFER_SIZE", 1000), 1))
+	callRecordsNext int
+	callRecordsFull bool
+	callRecordsMu   sync.Mutex
+)
+
+// QueueAnalytics summarizes queue wait times over the analytics window
+type QueueAnalytics struct {
+	WindowSeconds  int     `json:"windowSeconds"`
+	Calls          int     `json:"calls"`
+	Accepted       int     `json:"accepted"`
+	Abandoned      int     `json:"abandoned"`
+	CallsPerMinute float64 `json:"callsPerMinute"`
+	AbandonRate    float64 `json:"abandonRate"`
+	WaitP50Ms      int64   `json:"waitP50Ms"`
+	WaitP95Ms      int64   `json:"waitP95Ms"`
+	WaitP99Ms      int64   `json:"waitP99Ms"`
+}
+
+// recordCall adds a finished call to the history, overwriting the oldest record when full
+func recordCall(rec callRecord) {
+	callRecordsMu.Lock()
+	defer callRecordsMu.Unlock()
+	callRecords[callRecordsNext] = rec
+	callRecordsNext = (callRecordsNext + 1) % len(callRecords)
+	if callRecordsNext == 0 {
+		callRecordsFull = true
+	}
+}
+
+// queueAnalytics computes QueueAnalytics from the calls enqueued since now minus analyticsWindow
+func queueAnalytics(now time.Time) QueueAnalytics {
+	callRecordsMu.Lock()
+	records := append([]callRecord(nil), callRecords[:callRecordsNext]...)
+	if callRecordsFull {
+		records = append(records, callRecords[callRecordsNext:]...)
+	}
+	callRecordsMu.Unlock()
+
+	stats := QueueAnalytics{WindowSeconds: int(analyticsWindow.Seconds())}
+	since := now.Add(-analyticsWindow)
+	var waits []time.Duration
+	for _, rec := range records {
+		if rec.EnqueuedAt.Before(since) {
+			continue
+		}
+		stats.Calls++
+		if rec.AcceptedAt.IsZero() {
+			stats.Abandoned++
+			continue
+		}
+		stats.Accepted++
+		waits = append(waits, rec.AcceptedAt.Sub(rec.EnqueuedAt))
+	}
+	stats.CallsPerMinute = float64(stats.Calls) / analyticsWindow.Minutes()
+	if stats.Calls > 0 {
+		stats.AbandonRate = float64(stats.Abandoned) / float64(stats.Calls)
+	}
+	stats.WaitP50Ms = percentile(waits, 50).Milliseconds()
+	stats.WaitP95Ms = percentile(waits, 95).Milliseconds()
+	stats.WaitP99Ms = percentile(waits, 99).Milliseconds()
+	return stats
+}
+
+// handleQueueAnalytics serves wait time statistics for the last hour of queued calls
+func handleQueueAnalytics(w http.ResponseWriter, r *http.Request) {
+	writeJSONResponse(w, http.StatusOK, queueAnalytics(time.Now()))
+}
@@ -0,0 +1,89 @@
+package signaling
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// setCallRecords gives the test an empty call history of size records
+func setCallRecords(t *testing.T, size int) {
+	callRecordsMu.Lock()
+	records, next, full := callRecords, callRecordsNext, callRecordsFull
+	callRecords, callRecordsNext, callRecordsFull = make([]callRecord, size), 0, false
+	callRecordsMu.Unlock()
+	t.Cleanup(func() {
+		callRecordsMu.Lock()
+		callRecords, callRecordsNext, callRecordsFull = records, next, full
+		callRecordsMu.Unlock()
+	})
+}
+
+func TestQueueAnalyticsPercentiles(t *testing.T) {
+	setCallRecords(t, 1000)
+	setAdminToken(t, "analytics-admin")
+	ts := NewTestServer(t)
+	now := time.Now()
+
+	// 100 calls answered after 1s to 100s, 25 abandoned, and 10 from before the window that must not count
+	for i := 1; i <= 100; i++ {
+		enqueued := now.Add(-time.Duration(i) * 30 * time.Second)
+		recordCall(callRecord{CallID: fmt.Sprintf("answered-%d", i), EnqueuedAt: enqueued, AcceptedAt: enqueued.Add(time.Duration(i) * time.Second)})
+	}
+	for i := 1; i <= 25; i++ {
+		enqueued := now.Add(-time.Duration(i) * time.Minute)
+		recordCall(callRecord{CallID: fmt.Sprintf("abandoned-%d", i), EnqueuedAt: enqueued, AbandonedAt: enqueued.Add(time.Minute)})
+	}
+	for i := 1; i <= 10; i++ {
+		enqueued := now.Add(-analyticsWindow - time.Duration(i)*time.Minute)
+		recordCall(callRecord{CallID: fmt.Sprintf("old-%d", i), EnqueuedAt: enqueued, AcceptedAt: enqueued.Add(time.Hour)})
+	}
+
+	resp := ts.adminRequest("GET", "/api/v1/analytics/queue", "analytics-admin")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("analytics status %d", resp.StatusCode)
+	}
+	var stats QueueAnalytics
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	want := QueueAnalytics{
+		WindowSeconds:  3600,
+		Calls:          125,
+		Accepted:       100,
+		Abandoned:      25,
+		CallsPerMinute: 125.0 / 60,
+		AbandonRate:    0.2,
+		WaitP50Ms:      50000,
+		WaitP95Ms:      95000,
+		WaitP99Ms:      99000,
+	}
+	if math.Abs(stats.CallsPerMinute-want.CallsPerMinute) < 1e-9 {
+		stats.CallsPerMinute = want.CallsPerMinute
+	}
+	if stats != want {
+		t.Fatalf("analytics %+v, want %+v", stats, want)
+	}
+
+	if resp := ts.adminRequest("GET", "/api/v1/analytics/queue", ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("analytics without a token answered %d", resp.StatusCode)
+	}
+}
+
+func TestCallRecordsKeepLatest(t *testing.T) {
+	setCallRecords(t, 3)
+	now := time.Now()
+	for i := 1; i <= 5; i++ {
+		recordCall(callRecord{CallID: fmt.Sprintf("ring-%d", i), EnqueuedAt: now, AcceptedAt: now.Add(time.Duration(i) * time.Second)})
+	}
+	stats := queueAnalytics(now)
+	if stats.Calls != 3 || stats.WaitP50Ms != 4000 || stats.WaitP99Ms != 5000 {
+		t.Fatalf("analytics over a full ring %+v, want the last 3 calls", stats)
+	}
+	if empty := queueAnalytics(now.Add(2 * analyticsWindow)); empty.Calls != 0 || empty.AbandonRate != 0 || empty.WaitP50Ms != 0 {
+		t.Fatalf("analytics with nothing in the window %+v", empty)
+	}
+}
@@ -0,0 +1,59 @@
+package signaling
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// auditLogPath is the file audit events are appended to as JSON lines; empty writes them to the server log
+var auditLogPath = envString("AUDIT_LOG_PATH", "")
+
+// jsonlMu serializes appends to the JSON-lines logs
+var jsonlMu sync.Mutex
+
+// AuditEvent is one line of the audit log
+type AuditEvent struct {
+	Time     time.Time   `json:"time"`
+	Event    string      `json:"event"`
+	CallID   string      `json:"callId,omitempty"`
+	ClientID string      `json:"clientId,omitempty"`
+	Details  interface{} `json:"details,omitempty"`
+}
+
+// audit records an event in the audit log
+func audit(event, callID, clientID string, details interface{}) {
+	line, err := json.Marshal(AuditEvent{
+		Time:     time.Now().UTC(),
+		Event:    event,
+		CallID:   callID,
+		ClientID: clientID,
+		Details:  details,
+	})
+	if err != nil {
+		log.Printf("Error encoding audit event %s: %v", event, err)
+		return
+	}
+	if auditLogPath == "" {
+		log.Printf("AUDIT %s", line)
+		return
+	}
+	appendJSONLine(auditLogPath, line)
+}
+
+// appendJSONLine appends one encoded JSON value and a newline to the file at path
+func appendJSONLine(path string, line []byte) {
+	jsonlMu.Lock()
+	defer jsonlMu.Unlock()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		log.Printf("Error opening %s: %v", path, err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		log.Printf("Error writing %s: %v", path, err)
+	}
+}
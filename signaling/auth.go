@@ -0,0 +1,167 @@
+package signaling
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Connection authentication configuration, tokens are required when either is set
+var (
+	jwtSecret        = envString("JWT_SECRET", "")
+	jwtPublicKeyFile = envString("JWT_PUBLIC_KEY_FILE", "")
+	jwtPublicKey     = loadJWTPublicKey(jwtPublicKeyFile)
+)
+
+// SetJWTSecret replaces JWT_SECRET, the key HS256 connection tokens are signed with, and returns the one it replaced;
+// an empty secret stops accepting HS256 tokens. Call it before the server takes connections.
+func (s *Server) SetJWTSecret(secret string) (previous string) {
+	previous, jwtSecret = jwtSecret, secret
+	return previous
+}
+
+// connectedSubjects are the client IDs taken from the tokens of connected clients, so each sub connects once at a time
+var connectedSubjects sync.Map
+
+// claimSubject reserves a token's sub as a client ID, reporting false if a connected client already has it;
+// nil claims, from unauthenticated servers, need no reservation
+func claimSubject(auth *AuthClaims) bool {
+	if auth == nil {
+		return true
+	}
+	_, taken := connectedSubjects.LoadOrStore(auth.Subject, true)
+	return !taken
+}
+
+// releaseSubject frees a client ID reserved with claimSubject
+func releaseSubject(auth *AuthClaims) {
+	if auth != nil {
+		connectedSubjects.Delete(auth.Subject)
+	}
+}
+
+// errRoomForbidden is returned when a client's token does not grant it the room it tries to enter
+var errRoomForbidden = errors.New("room_forbidden")
+
+// AuthClaims are what a client's connection token allows it
+type AuthClaims struct {
+	Subject   string    // client ID the client connects as
+	Rooms     []string  // call IDs the client may enter, "*" for any
+	Role      string    // application-defined role
+	ExpiresAt time.Time // when the token expires, after which the cleanup pass closes connections still using it
+}
+
+// roomList is the rooms claim, either an array of call IDs or the string "*"
+type roomList []string
+
+// UnmarshalJSON accepts both forms of the rooms claim
+func (l *roomList) UnmarshalJSON(data []byte) error {
+	var wildcard string
+	if err := json.Unmarshal(data, &wildcard); err == nil {
+		if wildcard != "*" {
+			return fmt.Errorf("rooms must be an array or \"*\", got %q", wildcard)
+		}
+		*l = roomList{"*"}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(l))
+}
+
+// authClaims are the claims of a connection token
+type authClaims struct {
+	Rooms roomList `json:"rooms"`
+	Role  string   `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// authRequired reports whether connections must present a token
+func authRequired() bool {
+	return jwtSecret != "" || jwtPublicKey != nil || introspectURL != ""
+}
+
+// loadJWTPublicKey reads the PEM RSA key RS256 tokens are verified with, exiting if the file is unusable
+func loadJWTPublicKey(path string) *rsa.PublicKey {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("Reading JWT_PUBLIC_KEY_FILE %s failed: %v", path, err)
+	}
+	key, err := jwt.ParseRSAPublicKeyFromPEM(data)
+	if err != nil {
+		log.Fatalf("Parsing JWT_PUBLIC_KEY_FILE %s failed: %v", path, err)
+	}
+	return key
+}
+
+// parseAuth validates the ?token= connection token, returning nil claims when authentication is disabled.
+// Without a license secret, whose tokens use the header, the token may instead come as Authorization: Bearer.
+func parseAuth(r *http.Request) (*AuthClaims, error) {
+	if !authRequired() {
+		return nil, nil
+	}
+	tokenString := r.URL.Query().Get("token")
+	if tokenString == "" && licenseSecret == "" {
+		tokenString, _ = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+	return parseToken(tokenString)
+}
+
+// parseToken verifies a connection token, by introspection when OAUTH2_INTROSPECT_URL is set, and returns its claims
+func parseToken(tokenString string) (*AuthClaims, error) {
+	if tokenString == "" {
+		return nil, errors.New("missing token")
+	}
+	if introspectURL != "" {
+		return introspect(tokenString)
+	}
+	var methods []string
+	if jwtSecret != "" {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if jwtPublicKey != nil {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+	claims := &authClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		if t.Method.Alg() == jwt.SigningMethodRS256.Alg() {
+			return jwtPublicKey, nil
+		}
+		return []byte(jwtSecret), nil
+	}, jwt.WithValidMethods(methods), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" || len(claims.Subject) > 64 {
+		return nil, errors.New("token has no valid sub")
+	}
+	return &AuthClaims{
+		Subject:   claims.Subject,
+		Rooms:     claims.Rooms,
+		Role:      claims.Role,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}, nil
+}
+
+// claims returns the client's current token claims, which refresh_token may replace
+func (c *Client) claims() *AuthClaims {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Auth
+}
+
+// allows reports whether the claims grant callID; nil claims, from unauthenticated servers, allow everything
+func (a *AuthClaims) allows(callID string) bool {
+	return a == nil || slices.Contains(a.Rooms, "*") || slices.Contains(a.Rooms, callID)
+}
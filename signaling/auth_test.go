@@ -0,0 +1,57 @@
+package signaling
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestDuplicateSubjectRejected(t *testing.T) {
+	ts := NewTestServer(t)
+	first := ts.ConnectWithClientID("dup-subject")
+	token := ts.Token("dup-subject", jwt.MapClaims{"rooms": "*"})
+	if status := ts.DialStatus(url.Values{"token": {token}}); status != http.StatusConflict {
+		t.Fatalf("second connection as dup-subject got %d, want 409", status)
+	}
+	resp, err := http.Post(ts.URL()+"/api/v1/poll/connect?token="+token, "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("long-poll connection as dup-subject got %d, want 409", resp.StatusCode)
+	}
+
+	first.Close()
+	for deadline := time.Now().Add(testTimeout); findClient("dup-subject") != nil; time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("first connection never cleaned up")
+		}
+	}
+	ts.ConnectWithClientID("dup-subject")
+}
+
+func TestTokenRoomsLimitAdmission(t *testing.T) {
+	ts := NewTestServer(t)
+	host := ts.Connect()
+	ts.Send(host, Message{Type: "offer", CallID: "token-room", Data: sdpData("offer")})
+	ts.waitForRoom("token-room", 1)
+
+	outsider := ts.Dial(url.Values{"token": {ts.Token("token-outsider", jwt.MapClaims{"rooms": []string{"other-room"}})}})
+	ts.Send(outsider, Message{Type: "join_call", CallID: "token-room"})
+	ts.AssertError(outsider, "room_forbidden")
+	insider := ts.Dial(url.Values{"token": {ts.Token("token-insider", jwt.MapClaims{"rooms": []string{"token-room"}})}})
+	ts.Send(insider, Message{Type: "join_call", CallID: "token-room"})
+	ts.AssertMessageReceived(insider, "call_joined", testTimeout)
+}
+
+func TestExpiredTokenRejected(t *testing.T) {
+	ts := NewTestServer(t)
+	token := ts.Token("token-expired", jwt.MapClaims{"rooms": "*", "exp": time.Now().Add(-time.Minute).Unix()})
+	if status := ts.DialStatus(url.Values{"token": {token}}); status != http.StatusUnauthorized {
+		t.Fatalf("expired token got %d, want 401", status)
+	}
+}
@@ -0,0 +1,310 @@
+package signaling
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+)
+
+// BenchmarkConcurrentCalls runs b.N two-party calls at once, each connecting both clients, exchanging offer,
+// answer and ICE candidates, hanging up and disconnecting, and stops the clock once the server has cleaned up
+// every client
+func BenchmarkConcurrentCalls(b *testing.B) {
+	previousLog := log.Writer()
+	log.SetOutput(io.Discard)
+	b.Cleanup(func() {
+		for userCountPending.Load() {
+			time.Sleep(time.Millisecond)
+		}
+		log.SetOutput(previousLog)
+	})
+	ts := NewTestServer(b)
+	baseline := clientCount.Load()
+
+	queries := make([][2]url.Values, b.N)
+	for i := range queries {
+		for j, role := range []string{"caller", "callee"} {
+			queries[i][j] = url.Values{"token": {ts.Token(fmt.Sprintf("bench-%s-%d-%d", role, b.N, i), jwt.MapClaims{"rooms": "*"})}}
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	errs := make(chan error, b.N)
+	var wg sync.WaitGroup
+	for i := 0; i < b.N; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := ts.runCall(queries[i][0], queries[i][1], fmt.Sprintf("bench-call-%d-%d", b.N, i)); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	for deadline := time.Now().Add(testTimeout); clientCount.Load() > baseline && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	b.StopTimer()
+
+	close(errs)
+	for err := range errs {
+		b.Fatal(err)
+	}
+	if n := clientCount.Load(); n > baseline {
+		b.Fatalf("%d clients still connected after every call ended", n-baseline)
+	}
+}
+
+// disconnectBenchCalls is how many calls stay up in the background while BenchmarkDisconnectDuringCalls runs
+const disconnectBenchCalls = 200
+
+// BenchmarkDisconnectDuringCalls times a callee disconnecting from a call until the caller is told, while
+// disconnectBenchCalls other calls are in progress; what a disconnect costs should not depend on them
+func BenchmarkDisconnectDuringCalls(b *testing.B) {
+	previousLog := log.Writer()
+	log.SetOutput(io.Discard)
+	b.Cleanup(func() {
+		for userCountPending.Load() {
+			time.Sleep(time.Millisecond)
+		}
+		log.SetOutput(previousLog)
+	})
+	ts := NewTestServer(b)
+	for i := 0; i < disconnectBenchCalls; i++ {
+		ts.startCall(ts.Connect(), ts.Connect(), fmt.Sprintf("bench-background-%d-%d", b.N, i))
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		caller, callee := ts.Connect(), ts.Connect()
+		ts.startCall(caller, callee, fmt.Sprintf("bench-disconnect-%d-%d", b.N, i))
+		b.StartTimer()
+		callee.Close()
+		ts.AssertMessageReceived(caller, "peer_disconnected", testTimeout)
+		caller.Close()
+	}
+}
+
+// runCall connects a caller and callee and takes them through a call from offer to disconnect
+func (ts *TestServer) runCall(callerQuery, calleeQuery url.Values, callID string) error {
+	caller, err := ts.dial(callerQuery, nil)
+	if err != nil {
+		return err
+	}
+	defer caller.Close()
+	callee, err := ts.dial(calleeQuery, nil)
+	if err != nil {
+		return err
+	}
+	defer callee.Close()
+
+	steps := []struct {
+		from, to *websocket.Conn
+		msg      Message
+		expect   string
+	}{
+		{caller, nil, Message{Type: "offer", CallID: callID, Data: sdpData("offer")}, ""},
+		{callee, callee, Message{Type: "accept_call", CallID: callID}, "offer"},
+		{callee, caller, Message{Type: "answer", CallID: callID, Data: sdpData("answer")}, "answer"},
+		{caller, callee, Message{Type: "ice-candidate", CallID: callID, Data: `{"candidate":"candidate:1 1 udp 1 10.0.0.1 5000 typ host"}`}, "ice-candidate"},
+		{callee, caller, Message{Type: "ice-candidate", CallID: callID, Data: `{"candidate":"candidate:1 1 udp 1 10.0.0.2 5000 typ host"}`}, "ice-candidate"},
+		{callee, caller, Message{Type: "hangup", CallID: callID}, "peer_disconnected"},
+	}
+	for _, step := range steps {
+		if err := step.from.WriteJSON(step.msg); err != nil {
+			return fmt.Errorf("%s: sending %s: %v", callID, step.msg.Type, err)
+		}
+		if step.to == nil {
+			if err := awaitRoom(callID, 1); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := ts.receive(step.to, step.expect, testTimeout); err != nil {
+			return fmt.Errorf("%s: after %s: %v", callID, step.msg.Type, err)
+		}
+	}
+	return nil
+}
+
+// BenchmarkClientLookup looks clients up from many goroutines at once, through the clients sync.Map and through
+// the mutex-guarded map it replaced, with a write for every 100 lookups as connects and disconnects would cause
+func BenchmarkClientLookup(b *testing.B) {
+	const registered = 1000
+	conns := make([]*wsConn, registered)
+	for i := range conns {
+		conns[i] = &wsConn{}
+	}
+
+	lookups := map[string]func() func(i int){
+		"sync.Map": func() func(i int) {
+			var m sync.Map
+			for _, conn := range conns {
+				m.Store(conn, &Client{})
+			}
+			return func(i int) {
+				conn := conns[i%registered]
+				if i%100 == 0 {
+					m.Store(conn, &Client{})
+					return
+				}
+				if _, ok := m.Load(conn); !ok {
+					panic("client missing")
+				}
+			}
+		},
+		"mutex": func() func(i int) {
+			var mu sync.Mutex
+			m := make(map[*wsConn]*Client)
+			for _, conn := range conns {
+				m[conn] = &Client{}
+			}
+			return func(i int) {
+				conn := conns[i%registered]
+				mu.Lock()
+				defer mu.Unlock()
+				if i%100 == 0 {
+					m[conn] = &Client{}
+					return
+				}
+				if _, ok := m[conn]; !ok {
+					panic("client missing")
+				}
+			}
+		},
+	}
+	for _, goroutines := range []int{100, 1000, 10000} {
+		for _, name := range []string{"sync.Map", "mutex"} {
+			b.Run(fmt.Sprintf("goroutines=%d/%s", goroutines, name), func(b *testing.B) {
+				lookup := lookups[name]()
+				b.ReportAllocs()
+				b.ResetTimer()
+				var wg sync.WaitGroup
+				for g := 0; g < goroutines; g++ {
+					wg.Add(1)
+					go func(g int) {
+						defer wg.Done()
+						for i := g; i < b.N; i += goroutines {
+							lookup(i)
+						}
+					}(g)
+				}
+				wg.Wait()
+			})
+		}
+	}
+}
+
+// BenchmarkRoomLocking updates rooms from one goroutine per room across 1000 rooms of 4 clients, through the
+// per-room locks and through an exclusive roomsMu as every room operation used to take
+func BenchmarkRoomLocking(b *testing.B) {
+	const roomCount = 1000
+	callIDs := make([]string, roomCount)
+	roomsMu.Lock()
+	for i := range callIDs {
+		callIDs[i] = fmt.Sprintf("bench-lock-%d", i)
+		room := newRoom()
+		for j := 0; j < 4; j++ {
+			room.clients[&wsConn{}] = true
+		}
+		rooms[callIDs[i]] = room
+	}
+	roomsMu.Unlock()
+	b.Cleanup(func() {
+		roomsMu.Lock()
+		for _, callID := range callIDs {
+			delete(rooms, callID)
+		}
+		roomsMu.Unlock()
+	})
+
+	// touch is a typical room operation: read the members and update the room
+	touch := func(room *Room) {
+		for range room.clients {
+		}
+		room.transcriptions++
+	}
+	for _, mode := range []string{"per-room", "global"} {
+		b.Run(mode, func(b *testing.B) {
+			b.ReportAllocs()
+			var wg sync.WaitGroup
+			for r := 0; r < roomCount; r++ {
+				wg.Add(1)
+				go func(r int) {
+					defer wg.Done()
+					for i := r; i < b.N; i += roomCount {
+						if mode == "global" {
+							roomsMu.Lock()
+							touch(rooms[callIDs[r]])
+							roomsMu.Unlock()
+							continue
+						}
+						room, unlock := lockRoom(callIDs[r])
+						touch(room)
+						unlock()
+					}
+				}(r)
+			}
+			wg.Wait()
+		})
+	}
+}
+
+// removeBenchRooms is how many rooms BenchmarkRemoveFromRooms keeps open
+const removeBenchRooms = 10000
+
+// BenchmarkRemoveFromRooms times dropping a monitor of one room among removeBenchRooms, either looking in
+// every room as the disconnect path used to or only in the room the client is recorded against
+func BenchmarkRemoveFromRooms(b *testing.B) {
+	previousLog := log.Writer()
+	log.SetOutput(io.Discard)
+	b.Cleanup(func() { log.SetOutput(previousLog) })
+
+	callIDs := make([]string, removeBenchRooms)
+	roomsMu.Lock()
+	for i := range callIDs {
+		callIDs[i] = fmt.Sprintf("bench-rooms-%d", i)
+		rooms[callIDs[i]] = newRoom()
+	}
+	roomsMu.Unlock()
+	b.Cleanup(func() {
+		roomsMu.Lock()
+		for _, callID := range callIDs {
+			delete(rooms, callID)
+		}
+		roomsMu.Unlock()
+	})
+	conn, _ := batchedPair(b)
+	monitor := &Client{id: "bench-rooms-monitor", conn: conn}
+	target := callIDs[removeBenchRooms/2]
+
+	for _, bm := range []struct {
+		name    string
+		callIDs []string
+	}{
+		{"scan", callIDs},
+		{"lookup", []string{target}},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				roomsMu.Lock()
+				rooms[target].monitors[monitor.id] = monitor
+				roomsMu.Unlock()
+				removeFromRooms(conn, bm.callIDs)
+			}
+			if len(rooms[target].monitors) != 0 {
+				b.Fatal("monitor still in the room")
+			}
+		})
+	}
+}
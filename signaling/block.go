@@ -0,0 +1,72 @@
+package signaling
+
+import (
+	"errors"
+	"log"
+)
+
+// maxBlockedUsers bounds how many clients one client can block
+const maxBlockedUsers = 1000
+
+// errBlocked is returned when a client tries to enter a room whose host it has blocked or been blocked by
+var errBlocked = errors.New("blocked")
+
+// hasBlocked reports whether the client has blocked the client with the given ID
+func (c *Client) hasBlocked(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.blockedUsers[id]
+}
+
+// blocks reports whether either of two connections' clients has blocked the other
+func blocks(a, b *wsConn) bool {
+	ca, okA := getClient(a)
+	cb, okB := getClient(b)
+	if !okA || !okB {
+		return false
+	}
+	return ca.hasBlocked(cb.id) || cb.hasBlocked(ca.id)
+}
+
+// handleBlockUser stops a client from receiving calls from, or being joined by, another client
+func handleBlockUser(sender *wsConn, msg Message) {
+	client, ok := getClient(sender)
+	if !ok {
+		return
+	}
+	if msg.ClientID == "" || len(msg.ClientID) > 64 || msg.ClientID == client.id {
+		sendError(sender, "invalid_client_id")
+		return
+	}
+	client.mu.Lock()
+	full := len(client.blockedUsers) >= maxBlockedUsers && !client.blockedUsers[msg.ClientID]
+	if !full {
+		client.blockedUsers[msg.ClientID] = true
+	}
+	client.mu.Unlock()
+	if full {
+		sendError(sender, "block_list_full")
+		return
+	}
+	log.Printf("Client %v blocked %s", sender.RemoteAddr(), msg.ClientID)
+}
+
+// handleUnblockUser removes a client from the sender's block list
+func handleUnblockUser(sender *wsConn, msg Message) {
+	client, ok := getClient(sender)
+	if !ok {
+		return
+	}
+	client.mu.Lock()
+	delete(client.blockedUsers, msg.ClientID)
+	client.mu.Unlock()
+}
+
+// sendJoinRejected tells a client that asked to join callID that it was kept out by a block
+func sendJoinRejected(ws *wsConn, callID string) {
+	log.Printf("Client %v could not join call %s: blocked", ws.RemoteAddr(), callID)
+	if err := ws.WriteJSON(Message{Type: "join_rejected", CallID: callID, Reason: errBlocked.Error()}); err != nil {
+		log.Printf("Error sending join_rejected to %v: %v", ws.RemoteAddr(), err)
+		go cleanupClient(ws)
+	}
+}
@@ -0,0 +1,62 @@
+package signaling
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBlockedClientKeptOutOfHostRoom(t *testing.T) {
+	ts := NewTestServer(t)
+	host := ts.ConnectWithClientID("block-host")
+	ts.Send(host, Message{Type: "block_user", ClientID: "block-pest"})
+	ts.Send(host, Message{Type: "offer", CallID: "block-room", Data: sdpData("offer")})
+	ts.waitForRoom("block-room", 1)
+	pest := ts.ConnectWithClientID("block-pest")
+
+	for _, msgType := range []string{"join_call", "accept_call"} {
+		ts.Send(pest, Message{Type: msgType, CallID: "block-room"})
+		if msg := ts.AssertMessageReceived(pest, "join_rejected", testTimeout); msg.Reason != "blocked" {
+			t.Fatalf("%s rejected with %q, want blocked", msgType, msg.Reason)
+		}
+	}
+	ts.Send(pest, Message{Type: "answer", CallID: "block-room", Data: sdpData("answer")})
+	ts.AssertError(pest, "blocked")
+	ts.Send(pest, Message{Type: "offer", CallID: "block-room", Data: sdpData("offer")})
+	ts.AssertError(pest, "blocked")
+	ts.RequireNoMessageOfType(host, "peer_joined", 100*time.Millisecond)
+	ts.RequireNoMessageOfType(host, "answer", 50*time.Millisecond)
+
+	friend := ts.Connect()
+	ts.Send(friend, Message{Type: "join_call", CallID: "block-room"})
+	ts.AssertMessageReceived(friend, "call_joined", testTimeout)
+}
+
+func TestBlockingHostKeepsClientOut(t *testing.T) {
+	ts := NewTestServer(t)
+	host := ts.ConnectWithClientID("block-host-2")
+	ts.Send(host, Message{Type: "offer", CallID: "block-room-2", Data: sdpData("offer")})
+	ts.waitForRoom("block-room-2", 1)
+	joiner := ts.Connect()
+	ts.Send(joiner, Message{Type: "block_user", ClientID: "block-host-2"})
+	ts.Send(joiner, Message{Type: "join_call", CallID: "block-room-2"})
+	ts.AssertMessageReceived(joiner, "join_rejected", testTimeout)
+}
+
+func TestBlockedClientNotRung(t *testing.T) {
+	ts := NewTestServer(t)
+	callee := ts.ConnectWithClientID("block-callee")
+	ts.Send(callee, Message{Type: "block_user", ClientID: "block-caller"})
+	caller := ts.ConnectWithClientID("block-caller")
+	ts.Send(caller, Message{Type: "incoming_call", CallID: "block-ring"})
+	ts.waitForRoom("block-ring", 1)
+	ts.RequireNoMessageOfType(callee, "incoming_call", 100*time.Millisecond)
+}
+
+func TestBlockUserValidation(t *testing.T) {
+	ts := NewTestServer(t)
+	conn := ts.ConnectWithClientID("block-self")
+	ts.Send(conn, Message{Type: "block_user", ClientID: "block-self"})
+	ts.AssertError(conn, "invalid_client_id")
+	ts.Send(conn, Message{Type: "block_user"})
+	ts.AssertError(conn, "invalid_client_id")
+}
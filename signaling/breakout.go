@@ -0,0 +1,118 @@
+package signaling
+
+import (
+	"log"
+	"strconv"
+)
+
+// maxBreakoutRooms bounds how many breakout rooms a host can open at once
+const maxBreakoutRooms = 20
+
+// awaitingBreakout reports whether the room is a breakout whose parent is still open, so it is kept while empty; callers hold roomsMu
+func (r *Room) awaitingBreakout() bool {
+	if r.parentCallID == "" {
+		return false
+	}
+	_, open := rooms[r.parentCallID]
+	return open
+}
+
+// handleCreateBreakoutRooms opens count sub-rooms <parentCallId>-1..n with the parent's settings
+func handleCreateBreakoutRooms(sender *wsConn, msg Message) {
+	parentID := msg.ParentCallID
+	if msg.Count < 1 || msg.Count > maxBreakoutRooms {
+		sendError(sender, "invalid_breakout_count")
+		return
+	}
+
+	roomsMu.Lock()
+	parent, ok := rooms[parentID]
+	if !ok {
+		roomsMu.Unlock()
+		sendError(sender, "Call not found")
+		return
+	}
+	if parent.host != sender {
+		roomsMu.Unlock()
+		sendError(sender, "not_host")
+		return
+	}
+	callIDs := make([]string, msg.Count)
+	for i := range callIDs {
+		callIDs[i] = parentID + "-" + strconv.Itoa(i+1)
+		if existing, taken := rooms[callIDs[i]]; taken && existing.parentCallID != parentID {
+			roomsMu.Unlock()
+			sendError(sender, "breakout_unavailable")
+			return
+		}
+	}
+	for _, callID := range callIDs {
+		if _, taken := rooms[callID]; taken {
+			continue
+		}
+		if hooks := eventHooks.Load(); hooks != nil && hooks.OnRoomCreated != nil {
+			if err := hooks.OnRoomCreated(callID); err != nil {
+				roomsMu.Unlock()
+				log.Printf("Hook rejected breakout room %s: %v", callID, err)
+				sendError(sender, err.Error())
+				return
+			}
+		}
+		room := newRoom()
+		room.options = parent.options
+		room.options.Lobby, room.options.LobbyMedia = false, nil // assignees were already admitted to the parent
+		room.parentCallID = parentID
+		rooms[callID] = room
+	}
+	roomsMu.Unlock()
+
+	log.Printf("Host %v opened %d breakout rooms for %s", sender.RemoteAddr(), len(callIDs), parentID)
+	if err := sender.WriteJSON(Message{
+		Type:    "breakout_rooms_created",
+		CallID:  parentID,
+		CallIDs: callIDs,
+	}); err != nil {
+		log.Printf("Error sending breakout_rooms_created to %v: %v", sender.RemoteAddr(), err)
+		go cleanupClient(sender)
+	}
+}
+
+// handleAssignToBreakout moves a member of the parent room into one of its breakout rooms
+func handleAssignToBreakout(sender *wsConn, msg Message) {
+	var parentID string
+	roomsMu.RLock()
+	if breakout, ok := rooms[msg.BreakoutCallID]; ok {
+		parentID = breakout.parentCallID
+	}
+	roomsMu.RUnlock()
+	if parentID == "" {
+		sendError(sender, "invalid_breakout_room")
+		return
+	}
+
+	room, unlock := rlockRoom(parentID)
+	if room == nil {
+		sendError(sender, "invalid_breakout_room")
+		return
+	}
+	isHost := room.host == sender
+	isMember := room.hasMember(msg.TargetClientID)
+	unlock()
+	if !isHost {
+		sendError(sender, "not_host")
+		return
+	}
+	target := findClient(msg.TargetClientID)
+	if !isMember || target == nil {
+		sendError(sender, "invalid_client_id")
+		return
+	}
+
+	if err := target.conn.WriteJSON(Message{Type: "breakout_assigned", CallID: msg.BreakoutCallID}); err != nil {
+		log.Printf("Error sending breakout_assigned to %v: %v", target.conn.RemoteAddr(), err)
+		go cleanupClient(target.conn)
+		return
+	}
+	handleHangup(target.conn, parentID)
+	log.Printf("Host %v moved %s from %s to breakout %s", sender.RemoteAddr(), target.id, parentID, msg.BreakoutCallID)
+}
@@ -0,0 +1,86 @@
+package signaling
+
+import (
+	"log"
+	"sort"
+)
+
+// featureMessages maps the message types, sent or received, that belong to an optional feature to that feature's capability name
+var featureMessages = map[string]string{
+	"e2ee_key":                  "e2ee",
+	"create_poll":               "poll",
+	"vote":                      "poll",
+	"close_poll":                "poll",
+	"poll":                      "poll",
+	"poll_update":               "poll",
+	"poll_closed":               "poll",
+	"reaction":                  "reactions",
+	"set_noise_cancellation":    "noise_cancellation",
+	"noise_cancellation_failed": "noise_cancellation",
+	"caption":                   "captions",
+	"message_lobby":             "lobby",
+	"lobby_message_received":    "lobby",
+	"message_from_host":         "lobby",
+}
+
+// serverCapabilities lists the optional features this server supports, sorted
+var serverCapabilities = func() []string {
+	seen := make(map[string]bool)
+	var supported []string
+	for _, feature := range featureMessages {
+		if !seen[feature] {
+			seen[feature] = true
+			supported = append(supported, feature)
+		}
+	}
+	sort.Strings(supported)
+	return supported
+}()
+
+// supports reports whether the client declared the feature; clients that never sent capabilities get everything
+func (c *Client) supports(feature string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Capabilities == nil || c.Capabilities[feature]
+}
+
+// accepts reports whether a message of type msgType may be sent to the connection's client
+func (c *wsConn) accepts(msgType string) bool {
+	feature, ok := featureMessages[msgType]
+	if !ok {
+		return true
+	}
+	client, ok := getClient(c)
+	return !ok || client.supports(feature)
+}
+
+// sendCapabilities tells the client which optional features the server supports and has enabled
+func sendCapabilities(ws *wsConn) {
+	if err := ws.WriteJSON(Message{Type: "capabilities", Supported: enabledCapabilities()}); err != nil {
+		log.Printf("Error sending capabilities to %v: %v", ws.RemoteAddr(), err)
+		go cleanupClient(ws)
+	}
+}
+
+// handleCapabilities records the optional features a client supports and answers with the server's own;
+// features the server does not know are ignored
+func handleCapabilities(sender *wsConn, msg Message) {
+	client, ok := getClient(sender)
+	if !ok {
+		return
+	}
+	capabilities := make(map[string]bool)
+	for _, feature := range serverCapabilities {
+		capabilities[feature] = false
+	}
+	for _, feature := range msg.Supported {
+		if _, known := capabilities[feature]; known {
+			capabilities[feature] = true
+		}
+	}
+	client.mu.Lock()
+	client.Capabilities = capabilities
+	client.mu.Unlock()
+	log.Printf("Client %v declared capabilities %v", sender.RemoteAddr(), msg.Supported)
+	sendCapabilities(sender)
+}
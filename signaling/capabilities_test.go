@@ -0,0 +1,77 @@
+package signaling
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+)
+
+func TestCapabilitiesExchanged(t *testing.T) {
+	ts := NewTestServer(t)
+	// Connect waits for the greeting itself, so dial without it to see what the server sends first
+	conn, _, err := websocket.DefaultDialer.Dial(ts.wsURL(url.Values{"token": {ts.Token("caps-client", jwt.MapClaims{"rooms": "*"})}}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.track(conn)
+	want := strings.Join(serverCapabilities, ",")
+	if msg := ts.AssertMessageReceived(conn, "capabilities", testTimeout); strings.Join(msg.Supported, ",") != want {
+		t.Fatalf("greeting capabilities %v, want %s", msg.Supported, want)
+	}
+
+	ts.Send(conn, Message{Type: "capabilities", Supported: []string{"reactions", "teleportation"}})
+	if msg := ts.AssertMessageReceived(conn, "capabilities", testTimeout); strings.Join(msg.Supported, ",") != want {
+		t.Fatalf("capabilities reply %v, want %s", msg.Supported, want)
+	}
+	client := findClient("caps-client")
+	client.mu.Lock()
+	declared := client.Capabilities
+	client.mu.Unlock()
+	if !declared["reactions"] || declared["poll"] || declared["teleportation"] {
+		t.Fatalf("stored capabilities %v", declared)
+	}
+}
+
+func TestCapabilityGatedRouting(t *testing.T) {
+	ts := NewTestServer(t)
+	sender, reactor, legacy := ts.Connect(), ts.ConnectWithClientID("caps-reactor"), ts.Connect()
+	ts.startCall(sender, reactor, "caps-call")
+	ts.Send(legacy, Message{Type: "join_call", CallID: "caps-call"})
+	ts.AssertMessageReceived(legacy, "call_joined", testTimeout)
+	ts.Send(reactor, Message{Type: "capabilities", Supported: []string{"reactions"}})
+	ts.AssertMessageReceived(reactor, "capabilities", testTimeout)
+
+	ts.Send(sender, Message{Type: "reaction", CallID: "caps-call", Emoji: "🎉"})
+	ts.AssertMessageReceived(reactor, "reaction", testTimeout)
+	ts.AssertMessageReceived(legacy, "reaction", testTimeout)
+
+	// the reactor left noise_cancellation out, while a client that never declared anything gets every feature
+	enabled := true
+	ts.Send(sender, Message{Type: "set_noise_cancellation", CallID: "caps-call", Enabled: &enabled})
+	ts.AssertMessageReceived(legacy, "set_noise_cancellation", testTimeout)
+	ts.RequireNoMessageOfType(reactor, "set_noise_cancellation", 100*time.Millisecond)
+
+	// messages outside any feature still reach everyone
+	ts.Send(sender, Message{Type: "relay", CallID: "caps-call", Data: "plain"})
+	ts.AssertMessageReceived(reactor, "relay", testTimeout)
+}
+
+func TestLobbyNotesNeedLobbyCapability(t *testing.T) {
+	ts := NewTestServer(t)
+	host, aware, unaware := ts.Connect(), ts.Connect(), ts.Connect()
+	ts.openLobbyRoom(host, "caps-lobby")
+	ts.Send(aware, Message{Type: "capabilities", Supported: []string{"lobby"}})
+	ts.AssertMessageReceived(aware, "capabilities", testTimeout)
+	ts.Send(unaware, Message{Type: "capabilities", Supported: []string{"reactions"}})
+	ts.AssertMessageReceived(unaware, "capabilities", testTimeout)
+	ts.enterLobby(aware, "caps-lobby")
+	ts.enterLobby(unaware, "caps-lobby")
+
+	ts.Send(host, Message{Type: "message_lobby", CallID: "caps-lobby", Data: "Starting shortly"})
+	ts.AssertMessageReceived(aware, "message_from_host", testTimeout)
+	ts.RequireNoMessageOfType(unaware, "message_from_host", 100*time.Millisecond)
+}
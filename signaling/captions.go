@@ -0,0 +1,116 @@
+package signaling
+
+import (
+	"log"
+	"time"
+	"unicode/utf8"
+)
+
+// maxCaptionLanguages bounds how many languages one caption subscription can list
+const maxCaptionLanguages = 16
+
+// validLang reports whether lang looks like a BCP 47 tag such as "en-US"
+func validLang(lang string) bool {
+	if lang == "" || len(lang) > 35 {
+		return false
+	}
+	for _, r := range lang {
+		if !(r == '-' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// wantsCaption reports whether the client has subscribed to captions in lang
+func (c *Client) wantsCaption(lang string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.captionFilter == nil || c.captionFilter[lang]
+}
+
+// handleCaption relays a speech-to-text caption to the room members subscribed to its language
+func handleCaption(sender *wsConn, msg Message) {
+	if msg.Text == "" || utf8.RuneCountInString(msg.Text) > 500 || !validLang(msg.Lang) || len(msg.SpeakerClientID) > 64 {
+		sendError(sender, "invalid_caption")
+		return
+	}
+	if !allowMessage(sender, "caption", 10, time.Second) {
+		return
+	}
+	client, ok := getClient(sender)
+	if !ok {
+		return
+	}
+	client.mu.Lock()
+	client.captionLanguage = msg.Lang
+	client.mu.Unlock()
+
+	speaker := msg.SpeakerClientID
+	if speaker == "" {
+		speaker = client.id
+	}
+
+	room, unlock := lockRoom(msg.CallID)
+	if room == nil || !room.clients[sender] {
+		if room != nil {
+			unlock()
+		}
+		log.Printf("Dropped caption for call %s from %v: not in room", msg.CallID, sender.RemoteAddr())
+		return
+	}
+	out := room.stamp(Message{
+		Type:            "caption",
+		CallID:          msg.CallID,
+		From:            client.id,
+		Lang:            msg.Lang,
+		Text:            msg.Text,
+		IsFinal:         msg.IsFinal,
+		SpeakerClientID: speaker,
+	})
+	members := make([]*wsConn, 0, len(room.clients))
+	for conn := range room.clients {
+		if conn != sender {
+			members = append(members, conn)
+		}
+	}
+	unlock()
+
+	for _, conn := range members {
+		if member, ok := getClient(conn); ok && !member.wantsCaption(msg.Lang) {
+			continue
+		}
+		if err := conn.WriteJSON(out); err != nil {
+			log.Printf("Error relaying caption to %v: %v", conn.RemoteAddr(), err)
+			go cleanupClient(conn)
+		}
+	}
+	copyToMonitors(out)
+}
+
+// handleCaptionSubscription limits the captions a client receives to the given languages; an empty list means all
+func handleCaptionSubscription(sender *wsConn, msg Message) {
+	if len(msg.Languages) > maxCaptionLanguages {
+		sendError(sender, "invalid_caption_subscription")
+		return
+	}
+	var subscription map[string]bool
+	if len(msg.Languages) > 0 {
+		subscription = make(map[string]bool, len(msg.Languages))
+		for _, lang := range msg.Languages {
+			if !validLang(lang) {
+				sendError(sender, "invalid_caption_subscription")
+				return
+			}
+			subscription[lang] = true
+		}
+	}
+	client, ok := getClient(sender)
+	if !ok {
+		return
+	}
+	client.mu.Lock()
+	client.captionFilter = subscription
+	client.mu.Unlock()
+	log.Printf("Client %v subscribed to captions in %v", sender.RemoteAddr(), msg.Languages)
+}
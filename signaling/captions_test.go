@@ -0,0 +1,48 @@
+package signaling
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCaptionsFollowSubscription(t *testing.T) {
+	ts := NewTestServer(t)
+	speaker, listener := ts.Connect(), ts.ConnectWithClientID("captions-listener")
+	ts.startCall(speaker, listener, "captions-call")
+	ts.Send(listener, Message{Type: "caption_subscription", Languages: []string{"de-DE"}})
+	for deadline := time.Now().Add(testTimeout); findClient("captions-listener").wantsCaption("en-US"); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("caption_subscription never applied")
+		}
+	}
+
+	ts.Send(speaker, Message{Type: "caption", CallID: "captions-call", Lang: "en-US", Text: "hello", IsFinal: true})
+	ts.Send(speaker, Message{Type: "caption", CallID: "captions-call", Lang: "de-DE", Text: "hallo", IsFinal: true})
+	msg := ts.AssertMessageReceived(listener, "caption", testTimeout)
+	if msg.Lang != "de-DE" || msg.Text != "hallo" || !msg.IsFinal {
+		t.Fatalf("subscriber got caption %+v", msg)
+	}
+	ts.RequireNoMessageOfType(listener, "caption", 50*time.Millisecond)
+
+	// retransmission keeps to the subscription too
+	ts.Send(listener, Message{Type: "retransmit_request", CallID: "captions-call"})
+	if msg := ts.AssertMessageReceived(listener, "caption", testTimeout); msg.Lang != "de-DE" {
+		t.Fatalf("retransmitted caption in %s", msg.Lang)
+	}
+	ts.RequireNoMessageOfType(listener, "caption", 50*time.Millisecond)
+}
+
+func TestCaptionRules(t *testing.T) {
+	ts := NewTestServer(t)
+	conn := ts.Connect()
+	for _, msg := range []Message{
+		{Type: "caption", CallID: "captions-rules", Lang: "en-US"},
+		{Type: "caption", CallID: "captions-rules", Lang: "not a tag", Text: "hello"},
+		{Type: "caption", CallID: "captions-rules", Text: "hello"},
+	} {
+		ts.Send(conn, msg)
+		ts.AssertError(conn, "invalid_caption")
+	}
+	ts.Send(conn, Message{Type: "caption_subscription", Languages: []string{"en US"}})
+	ts.AssertError(conn, "invalid_caption_subscription")
+}
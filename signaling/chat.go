@@ -0,0 +1,199 @@
+package signaling
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+	"unicode/utf8"
+
+	_ "modernc.org/sqlite"
+)
+
+// Chat history configuration, history is only kept when SQLITE_PATH is set
+var (
+	sqlitePath       = envString("SQLITE_PATH", "")
+	chatHistoryLimit = envInt("CHAT_HISTORY_LIMIT", 50)
+	chatStore        *sql.DB
+)
+
+// ChatMessage is a stored chat relay message
+type ChatMessage struct {
+	ID        int64     `json:"id"`
+	CallID    string    `json:"callId"`
+	ClientID  string    `json:"clientId"`
+	Timestamp time.Time `json:"timestamp"`
+	Payload   string    `json:"payload"`
+}
+
+// migrations are applied in order, the schema version is the number applied
+var migrations = []string{
+	`CREATE TABLE chat_messages (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		call_id TEXT NOT NULL,
+		client_id TEXT NOT NULL,
+		timestamp INTEGER NOT NULL,
+		payload TEXT NOT NULL
+	)`,
+	`CREATE INDEX chat_messages_call_id ON chat_messages (call_id, id)`,
+	`ALTER TABLE chat_messages ADD COLUMN expires_at INTEGER`,
+	`CREATE INDEX chat_messages_expires_at ON chat_messages (expires_at)`,
+}
+
+// openChatStore opens the SQLite database at path and brings its schema up to date
+func openChatStore(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// migrate applies the migrations the database has not seen yet
+func migrate(db *sql.DB) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_version (version INTEGER NOT NULL)`); err != nil {
+		return err
+	}
+	var version int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&version); err != nil {
+		return err
+	}
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_version (version) VALUES (?)`, i+1); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		log.Printf("Applied chat store migration %d", i+1)
+	}
+	return nil
+}
+
+// saveChatMessage stores a relayed chat message when the room's retention policy persists it
+func saveChatMessage(callID, clientID, payload, policy string) {
+	if !persistsChat(policy) {
+		return
+	}
+	var expiresAt any
+	if expiry := chatExpiry(policy); !expiry.IsZero() {
+		expiresAt = expiry.UnixMilli()
+	}
+	if _, err := chatStore.Exec(
+		`INSERT INTO chat_messages (call_id, client_id, timestamp, payload, expires_at) VALUES (?, ?, ?, ?, ?)`,
+		callID, clientID, time.Now().UnixMilli(), payload, expiresAt,
+	); err != nil {
+		log.Printf("Error storing chat message for call %s: %v", callID, err)
+	}
+}
+
+// chatHistory returns the last limit unexpired chat messages of a call, oldest first
+func chatHistory(callID string, limit int) ([]ChatMessage, error) {
+	rows, err := chatStore.Query(
+		`SELECT id, call_id, client_id, timestamp, payload FROM (
+			SELECT * FROM chat_messages WHERE call_id = ? AND (expires_at IS NULL OR expires_at > ?) ORDER BY id DESC LIMIT ?
+		) ORDER BY id`,
+		callID, time.Now().UnixMilli(), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []ChatMessage
+	for rows.Next() {
+		var m ChatMessage
+		var ts int64
+		if err := rows.Scan(&m.ID, &m.CallID, &m.ClientID, &ts, &m.Payload); err != nil {
+			return nil, err
+		}
+		m.Timestamp = time.UnixMilli(ts).UTC()
+		history = append(history, m)
+	}
+	return history, rows.Err()
+}
+
+// sendChatHistory sends a joiner the recent chat of the call they joined, from wherever the room's retention policy keeps it
+func sendChatHistory(conn *wsConn, callID string) {
+	policy, ok := roomRetention(callID)
+	if !ok || policy == retentionNone {
+		return
+	}
+	var history []ChatMessage
+	if persistsChat(policy) {
+		var err error
+		if history, err = chatHistory(callID, chatHistoryLimit); err != nil {
+			log.Printf("Error loading chat history for call %s: %v", callID, err)
+			return
+		}
+	} else if policy != "" {
+		room, unlock := rlockRoom(callID)
+		if room == nil {
+			return
+		}
+		history = room.sessionChatHistory(callID, chatHistoryLimit)
+		unlock()
+	}
+	if len(history) == 0 {
+		return
+	}
+	if err := conn.WriteJSON(Message{Type: "chat_history", CallID: callID, Messages: history}); err != nil {
+		log.Printf("Error sending chat_history to %v: %v", conn.RemoteAddr(), err)
+		go cleanupClient(conn)
+	}
+}
+
+// handleRelay relays a chat message to the room and stores it
+func handleRelay(sender *wsConn, msg Message) {
+	if msg.Data == "" || utf8.RuneCountInString(msg.Data) > 2000 {
+		sendError(sender, "invalid_relay")
+		return
+	}
+	if !allowMessage(sender, "relay", 5, time.Second) {
+		return
+	}
+	id := clientID(sender)
+	policy, _ := roomRetention(msg.CallID)
+	relay := Message{
+		Type: "relay", CallID: msg.CallID, From: id, Data: msg.Data, AckRequested: msg.AckRequested,
+		ServerTimestamp: time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	if relayToRoom(sender, relay) {
+		saveChatMessage(msg.CallID, id, msg.Data, policy)
+	}
+}
+
+// typingInterval is how long a typing indicator lasts before the same client's next one is relayed
+const typingInterval = 3 * time.Second
+
+// handleTyping tells the room a client is composing a chat message, at most once per typingInterval
+func handleTyping(sender *wsConn, msg Message) {
+	client, ok := getClient(sender)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	client.mu.Lock()
+	if now.Sub(client.lastTypingAt) < typingInterval {
+		client.mu.Unlock()
+		return
+	}
+	client.lastTypingAt = now
+	client.mu.Unlock()
+
+	relayToRoom(sender, Message{Type: "typing", CallID: msg.CallID, ClientID: client.id})
+}
@@ -0,0 +1,152 @@
+package signaling
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// setChatStore opens a fresh SQLite chat store for the rest of the test and returns its path
+func setChatStore(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "chat.db")
+	db, err := openChatStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := chatStore
+	chatStore = db
+	t.Cleanup(func() {
+		chatStore = previous
+		db.Close()
+	})
+	return path
+}
+
+// schemaVersion returns the highest migration applied to db
+func schemaVersion(t *testing.T, db *sql.DB) int {
+	t.Helper()
+	var version int
+	if err := db.QueryRow(`SELECT MAX(version) FROM schema_version`).Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	return version
+}
+
+func TestChatStoreMigrations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chat.db")
+
+	// a database left at the first two migrations by an older server
+	old, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := migrations
+	migrations = migrations[:2]
+	err = migrate(old)
+	migrations = saved
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := old.Exec(`INSERT INTO chat_messages (call_id, client_id, timestamp, payload) VALUES ('migrated-call', 'old', 1, 'kept')`); err != nil {
+		t.Fatal(err)
+	}
+	old.Close()
+
+	for i := 0; i < 2; i++ {
+		db, err := openChatStore(path)
+		if err != nil {
+			t.Fatalf("opening the store, pass %d: %v", i+1, err)
+		}
+		if version := schemaVersion(t, db); version != len(migrations) {
+			t.Fatalf("schema version %d, want %d", version, len(migrations))
+		}
+		var expiring sql.NullInt64
+		if err := db.QueryRow(`SELECT expires_at FROM chat_messages WHERE call_id = 'migrated-call'`).Scan(&expiring); err != nil || expiring.Valid {
+			t.Fatalf("migrated message expires_at %v, %v", expiring, err)
+		}
+		db.Close()
+	}
+}
+
+func TestChatHistorySurvivesReconnect(t *testing.T) {
+	setChatStore(t)
+	previousLimit := chatHistoryLimit
+	t.Cleanup(func() { chatHistoryLimit = previousLimit })
+	ts := NewTestServer(t)
+	caller, callee := ts.ConnectWithClientID("history-caller"), ts.ConnectWithClientID("history-callee")
+	ts.startCall(caller, callee, "history-call")
+	for i := 1; i <= 3; i++ {
+		ts.Send(caller, Message{Type: "relay", CallID: "history-call", Data: fmt.Sprintf("chat %d", i)})
+		ts.AssertMessageReceived(callee, "relay", testTimeout)
+	}
+
+	callee.Close()
+	for deadline := time.Now().Add(testTimeout); ; time.Sleep(5 * time.Millisecond) {
+		room, unlock := rlockRoom("history-call")
+		left := len(room.clients) == 1
+		unlock()
+		if left {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("callee never left the room")
+		}
+	}
+
+	chatHistoryLimit = 2
+	rejoined := ts.ConnectWithClientID("history-callee")
+	ts.Send(rejoined, Message{Type: "join_call", CallID: "history-call"})
+	msg := ts.AssertMessageReceived(rejoined, "chat_history", testTimeout)
+	if len(msg.Messages) != 2 {
+		t.Fatalf("chat_history has %d messages, want the last 2", len(msg.Messages))
+	}
+	for i, m := range msg.Messages {
+		if want := fmt.Sprintf("chat %d", i+2); m.Payload != want || m.ClientID != "history-caller" || m.CallID != "history-call" || m.Timestamp.IsZero() {
+			t.Fatalf("history message %d is %+v, want %q from history-caller", i, m, want)
+		}
+	}
+}
+
+func TestChatHistoryKeptAcrossStoreReopen(t *testing.T) {
+	path := setChatStore(t)
+	saveChatMessage("reopen-call", "reopen-client", "before restart", "")
+	chatStore.Close()
+	db, err := openChatStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chatStore = db
+	t.Cleanup(func() { db.Close() })
+
+	history, err := chatHistory("reopen-call", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].Payload != "before restart" || history[0].ClientID != "reopen-client" {
+		t.Fatalf("history after reopening %+v", history)
+	}
+}
+
+func TestTypingDeduplicated(t *testing.T) {
+	ts := NewTestServer(t)
+	caller, callee := ts.ConnectWithClientID("typing-caller"), ts.Connect()
+	ts.startCall(caller, callee, "typing-call")
+
+	ts.Send(caller, Message{Type: "typing", CallID: "typing-call"})
+	if msg := ts.AssertMessageReceived(callee, "typing", testTimeout); msg.ClientID != "typing-caller" || msg.Data != "" {
+		t.Fatalf("typing %+v", msg)
+	}
+	ts.Send(caller, Message{Type: "typing", CallID: "typing-call"})
+	ts.RequireNoMessageOfType(callee, "typing", 100*time.Millisecond)
+	ts.RequireNoMessageOfType(caller, "typing", 50*time.Millisecond)
+
+	// once typingInterval has passed since the last relayed indicator the next one goes out again
+	client := findClient("typing-caller")
+	client.mu.Lock()
+	client.lastTypingAt = time.Now().Add(-typingInterval)
+	client.mu.Unlock()
+	ts.Send(caller, Message{Type: "typing", CallID: "typing-call"})
+	ts.AssertMessageReceived(callee, "typing", testTimeout)
+}
@@ -0,0 +1,69 @@
+package signaling
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Cleanup loop configuration
+var (
+	cleanupBaseInterval  = 30 * time.Second
+	cleanupLoadThreshold = envInt("CLEANUP_LOAD_THRESHOLD", 1000)
+	cleanupSlow          = time.Duration(envInt("CLEANUP_SLOW_MS", 1000)) * time.Millisecond
+	cleanupMaxInterval   = time.Duration(envInt("CLEANUP_MAX_INTERVAL_SECONDS", 240)) * time.Second
+
+	// roomInactiveTTL evicts rooms that have relayed nothing, and whose members have been silent, for this long; 0 disables it
+	roomInactiveTTL = time.Duration(envInt("ROOM_INACTIVE_TTL", 300)) * time.Second
+	// maxRoomAge evicts rooms this long after creation however busy they are; 0 disables it
+	maxRoomAge = time.Duration(envInt("MAX_ROOM_AGE", 0)) * time.Second
+
+	// currentCleanupInterval is the sleep between cleanup passes, stretched under load
+	currentCleanupInterval atomic.Int64
+)
+
+func init() {
+	currentCleanupInterval.Store(int64(cleanupBaseInterval))
+}
+
+// timeoutReason says why cleanup should evict the room at now, or "" to keep it; callers hold roomsMu
+func (r *Room) timeoutReason(now time.Time) string {
+	switch {
+	case maxRoomAge > 0 && now.Sub(r.createdAt) > maxRoomAge:
+		return "max_age"
+	case roomInactiveTTL > 0 && now.Sub(r.LastActivity) > roomInactiveTTL && !r.membersResponsive(now):
+		return "inactive"
+	}
+	return ""
+}
+
+// membersResponsive reports whether any member has sent a message or answered a ping within roomInactiveTTL;
+// an established call relays nothing through the server, so its members' pongs are what keep it alive
+func (r *Room) membersResponsive(now time.Time) bool {
+	for conn := range r.clients {
+		if client, ok := getClient(conn); ok && now.Sub(client.lastSeen()) <= roomInactiveTTL {
+			return true
+		}
+	}
+	return false
+}
+
+// nextCleanupInterval doubles the interval after a heavy, slow pass and resets it once load drops
+func nextCleanupInterval(current time.Duration, pings int, took time.Duration) time.Duration {
+	if pings > cleanupLoadThreshold && took > cleanupSlow {
+		next := current * 2
+		if next > cleanupMaxInterval {
+			next = cleanupMaxInterval
+		}
+		return next
+	}
+	return cleanupBaseInterval
+}
+
+// readTimeout is how long a connection may stay silent, long enough to span two ping intervals
+func readTimeout() time.Duration {
+	timeout := 2 * time.Duration(currentCleanupInterval.Load())
+	if timeout < 60*time.Second {
+		timeout = 60 * time.Second
+	}
+	return timeout
+}
@@ -0,0 +1,107 @@
+package signaling
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEstablishedCallNotEvictedAsInactive(t *testing.T) {
+	ts := NewTestServer(t)
+	caller, callee := ts.ConnectWithClientID("inactive-caller"), ts.ConnectWithClientID("inactive-callee")
+	ts.startCall(caller, callee, "inactive-call")
+	now := time.Now()
+	quiet := now.Add(-2 * roomInactiveTTL)
+
+	room, unlock := lockRoom("inactive-call")
+	room.LastActivity = quiet
+	unlock()
+	for _, id := range []string{"inactive-caller", "inactive-callee"} {
+		client := findClient(id)
+		client.mu.Lock()
+		client.lastMessageAt = quiet
+		client.mu.Unlock()
+	}
+
+	// the callee still answers pings, so the call is live
+	responsive := findClient("inactive-callee")
+	responsive.recordPing(now.Add(-time.Second))
+	responsive.recordPong()
+	roomsMu.Lock()
+	reason := rooms["inactive-call"].timeoutReason(now)
+	roomsMu.Unlock()
+	if reason != "" {
+		t.Fatalf("responsive call timed out: %s", reason)
+	}
+
+	responsive.mu.Lock()
+	responsive.lastPongAt = quiet
+	responsive.mu.Unlock()
+	roomsMu.Lock()
+	reason = rooms["inactive-call"].timeoutReason(now)
+	roomsMu.Unlock()
+	if reason != "inactive" {
+		t.Fatalf("silent room got %q, want inactive", reason)
+	}
+}
+
+func TestMaxRoomAgeEvictsBusyRoom(t *testing.T) {
+	ts := NewTestServer(t)
+	previous := maxRoomAge
+	maxRoomAge = time.Minute
+	t.Cleanup(func() { maxRoomAge = previous })
+	caller := ts.Connect()
+	ts.Send(caller, Message{Type: "offer", CallID: "aged-room", Data: sdpData("offer")})
+	ts.waitForRoom("aged-room", 1)
+
+	roomsMu.Lock()
+	defer roomsMu.Unlock()
+	room := rooms["aged-room"]
+	if reason := room.timeoutReason(time.Now()); reason != "" {
+		t.Fatalf("new room timed out: %s", reason)
+	}
+	if reason := room.timeoutReason(room.createdAt.Add(2 * time.Minute)); reason != "max_age" {
+		t.Fatalf("old room got %q, want max_age", reason)
+	}
+}
+
+func TestNextCleanupInterval(t *testing.T) {
+	if got := nextCleanupInterval(cleanupBaseInterval, cleanupLoadThreshold+1, cleanupSlow+time.Millisecond); got != 2*cleanupBaseInterval {
+		t.Fatalf("slow heavy pass: %v, want %v", got, 2*cleanupBaseInterval)
+	}
+	if got := nextCleanupInterval(cleanupMaxInterval, cleanupLoadThreshold+1, cleanupSlow+time.Millisecond); got != cleanupMaxInterval {
+		t.Fatalf("capped interval: %v, want %v", got, cleanupMaxInterval)
+	}
+	if got := nextCleanupInterval(4*cleanupBaseInterval, 1, 0); got != cleanupBaseInterval {
+		t.Fatalf("light pass: %v, want %v", got, cleanupBaseInterval)
+	}
+}
+
+func TestCleanupBacksOffUnderLoad(t *testing.T) {
+	ts := NewTestServer(t)
+	previousThreshold, previousSlow := cleanupLoadThreshold, cleanupSlow
+	t.Cleanup(func() {
+		cleanupLoadThreshold, cleanupSlow = previousThreshold, previousSlow
+		currentCleanupInterval.Store(int64(cleanupBaseInterval))
+	})
+	// any pass pinging more than two clients counts as heavy and slow
+	cleanupLoadThreshold, cleanupSlow = 2, 0
+	for i := 0; i < 3; i++ {
+		ts.Connect()
+	}
+
+	for _, want := range []time.Duration{2 * cleanupBaseInterval, 4 * cleanupBaseInterval, cleanupMaxInterval, cleanupMaxInterval} {
+		cleanupPass()
+		if got := time.Duration(currentCleanupInterval.Load()); got != want {
+			t.Fatalf("interval after heavy pass %v, want %v", got, want)
+		}
+	}
+	if readTimeout() != 2*cleanupMaxInterval {
+		t.Fatalf("read timeout %v did not stretch with the interval", readTimeout())
+	}
+
+	cleanupLoadThreshold = 100
+	cleanupPass()
+	if got := time.Duration(currentCleanupInterval.Load()); got != cleanupBaseInterval {
+		t.Fatalf("interval after light pass %v, want %v", got, cleanupBaseInterval)
+	}
+}
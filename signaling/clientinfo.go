@@ -0,0 +1,80 @@
+package signaling
+
+import (
+	"log"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// validPlatforms are the client types client_info may report
+var validPlatforms = []string{"web", "ios", "android", "desktop", "bot"}
+
+// unknownPlatform labels clients that have not sent client_info
+const unknownPlatform = "unknown"
+
+// One videochat_clients gauge per platform, counted from the connected clients when scraped
+func init() {
+	for _, platform := range append(validPlatforms, unknownPlatform) {
+		platform := platform
+		promauto.NewGaugeFunc(prometheus.GaugeOpts{
+			Name:        "videochat_clients",
+			Help:        "Connected clients by platform reported in client_info.",
+			ConstLabels: prometheus.Labels{"platform": platform},
+		}, func() float64 {
+			return float64(platformCounts()[platform])
+		})
+	}
+}
+
+// validPlatform reports whether platform is one of validPlatforms
+func validPlatform(platform string) bool {
+	for _, p := range validPlatforms {
+		if p == platform {
+			return true
+		}
+	}
+	return false
+}
+
+// platformName returns the platform from the client's client_info, or unknownPlatform
+func (c *Client) platformName() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.platform == "" {
+		return unknownPlatform
+	}
+	return c.platform
+}
+
+// platformCounts counts the connected clients by platform
+func platformCounts() map[string]int {
+	counts := make(map[string]int)
+	clients.Range(func(_, v interface{}) bool {
+		counts[v.(*Client).platformName()]++
+		return true
+	})
+	return counts
+}
+
+// handleClientInfo records which kind of app a client is, for analytics
+func handleClientInfo(sender *wsConn, msg Message) {
+	if !validPlatform(msg.Platform) || len(msg.AppVersion) > 32 || len(msg.SDKVersion) > 32 {
+		sendError(sender, "invalid_client_info")
+		return
+	}
+	if !allowMessage(sender, "client_info", 5, time.Minute) {
+		return
+	}
+	client, ok := getClient(sender)
+	if !ok {
+		return
+	}
+	client.mu.Lock()
+	client.platform = msg.Platform
+	client.appVersion = msg.AppVersion
+	client.sdkVersion = msg.SDKVersion
+	client.mu.Unlock()
+	log.Printf("Client %v is %s app %q, SDK %q", sender.RemoteAddr(), msg.Platform, msg.AppVersion, msg.SDKVersion)
+}
@@ -0,0 +1,40 @@
+package signaling
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClientInfoTagsPlatform(t *testing.T) {
+	ts := NewTestServer(t)
+	before := ts.scrapeMetric(`videochat_clients{platform="ios"}`)
+	conn := ts.ConnectWithClientID("info-ios")
+	if platform := findClient("info-ios").platformName(); platform != unknownPlatform {
+		t.Fatalf("platform %q before client_info, want %q", platform, unknownPlatform)
+	}
+
+	ts.Send(conn, Message{Type: "client_info", Platform: "ios", AppVersion: "2.1.0", SDKVersion: "3.0.1"})
+	// client_info has no reply, so wait for one to a later message
+	ts.Send(conn, Message{Type: "echo", Seq: 1})
+	ts.AssertMessageReceived(conn, "echo_reply", testTimeout)
+	if platform := findClient("info-ios").platformName(); platform != "ios" {
+		t.Fatalf("platform %q after client_info, want ios", platform)
+	}
+	if after := ts.scrapeMetric(`videochat_clients{platform="ios"}`); after != before+1 {
+		t.Fatalf("videochat_clients for ios went from %v to %v", before, after)
+	}
+}
+
+func TestClientInfoRules(t *testing.T) {
+	ts := NewTestServer(t)
+	conn := ts.Connect()
+	for _, msg := range []Message{
+		{Type: "client_info"},
+		{Type: "client_info", Platform: "smartwatch"},
+		{Type: "client_info", Platform: "web", AppVersion: strings.Repeat("1", 33)},
+		{Type: "client_info", Platform: "web", SDKVersion: strings.Repeat("1", 33)},
+	} {
+		ts.Send(conn, msg)
+		ts.AssertError(conn, "invalid_client_info")
+	}
+}
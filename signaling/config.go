@@ -0,0 +1,74 @@
+package signaling
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// envString returns the environment variable name, or def when it is unset
+func envString(name, def string) string {
+	if v := strings.TrimSpace(os.Getenv(name)); v != "" {
+		return v
+	}
+	return def
+}
+
+// envInt returns the environment variable name parsed as an int, or def when it is unset or invalid
+func envInt(name string, def int) int {
+	v := strings.TrimSpace(os.Getenv(name))
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Printf("Invalid %s %q, using default %d", name, v, def)
+		return def
+	}
+	return n
+}
+
+// envFloat returns the environment variable name parsed as a float, or def when it is unset or invalid
+func envFloat(name string, def float64) float64 {
+	v := strings.TrimSpace(os.Getenv(name))
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Printf("Invalid %s %q, using default %v", name, v, def)
+		return def
+	}
+	return f
+}
+
+// envList returns the comma-separated environment variable name as a trimmed list
+func envList(name string) []string {
+	var list []string
+	for _, v := range strings.Split(os.Getenv(name), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// envHeaders returns the semicolon-separated "Key: value" pairs in environment variable name as headers
+func envHeaders(name string) http.Header {
+	headers := make(http.Header)
+	for _, pair := range strings.Split(os.Getenv(name), ";") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, ":")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			log.Printf("Ignoring invalid %s entry %q", name, pair)
+			continue
+		}
+		headers.Add(key, strings.TrimSpace(value))
+	}
+	return headers
+}
@@ -0,0 +1,55 @@
+package signaling
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+)
+
+func TestEnvHeaders(t *testing.T) {
+	t.Setenv("TEST_HEADERS", " Strict-Transport-Security: max-age=63072000 ;X-Frame-Options:DENY;; no-colon ; :no-key; X-Custom: a:b")
+	headers := envHeaders("TEST_HEADERS")
+	want := map[string]string{
+		"Strict-Transport-Security": "max-age=63072000",
+		"X-Frame-Options":           "DENY",
+		"X-Custom":                  "a:b",
+	}
+	if len(headers) != len(want) {
+		t.Fatalf("headers %v, want %v", headers, want)
+	}
+	for key, value := range want {
+		if got := headers.Get(key); got != value {
+			t.Errorf("%s: %q, want %q", key, got, value)
+		}
+	}
+}
+
+func TestUpgradeResponseHeaders(t *testing.T) {
+	t.Setenv("CUSTOM_RESPONSE_HEADERS", "Strict-Transport-Security: max-age=63072000; X-Frame-Options: DENY; X-Content-Type-Options: sniff")
+	previous := upgradeHeaders
+	upgradeHeaders = newUpgradeHeaders()
+	t.Cleanup(func() { upgradeHeaders = previous })
+	ts := NewTestServer(t)
+
+	query := url.Values{"token": {ts.Token("upgrade-headers", jwt.MapClaims{"rooms": "*"})}}
+	conn, resp, err := websocket.DefaultDialer.Dial(ts.wsURL(query), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.track(conn)
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("upgrade status %d", resp.StatusCode)
+	}
+	for key, value := range map[string]string{
+		"Strict-Transport-Security": "max-age=63072000",
+		"X-Frame-Options":           "DENY",
+		"X-Content-Type-Options":    "nosniff",
+	} {
+		if got := resp.Header.Values(key); len(got) != 1 || got[0] != value {
+			t.Errorf("101 response %s: %q, want %q", key, got, value)
+		}
+	}
+}
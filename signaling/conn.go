@@ -0,0 +1,335 @@
+package signaling
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// compressionThreshold is the smallest message size in bytes worth compressing
+var compressionThreshold = envInt("COMPRESSION_THRESHOLD_BYTES", 512)
+
+// batchWriteDelay is how long the writer waits for more messages to share a frame; 0 writes each message immediately
+var batchWriteDelay = time.Duration(envInt("BATCH_WRITE_DELAY_MS", 5)) * time.Millisecond
+
+// egressLimit is how many bytes a connection may be sent per second before ICE candidates to it are delayed; 0 means unlimited
+var egressLimit = int64(envInt("EGRESS_THROTTLE_BYTES_PER_SEC", 0))
+
+// egressRetryDelay is how often the writer checks whether held-back ICE candidates may be sent
+const egressRetryDelay = 100 * time.Millisecond
+
+// sendQueueSize is how many messages each priority lane holds before WriteJSON gives up on the client
+const sendQueueSize = 256
+
+var (
+	errSendQueueFull = errors.New("send queue full")
+	errConnClosed    = errors.New("connection closed")
+)
+
+// highPriorityTypes are the time-sensitive signaling messages written ahead of everything else
+var highPriorityTypes = map[string]bool{
+	"offer":         true,
+	"answer":        true,
+	"ice-candidate": true,
+}
+
+// wsConn wraps a WebSocket connection, queueing writes for a single writer goroutine and compressing only large messages;
+// long-polling clients get a wsConn whose poll transport stands in for Conn
+type wsConn struct {
+	*websocket.Conn
+	poll    *pollConn
+	writeMu sync.Mutex
+	queue   priorityQueue
+	done    chan struct{}
+	closed  sync.Once
+	key     *[32]byte // secretbox key when app-layer encryption is negotiated
+
+	batchDelay  time.Duration // batchWriteDelay when the connection opened
+	egressLimit int64         // egressLimit when the connection opened
+
+	forwardedAddr net.Addr // client address behind a trusted reverse proxy, reported by RemoteAddr
+
+	bytesSentThisSecond atomic.Int64 // reset every second by resetEgressWindows
+
+	throttledMu    sync.Mutex
+	throttled      [][]byte      // ICE candidates held back while over egressLimit, oldest first
+	throttledReady chan struct{} // wakes the writer when a candidate is held back
+
+	errMu    sync.Mutex
+	writeErr error // first error from the writer, returned by later WriteJSON calls
+}
+
+// priorityQueue holds encoded messages waiting for the writer, in a HIGH and a LOW lane
+type priorityQueue struct {
+	high chan []byte
+	low  chan []byte
+}
+
+// newWSConn wraps conn and starts its writer, encrypting messages under key unless it is nil
+func newWSConn(conn *websocket.Conn, key *[32]byte) *wsConn {
+	return newConn(conn, key, nil)
+}
+
+// newConn builds a wsConn over a WebSocket or a long-poll transport and starts its writer
+func newConn(conn *websocket.Conn, key *[32]byte, poll *pollConn) *wsConn {
+	c := &wsConn{
+		Conn: conn,
+		poll: poll,
+		key:  key,
+		queue: priorityQueue{
+			high: make(chan []byte, sendQueueSize),
+			low:  make(chan []byte, sendQueueSize),
+		},
+		done:           make(chan struct{}),
+		throttledReady: make(chan struct{}, 1),
+		batchDelay:     batchWriteDelay,
+		egressLimit:    egressLimit,
+	}
+	go c.writeLoop()
+	return c
+}
+
+// WriteJSON encodes v as JSON and queues it on the lane matching its priority; ICE candidates sent while the
+// connection is over egressLimit, or while earlier ones are still held back, wait in order for the writer.
+// Messages for a feature the client did not declare in its capabilities are dropped.
+func (c *wsConn) WriteJSON(v interface{}) error {
+	if msg, ok := v.(Message); ok && !c.accepts(msg.Type) {
+		return nil
+	}
+	select {
+	case <-c.done:
+		return errConnClosed
+	default:
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	c.errMu.Lock()
+	err = c.writeErr
+	c.errMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	lane := c.queue.low
+	if msg, ok := v.(Message); ok && highPriorityTypes[msg.Type] {
+		if msg.Type == "ice-candidate" {
+			if held, err := c.holdBack(data); held || err != nil {
+				return err
+			}
+		}
+		lane = c.queue.high
+	}
+	select {
+	case lane <- data:
+		return nil
+	default:
+		return errSendQueueFull
+	}
+}
+
+// holdBack queues an encoded ICE candidate for the writer if the connection is over egressLimit or already
+// holding candidates back, so candidates are never reordered; it reports false when the candidate can go out now
+func (c *wsConn) holdBack(data []byte) (bool, error) {
+	c.throttledMu.Lock()
+	defer c.throttledMu.Unlock()
+	if len(c.throttled) == 0 && !c.overEgressLimit() {
+		return false, nil
+	}
+	if len(c.throttled) >= sendQueueSize {
+		return true, errSendQueueFull
+	}
+	c.throttled = append(c.throttled, data)
+	select {
+	case c.throttledReady <- struct{}{}:
+	default:
+	}
+	return true, nil
+}
+
+// releaseThrottled takes the oldest held-back ICE candidate once the connection is under egressLimit again,
+// reporting whether any are still waiting
+func (c *wsConn) releaseThrottled() (data []byte, waiting bool) {
+	c.throttledMu.Lock()
+	defer c.throttledMu.Unlock()
+	if len(c.throttled) == 0 {
+		return nil, false
+	}
+	if c.overEgressLimit() {
+		return nil, true
+	}
+	data = c.throttled[0]
+	c.throttled[0] = nil
+	c.throttled = c.throttled[1:]
+	return data, true
+}
+
+// overEgressLimit reports whether the connection has been sent more than egressLimit bytes this second
+func (c *wsConn) overEgressLimit() bool {
+	return c.egressLimit > 0 && c.bytesSentThisSecond.Load() >= c.egressLimit
+}
+
+// resetEgressWindows starts a new egress accounting window for every connection each second
+func resetEgressWindows() {
+	for range time.Tick(time.Second) {
+		clients.Range(func(k, _ interface{}) bool {
+			k.(*wsConn).bytesSentThisSecond.Store(0)
+			return true
+		})
+	}
+}
+
+// next waits for a queued message, taking HIGH first, then ICE candidates held back until the connection is
+// under egressLimit again, then LOW
+func (c *wsConn) next(timeout <-chan time.Time) ([]byte, bool) {
+	for {
+		select {
+		case data := <-c.queue.high:
+			return data, true
+		default:
+		}
+		data, waiting := c.releaseThrottled()
+		if data != nil {
+			return data, true
+		}
+		var retry <-chan time.Time
+		if waiting {
+			retry = time.After(egressRetryDelay)
+		}
+		select {
+		case data := <-c.queue.high:
+			return data, true
+		case data := <-c.queue.low:
+			return data, true
+		case <-c.throttledReady:
+		case <-retry:
+		case <-timeout:
+			return nil, false
+		case <-c.done:
+			return nil, false
+		}
+	}
+}
+
+// writeLoop writes queued messages until the connection closes, batching those queued within the batch delay
+func (c *wsConn) writeLoop() {
+	for {
+		data, ok := c.next(nil)
+		if !ok {
+			return
+		}
+		batch := [][]byte{data}
+		if c.batchDelay > 0 {
+			timeout := time.After(c.batchDelay)
+			for {
+				data, ok := c.next(timeout)
+				if !ok {
+					break
+				}
+				batch = append(batch, data)
+			}
+		}
+
+		frame := batch[0]
+		if len(batch) > 1 {
+			frame = append(append([]byte{'['}, bytes.Join(batch, []byte{','})...), ']')
+		}
+		if c.key != nil {
+			sealed, err := sealFrame(c.key, frame)
+			if err != nil {
+				log.Printf("Error encrypting message for %v: %v", c.RemoteAddr(), err)
+				continue
+			}
+			frame = sealed
+		}
+		c.bytesSentThisSecond.Add(int64(len(frame)))
+		start := time.Now()
+		if err := c.WriteMessage(websocket.TextMessage, frame); err != nil {
+			c.errMu.Lock()
+			c.writeErr = err
+			c.errMu.Unlock()
+			go cleanupClient(c)
+			return
+		}
+		c.checkBacklog(len(frame), time.Since(start))
+	}
+}
+
+// WriteMessage writes a message, enabling compression only when it reaches compressionThreshold
+func (c *wsConn) WriteMessage(messageType int, data []byte) error {
+	if c.poll != nil {
+		return c.poll.deliver(data)
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.EnableWriteCompression(len(data) >= compressionThreshold)
+	return c.Conn.WriteMessage(messageType, data)
+}
+
+// ReadJSON reads the next message into v, decrypting it first when app-layer encryption is on
+func (c *wsConn) ReadJSON(v interface{}) error {
+	if c.key == nil {
+		return c.Conn.ReadJSON(v)
+	}
+	_, frame, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	data, err := openFrame(c.key, frame)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Close stops the writer and closes the underlying connection
+func (c *wsConn) Close() error {
+	c.closed.Do(func() { close(c.done) })
+	if c.poll != nil {
+		return nil
+	}
+	return c.Conn.Close()
+}
+
+// RemoteAddr returns the client's address, as forwarded by a trusted proxy when there is one
+func (c *wsConn) RemoteAddr() net.Addr {
+	if c.forwardedAddr != nil {
+		return c.forwardedAddr
+	}
+	if c.poll != nil {
+		return c.poll.addr
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// SetReadDeadline sets the WebSocket read deadline; long-poll clients are timed out by WriteControl instead
+func (c *wsConn) SetReadDeadline(t time.Time) error {
+	if c.poll != nil {
+		return nil
+	}
+	return c.Conn.SetReadDeadline(t)
+}
+
+// SetPongHandler sets the WebSocket pong handler, long-poll clients never send pongs
+func (c *wsConn) SetPongHandler(h func(string) error) {
+	if c.poll != nil {
+		return
+	}
+	c.Conn.SetPongHandler(h)
+}
+
+// WriteControl writes a control frame; for long-poll clients a ping instead fails once they stop polling
+func (c *wsConn) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	if c.poll != nil {
+		return c.poll.checkAlive()
+	}
+	return c.Conn.WriteControl(messageType, data, deadline)
+}
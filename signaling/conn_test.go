@@ -0,0 +1,473 @@
+package signaling
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+)
+
+// setBatchWriteDelay replaces BATCH_WRITE_DELAY_MS for connections opened during the test
+func setBatchWriteDelay(t *testing.T, delay time.Duration) {
+	previous := batchWriteDelay
+	batchWriteDelay = delay
+	t.Cleanup(func() { batchWriteDelay = previous })
+}
+
+// setEgressLimit replaces EGRESS_THROTTLE_BYTES_PER_SEC for connections opened during the test and opens a new
+// egress window every period for the rest of it
+func setEgressLimit(t *testing.T, limit int64, period time.Duration) {
+	previous := egressLimit
+	egressLimit = limit
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(period)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				clients.Range(func(k, _ interface{}) bool {
+					k.(*wsConn).bytesSentThisSecond.Store(0)
+					return true
+				})
+			case <-stop:
+				return
+			}
+		}
+	}()
+	t.Cleanup(func() {
+		close(stop)
+		egressLimit = previous
+	})
+}
+
+func TestThrottledICECandidatesKeepOrder(t *testing.T) {
+	setEgressLimit(t, 1, 20*time.Millisecond)
+	ts := NewTestServer(t)
+	caller, callee := ts.Connect(), ts.Connect()
+	ts.startCall(caller, callee, "throttle-order")
+
+	for i := 0; i < 10; i++ {
+		ts.Send(caller, Message{Type: "ice-candidate", CallID: "throttle-order", Data: fmt.Sprintf(`{"candidate":"c%d"}`, i)})
+	}
+	for i := 0; i < 10; i++ {
+		want := fmt.Sprintf(`{"candidate":"c%d"}`, i)
+		if msg := ts.AssertMessageReceived(callee, "ice-candidate", testTimeout); msg.Data != want {
+			t.Fatalf("candidate %d is %s, want %s", i, msg.Data, want)
+		}
+	}
+}
+
+func TestThrottledICECandidateAfterClose(t *testing.T) {
+	previous := egressLimit
+	egressLimit = 1
+	t.Cleanup(func() { egressLimit = previous })
+	ts := NewTestServer(t)
+	ts.ConnectWithClientID("throttle-closed")
+	client := findClient("throttle-closed")
+	if client == nil {
+		t.Fatal("client not registered")
+	}
+	client.conn.bytesSentThisSecond.Store(1)
+
+	if err := client.conn.WriteJSON(Message{Type: "ice-candidate", Data: "{}"}); err != nil {
+		t.Fatalf("holding back a candidate: %v", err)
+	}
+	client.conn.Close()
+	if err := client.conn.WriteJSON(Message{Type: "ice-candidate", Data: "{}"}); err != errConnClosed {
+		t.Fatalf("WriteJSON after close returned %v, want errConnClosed", err)
+	}
+}
+
+func TestThrottledICECandidatesBounded(t *testing.T) {
+	previous := egressLimit
+	egressLimit = 1
+	t.Cleanup(func() { egressLimit = previous })
+	ts := NewTestServer(t)
+	ts.ConnectWithClientID("throttle-bounded")
+	client := findClient("throttle-bounded")
+	if client == nil {
+		t.Fatal("client not registered")
+	}
+	client.conn.bytesSentThisSecond.Store(1 << 30)
+
+	var err error
+	for i := 0; i <= sendQueueSize && err == nil; i++ {
+		err = client.conn.WriteJSON(Message{Type: "ice-candidate", Data: "{}"})
+	}
+	if err != errSendQueueFull {
+		t.Fatalf("got %v after %d held-back candidates, want errSendQueueFull", err, sendQueueSize+1)
+	}
+}
+
+// frameRecorder is the client side of a connection, noting whether each WebSocket frame the server sends is compressed
+type frameRecorder struct {
+	net.Conn
+	mu       sync.Mutex
+	pending  []byte
+	upgraded bool
+	seen     []frame
+}
+
+// frame is the payload length of a frame and whether it was compressed
+type frame struct {
+	length     int
+	compressed bool
+}
+
+func (fr *frameRecorder) Read(p []byte) (int, error) {
+	n, err := fr.Conn.Read(p)
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	fr.pending = append(fr.pending, p[:n]...)
+	if !fr.upgraded {
+		end := bytes.Index(fr.pending, []byte("\r\n\r\n"))
+		if end < 0 {
+			return n, err
+		}
+		fr.upgraded = true
+		fr.pending = fr.pending[end+4:]
+	}
+	for len(fr.pending) >= 2 {
+		header, length := 2, int(fr.pending[1]&0x7f)
+		switch length {
+		case 126:
+			if len(fr.pending) < 4 {
+				return n, err
+			}
+			header, length = 4, int(binary.BigEndian.Uint16(fr.pending[2:4]))
+		case 127:
+			if len(fr.pending) < 10 {
+				return n, err
+			}
+			header, length = 10, int(binary.BigEndian.Uint64(fr.pending[2:10]))
+		}
+		if len(fr.pending) < header+length {
+			return n, err
+		}
+		fr.seen = append(fr.seen, frame{length: length, compressed: fr.pending[0]&0x40 != 0})
+		fr.pending = fr.pending[header+length:]
+	}
+	return n, err
+}
+
+// frames returns the frames seen so far
+func (fr *frameRecorder) frames() []frame {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	return append([]frame(nil), fr.seen...)
+}
+
+func TestCompressionOnlyAboveThreshold(t *testing.T) {
+	ts := NewTestServer(t)
+	recorder := &frameRecorder{}
+	dialer := websocket.Dialer{
+		EnableCompression: true,
+		NetDialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := (&net.Dialer{}).DialContext(ctx, network, addr)
+			recorder.Conn = conn
+			return recorder, err
+		},
+	}
+	conn, _, err := dialer.Dial(ts.wsURL(url.Values{"token": {ts.Token("compression", jwt.MapClaims{"rooms": "*"})}}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.track(conn)
+	ts.AssertMessageReceived(conn, "capabilities", testTimeout)
+	time.Sleep(2 * batchWriteDelay)
+	for _, f := range recorder.frames() {
+		if f.compressed {
+			t.Fatalf("%d byte frame compressed, below the %d byte threshold", f.length, compressionThreshold)
+		}
+	}
+
+	ts.Send(conn, Message{Type: "echo", Seq: 1, SentAt: "small"})
+	ts.AssertMessageReceived(conn, "echo_reply", testTimeout)
+	ts.Send(conn, Message{Type: "echo", Seq: 2, SentAt: strings.Repeat("large ", 1000)})
+	if msg := ts.AssertMessageReceived(conn, "echo_reply", testTimeout); len(msg.SentAt) != 6000 {
+		t.Fatalf("large echo_reply came back with %d bytes of sentAt", len(msg.SentAt))
+	}
+	var compressed []frame
+	for _, f := range recorder.frames() {
+		if f.compressed {
+			compressed = append(compressed, f)
+		}
+	}
+	if len(compressed) != 1 || compressed[0].length >= 1000 {
+		t.Fatalf("compressed frames %+v, want only the large echo_reply, shrunk well below its 6000 bytes", compressed)
+	}
+}
+
+// BenchmarkCompressionThreshold writes 100 and 5000 byte messages to a compressing client, with compressionThreshold
+// at its default and at 0, which compresses every message
+func BenchmarkCompressionThreshold(b *testing.B) {
+	for _, size := range []int{100, 5000} {
+		for _, threshold := range []int{compressionThreshold, 0} {
+			b.Run(fmt.Sprintf("size=%d/threshold=%d", size, threshold), func(b *testing.B) {
+				previous := compressionThreshold
+				compressionThreshold = threshold
+				b.Cleanup(func() { compressionThreshold = previous })
+				server, client := compressingPair(b)
+				payload := bytes.Repeat([]byte("a1b2c3d4e5"), size/10)
+				go func() {
+					for {
+						if _, _, err := client.ReadMessage(); err != nil {
+							return
+						}
+					}
+				}()
+
+				b.SetBytes(int64(size))
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if err := server.WriteMessage(websocket.TextMessage, payload); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// compressingPair returns the server end, as a wsConn, and the client end of a WebSocket connection that
+// negotiated permessage-deflate
+func compressingPair(b *testing.B) (*wsConn, *websocket.Conn) {
+	server, client := socketPair(b, websocket.Dialer{EnableCompression: true})
+	conn := &wsConn{Conn: server}
+	b.Cleanup(func() { conn.Conn.Close() })
+	return conn, client
+}
+
+// socketPair returns the server and client ends of a WebSocket connection the client opened with dialer
+func socketPair(tb testing.TB, dialer websocket.Dialer) (*websocket.Conn, *websocket.Conn) {
+	accepted := make(chan *websocket.Conn, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			tb.Error(err)
+			return
+		}
+		accepted <- conn
+	}))
+	tb.Cleanup(server.Close)
+	client, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { client.Close() })
+	return <-accepted, client
+}
+
+// batchedPair returns a wsConn with its writer running and the client end reading from it
+func batchedPair(tb testing.TB) (*wsConn, *websocket.Conn) {
+	server, client := socketPair(tb, websocket.Dialer{})
+	conn := newWSConn(server, nil)
+	tb.Cleanup(func() { conn.Close() })
+	return conn, client
+}
+
+// readFrame reads one frame from client and decodes it, reporting whether it was a batch
+func readFrame(t *testing.T, client *websocket.Conn) ([]Message, bool) {
+	t.Helper()
+	client.SetReadDeadline(time.Now().Add(testTimeout))
+	_, data, err := client.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data[0] == '[' {
+		var batch []Message
+		if err := json.Unmarshal(data, &batch); err != nil {
+			t.Fatal(err)
+		}
+		return batch, true
+	}
+	var msg Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		t.Fatal(err)
+	}
+	return []Message{msg}, false
+}
+
+func TestBatchedWritesShareOneFrame(t *testing.T) {
+	setBatchWriteDelay(t, 50*time.Millisecond)
+	conn, client := batchedPair(t)
+
+	for seq := int64(1); seq <= 5; seq++ {
+		if err := conn.WriteJSON(Message{Type: "ice-candidate", Seq: seq}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	batch, batched := readFrame(t, client)
+	if !batched || len(batch) != 5 {
+		t.Fatalf("got %d messages, batched %v, want one frame of 5", len(batch), batched)
+	}
+	for i, msg := range batch {
+		if msg.Seq != int64(i+1) {
+			t.Fatalf("batch out of order: %+v", batch)
+		}
+	}
+
+	// a message with nothing queued behind it goes out as a plain object
+	if err := conn.WriteJSON(Message{Type: "echo_reply", Seq: 6}); err != nil {
+		t.Fatal(err)
+	}
+	if msgs, batched := readFrame(t, client); batched || msgs[0].Seq != 6 {
+		t.Fatalf("lone message came as %+v, batched %v", msgs, batched)
+	}
+}
+
+func TestBatchingDisabled(t *testing.T) {
+	setBatchWriteDelay(t, 0)
+	conn, client := batchedPair(t)
+	for seq := int64(1); seq <= 3; seq++ {
+		if err := conn.WriteJSON(Message{Type: "ice-candidate", Seq: seq}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for seq := int64(1); seq <= 3; seq++ {
+		if msgs, batched := readFrame(t, client); batched || msgs[0].Seq != seq {
+			t.Fatalf("frame %d was %+v, batched %v, want candidate %d alone", seq, msgs, batched, seq)
+		}
+	}
+}
+
+func TestHighPriorityLaneDrainsFirst(t *testing.T) {
+	setBatchWriteDelay(t, 0)
+	conn, client := batchedPair(t)
+
+	// hold the socket so the writer blocks on its first message and the rest back up in the lanes
+	conn.writeMu.Lock()
+	if err := conn.WriteJSON(Message{Type: "relay", Seq: 0}); err != nil {
+		t.Fatal(err)
+	}
+	for len(conn.queue.low) > 0 {
+		runtime.Gosched()
+	}
+	for seq := int64(1); seq <= 3; seq++ {
+		if err := conn.WriteJSON(Message{Type: "relay", Seq: seq}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for seq, msgType := range []string{"offer", "ice-candidate", "answer"} {
+		if err := conn.WriteJSON(Message{Type: msgType, Seq: int64(seq + 4)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	conn.writeMu.Unlock()
+
+	var order []string
+	for len(order) < 7 {
+		msgs, _ := readFrame(t, client)
+		for _, msg := range msgs {
+			order = append(order, fmt.Sprintf("%s/%d", msg.Type, msg.Seq))
+		}
+	}
+	want := "relay/0 offer/4 ice-candidate/5 answer/6 relay/1 relay/2 relay/3"
+	if got := strings.Join(order, " "); got != want {
+		t.Fatalf("delivered %s, want %s", got, want)
+	}
+}
+
+// BenchmarkICECandidateBatching streams ICE candidates to a client as fast as the send queue takes them,
+// batching within the default BATCH_WRITE_DELAY_MS and writing one frame per candidate
+func BenchmarkICECandidateBatching(b *testing.B) {
+	for _, delay := range []time.Duration{batchWriteDelay, 0} {
+		b.Run(fmt.Sprintf("delay=%v", delay), func(b *testing.B) {
+			previous := batchWriteDelay
+			batchWriteDelay = delay
+			b.Cleanup(func() { batchWriteDelay = previous })
+			conn, client := batchedPair(b)
+			candidate := Message{Type: "ice-candidate", CallID: "bench", Data: `{"candidate":"candidate:1 1 udp 2122260223 192.0.2.1 54400 typ host","sdpMid":"0"}`}
+
+			received := make(chan int, 1)
+			go func() {
+				messages, frames := 0, 0
+				for messages < b.N {
+					_, data, err := client.ReadMessage()
+					if err != nil {
+						break
+					}
+					frames++
+					if data[0] == '[' {
+						var batch []json.RawMessage
+						json.Unmarshal(data, &batch)
+						messages += len(batch)
+					} else {
+						messages++
+					}
+				}
+				received <- frames
+			}()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for conn.WriteJSON(candidate) == errSendQueueFull {
+					runtime.Gosched()
+				}
+			}
+			frames := <-received
+			b.StopTimer()
+			b.ReportMetric(float64(frames)/float64(b.N), "frames/op")
+		})
+	}
+}
+
+// BenchmarkEgressThrottle streams ICE candidates to a client without an egress limit and under a 10,000 bytes/s
+// EGRESS_THROTTLE_BYTES_PER_SEC, opening a new egress window before every candidate so only bursts the writer
+// sends in between are held back; the difference is what the throttle costs a connection near its budget
+func BenchmarkEgressThrottle(b *testing.B) {
+	for _, limit := range []int64{0, 10000} {
+		b.Run(fmt.Sprintf("limit=%d", limit), func(b *testing.B) {
+			previousLimit, previousDelay := egressLimit, batchWriteDelay
+			egressLimit, batchWriteDelay = limit, 0
+			b.Cleanup(func() { egressLimit, batchWriteDelay = previousLimit, previousDelay })
+			conn, client := batchedPair(b)
+			candidate := Message{Type: "ice-candidate", CallID: "bench", Data: `{"candidate":"candidate:1 1 udp 2122260223 192.0.2.1 54400 typ host","sdpMid":"0"}`}
+
+			received := make(chan struct{})
+			go func() {
+				defer close(received)
+				for messages := 0; messages < b.N; messages++ {
+					if _, _, err := client.ReadMessage(); err != nil {
+						return
+					}
+				}
+			}()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				conn.bytesSentThisSecond.Store(0)
+				for conn.WriteJSON(candidate) == errSendQueueFull {
+					conn.bytesSentThisSecond.Store(0)
+					runtime.Gosched()
+				}
+			}
+			// a burst the writer sent after the last reset can still hold the final candidates back
+			for waiting := true; waiting; {
+				select {
+				case <-received:
+					waiting = false
+				case <-time.After(egressRetryDelay):
+					conn.bytesSentThisSecond.Store(0)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package signaling
+
+import (
+	"encoding/base64"
+	"log"
+	"time"
+)
+
+const (
+	// connectionTestPayloadSize is the exact size echoed payloads are padded or truncated to
+	connectionTestPayloadSize = 256
+	// connectionTestSamples is how many round trips are kept per client
+	connectionTestSamples = 5
+)
+
+// handleConnectionTest echoes a fixed-size payload with the server's timestamp so the client can time the round trip
+func handleConnectionTest(sender *wsConn, msg Message) {
+	payload, err := base64.StdEncoding.DecodeString(msg.Payload)
+	if err != nil {
+		sendError(sender, "invalid_payload")
+		return
+	}
+	if !allowMessage(sender, "connection_test", 1, 10*time.Second) {
+		return
+	}
+	client, ok := getClient(sender)
+	if !ok {
+		return
+	}
+	echoed := make([]byte, connectionTestPayloadSize)
+	copy(echoed, payload)
+
+	now := time.Now()
+	client.mu.Lock()
+	client.connectionTestAt = now
+	client.mu.Unlock()
+	if err := sender.WriteJSON(Message{
+		Type:            "connection_test",
+		Payload:         base64.StdEncoding.EncodeToString(echoed),
+		ServerTimestamp: now.UTC().Format(time.RFC3339Nano),
+	}); err != nil {
+		log.Printf("Error sending connection_test to %v: %v", sender.RemoteAddr(), err)
+		go cleanupClient(sender)
+	}
+}
+
+// handleConnectionTestResult times the round trip from the echo to the client returning its serverTimestamp
+func handleConnectionTestResult(sender *wsConn, msg Message) {
+	client, ok := getClient(sender)
+	if !ok {
+		return
+	}
+	sentAt, err := time.Parse(time.RFC3339Nano, msg.ServerTimestamp)
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if err != nil || client.connectionTestAt.IsZero() || !sentAt.Equal(client.connectionTestAt) {
+		return
+	}
+	client.connectionTests.add(time.Since(client.connectionTestAt))
+	client.connectionTestAt = time.Time{}
+}
@@ -0,0 +1,104 @@
+package signaling
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// expireRateWindow lets the client with id send another message of kind straight away
+func expireRateWindow(t *testing.T, id, kind string) {
+	t.Helper()
+	client := findClient(id)
+	if client == nil {
+		t.Fatalf("client %s not connected", id)
+	}
+	client.limits.mu.Lock()
+	defer client.limits.mu.Unlock()
+	if w, ok := client.limits.windows[kind]; ok {
+		w.start = w.start.Add(-time.Hour)
+	}
+}
+
+// connectionTest sends payload as a connection_test and returns the decoded echo and its serverTimestamp
+func (ts *TestServer) connectionTest(conn *websocket.Conn, payload []byte) ([]byte, string) {
+	ts.t.Helper()
+	ts.Send(conn, Message{Type: "connection_test", Payload: base64.StdEncoding.EncodeToString(payload)})
+	msg := ts.AssertMessageReceived(conn, "connection_test", testTimeout)
+	echoed, err := base64.StdEncoding.DecodeString(msg.Payload)
+	if err != nil {
+		ts.t.Fatalf("echoed payload %q: %v", msg.Payload, err)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, msg.ServerTimestamp); err != nil {
+		ts.t.Fatalf("serverTimestamp %q: %v", msg.ServerTimestamp, err)
+	}
+	return echoed, msg.ServerTimestamp
+}
+
+func TestConnectionTestEchoesFixedSizePayload(t *testing.T) {
+	ts := NewTestServer(t)
+	conn := ts.ConnectWithClientID("conn-test")
+
+	short := []byte("ping")
+	echoed, _ := ts.connectionTest(conn, short)
+	if len(echoed) != connectionTestPayloadSize || !bytes.HasPrefix(echoed, short) || !bytes.Equal(echoed[len(short):], make([]byte, connectionTestPayloadSize-len(short))) {
+		t.Fatalf("short payload echoed as %d bytes %q", len(echoed), echoed)
+	}
+
+	ts.Send(conn, Message{Type: "connection_test", Payload: "AAAA"})
+	ts.AssertError(conn, "rate_limited")
+	ts.RequireNoMessageOfType(conn, "connection_test", 50*time.Millisecond)
+
+	expireRateWindow(t, "conn-test", "connection_test")
+	long := bytes.Repeat([]byte{0xab}, connectionTestPayloadSize+44)
+	if echoed, _ := ts.connectionTest(conn, long); !bytes.Equal(echoed, long[:connectionTestPayloadSize]) {
+		t.Fatalf("long payload echoed as %d bytes", len(echoed))
+	}
+
+	ts.Send(conn, Message{Type: "connection_test", Payload: "not base64!"})
+	ts.AssertError(conn, "invalid_payload")
+}
+
+func TestConnectionTestMedianOfLastFive(t *testing.T) {
+	ts := NewTestServer(t)
+	setAdminToken(t, "conn-test-admin")
+	conn := ts.ConnectWithClientID("conn-test-stats")
+
+	// six round trips where only the first is dropped from the ring: the median of the five kept is
+	// 100ms, but would be near 0 if the first still counted
+	for _, delay := range []time.Duration{0, 0, 0, 100, 100, 100} {
+		expireRateWindow(t, "conn-test-stats", "connection_test")
+		_, serverTimestamp := ts.connectionTest(conn, []byte("rtt"))
+		time.Sleep(delay * time.Millisecond)
+		ts.Send(conn, Message{Type: "connection_test_result", ServerTimestamp: serverTimestamp})
+	}
+	// a result for an echo that was never sent is ignored
+	ts.Send(conn, Message{Type: "connection_test_result", ServerTimestamp: time.Now().Add(-time.Minute).UTC().Format(time.RFC3339Nano)})
+	// the echo shows the results before it have been handled
+	ts.Send(conn, Message{Type: "echo"})
+	ts.AssertMessageReceived(conn, "echo_reply", testTimeout)
+
+	resp := ts.adminRequest("GET", "/api/v1/clients/conn-test-stats", "conn-test-admin")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("client status %d", resp.StatusCode)
+	}
+	var desc ClientSnapshot
+	if err := json.NewDecoder(resp.Body).Decode(&desc); err != nil {
+		t.Fatal(err)
+	}
+	if desc.ConnectionTestP50Ms < 100 || desc.ConnectionTestP50Ms > 1000 {
+		t.Fatalf("connectionTestP50Ms %d, want the 100ms median of the last five", desc.ConnectionTestP50Ms)
+	}
+	client := findClient("conn-test-stats")
+	client.mu.Lock()
+	samples := len(client.connectionTests.values())
+	client.mu.Unlock()
+	if samples != connectionTestSamples {
+		t.Fatalf("%d round trips kept, want %d", samples, connectionTestSamples)
+	}
+}
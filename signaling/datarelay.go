@@ -0,0 +1,58 @@
+package signaling
+
+import (
+	"encoding/base64"
+	"log"
+	"time"
+)
+
+// maxDataRelayBytes bounds the decoded payload of one data_relay message
+const maxDataRelayBytes = 8 << 10
+
+// validChannelName reports whether name is a usable data channel label for data_relay
+func validChannelName(name string) bool {
+	if name == "" || len(name) > 64 {
+		return false
+	}
+	for _, r := range name {
+		if !(r == '-' || r == '_' || r == '.' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// handleDataRelay forwards a small data channel message to the named room member,
+// a best-effort fallback for clients whose data channels cannot connect
+func handleDataRelay(sender *wsConn, msg Message) {
+	if !validChannelName(msg.Channel) || base64.StdEncoding.DecodedLen(len(msg.Data)) > maxDataRelayBytes+2 {
+		sendError(sender, "invalid_data_relay")
+		return
+	}
+	payload, err := base64.StdEncoding.DecodeString(msg.Data)
+	if err != nil || len(payload) > maxDataRelayBytes {
+		sendError(sender, "invalid_data_relay")
+		return
+	}
+	if !allowMessage(sender, "data_relay:"+msg.To+":"+msg.Channel, 1000, time.Second) {
+		return
+	}
+	target := findRoomMember(msg.CallID, sender, msg.To)
+	if target == nil {
+		sendError(sender, "peer_not_found")
+		return
+	}
+	if err := target.WriteJSON(Message{
+		Type:    "data_relay",
+		CallID:  msg.CallID,
+		From:    clientID(sender),
+		To:      msg.To,
+		Channel: msg.Channel,
+		Data:    msg.Data,
+	}); err != nil {
+		log.Printf("Error relaying data_relay to %v: %v", target.RemoteAddr(), err)
+		go cleanupClient(target)
+		return
+	}
+	dataRelayMessages.Inc()
+}
@@ -0,0 +1,40 @@
+package signaling
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestDataRelayForwarded(t *testing.T) {
+	ts := NewTestServer(t)
+	sender, peer := ts.ConnectWithClientID("relay-sender"), ts.ConnectWithClientID("relay-peer")
+	ts.startCall(sender, peer, "relay-call")
+
+	data := base64.StdEncoding.EncodeToString([]byte("cursor:12,40"))
+	ts.Send(sender, Message{Type: "data_relay", CallID: "relay-call", To: "relay-peer", Channel: "whiteboard", Data: data})
+	msg := ts.AssertMessageReceived(peer, "data_relay", testTimeout)
+	if msg.From != "relay-sender" || msg.To != "relay-peer" || msg.Channel != "whiteboard" || msg.Data != data {
+		t.Fatalf("data_relay %+v", msg)
+	}
+}
+
+func TestDataRelayRules(t *testing.T) {
+	ts := NewTestServer(t)
+	sender, peer := ts.Connect(), ts.ConnectWithClientID("relay-rules-peer")
+	ts.startCall(sender, peer, "relay-rules")
+
+	valid := base64.StdEncoding.EncodeToString([]byte("ping"))
+	for _, msg := range []Message{
+		{Type: "data_relay", CallID: "relay-rules", To: "relay-rules-peer", Data: valid},
+		{Type: "data_relay", CallID: "relay-rules", To: "relay-rules-peer", Channel: "has space", Data: valid},
+		{Type: "data_relay", CallID: "relay-rules", To: "relay-rules-peer", Channel: strings.Repeat("c", 65), Data: valid},
+		{Type: "data_relay", CallID: "relay-rules", To: "relay-rules-peer", Channel: "chat", Data: "not base64!"},
+		{Type: "data_relay", CallID: "relay-rules", To: "relay-rules-peer", Channel: "chat", Data: base64.StdEncoding.EncodeToString(make([]byte, maxDataRelayBytes+1))},
+	} {
+		ts.Send(sender, msg)
+		ts.AssertError(sender, "invalid_data_relay")
+	}
+	ts.Send(sender, Message{Type: "data_relay", CallID: "relay-rules", To: "nobody", Channel: "chat", Data: valid})
+	ts.AssertError(sender, "peer_not_found")
+}
@@ -0,0 +1,74 @@
+package signaling
+
+import (
+	"log"
+	"time"
+)
+
+// clientDebug enables debug_room_dump; it stays off in production to avoid leaking room details
+var clientDebug = envString("ENABLE_CLIENT_DEBUG", "false") == "true"
+
+// DebugClient is a sanitized description of a room member in room_dump, without IPs or SDP. It has no muted field:
+// clients mute their tracks locally and never tell the server, so it would always read false.
+type DebugClient struct {
+	ClientID       string    `json:"clientId"`
+	JoinedAt       time.Time `json:"joinedAt"`
+	VideoEffect    string    `json:"videoEffect,omitempty"`
+	NetworkQuality int       `json:"networkQuality,omitempty"`
+	E2EEEnabled    bool      `json:"e2eeEnabled"`
+	Host           bool      `json:"host"`
+}
+
+// handleDebugRoomDump sends the room host a sanitized view of the room's state. The room counts as locked while it is
+// in lobby mode, the only way the server keeps clients from walking in.
+func handleDebugRoomDump(sender *wsConn, msg Message) {
+	if !clientDebug {
+		sendError(sender, "debug_disabled")
+		return
+	}
+	if !allowMessage(sender, "debug_room_dump", 1, time.Minute) {
+		return
+	}
+
+	room, unlock := rlockRoom(msg.CallID)
+	if room == nil {
+		sendError(sender, "Call not found")
+		return
+	}
+	if room.host != sender {
+		unlock()
+		sendError(sender, "not_host")
+		return
+	}
+	members := make([]DebugClient, 0, len(room.clients))
+	for member := range room.clients {
+		client, ok := getClient(member)
+		if !ok {
+			continue
+		}
+		client.mu.Lock()
+		members = append(members, DebugClient{
+			ClientID:       client.id,
+			JoinedAt:       room.joinedAt[member],
+			VideoEffect:    client.videoEffect,
+			NetworkQuality: room.quality[member],
+			E2EEEnabled:    client.e2eeEnabled,
+			Host:           member == room.host,
+		})
+		client.mu.Unlock()
+	}
+	offerPresent := room.offer != nil && !room.offerExpired()
+	locked := room.options.Lobby
+	unlock()
+
+	if err := sender.WriteJSON(Message{
+		Type:         "room_dump",
+		CallID:       msg.CallID,
+		Clients:      members,
+		OfferPresent: &offerPresent,
+		Locked:       &locked,
+	}); err != nil {
+		log.Printf("Error sending room_dump to %v: %v", sender.RemoteAddr(), err)
+		go cleanupClient(sender)
+	}
+}
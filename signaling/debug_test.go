@@ -0,0 +1,51 @@
+package signaling
+
+import "testing"
+
+// setClientDebug replaces ENABLE_CLIENT_DEBUG for the rest of the test
+func setClientDebug(t *testing.T, enabled bool) {
+	previous := clientDebug
+	clientDebug = enabled
+	t.Cleanup(func() { clientDebug = previous })
+}
+
+func TestDebugRoomDump(t *testing.T) {
+	ts := NewTestServer(t)
+	setClientDebug(t, true)
+	host, guest := ts.Connect(), ts.ConnectWithClientID("dump-guest")
+	ts.startCall(host, guest, "dump-call")
+
+	ts.Send(guest, Message{Type: "debug_room_dump", CallID: "dump-call"})
+	ts.AssertError(guest, "not_host")
+	ts.Send(host, Message{Type: "debug_room_dump", CallID: "dump-call"})
+	dump := ts.AssertMessageReceived(host, "room_dump", testTimeout)
+	if dump.OfferPresent == nil || !*dump.OfferPresent || dump.Locked == nil || *dump.Locked || len(dump.Clients) != 2 {
+		t.Fatalf("room_dump %+v", dump)
+	}
+	for _, member := range dump.Clients {
+		if member.JoinedAt.IsZero() || member.Host == (member.ClientID == "dump-guest") {
+			t.Fatalf("room_dump member %+v", member)
+		}
+	}
+	ts.Send(host, Message{Type: "debug_room_dump", CallID: "dump-call"})
+	ts.AssertError(host, "rate_limited")
+
+	// a lobby keeps newcomers out, so the room reads as locked
+	lobbyHost := ts.Connect()
+	ts.Send(lobbyHost, Message{Type: "offer", CallID: "dump-lobby", Data: sdpData("offer"), Lobby: true})
+	ts.waitForRoom("dump-lobby", 1)
+	ts.Send(lobbyHost, Message{Type: "debug_room_dump", CallID: "dump-lobby"})
+	if dump := ts.AssertMessageReceived(lobbyHost, "room_dump", testTimeout); dump.Locked == nil || !*dump.Locked {
+		t.Fatalf("lobby room_dump %+v", dump)
+	}
+}
+
+func TestDebugRoomDumpDisabled(t *testing.T) {
+	ts := NewTestServer(t)
+	setClientDebug(t, false)
+	host := ts.Connect()
+	ts.Send(host, Message{Type: "offer", CallID: "dump-disabled", Data: sdpData("offer")})
+	ts.waitForRoom("dump-disabled", 1)
+	ts.Send(host, Message{Type: "debug_room_dump", CallID: "dump-disabled"})
+	ts.AssertError(host, "debug_disabled")
+}
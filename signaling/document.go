@@ -0,0 +1,72 @@
+package signaling
+
+import (
+	"log"
+	"time"
+	"unicode/utf8"
+)
+
+// SharedDocument is the document or whiteboard a room member is presenting
+type SharedDocument struct {
+	URL      string    `json:"url"`
+	Title    string    `json:"title,omitempty"`
+	SharedBy string    `json:"sharedBy"`
+	SharedAt time.Time `json:"sharedAt"`
+}
+
+// handleShareDocument lets any member present a document URL to the room, replacing whatever was shared before
+func handleShareDocument(sender *wsConn, msg Message) {
+	if err := validateHTTPSURL(msg.URL); err != nil || utf8.RuneCountInString(msg.Title) > 200 {
+		sendError(sender, "invalid_document")
+		return
+	}
+	if !allowMessage(sender, "share_document", 1, time.Second) {
+		return
+	}
+	room, unlock := lockRoom(msg.CallID)
+	if room == nil || !room.clients[sender] {
+		if room != nil {
+			unlock()
+		}
+		sendError(sender, "not_in_call")
+		return
+	}
+	doc := &SharedDocument{
+		URL:      msg.URL,
+		Title:    msg.Title,
+		SharedBy: room.memberIDs[sender],
+		SharedAt: time.Now().UTC(),
+	}
+	room.SharedDocument = doc
+	unlock()
+
+	broadcastToRoom(sender, Message{Type: "document_shared", CallID: msg.CallID, SharedDocument: doc})
+	log.Printf("Client %v shared document %s in room %s", sender.RemoteAddr(), msg.URL, msg.CallID)
+}
+
+// handleStopDocumentShare clears the room's shared document, which only its sharer or the host may do
+func handleStopDocumentShare(sender *wsConn, msg Message) {
+	room, unlock := lockRoom(msg.CallID)
+	if room == nil || !room.clients[sender] {
+		if room != nil {
+			unlock()
+		}
+		sendError(sender, "not_in_call")
+		return
+	}
+	if room.SharedDocument == nil {
+		unlock()
+		sendError(sender, "no_shared_document")
+		return
+	}
+	if room.host != sender && room.SharedDocument.SharedBy != room.memberIDs[sender] {
+		unlock()
+		sendError(sender, "not_host")
+		return
+	}
+	room.SharedDocument = nil
+	unlock()
+
+	broadcastToRoom(sender, Message{Type: "document_share_stopped", CallID: msg.CallID, ClientID: clientID(sender)})
+	log.Printf("Client %v stopped the document share in room %s", sender.RemoteAddr(), msg.CallID)
+}
@@ -0,0 +1,53 @@
+package signaling
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestDocumentSharedWithRoom(t *testing.T) {
+	ts := NewTestServer(t)
+	host, presenter := ts.Connect(), ts.ConnectWithClientID("document-presenter")
+	ts.startCall(host, presenter, "document-call")
+
+	ts.Send(presenter, Message{Type: "share_document", CallID: "document-call", URL: "https://docs.example.com/deck", Title: "Q3 review"})
+	for _, conn := range []*websocket.Conn{host, presenter} {
+		msg := ts.AssertMessageReceived(conn, "document_shared", testTimeout)
+		if doc := msg.SharedDocument; doc == nil || doc.URL != "https://docs.example.com/deck" || doc.Title != "Q3 review" || doc.SharedBy != "document-presenter" || doc.SharedAt.IsZero() {
+			t.Fatalf("document_shared %+v", msg.SharedDocument)
+		}
+	}
+
+	joiner := ts.Connect()
+	ts.Send(joiner, Message{Type: "join_call", CallID: "document-call"})
+	if joined := ts.AssertMessageReceived(joiner, "call_joined", testTimeout); joined.SharedDocument == nil || joined.SharedDocument.URL != "https://docs.example.com/deck" {
+		t.Fatalf("call_joined has shared document %+v", joined.SharedDocument)
+	}
+
+	ts.Send(host, Message{Type: "stop_document_share", CallID: "document-call"})
+	for _, conn := range []*websocket.Conn{host, presenter, joiner} {
+		ts.AssertMessageReceived(conn, "document_share_stopped", testTimeout)
+	}
+}
+
+func TestDocumentShareRules(t *testing.T) {
+	ts := NewTestServer(t)
+	host, presenter, outsider := ts.Connect(), ts.Connect(), ts.Connect()
+	ts.startCall(host, presenter, "document-rules")
+
+	ts.Send(presenter, Message{Type: "share_document", CallID: "document-rules", URL: "http://docs.example.com/deck"})
+	ts.AssertError(presenter, "invalid_document")
+	ts.Send(presenter, Message{Type: "share_document", CallID: "document-rules", URL: "https://docs.example.com/deck", Title: strings.Repeat("é", 201)})
+	ts.AssertError(presenter, "invalid_document")
+	ts.Send(presenter, Message{Type: "stop_document_share", CallID: "document-rules"})
+	ts.AssertError(presenter, "no_shared_document")
+	ts.Send(outsider, Message{Type: "share_document", CallID: "document-rules", URL: "https://docs.example.com/deck"})
+	ts.AssertError(outsider, "not_in_call")
+
+	ts.Send(host, Message{Type: "share_document", CallID: "document-rules", URL: "https://docs.example.com/deck"})
+	ts.AssertMessageReceived(presenter, "document_shared", testTimeout)
+	ts.Send(presenter, Message{Type: "stop_document_share", CallID: "document-rules"})
+	ts.AssertError(presenter, "not_host")
+}
@@ -0,0 +1,58 @@
+package signaling
+
+import (
+	"encoding/base64"
+	"errors"
+	"log"
+)
+
+// validatePublicKey checks that key is base64 for an X25519 (32 byte) or uncompressed P-256 (65 byte) public key
+func validatePublicKey(key string) error {
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		raw, err = base64.RawURLEncoding.DecodeString(key)
+	}
+	if err != nil {
+		return errors.New("public key is not base64")
+	}
+	switch {
+	case len(raw) == 32:
+		return nil
+	case len(raw) == 65 && raw[0] == 0x04:
+		return nil
+	}
+	return errors.New("public key must be 32 byte X25519 or 65 byte P-256")
+}
+
+// handleE2EEKey relays a key exchange public key to one named peer in the room
+func handleE2EEKey(sender *wsConn, msg Message) {
+	if err := validatePublicKey(msg.PublicKey); err != nil {
+		log.Printf("Rejected e2ee_key from %v: %v", sender.RemoteAddr(), err)
+		sendError(sender, "invalid_public_key")
+		return
+	}
+	client, ok := getClient(sender)
+	if !ok {
+		return
+	}
+	target := findRoomMember(msg.CallID, sender, msg.To)
+	if target == nil {
+		sendError(sender, "peer_not_found")
+		return
+	}
+
+	client.mu.Lock()
+	client.e2eeEnabled = true
+	client.mu.Unlock()
+
+	if err := target.WriteJSON(Message{
+		Type:      "e2ee_key",
+		CallID:    msg.CallID,
+		From:      client.id,
+		To:        msg.To,
+		PublicKey: msg.PublicKey,
+	}); err != nil {
+		log.Printf("Error relaying e2ee_key to %v: %v", target.RemoteAddr(), err)
+		go cleanupClient(target)
+	}
+}
@@ -0,0 +1,173 @@
+package signaling
+
+import (
+	"errors"
+	"log"
+	"net/url"
+	"time"
+)
+
+// validVideoEffects are the effects a client can report
+var validVideoEffects = map[string]bool{
+	"none":               true,
+	"blur":               true,
+	"virtual_background": true,
+}
+
+// handleSetVideoEffect records a client's video effect and tells the room about it
+func handleSetVideoEffect(sender *wsConn, msg Message) {
+	if !validVideoEffects[msg.Effect] || len(msg.BackgroundID) > 64 {
+		sendError(sender, "invalid_video_effect")
+		return
+	}
+	if !allowMessage(sender, "set_video_effect", 5, time.Second) {
+		return
+	}
+	client, ok := getClient(sender)
+	if !ok {
+		return
+	}
+	client.mu.Lock()
+	client.videoEffect = msg.Effect
+	client.backgroundID = msg.BackgroundID
+	client.mu.Unlock()
+
+	if relayToRoom(sender, Message{
+		Type:         "set_video_effect",
+		CallID:       msg.CallID,
+		ClientID:     client.id,
+		Effect:       msg.Effect,
+		BackgroundID: msg.BackgroundID,
+	}) {
+		log.Printf("Client %v set video effect %s in room %s", sender.RemoteAddr(), msg.Effect, msg.CallID)
+	}
+}
+
+// BackgroundSync is the virtual background last shared with a room by background_sync
+type BackgroundSync struct {
+	BackgroundID  string
+	BackgroundURL string
+	Mandatory     bool   // set by the host, and not replaced by suggestions
+	SetBy         string // client ID of the member who shared it
+}
+
+// validateHTTPSURL checks that a URL shared with a room, such as a background or document, is served over HTTPS
+func validateHTTPSURL(raw string) error {
+	if len(raw) > 2048 {
+		return errors.New("URL too long")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return errors.New("URL must be https")
+	}
+	return nil
+}
+
+// handleBackgroundSync shares a virtual background with the room: the host can make it mandatory,
+// anyone else's is relayed as a background_suggestion
+func handleBackgroundSync(sender *wsConn, msg Message) {
+	if msg.BackgroundID == "" || len(msg.BackgroundID) > 64 {
+		sendError(sender, "invalid_background")
+		return
+	}
+	if err := validateHTTPSURL(msg.BackgroundURL); err != nil {
+		log.Printf("Rejected background_sync from %v: %v", sender.RemoteAddr(), err)
+		sendError(sender, "invalid_background")
+		return
+	}
+	if !allowMessage(sender, "background_sync", 1, time.Second) {
+		return
+	}
+	room, unlock := lockRoom(msg.CallID)
+	if room == nil || !room.clients[sender] {
+		if room != nil {
+			unlock()
+		}
+		sendError(sender, "not_in_call")
+		return
+	}
+	if msg.Mandatory && room.host != sender {
+		unlock()
+		sendError(sender, "not_host")
+		return
+	}
+	id := room.memberIDs[sender]
+	if msg.Mandatory || room.BackgroundSync == nil || !room.BackgroundSync.Mandatory {
+		room.BackgroundSync = &BackgroundSync{
+			BackgroundID:  msg.BackgroundID,
+			BackgroundURL: msg.BackgroundURL,
+			Mandatory:     msg.Mandatory,
+			SetBy:         id,
+		}
+	}
+	unlock()
+
+	out := Message{
+		Type:          "background_sync",
+		CallID:        msg.CallID,
+		ClientID:      id,
+		BackgroundID:  msg.BackgroundID,
+		BackgroundURL: msg.BackgroundURL,
+		Mandatory:     msg.Mandatory,
+	}
+	if !msg.Mandatory {
+		out.Type = "background_suggestion"
+	}
+	relayToRoom(sender, out)
+	log.Printf("Client %v shared background %s in room %s (mandatory: %t)", sender.RemoteAddr(), msg.BackgroundID, msg.CallID, msg.Mandatory)
+}
+
+// handleSetNoiseCancellation records whether a client filters its audio and tells the room
+func handleSetNoiseCancellation(sender *wsConn, msg Message) {
+	if msg.Enabled == nil {
+		sendError(sender, "invalid_noise_cancellation")
+		return
+	}
+	if !allowMessage(sender, "set_noise_cancellation", 5, time.Second) {
+		return
+	}
+	client, ok := getClient(sender)
+	if !ok {
+		return
+	}
+	client.mu.Lock()
+	client.noiseCancellation = *msg.Enabled
+	client.mu.Unlock()
+
+	relayToRoom(sender, Message{
+		Type:     "set_noise_cancellation",
+		CallID:   msg.CallID,
+		ClientID: client.id,
+		Enabled:  msg.Enabled,
+	})
+}
+
+// handleNoiseCancellationFailed tells the room a client's noise cancellation stopped working, marking it off
+func handleNoiseCancellationFailed(sender *wsConn, msg Message) {
+	if len(msg.Data) > 200 {
+		sendError(sender, "invalid_noise_cancellation")
+		return
+	}
+	if !allowMessage(sender, "noise_cancellation_failed", 1, time.Second) {
+		return
+	}
+	client, ok := getClient(sender)
+	if !ok {
+		return
+	}
+	client.mu.Lock()
+	client.noiseCancellation = false
+	client.mu.Unlock()
+
+	if relayToRoom(sender, Message{
+		Type:     "noise_cancellation_failed",
+		CallID:   msg.CallID,
+		ClientID: client.id,
+		Data:     msg.Data,
+	}) {
+		log.Printf("Client %v reported noise cancellation failure in room %s: %s", sender.RemoteAddr(), msg.CallID, msg.Data)
+	}
+}
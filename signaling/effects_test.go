@@ -0,0 +1,106 @@
+package signaling
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// peerNoiseCancellation joins conn to callID and returns the noise cancellation state call_joined gives for peerID
+func (ts *TestServer) peerNoiseCancellation(conn *websocket.Conn, callID, peerID string) bool {
+	ts.t.Helper()
+	ts.Send(conn, Message{Type: "join_call", CallID: callID})
+	joined := ts.AssertMessageReceived(conn, "call_joined", testTimeout)
+	for _, peer := range joined.Peers {
+		if peer.ClientID == peerID {
+			return peer.NoiseCancellation
+		}
+	}
+	ts.t.Fatalf("call_joined peers %+v miss %s", joined.Peers, peerID)
+	return false
+}
+
+func TestNoiseCancellationRelayedAndStored(t *testing.T) {
+	ts := NewTestServer(t)
+	caller, callee := ts.ConnectWithClientID("noise-caller"), ts.Connect()
+	ts.startCall(caller, callee, "noise-call")
+
+	enabled := true
+	ts.Send(caller, Message{Type: "set_noise_cancellation", CallID: "noise-call", Enabled: &enabled})
+	if msg := ts.AssertMessageReceived(callee, "set_noise_cancellation", testTimeout); msg.ClientID != "noise-caller" || msg.Enabled == nil || !*msg.Enabled {
+		t.Fatalf("set_noise_cancellation %+v", msg)
+	}
+	ts.RequireNoMessageOfType(caller, "set_noise_cancellation", 50*time.Millisecond)
+	if !ts.peerNoiseCancellation(ts.Connect(), "noise-call", "noise-caller") {
+		t.Fatal("joiner not told the caller filters noise")
+	}
+
+	ts.Send(caller, Message{Type: "noise_cancellation_failed", CallID: "noise-call", Data: "RNNoise worklet crashed"})
+	if msg := ts.AssertMessageReceived(callee, "noise_cancellation_failed", testTimeout); msg.ClientID != "noise-caller" || msg.Data != "RNNoise worklet crashed" {
+		t.Fatalf("noise_cancellation_failed %+v", msg)
+	}
+	if ts.peerNoiseCancellation(ts.Connect(), "noise-call", "noise-caller") {
+		t.Fatal("noise cancellation still on after it failed")
+	}
+}
+
+func TestNoiseCancellationRules(t *testing.T) {
+	ts := NewTestServer(t)
+	caller, callee := ts.Connect(), ts.Connect()
+	ts.startCall(caller, callee, "noise-rules")
+
+	ts.Send(caller, Message{Type: "set_noise_cancellation", CallID: "noise-rules"})
+	ts.AssertError(caller, "invalid_noise_cancellation")
+	ts.Send(caller, Message{Type: "noise_cancellation_failed", CallID: "noise-rules", Data: strings.Repeat("x", 201)})
+	ts.AssertError(caller, "invalid_noise_cancellation")
+
+	ts.Send(caller, Message{Type: "noise_cancellation_failed", CallID: "noise-rules"})
+	ts.AssertMessageReceived(callee, "noise_cancellation_failed", testTimeout)
+	ts.Send(caller, Message{Type: "noise_cancellation_failed", CallID: "noise-rules"})
+	ts.AssertError(caller, "rate_limited")
+	ts.RequireNoMessageOfType(callee, "noise_cancellation_failed", 100*time.Millisecond)
+}
+
+func TestBackgroundSyncRelayed(t *testing.T) {
+	ts := NewTestServer(t)
+	host, member := ts.ConnectWithClientID("background-host"), ts.ConnectWithClientID("background-member")
+	ts.startCall(host, member, "background-call")
+
+	ts.Send(host, Message{Type: "background_sync", CallID: "background-call", BackgroundID: "bg-001", BackgroundURL: "https://cdn.example.com/bg-001.jpg", Mandatory: true})
+	if msg := ts.AssertMessageReceived(member, "background_sync", testTimeout); msg.ClientID != "background-host" || msg.BackgroundID != "bg-001" || !msg.Mandatory {
+		t.Fatalf("background_sync %+v", msg)
+	}
+	ts.Send(member, Message{Type: "background_sync", CallID: "background-call", BackgroundID: "bg-002", BackgroundURL: "https://cdn.example.com/bg-002.jpg"})
+	if msg := ts.AssertMessageReceived(host, "background_suggestion", testTimeout); msg.ClientID != "background-member" || msg.BackgroundID != "bg-002" {
+		t.Fatalf("background_suggestion %+v", msg)
+	}
+
+	room, unlock := rlockRoom("background-call")
+	stored := *room.BackgroundSync
+	unlock()
+	if stored.BackgroundID != "bg-001" || !stored.Mandatory || stored.SetBy != "background-host" {
+		t.Fatalf("suggestion replaced the mandatory background: %+v", stored)
+	}
+}
+
+func TestBackgroundSyncRules(t *testing.T) {
+	ts := NewTestServer(t)
+	host, member, outsider := ts.Connect(), ts.Connect(), ts.Connect()
+	ts.startCall(host, member, "background-rules")
+
+	for _, msg := range []Message{
+		{Type: "background_sync", CallID: "background-rules", BackgroundURL: "https://cdn.example.com/bg.jpg"},
+		{Type: "background_sync", CallID: "background-rules", BackgroundID: strings.Repeat("b", 65), BackgroundURL: "https://cdn.example.com/bg.jpg"},
+		{Type: "background_sync", CallID: "background-rules", BackgroundID: "bg-http", BackgroundURL: "http://cdn.example.com/bg.jpg"},
+		{Type: "background_sync", CallID: "background-rules", BackgroundID: "bg-long", BackgroundURL: "https://cdn.example.com/" + strings.Repeat("x", 2048)},
+	} {
+		ts.Send(member, msg)
+		ts.AssertError(member, "invalid_background")
+	}
+	ts.Send(member, Message{Type: "background_sync", CallID: "background-rules", BackgroundID: "bg-001", BackgroundURL: "https://cdn.example.com/bg.jpg", Mandatory: true})
+	ts.AssertError(member, "not_host")
+	ts.Send(outsider, Message{Type: "background_sync", CallID: "background-rules", BackgroundID: "bg-001", BackgroundURL: "https://cdn.example.com/bg.jpg"})
+	ts.AssertError(outsider, "not_in_call")
+}
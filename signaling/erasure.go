@@ -0,0 +1,106 @@
+package signaling
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+)
+
+// eraseChatMessages deletes a client's stored chat messages, returning how many there were
+func eraseChatMessages(clientID string) (int64, error) {
+	if chatStore == nil {
+		return 0, nil
+	}
+	res, err := chatStore.Exec(`DELETE FROM chat_messages WHERE client_id = ?`, clientID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// eraseJSONLines rewrites the JSON-lines file at path without the entries whose clientId is clientID, returning how many were removed
+func eraseJSONLines(path, clientID string) (int, error) {
+	if path == "" {
+		return 0, nil
+	}
+	jsonlMu.Lock()
+	defer jsonlMu.Unlock()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var kept bytes.Buffer
+	removed := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var entry struct {
+			ClientID string `json:"clientId"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil && entry.ClientID == clientID {
+			removed++
+			continue
+		}
+		kept.Write(scanner.Bytes())
+		kept.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, kept.Bytes(), 0o600); err != nil {
+		return 0, err
+	}
+	return removed, os.Rename(tmp, path)
+}
+
+// handleEraseClient disconnects a client and erases the personal data the server holds about it
+func handleEraseClient(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("clientId")
+	found := false
+	if client := findClient(id); client != nil {
+		found = true
+		clientsMu.Lock()
+		client.ip = ""
+		clientsMu.Unlock()
+		client.mu.Lock()
+		client.snapshot = ""
+		client.mu.Unlock()
+		cleanupClient(client.conn)
+	}
+
+	messages, err := eraseChatMessages(id)
+	if err != nil {
+		log.Printf("Error erasing chat messages of client %s: %v", id, err)
+		http.Error(w, "Erasure failed", http.StatusInternalServerError)
+		return
+	}
+	entries := 0
+	for _, path := range []string{auditLogPath, feedbackLogPath, issueLogPath} {
+		n, err := eraseJSONLines(path, id)
+		if err != nil {
+			log.Printf("Error erasing client %s from %s: %v", id, path, err)
+			http.Error(w, "Erasure failed", http.StatusInternalServerError)
+			return
+		}
+		entries += n
+	}
+
+	if !found && messages == 0 && entries == 0 {
+		http.Error(w, "Client not found", http.StatusNotFound)
+		return
+	}
+	log.Printf("Erased client %s: connected %v, %d chat messages, %d log entries", id, found, messages, entries)
+	w.WriteHeader(http.StatusNoContent)
+}
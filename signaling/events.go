@@ -0,0 +1,171 @@
+package signaling
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// eventStreamBuffer is how many events a slow event stream may fall behind before events to it are dropped
+const eventStreamBuffer = 256
+
+// eventStreamKeepAlive is how often an idle event stream is sent a comment so proxies keep it open
+const eventStreamKeepAlive = 30 * time.Second
+
+// eventStream is one GET /ws-events subscriber
+type eventStream struct {
+	callID string // room the stream follows, empty for every room
+	events chan []byte
+	closed chan struct{}
+	once   sync.Once
+}
+
+// Event stream subscribers
+var (
+	eventStreams   = make(map[*eventStream]bool)
+	eventStreamsMu sync.Mutex
+)
+
+// close ends the stream, once
+func (s *eventStream) close() {
+	s.once.Do(func() { close(s.closed) })
+}
+
+// publishEvent sends a message relayed in a room to the event streams following that room or every room
+func publishEvent(msg Message) {
+	eventStreamsMu.Lock()
+	defer eventStreamsMu.Unlock()
+	if len(eventStreams) == 0 {
+		return
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("Error encoding %s for event streams: %v", msg.Type, err)
+		return
+	}
+	for s := range eventStreams {
+		if s.callID != "" && s.callID != msg.CallID {
+			continue
+		}
+		select {
+		case s.events <- data:
+		default:
+		}
+	}
+}
+
+// closeEventStreams tells the streams following a deleted room that it is gone and ends them
+func closeEventStreams(callID string) {
+	publishEvent(Message{Type: "room_deleted", CallID: callID})
+	eventStreamsMu.Lock()
+	for s := range eventStreams {
+		if s.callID == callID {
+			s.close()
+		}
+	}
+	eventStreamsMu.Unlock()
+}
+
+// authorizeEventStream checks who may follow an event stream: every room needs the admin token,
+// one room needs a connection token granting it, or the monitor token when connection tokens are off
+func authorizeEventStream(r *http.Request, callID string) (int, string) {
+	if callID == "" {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if adminToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			return http.StatusUnauthorized, "Unauthorized"
+		}
+		return http.StatusOK, ""
+	}
+	if authRequired() {
+		auth, err := parseAuth(r)
+		if err != nil {
+			return http.StatusUnauthorized, "Invalid or expired token"
+		}
+		if !auth.allows(callID) {
+			return http.StatusForbidden, "Forbidden"
+		}
+		return http.StatusOK, ""
+	}
+	token := r.URL.Query().Get("token")
+	if monitorToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(monitorToken)) != 1 {
+		return http.StatusUnauthorized, "Unauthorized"
+	}
+	return http.StatusOK, ""
+}
+
+// handleEventStream streams the messages relayed in one room, or in every room, as Server-Sent Events
+// until the client goes away or the room is deleted
+func handleEventStream(w http.ResponseWriter, r *http.Request) {
+	callID := r.URL.Query().Get("callId")
+	if status, text := authorizeEventStream(r, callID); status != http.StatusOK {
+		log.Printf("Rejected event stream for call %q from %v: %s", callID, r.RemoteAddr, text)
+		http.Error(w, text, status)
+		return
+	}
+	if callID != "" {
+		room, unlock := rlockRoom(callID)
+		if room == nil {
+			http.Error(w, "Call not found", http.StatusNotFound)
+			return
+		}
+		unlock()
+	}
+
+	s := &eventStream{callID: callID, events: make(chan []byte, eventStreamBuffer), closed: make(chan struct{})}
+	eventStreamsMu.Lock()
+	eventStreams[s] = true
+	eventStreamsMu.Unlock()
+	defer func() {
+		eventStreamsMu.Lock()
+		delete(eventStreams, s)
+		eventStreamsMu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	if err := rc.Flush(); err != nil {
+		log.Printf("Event stream for %v cannot be flushed: %v", r.RemoteAddr, err)
+		return
+	}
+	log.Printf("Event stream opened for call %q by %v", callID, r.RemoteAddr)
+
+	keepAlive := time.NewTicker(eventStreamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case data := <-s.events:
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case <-s.closed:
+			for {
+				select {
+				case data := <-s.events:
+					fmt.Fprintf(w, "data: %s\n\n", data)
+				default:
+					rc.Flush()
+					log.Printf("Event stream for call %s closed: room deleted", callID)
+					return
+				}
+			}
+		case <-r.Context().Done():
+			log.Printf("Event stream for call %q closed by %v", callID, r.RemoteAddr)
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
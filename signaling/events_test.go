@@ -0,0 +1,108 @@
+package signaling
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// openEventStream opens GET /ws-events with query and returns the response, read for at most testTimeout
+func (ts *TestServer) openEventStream(query url.Values) *http.Response {
+	ts.t.Helper()
+	client := &http.Client{Timeout: testTimeout}
+	resp, err := client.Get(ts.URL() + "/ws-events?" + query.Encode())
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	ts.t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// nextEvent returns the next data event from an event stream, skipping keep-alive comments
+func nextEvent(t *testing.T, events *bufio.Scanner) Message {
+	t.Helper()
+	for events.Scan() {
+		if data, ok := strings.CutPrefix(events.Text(), "data: "); ok {
+			var msg Message
+			if err := json.Unmarshal([]byte(data), &msg); err != nil {
+				t.Fatalf("event %q: %v", data, err)
+			}
+			return msg
+		}
+	}
+	t.Fatalf("event stream ended: %v", events.Err())
+	return Message{}
+}
+
+func TestEventStreamFollowsRoom(t *testing.T) {
+	ts := NewTestServer(t)
+	caller, callee := ts.Connect(), ts.Connect()
+	ts.startCall(caller, callee, "events-call")
+
+	resp := ts.openEventStream(url.Values{"callId": {"events-call"}, "token": {ts.Token("events-viewer", jwt.MapClaims{"rooms": []string{"events-call"}})}})
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("event stream answered %d with %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	events := bufio.NewScanner(resp.Body)
+
+	ts.Send(caller, Message{Type: "relay", CallID: "events-call", Data: "hello dashboard"})
+	if msg := nextEvent(t, events); msg.Type != "relay" || msg.Data != "hello dashboard" {
+		t.Fatalf("event %+v", msg)
+	}
+
+	caller.Close()
+	callee.Close()
+	for {
+		if msg := nextEvent(t, events); msg.Type == "room_deleted" {
+			break
+		}
+	}
+	for events.Scan() {
+		if strings.HasPrefix(events.Text(), "data: ") {
+			t.Fatalf("event stream went on after room_deleted: %q", events.Text())
+		}
+	}
+	if err := events.Err(); err != nil {
+		t.Fatalf("event stream did not end after room_deleted: %v", err)
+	}
+}
+
+func TestEventStreamAccess(t *testing.T) {
+	ts := NewTestServer(t)
+	setAdminToken(t, "events-admin")
+	caller, callee := ts.Connect(), ts.Connect()
+	ts.startCall(caller, callee, "events-access")
+
+	for _, tt := range []struct {
+		query  url.Values
+		status int
+	}{
+		{url.Values{"callId": {"events-access"}}, http.StatusUnauthorized},
+		{url.Values{"callId": {"events-access"}, "token": {ts.Token("events-outsider", jwt.MapClaims{"rooms": []string{"elsewhere"}})}}, http.StatusForbidden},
+		{url.Values{"callId": {"events-missing"}, "token": {ts.Token("events-lost", jwt.MapClaims{"rooms": "*"})}}, http.StatusNotFound},
+		{url.Values{}, http.StatusUnauthorized},
+	} {
+		if resp := ts.openEventStream(tt.query); resp.StatusCode != tt.status {
+			t.Errorf("event stream for %v answered %d, want %d", tt.query, resp.StatusCode, tt.status)
+		}
+	}
+
+	req, err := http.NewRequest("GET", ts.URL()+"/ws-events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer events-admin")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("every-room stream with the admin token answered %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,105 @@
+package signaling_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"vc_server/signaling"
+)
+
+// Example_embedding serves the signaling protocol from a program's own HTTP handler,
+// putting every connection on /support into one room
+func Example_embedding() {
+	server := signaling.NewServer()
+	upgrader := websocket.Upgrader{}
+	added := make(chan error, 1)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/support", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		added <- server.AddClientToRoom("support-desk", conn)
+	})
+	// the rest of the API, without the bundled web client
+	mux.Handle("/", server.Handler(nil))
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/support", nil)
+	if err != nil {
+		fmt.Println("dial:", err)
+		return
+	}
+	defer conn.Close()
+	if err := <-added; err != nil {
+		fmt.Println("add:", err)
+		return
+	}
+
+	room, ok := server.GetRoom("support-desk")
+	fmt.Println(ok, room.CallID, len(room.ClientIDs), room.HostID == room.ClientIDs[0])
+
+	if err := server.RemoveRoom("support-desk"); err != nil {
+		fmt.Println("remove:", err)
+	}
+	_, ok = server.GetRoom("support-desk")
+	fmt.Println(ok)
+	// Output:
+	// true support-desk 1 true
+	// false
+}
+
+// ExampleServer_SetEventHooks only lets connections into rooms named team-*, and
+// hears about members leaving and rooms closing
+func ExampleServer_SetEventHooks() {
+	server := signaling.NewServer()
+	server.SetEventHooks(signaling.EventHooks{
+		OnRoomCreated: func(callID string) error {
+			if !strings.HasPrefix(callID, "team-") {
+				return fmt.Errorf("%s is not a team room", callID)
+			}
+			return nil
+		},
+		OnClientLeft: func(callID, clientID, reason string) {
+			fmt.Println("left", callID, reason)
+		},
+		OnRoomDeleted: func(callID string, duration time.Duration) {
+			fmt.Println("deleted", callID)
+		},
+	})
+	defer server.SetEventHooks(signaling.EventHooks{})
+
+	upgrader := websocket.Upgrader{}
+	added := make(chan error, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		added <- server.AddClientToRoom(r.URL.Query().Get("room"), conn)
+	}))
+	defer ts.Close()
+
+	for _, room := range []string{"general", "team-blue"} {
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/?room="+room, nil)
+		if err != nil {
+			fmt.Println("dial:", err)
+			return
+		}
+		defer conn.Close()
+		fmt.Println(room, <-added)
+	}
+	server.RemoveRoom("team-blue")
+	// Output:
+	// general general is not a team room
+	// team-blue <nil>
+	// left team-blue room_closed
+	// deleted team-blue
+}
@@ -0,0 +1,93 @@
+package signaling
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+)
+
+// FeatureFlags turns optional features on and off at runtime, every capability starts enabled
+var (
+	FeatureFlags   = initialFeatureFlags()
+	featureFlagsMu sync.RWMutex
+)
+
+// initialFeatureFlags enables every feature the server supports
+func initialFeatureFlags() map[string]bool {
+	flags := make(map[string]bool, len(serverCapabilities))
+	for _, feature := range serverCapabilities {
+		flags[feature] = true
+	}
+	return flags
+}
+
+// featureEnabled reports whether an operator has left the feature on
+func featureEnabled(feature string) bool {
+	featureFlagsMu.RLock()
+	defer featureFlagsMu.RUnlock()
+	return FeatureFlags[feature]
+}
+
+// enabledCapabilities lists the supported features that are currently enabled, sorted
+func enabledCapabilities() []string {
+	var enabled []string
+	for _, feature := range serverCapabilities {
+		if featureEnabled(feature) {
+			enabled = append(enabled, feature)
+		}
+	}
+	return enabled
+}
+
+// FeatureFlagRequest is the body of POST /api/v1/admin/feature-flags
+type FeatureFlagRequest struct {
+	Flag    string `json:"flag"`
+	Enabled *bool  `json:"enabled"`
+}
+
+// handleListFeatureFlags returns the current feature flags
+func handleListFeatureFlags(w http.ResponseWriter, r *http.Request) {
+	featureFlagsMu.RLock()
+	flags := make(map[string]bool, len(FeatureFlags))
+	for flag, enabled := range FeatureFlags {
+		flags[flag] = enabled
+	}
+	featureFlagsMu.RUnlock()
+	writeJSONResponse(w, http.StatusOK, flags)
+}
+
+// handleSetFeatureFlag turns a feature on or off and tells every client that supports it
+func handleSetFeatureFlag(w http.ResponseWriter, r *http.Request) {
+	var req FeatureFlagRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil || req.Enabled == nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	featureFlagsMu.Lock()
+	if _, ok := FeatureFlags[req.Flag]; !ok {
+		featureFlagsMu.Unlock()
+		http.Error(w, "Unknown feature flag", http.StatusBadRequest)
+		return
+	}
+	FeatureFlags[req.Flag] = *req.Enabled
+	featureFlagsMu.Unlock()
+
+	notified := 0
+	clients.Range(func(k, v interface{}) bool {
+		ws, client := k.(*wsConn), v.(*Client)
+		if !client.supports(req.Flag) {
+			return true
+		}
+		if err := ws.WriteJSON(Message{Type: "feature_flag_update", Flag: req.Flag, Enabled: req.Enabled}); err != nil {
+			log.Printf("Error sending feature_flag_update to %v: %v", ws.RemoteAddr(), err)
+			go cleanupClient(ws)
+			return true
+		}
+		notified++
+		return true
+	})
+	audit("feature_flag_updated", "", "", map[string]interface{}{"flag": req.Flag, "enabled": *req.Enabled})
+	log.Printf("Feature %s set to %t by %v, notified %d clients", req.Flag, *req.Enabled, r.RemoteAddr, notified)
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{"flag": req.Flag, "enabled": *req.Enabled})
+}
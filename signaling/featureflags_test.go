@@ -0,0 +1,122 @@
+package signaling
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// postFeatureFlag sends body to POST /api/v1/admin/feature-flags with the admin token and returns the response;
+// every flag is restored when the test ends
+func (ts *TestServer) postFeatureFlag(token, body string) *http.Response {
+	ts.t.Helper()
+	featureFlagsMu.RLock()
+	previous := make(map[string]bool, len(FeatureFlags))
+	for flag, enabled := range FeatureFlags {
+		previous[flag] = enabled
+	}
+	featureFlagsMu.RUnlock()
+	ts.t.Cleanup(func() {
+		featureFlagsMu.Lock()
+		FeatureFlags = previous
+		featureFlagsMu.Unlock()
+	})
+
+	req, err := http.NewRequest("POST", ts.URL()+"/api/v1/admin/feature-flags", strings.NewReader(body))
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	ts.t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestFeatureFlagToggledMidCall(t *testing.T) {
+	ts := NewTestServer(t)
+	setAdminToken(t, "flags-admin")
+	host, guest, pollOnly := ts.Connect(), ts.Connect(), ts.Connect()
+	ts.startCall(host, guest, "flags-call")
+	ts.Send(guest, Message{Type: "capabilities", Supported: []string{"reactions"}})
+	ts.AssertMessageReceived(guest, "capabilities", testTimeout)
+	ts.Send(pollOnly, Message{Type: "capabilities", Supported: []string{"poll"}})
+	ts.AssertMessageReceived(pollOnly, "capabilities", testTimeout)
+
+	ts.Send(host, Message{Type: "reaction", CallID: "flags-call", Emoji: "👍"})
+	ts.AssertMessageReceived(guest, "reaction", testTimeout)
+
+	if resp := ts.postFeatureFlag("flags-admin", `{"flag":"reactions","enabled":false}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("disabling reactions answered %d", resp.StatusCode)
+	}
+	// the host never declared capabilities, so it is told along with the guest that declared reactions
+	for _, conn := range []*websocket.Conn{host, guest} {
+		if msg := ts.AssertMessageReceived(conn, "feature_flag_update", testTimeout); msg.Flag != "reactions" || msg.Enabled == nil || *msg.Enabled {
+			t.Fatalf("feature_flag_update %+v", msg)
+		}
+	}
+	ts.RequireNoMessageOfType(pollOnly, "feature_flag_update", 50*time.Millisecond)
+
+	ts.Send(host, Message{Type: "reaction", CallID: "flags-call", Emoji: "🎉"})
+	ts.AssertError(host, "feature_disabled")
+	ts.RequireNoMessageOfType(guest, "reaction", 50*time.Millisecond)
+	ts.Send(guest, Message{Type: "capabilities", Supported: []string{"reactions"}})
+	if msg := ts.AssertMessageReceived(guest, "capabilities", testTimeout); strings.Contains(strings.Join(msg.Supported, ","), "reactions") {
+		t.Fatalf("server still offers reactions: %v", msg.Supported)
+	}
+	var flags map[string]bool
+	if err := json.NewDecoder(ts.adminRequest("GET", "/api/v1/admin/feature-flags", "flags-admin").Body).Decode(&flags); err != nil {
+		t.Fatal(err)
+	}
+	if flags["reactions"] || !flags["poll"] {
+		t.Fatalf("feature flags %v", flags)
+	}
+
+	ts.postFeatureFlag("flags-admin", `{"flag":"reactions","enabled":true}`)
+	ts.AssertMessageReceived(guest, "feature_flag_update", testTimeout)
+	ts.Send(host, Message{Type: "reaction", CallID: "flags-call", Emoji: "🔥"})
+	if msg := ts.AssertMessageReceived(guest, "reaction", testTimeout); msg.Emoji != "🔥" {
+		t.Fatalf("reaction after re-enabling %+v", msg)
+	}
+}
+
+func TestFeatureFlagRequests(t *testing.T) {
+	ts := NewTestServer(t)
+	setAdminToken(t, "flags-admin")
+	for _, tt := range []struct {
+		token, body string
+		status      int
+	}{
+		{"wrong-admin", `{"flag":"reactions","enabled":false}`, http.StatusUnauthorized},
+		{"flags-admin", `{"flag":"teleportation","enabled":true}`, http.StatusBadRequest},
+		{"flags-admin", `{"flag":"reactions"}`, http.StatusBadRequest},
+		{"flags-admin", `{`, http.StatusBadRequest},
+	} {
+		if resp := ts.postFeatureFlag(tt.token, tt.body); resp.StatusCode != tt.status {
+			t.Errorf("%s with %s answered %d, want %d", tt.body, tt.token, resp.StatusCode, tt.status)
+		}
+	}
+	if !featureEnabled("reactions") {
+		t.Fatal("a rejected request turned reactions off")
+	}
+}
+
+func TestLobbyFlagRefusesHostNotes(t *testing.T) {
+	ts := NewTestServer(t)
+	setAdminToken(t, "flags-admin")
+	host, waiter := ts.Connect(), ts.Connect()
+	ts.openLobbyRoom(host, "flags-lobby")
+	ts.enterLobby(waiter, "flags-lobby")
+
+	ts.postFeatureFlag("flags-admin", `{"flag":"lobby","enabled":false}`)
+	ts.AssertMessageReceived(host, "feature_flag_update", testTimeout)
+	ts.Send(host, Message{Type: "message_lobby", CallID: "flags-lobby", Data: "Starting shortly"})
+	ts.AssertError(host, "feature_disabled")
+	ts.RequireNoMessageOfType(waiter, "message_from_host", 50*time.Millisecond)
+}
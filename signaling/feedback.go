@@ -0,0 +1,104 @@
+package signaling
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"time"
+	"unicode/utf8"
+)
+
+// feedbackLogPath is the JSON-lines file feedback is stored in, in the audit log format
+var feedbackLogPath = envString("FEEDBACK_LOG_PATH", "feedback.jsonl")
+
+// Feedback is the details of a feedback AuditEvent
+type Feedback struct {
+	Rating  int    `json:"rating"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// requestFeedback asks everyone who took part in a closed room, and is still connected, to rate the call
+func requestFeedback(callID string, room *Room) {
+	duration := int(time.Since(room.createdAt).Seconds())
+	for participant := range room.participants {
+		client, ok := getClient(participant)
+		if !ok {
+			continue
+		}
+		client.mu.Lock()
+		client.feedbackPending[callID] = true
+		client.mu.Unlock()
+		if err := participant.WriteJSON(Message{
+			Type:                   "feedback_request",
+			CallID:                 callID,
+			SessionDurationSeconds: duration,
+		}); err != nil {
+			log.Printf("Error sending feedback_request to %v: %v", participant.RemoteAddr(), err)
+			go cleanupClient(participant)
+		}
+	}
+}
+
+// handleFeedback stores a client's rating of a call it was asked about, once per call
+func handleFeedback(sender *wsConn, msg Message) {
+	if msg.Rating < 1 || msg.Rating > 5 || utf8.RuneCountInString(msg.Comment) > 1000 {
+		sendError(sender, "invalid_feedback")
+		return
+	}
+	client, ok := getClient(sender)
+	if !ok {
+		return
+	}
+	client.mu.Lock()
+	pending := client.feedbackPending[msg.CallID]
+	delete(client.feedbackPending, msg.CallID)
+	client.mu.Unlock()
+	if !pending {
+		sendError(sender, "feedback_not_requested")
+		return
+	}
+
+	line, err := json.Marshal(AuditEvent{
+		Time:     time.Now().UTC(),
+		Event:    "feedback",
+		CallID:   msg.CallID,
+		ClientID: client.id,
+		Details:  Feedback{Rating: msg.Rating, Comment: msg.Comment},
+	})
+	if err != nil {
+		log.Printf("Error encoding feedback from %v: %v", sender.RemoteAddr(), err)
+		return
+	}
+	appendJSONLine(feedbackLogPath, line)
+}
+
+// handleListFeedback returns all stored feedback
+func handleListFeedback(w http.ResponseWriter, r *http.Request) {
+	jsonlMu.Lock()
+	defer jsonlMu.Unlock()
+	entries := []json.RawMessage{}
+	f, err := os.Open(feedbackLogPath)
+	if errors.Is(err, os.ErrNotExist) {
+		writeJSONResponse(w, http.StatusOK, entries)
+		return
+	}
+	if err != nil {
+		log.Printf("Error opening %s: %v", feedbackLogPath, err)
+		http.Error(w, "Feedback unavailable", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if json.Valid(scanner.Bytes()) {
+			entries = append(entries, append(json.RawMessage(nil), scanner.Bytes()...))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Printf("Error reading %s: %v", feedbackLogPath, err)
+	}
+	writeJSONResponse(w, http.StatusOK, entries)
+}
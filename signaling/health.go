@@ -0,0 +1,74 @@
+package signaling
+
+import (
+	"net/http"
+	"time"
+)
+
+// RoomHealth is the response of the room health check
+type RoomHealth struct {
+	Healthy      bool           `json:"healthy"`
+	CallID       string         `json:"callId"`
+	OfferExpired bool           `json:"offerExpired"` // an unanswered offer outlived OFFER_TTL_SECONDS; answered offers never expire
+	Clients      []ClientHealth `json:"clients"`
+}
+
+// ClientHealth describes one room member in a RoomHealth
+type ClientHealth struct {
+	ClientID                string  `json:"clientId"`
+	Healthy                 bool    `json:"healthy"`
+	PingRTTMs               int64   `json:"pingRttMs"`
+	SecondsSinceLastMessage float64 `json:"secondsSinceLastMessage"`
+	HighLatency             bool    `json:"highLatency"`
+}
+
+// handleRoomHealth reports whether a room's clients are responsive, for load balancer health checks
+func handleRoomHealth(w http.ResponseWriter, r *http.Request) {
+	callID := r.PathValue("callId")
+
+	room, unlock := rlockRoom(callID)
+	if room == nil {
+		http.Error(w, "Call not found", http.StatusNotFound)
+		return
+	}
+	health := RoomHealth{
+		Healthy:      true,
+		CallID:       callID,
+		OfferExpired: room.offerExpired(),
+		Clients:      []ClientHealth{},
+	}
+	members := make([]*wsConn, 0, len(room.clients))
+	for client := range room.clients {
+		members = append(members, client)
+	}
+	unlock()
+
+	for _, member := range members {
+		client, ok := getClient(member)
+		if !ok {
+			continue
+		}
+		client.mu.Lock()
+		ch := ClientHealth{
+			ClientID:                client.id,
+			PingRTTMs:               client.pongLatency.Milliseconds(),
+			SecondsSinceLastMessage: time.Since(client.lastMessageAt).Seconds(),
+			HighLatency:             client.highRTTCount >= highRTTStreak,
+		}
+		client.mu.Unlock()
+		ch.Healthy = !ch.HighLatency
+		if !ch.Healthy {
+			health.Healthy = false
+		}
+		health.Clients = append(health.Clients, ch)
+	}
+	if health.OfferExpired {
+		health.Healthy = false
+	}
+
+	status := http.StatusOK
+	if !health.Healthy {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSONResponse(w, status, health)
+}
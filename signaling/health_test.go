@@ -0,0 +1,61 @@
+package signaling
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// getRoomHealth fetches the health check of callID
+func getRoomHealth(t *testing.T, ts *TestServer, callID string) (int, RoomHealth) {
+	t.Helper()
+	resp, err := http.Get(ts.URL() + "/api/v1/rooms/" + callID + "/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var health RoomHealth
+	if resp.StatusCode != http.StatusNotFound {
+		if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode, health
+}
+
+func TestRoomHealthIgnoresAnsweredOffer(t *testing.T) {
+	ts := NewTestServer(t)
+	setOfferTTL(t, 50*time.Millisecond)
+	caller, callee := ts.Connect(), ts.Connect()
+	ts.startCall(caller, callee, "health-answered")
+	ts.Send(callee, Message{Type: "answer", CallID: "health-answered", Data: sdpData("answer")})
+	ts.AssertMessageReceived(caller, "answer", testTimeout)
+
+	time.Sleep(100 * time.Millisecond)
+	status, health := getRoomHealth(t, ts, "health-answered")
+	if status != http.StatusOK || !health.Healthy || health.OfferExpired {
+		t.Fatalf("got %d %+v for an answered call, want a healthy room", status, health)
+	}
+}
+
+func TestRoomHealthReportsExpiredOffer(t *testing.T) {
+	ts := NewTestServer(t)
+	setOfferTTL(t, 50*time.Millisecond)
+	caller := ts.Connect()
+	ts.Send(caller, Message{Type: "offer", CallID: "health-stale", Data: sdpData("offer")})
+	ts.waitForRoom("health-stale", 1)
+
+	time.Sleep(100 * time.Millisecond)
+	status, health := getRoomHealth(t, ts, "health-stale")
+	if status != http.StatusServiceUnavailable || !health.OfferExpired {
+		t.Fatalf("got %d %+v for an unanswered stale offer, want 503 with offerExpired", status, health)
+	}
+}
+
+func TestRoomHealthUnknownRoom(t *testing.T) {
+	ts := NewTestServer(t)
+	if status, _ := getRoomHealth(t, ts, "health-missing"); status != http.StatusNotFound {
+		t.Fatalf("got %d for a missing room, want 404", status)
+	}
+}
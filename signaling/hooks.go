@@ -0,0 +1,92 @@
+package signaling
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// EventHooks are callbacks a program embedding the server can set to add its own room rules.
+// OnRoomCreated and OnClientJoined run while the room is locked, before the change takes effect,
+// and reject it with an error message to the client when they return an error; they must not
+// call back into the Server. OnClientLeft and OnRoomDeleted run afterwards with no locks held.
+type EventHooks struct {
+	OnRoomCreated  func(callID string) error
+	OnClientJoined func(callID, clientID string) error
+	OnClientLeft   func(callID, clientID, reason string)
+	OnRoomDeleted  func(callID string, duration time.Duration)
+}
+
+// eventHooks are the hooks installed with SetEventHooks, nil when there are none
+var eventHooks atomic.Pointer[EventHooks]
+
+// SetEventHooks installs hooks for every room, replacing any set before
+func (s *Server) SetEventHooks(hooks EventHooks) {
+	eventHooks.Store(&hooks)
+}
+
+// departure is a member leaving a room, reported to OnClientLeft once locks are released
+type departure struct {
+	callID   string
+	clientID string
+	reason   string
+}
+
+// admitToRoom checks that conn's token grants the room for callID, that the room has space for it under both
+// its MaxClients and conn's license, that conn and the host have not blocked each other and, in lobby mode,
+// that the host or a co-host admitted conn, then runs the creation and join hooks.
+// Every path into a room goes through it.
+// Callers hold the room lock, and roomsMu exclusively when created is true; a room that
+// was just created is deleted again when conn is refused.
+func admitToRoom(callID string, room *Room, created bool, conn *wsConn) error {
+	var err error
+	client, ok := getClient(conn)
+	var license License
+	if ok {
+		license = client.License
+	}
+	if ok && !client.claims().allows(callID) {
+		err = errRoomForbidden
+	} else if !room.clients[conn] && room.fullFor(license) {
+		err = errRoomFull
+	} else if !room.clients[conn] && room.host != nil && room.host != conn && blocks(room.host, conn) {
+		err = errBlocked
+	} else if !room.clients[conn] && room.options.Lobby && room.host != nil && !room.admitted[conn] {
+		err = errInLobby
+	}
+	hooks := eventHooks.Load()
+	if hooks == nil {
+		hooks = &EventHooks{}
+	}
+	if err == nil && created && hooks.OnRoomCreated != nil {
+		err = hooks.OnRoomCreated(callID)
+	}
+	if err == nil && !room.clients[conn] && hooks.OnClientJoined != nil {
+		err = hooks.OnClientJoined(callID, clientID(conn))
+	}
+	if err != nil && created {
+		delete(rooms, callID)
+	}
+	return err
+}
+
+// reportDepartures passes members that left rooms to OnClientLeft
+func reportDepartures(departures ...departure) {
+	hooks := eventHooks.Load()
+	if hooks == nil || hooks.OnClientLeft == nil {
+		return
+	}
+	for _, d := range departures {
+		hooks.OnClientLeft(d.callID, d.clientID, d.reason)
+	}
+}
+
+// reportRoomDeleted ends the deleted room's event streams, drops it from the tag counts and passes the room and how long it existed to OnRoomDeleted
+func reportRoomDeleted(callID string, room *Room) {
+	closeEventStreams(callID)
+	untagRoom(room)
+	hooks := eventHooks.Load()
+	if hooks == nil || hooks.OnRoomDeleted == nil {
+		return
+	}
+	hooks.OnRoomDeleted(callID, time.Since(room.createdAt))
+}
@@ -0,0 +1,62 @@
+package signaling
+
+import (
+	"embed"
+	"encoding/json"
+	"log"
+	"path"
+	"strings"
+)
+
+//go:embed i18n/*.json
+var translationFiles embed.FS
+
+// defaultLanguage is used for clients that have not registered a language we translate to
+const defaultLanguage = "en"
+
+// translations maps a language to the text of each error code, loaded from i18n/<lang>.json
+var translations = loadTranslations()
+
+// loadTranslations reads every embedded translation file
+func loadTranslations() map[string]map[string]string {
+	files, err := translationFiles.ReadDir("i18n")
+	if err != nil {
+		log.Fatalf("Embedded translations missing: %v", err)
+	}
+	all := make(map[string]map[string]string, len(files))
+	for _, f := range files {
+		data, err := translationFiles.ReadFile(path.Join("i18n", f.Name()))
+		if err != nil {
+			log.Fatalf("Reading translations %s failed: %v", f.Name(), err)
+		}
+		texts := make(map[string]string)
+		if err := json.Unmarshal(data, &texts); err != nil {
+			log.Fatalf("Parsing translations %s failed: %v", f.Name(), err)
+		}
+		all[strings.TrimSuffix(f.Name(), ".json")] = texts
+	}
+	return all
+}
+
+// translate returns the text for code in lang, trying the full tag, then its primary language,
+// then English, and finally the code itself
+func translate(lang, code string) string {
+	candidates := []string{lang}
+	if base, _, ok := strings.Cut(lang, "-"); ok {
+		candidates = append(candidates, base)
+	}
+	candidates = append(candidates, defaultLanguage)
+	for _, l := range candidates {
+		if text, ok := translations[strings.ToLower(l)][code]; ok {
+			return text
+		}
+	}
+	return code
+}
+
+// language returns the client's registered language, or "" if it never sent one
+func (c *Client) language() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lang
+}
@@ -0,0 +1,73 @@
+package signaling
+
+import (
+	"testing"
+)
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		lang, code, want string
+	}{
+		{"en", "not_host", "Only the host can do that"},
+		{"es", "not_host", "Solo el anfitrión puede hacer eso"},
+		{"fr", "missing_call_id", "Aucun appel n'a été indiqué"},
+		{"de", "not_host", "Nur der Gastgeber kann das tun"},
+		{"ja", "not_host", "この操作はホストのみ行えます"},
+		{"zh", "not_host", "只有主持人可以执行此操作"},
+		{"es-MX", "not_host", "Solo el anfitrión puede hacer eso"},
+		{"zh-Hans-CN", "missing_call_id", "未指定通话"},
+		{"FR", "not_host", "Seul l'hôte peut faire cela"},
+		{"pt-BR", "not_host", "Only the host can do that"},
+		{"", "not_host", "Only the host can do that"},
+		{"es", "no_such_code", "no_such_code"},
+	}
+	for _, tt := range tests {
+		if got := translate(tt.lang, tt.code); got != tt.want {
+			t.Errorf("translate(%q, %q) = %q, want %q", tt.lang, tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestTranslationsCoverEveryCode(t *testing.T) {
+	for _, lang := range []string{"en", "es", "fr", "de", "ja", "zh"} {
+		if _, ok := translations[lang]; !ok {
+			t.Fatalf("no translations for %s", lang)
+		}
+	}
+	for lang, texts := range translations {
+		for code := range translations[defaultLanguage] {
+			if texts[code] == "" {
+				t.Errorf("%s has no text for %s", lang, code)
+			}
+		}
+		for code := range texts {
+			if _, ok := translations[defaultLanguage][code]; !ok {
+				t.Errorf("%s translates %s, which English does not have", lang, code)
+			}
+		}
+	}
+}
+
+func TestErrorsInRegisteredLanguage(t *testing.T) {
+	ts := NewTestServer(t)
+	conn := ts.Connect()
+
+	ts.Send(conn, Message{Type: "hangup"})
+	if msg := ts.AssertMessageReceived(conn, "error", testTimeout); msg.Data != "No call was specified" {
+		t.Fatalf("unregistered client got %q", msg.Data)
+	}
+
+	ts.Send(conn, Message{Type: "register", Language: "fr-CA"})
+	ts.Send(conn, Message{Type: "hangup"})
+	if msg := ts.AssertMessageReceived(conn, "error", testTimeout); msg.Code != "missing_call_id" || msg.Data != "Aucun appel n'a été indiqué" {
+		t.Fatalf("fr-CA client got %+v", msg)
+	}
+
+	ts.Send(conn, Message{Type: "register", Language: "en_US!"})
+	ts.AssertError(conn, "invalid_language")
+	ts.Send(conn, Message{Type: "register", Language: "pt-BR"})
+	ts.Send(conn, Message{Type: "hangup"})
+	if msg := ts.AssertMessageReceived(conn, "error", testTimeout); msg.Data != "No call was specified" {
+		t.Fatalf("pt-BR client got %q, want the English fallback", msg.Data)
+	}
+}
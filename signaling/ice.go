@@ -0,0 +1,135 @@
+package signaling
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ICE server configuration; TURN credentials follow the TURN REST API scheme shared with coturn's use-auth-secret
+var (
+	stunURLs      = envList("STUN_URLS")
+	turnURLs      = envList("TURN_URLS")
+	turnSecret    = envString("TURN_SECRET", "")
+	turnCredTTL   = time.Duration(envInt("TURN_CREDENTIAL_TTL_SECONDS", 86400)) * time.Second
+	iceRefreshGap = 5 * time.Minute
+
+	// turnRefreshWindow is how close to expiry a client's TURN credentials are pushed fresh ones, at most half their lifetime
+	turnRefreshWindow = min(time.Duration(envInt("TURN_CREDENTIAL_REFRESH_WINDOW", 300))*time.Second, turnCredTTL/2)
+)
+
+// ICEServer is one entry of an RTCPeerConnection iceServers list
+type ICEServer struct {
+	URLs       []string `json:"urls"`
+	Username   string   `json:"username,omitempty"`
+	Credential string   `json:"credential,omitempty"`
+}
+
+// issuesTURNCredentials reports whether ICE servers carry time-limited TURN credentials
+func issuesTURNCredentials() bool {
+	return len(turnURLs) > 0 && turnSecret != ""
+}
+
+// iceServers returns the configured ICE servers with TURN credentials issued to clientID until expires
+func iceServers(clientID string, expires time.Time) []ICEServer {
+	servers := []ICEServer{}
+	if len(stunURLs) > 0 {
+		servers = append(servers, ICEServer{URLs: stunURLs})
+	}
+	if len(turnURLs) > 0 {
+		username, credential := turnCredentials(clientID, expires)
+		servers = append(servers, ICEServer{URLs: turnURLs, Username: username, Credential: credential})
+	}
+	return servers
+}
+
+// turnCredentials derives a time-limited TURN username and password, "<expiry>:<clientID>" signed with TURN_SECRET
+func turnCredentials(clientID string, expires time.Time) (string, string) {
+	if turnSecret == "" {
+		return "", ""
+	}
+	username := strconv.FormatInt(expires.Unix(), 10)
+	if clientID != "" {
+		username += ":" + clientID
+	}
+	mac := hmac.New(sha1.New, []byte(turnSecret))
+	mac.Write([]byte(username))
+	return username, base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// handleICEServers returns the ICE servers for a client about to set up a peer connection
+func handleICEServers(w http.ResponseWriter, r *http.Request) {
+	clientID := r.URL.Query().Get("clientId")
+	if len(clientID) > 64 {
+		http.Error(w, "Invalid clientId", http.StatusBadRequest)
+		return
+	}
+	var servers []ICEServer
+	if client := findClient(clientID); client != nil && clientID != "" {
+		servers = client.issueICEServers()
+	} else {
+		servers = iceServers(clientID, time.Now().Add(turnCredTTL))
+	}
+	writeJSONResponse(w, http.StatusOK, map[string][]ICEServer{"iceServers": servers})
+}
+
+// issueICEServers returns ICE servers with fresh TURN credentials for the client, remembering when they expire
+func (c *Client) issueICEServers() []ICEServer {
+	expires := time.Now().Add(turnCredTTL)
+	if issuesTURNCredentials() {
+		c.mu.Lock()
+		c.turnExpiry = expires
+		c.mu.Unlock()
+	}
+	return iceServers(c.id, expires)
+}
+
+// handleRefreshICEServers sends a client fresh ICE servers so long calls outlive their TURN credentials
+func handleRefreshICEServers(sender *wsConn, msg Message) {
+	if !allowMessage(sender, "refresh_ice_servers", 1, iceRefreshGap) {
+		return
+	}
+	client, ok := getClient(sender)
+	if !ok {
+		return
+	}
+	if err := sender.WriteJSON(Message{Type: "ice_servers", Servers: client.issueICEServers()}); err != nil {
+		log.Printf("Error sending ice_servers to %v: %v", sender.RemoteAddr(), err)
+		go cleanupClient(sender)
+		return
+	}
+	audit("ice_servers_refreshed", msg.CallID, client.id, nil)
+}
+
+// refreshExpiringTURNCredentials runs refreshTURNPass once a minute
+func refreshExpiringTURNCredentials() {
+	for range time.Tick(time.Minute) {
+		refreshTURNPass()
+	}
+}
+
+// refreshTURNPass pushes ice_servers with fresh TURN credentials to every client whose credentials expire
+// within turnRefreshWindow, so calls outlive them without the client having to ask
+func refreshTURNPass() {
+	deadline := time.Now().Add(turnRefreshWindow)
+	clients.Range(func(_, v interface{}) bool {
+		client := v.(*Client)
+		client.mu.Lock()
+		expiry := client.turnExpiry
+		client.mu.Unlock()
+		if expiry.IsZero() || expiry.After(deadline) {
+			return true
+		}
+		if err := client.conn.WriteJSON(Message{Type: "ice_servers", Servers: client.issueICEServers()}); err != nil {
+			log.Printf("Error pushing ice_servers to %v: %v", client.conn.RemoteAddr(), err)
+			go cleanupClient(client.conn)
+			return true
+		}
+		audit("ice_servers_refreshed", "", client.id, map[string]interface{}{"pushed": true})
+		return true
+	})
+}
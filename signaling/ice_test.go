@@ -0,0 +1,52 @@
+package signaling
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setTURN configures TURN_URLS, TURN_SECRET and TURN_CREDENTIAL_TTL_SECONDS for the rest of the test
+func setTURN(t *testing.T, ttl time.Duration) {
+	urls, secret, previousTTL, window := turnURLs, turnSecret, turnCredTTL, turnRefreshWindow
+	turnURLs, turnSecret, turnCredTTL, turnRefreshWindow = []string{"turn:turn.example.com:3478"}, "turn-secret", ttl, ttl/2
+	t.Cleanup(func() { turnURLs, turnSecret, turnCredTTL, turnRefreshWindow = urls, secret, previousTTL, window })
+}
+
+// turnExpiryOf returns the expiry encoded in the TURN username of an ice_servers message
+func turnExpiryOf(t *testing.T, msg Message) time.Time {
+	t.Helper()
+	for _, server := range msg.Servers {
+		if server.Username != "" {
+			unix, err := strconv.ParseInt(strings.SplitN(server.Username, ":", 2)[0], 10, 64)
+			if err != nil {
+				t.Fatalf("TURN username %q: %v", server.Username, err)
+			}
+			return time.Unix(unix, 0)
+		}
+	}
+	t.Fatalf("ice_servers %+v has no TURN credentials", msg.Servers)
+	return time.Time{}
+}
+
+func TestExpiringTURNCredentialsPushed(t *testing.T) {
+	ts := NewTestServer(t)
+	setTURN(t, 10*time.Minute)
+	issued, neverAsked := ts.ConnectWithClientID("turn-issued"), ts.Connect()
+
+	ts.Send(issued, Message{Type: "refresh_ice_servers"})
+	first := turnExpiryOf(t, ts.AssertMessageReceived(issued, "ice_servers", testTimeout))
+	refreshTURNPass()
+	ts.RequireNoMessageOfType(issued, "ice_servers", 50*time.Millisecond)
+
+	client := findClient("turn-issued")
+	client.mu.Lock()
+	client.turnExpiry = time.Now().Add(time.Minute)
+	client.mu.Unlock()
+	refreshTURNPass()
+	if pushed := turnExpiryOf(t, ts.AssertMessageReceived(issued, "ice_servers", testTimeout)); pushed.Before(first) {
+		t.Fatalf("pushed credentials expire at %v, before the first ones at %v", pushed, first)
+	}
+	ts.RequireNoMessageOfType(neverAsked, "ice_servers", 50*time.Millisecond)
+}
@@ -0,0 +1,66 @@
+package signaling
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+)
+
+// allowICEInjection enables inject_ice_candidate, for test harnesses only; never enable it in production
+var allowICEInjection = envString("ALLOW_ICE_INJECTION", "false") == "true"
+
+// injectedCandidate is the RTCIceCandidateInit an injected candidate is delivered as, in the data of an ice-candidate
+type injectedCandidate struct {
+	Candidate     string  `json:"candidate"`
+	SDPMid        *string `json:"sdpMid"`
+	SDPMLineIndex *int    `json:"sdpMLineIndex"`
+}
+
+// validInjectedCandidate reports whether msg carries a candidate line and at least one way to match it to a media section
+func validInjectedCandidate(msg Message) bool {
+	if !strings.HasPrefix(msg.Candidate, "candidate:") || len(msg.Candidate) > 1024 || len(msg.SDPMid) > 32 {
+		return false
+	}
+	if msg.SDPMLineIndex != nil && (*msg.SDPMLineIndex < 0 || *msg.SDPMLineIndex > 255) {
+		return false
+	}
+	return msg.SDPMid != "" || msg.SDPMLineIndex != nil
+}
+
+// handleInjectICECandidate delivers a synthetic ICE candidate to one room member as an ordinary ice-candidate,
+// so tests can drive ICE negotiation without real network interfaces
+func handleInjectICECandidate(sender *wsConn, msg Message) {
+	if !allowICEInjection {
+		sendError(sender, "ice_injection_disabled")
+		return
+	}
+	if !validInjectedCandidate(msg) {
+		sendError(sender, "invalid_ice_candidate")
+		return
+	}
+	if !allowMessage(sender, "inject_ice_candidate", 50, time.Second) {
+		return
+	}
+	target := findRoomMember(msg.CallID, sender, msg.To)
+	if target == nil {
+		sendError(sender, "peer_not_found")
+		return
+	}
+
+	candidate := injectedCandidate{Candidate: msg.Candidate, SDPMLineIndex: msg.SDPMLineIndex}
+	if msg.SDPMid != "" {
+		candidate.SDPMid = &msg.SDPMid
+	}
+	data, err := json.Marshal(candidate)
+	if err != nil {
+		log.Printf("Error encoding injected ICE candidate: %v", err)
+		return
+	}
+	if err := target.WriteJSON(Message{Type: "ice-candidate", CallID: msg.CallID, From: clientID(sender), Data: string(data)}); err != nil {
+		log.Printf("Error sending injected ICE candidate to %v: %v", target.RemoteAddr(), err)
+		go cleanupClient(target)
+		return
+	}
+	audit("ice_candidate_injected", msg.CallID, clientID(sender), map[string]interface{}{"to": msg.To})
+}
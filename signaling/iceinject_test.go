@@ -0,0 +1,91 @@
+package signaling
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// setICEInjection turns ALLOW_ICE_INJECTION on or off for the rest of the test
+func setICEInjection(t *testing.T, allow bool) {
+	previous := allowICEInjection
+	allowICEInjection = allow
+	t.Cleanup(func() { allowICEInjection = previous })
+}
+
+func TestInjectedICEExchange(t *testing.T) {
+	setICEInjection(t, true)
+	ts := NewTestServer(t)
+	caller, callee := ts.ConnectWithClientID("inject-caller"), ts.ConnectWithClientID("inject-callee")
+	ts.startCall(caller, callee, "inject-call")
+	ts.Send(callee, Message{Type: "answer", CallID: "inject-call", Data: sdpData("answer")})
+	ts.AssertMessageReceived(caller, "answer", testTimeout)
+	harness := ts.ConnectWithClientID("inject-harness")
+	ts.Send(harness, Message{Type: "join_call", CallID: "inject-call"})
+	ts.AssertMessageReceived(harness, "call_joined", testTimeout)
+
+	// the harness plays the network for both ends: a host and a server reflexive candidate per side, one matched
+	// to its media section by mid and one by line index
+	zero, one := 0, 1
+	exchange := []struct {
+		to        string
+		conn      *websocket.Conn
+		candidate string
+		mid       string
+		index     *int
+	}{
+		{"inject-callee", callee, "candidate:1 1 udp 2122260223 192.0.2.10 50000 typ host", "0", nil},
+		{"inject-caller", caller, "candidate:1 1 udp 2122260223 192.0.2.20 50001 typ host", "0", &zero},
+		{"inject-callee", callee, "candidate:2 1 udp 1686052607 203.0.113.10 60000 typ srflx raddr 192.0.2.10 rport 50000", "", &one},
+		{"inject-caller", caller, "candidate:2 1 udp 1686052607 203.0.113.20 60001 typ srflx raddr 192.0.2.20 rport 50001", "1", nil},
+	}
+	for _, step := range exchange {
+		ts.Send(harness, Message{Type: "inject_ice_candidate", CallID: "inject-call", To: step.to, Candidate: step.candidate, SDPMid: step.mid, SDPMLineIndex: step.index})
+		msg := ts.AssertMessageReceived(step.conn, "ice-candidate", testTimeout)
+		if msg.From != "inject-harness" || msg.CallID != "inject-call" {
+			t.Fatalf("%s got ice-candidate %+v", step.to, msg)
+		}
+		var init injectedCandidate
+		if err := json.Unmarshal([]byte(msg.Data), &init); err != nil {
+			t.Fatalf("ice-candidate data %q: %v", msg.Data, err)
+		}
+		if init.Candidate != step.candidate || (step.mid == "") != (init.SDPMid == nil) || (step.index == nil) != (init.SDPMLineIndex == nil) {
+			t.Fatalf("%s got candidate %s", step.to, msg.Data)
+		}
+		if init.SDPMid != nil && *init.SDPMid != step.mid || init.SDPMLineIndex != nil && *init.SDPMLineIndex != *step.index {
+			t.Fatalf("%s got candidate %s", step.to, msg.Data)
+		}
+	}
+	// each candidate went to its target only
+	ts.RequireNoMessageOfType(caller, "ice-candidate", 50*time.Millisecond)
+	ts.RequireNoMessageOfType(callee, "ice-candidate", 50*time.Millisecond)
+
+	ts.Send(harness, Message{Type: "inject_ice_candidate", CallID: "inject-call", To: "inject-outsider", Candidate: exchange[0].candidate, SDPMid: "0"})
+	ts.AssertError(harness, "peer_not_found")
+}
+
+func TestInjectedICECandidateRules(t *testing.T) {
+	setICEInjection(t, true)
+	ts := NewTestServer(t)
+	caller, callee := ts.Connect(), ts.ConnectWithClientID("inject-rules-callee")
+	ts.startCall(caller, callee, "inject-rules")
+
+	outOfRange := 256
+	for _, msg := range []Message{
+		{Candidate: "a=candidate:1 1 udp 1 192.0.2.1 5000 typ host", SDPMid: "0"},
+		{Candidate: "candidate:1 1 udp 1 192.0.2.1 5000 typ host"},
+		{Candidate: "candidate:1 1 udp 1 192.0.2.1 5000 typ host", SDPMLineIndex: &outOfRange},
+	} {
+		msg.Type, msg.CallID, msg.To = "inject_ice_candidate", "inject-rules", "inject-rules-callee"
+		ts.Send(caller, msg)
+		ts.AssertError(caller, "invalid_ice_candidate")
+	}
+	ts.RequireNoMessageOfType(callee, "ice-candidate", 50*time.Millisecond)
+
+	setICEInjection(t, false)
+	ts.Send(caller, Message{Type: "inject_ice_candidate", CallID: "inject-rules", To: "inject-rules-callee", Candidate: "candidate:1 1 udp 1 192.0.2.1 5000 typ host", SDPMid: "0"})
+	ts.AssertError(caller, "ice_injection_disabled")
+	ts.RequireNoMessageOfType(callee, "ice-candidate", 50*time.Millisecond)
+}
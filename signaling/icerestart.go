@@ -0,0 +1,44 @@
+package signaling
+
+import (
+	"log"
+	"time"
+)
+
+// maxICERestarts is how many ICE restarts one room may go through before ice_failed is refused
+var maxICERestarts = envInt("MAX_ICE_RESTARTS", 3)
+
+// handleICEFailed starts an ICE restart when a member reports its connection failed:
+// the stored offer is dropped and every member is told to renegotiate, the next offer being relayed to the room
+func handleICEFailed(sender *wsConn, msg Message) {
+	if !allowMessage(sender, "ice_failed", 1, 5*time.Second) {
+		return
+	}
+	room, unlock := lockRoom(msg.CallID)
+	if room == nil || !room.clients[sender] {
+		if room != nil {
+			unlock()
+		}
+		sendError(sender, "not_in_call")
+		return
+	}
+	if room.iceRestarts >= maxICERestarts {
+		unlock()
+		log.Printf("Refused ICE restart in room %s from %v: limit of %d reached", msg.CallID, sender.RemoteAddr(), maxICERestarts)
+		sendError(sender, "ice_restart_limit")
+		return
+	}
+	room.iceRestarts++
+	attempt := room.iceRestarts
+	members := len(room.clients)
+	room.offer = nil
+	room.restartPending = true
+	room.offerExpiresAt = time.Time{}
+	if offerDeadlineAfter > 0 {
+		room.offerDeadline = time.Now().Add(offerDeadlineAfter)
+	}
+	unlock()
+
+	log.Printf("ICE restart %d/%d in room %s with %d members, reported by %v", attempt, maxICERestarts, msg.CallID, members, sender.RemoteAddr())
+	broadcastToRoom(sender, Message{Type: "ice_restart", CallID: msg.CallID})
+}
@@ -0,0 +1,40 @@
+package signaling
+
+import "testing"
+
+// setMaxICERestarts replaces MAX_ICE_RESTARTS for the rest of the test
+func setMaxICERestarts(t *testing.T, limit int) {
+	previous := maxICERestarts
+	maxICERestarts = limit
+	t.Cleanup(func() { maxICERestarts = previous })
+}
+
+func TestICEFailedRestartsRoom(t *testing.T) {
+	ts := NewTestServer(t)
+	caller, callee := ts.Connect(), ts.Connect()
+	ts.startCall(caller, callee, "ice-restart")
+
+	ts.Send(callee, Message{Type: "ice_failed", CallID: "ice-restart"})
+	ts.AssertMessageReceived(caller, "ice_restart", testTimeout)
+	ts.AssertMessageReceived(callee, "ice_restart", testTimeout)
+
+	ts.Send(caller, Message{Type: "offer", CallID: "ice-restart", Data: sdpData("restart-offer")})
+	if msg := ts.AssertMessageReceived(callee, "offer", testTimeout); msg.Data != sdpData("restart-offer") {
+		t.Fatalf("restart offer relayed as %+v", msg)
+	}
+}
+
+func TestICERestartRules(t *testing.T) {
+	ts := NewTestServer(t)
+	setMaxICERestarts(t, 1)
+	caller, callee, outsider := ts.Connect(), ts.Connect(), ts.Connect()
+	ts.startCall(caller, callee, "ice-rules")
+
+	ts.Send(outsider, Message{Type: "ice_failed", CallID: "ice-rules"})
+	ts.AssertError(outsider, "not_in_call")
+
+	ts.Send(callee, Message{Type: "ice_failed", CallID: "ice-rules"})
+	ts.AssertMessageReceived(caller, "ice_restart", testTimeout)
+	ts.Send(caller, Message{Type: "ice_failed", CallID: "ice-rules"})
+	ts.AssertError(caller, "ice_restart_limit")
+}
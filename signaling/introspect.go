@@ -0,0 +1,144 @@
+package signaling
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OAuth2 token introspection (RFC 7662) configuration; when the URL is set, connection tokens are checked with it instead of as JWTs
+var (
+	introspectURL          = envString("OAUTH2_INTROSPECT_URL", "")
+	introspectClientID     = envString("OAUTH2_CLIENT_ID", "")
+	introspectClientSecret = envString("OAUTH2_CLIENT_SECRET", "")
+	introspectCacheTTL     = time.Duration(envInt("INTROSPECT_CACHE_TTL", 60)) * time.Second
+)
+
+// introspectTimeout bounds one call to the introspection endpoint
+const introspectTimeout = 5 * time.Second
+
+// introspectClient calls the introspection endpoint
+var introspectClient = &http.Client{Timeout: introspectTimeout}
+
+// introspectionCache holds active introspection results by token hash until they expire
+var introspectionCache sync.Map
+
+// cachedIntrospection is an active token's claims and when the cache stops trusting them
+type cachedIntrospection struct {
+	claims  *AuthClaims
+	expires time.Time
+}
+
+// introspectionResponse is the part of an RFC 7662 introspection response the server uses
+type introspectionResponse struct {
+	Active bool   `json:"active"`
+	Sub    string `json:"sub"`
+	Scope  string `json:"scope"`
+	Exp    int64  `json:"exp"`
+}
+
+// introspect checks a token with the introspection endpoint, answering from the cache while a result is fresh
+func introspect(token string) (*AuthClaims, error) {
+	sum := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(sum[:])
+	now := time.Now()
+	if v, ok := introspectionCache.Load(key); ok {
+		cached := v.(cachedIntrospection)
+		if now.Before(cached.expires) {
+			return cached.claims, nil
+		}
+		introspectionCache.Delete(key)
+	}
+
+	resp, err := requestIntrospection(token)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Active {
+		return nil, errors.New("token is not active")
+	}
+	if resp.Sub == "" || len(resp.Sub) > 64 {
+		return nil, errors.New("token has no valid sub")
+	}
+	claims := claimsFromScope(resp.Sub, resp.Scope)
+	expires := now.Add(introspectCacheTTL)
+	if resp.Exp > 0 {
+		claims.ExpiresAt = time.Unix(resp.Exp, 0)
+		if !now.Before(claims.ExpiresAt) {
+			return nil, errors.New("token is expired")
+		}
+		if claims.ExpiresAt.Before(expires) {
+			expires = claims.ExpiresAt
+		}
+	}
+	introspectionCache.Store(key, cachedIntrospection{claims: claims, expires: expires})
+	return claims, nil
+}
+
+// requestIntrospection POSTs the token to the introspection endpoint, authenticating with the client credentials when set
+func requestIntrospection(token string) (*introspectionResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), introspectTimeout)
+	defer cancel()
+	form := url.Values{"token": {token}, "token_type_hint": {"access_token"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, introspectURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if introspectClientID != "" {
+		req.SetBasicAuth(introspectClientID, introspectClientSecret)
+	}
+	res, err := introspectClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("introspection request failed: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("introspection endpoint returned %s", res.Status)
+	}
+	var resp introspectionResponse
+	if err := json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("decoding introspection response: %w", err)
+	}
+	return &resp, nil
+}
+
+// claimsFromScope builds claims from an introspected token: "room:<callId>" and "room:*" scopes grant rooms,
+// a "role:<role>" scope sets the role, and a token with no room scopes may enter any room
+func claimsFromScope(sub, scope string) *AuthClaims {
+	claims := &AuthClaims{Subject: sub}
+	for _, s := range strings.Fields(scope) {
+		if room, ok := strings.CutPrefix(s, "room:"); ok && room != "" {
+			claims.Rooms = append(claims.Rooms, room)
+		} else if role, ok := strings.CutPrefix(s, "role:"); ok {
+			claims.Role = role
+		}
+	}
+	if claims.Rooms == nil {
+		claims.Rooms = []string{"*"}
+	}
+	return claims
+}
+
+// sweepIntrospectionCache drops expired introspection results so tokens seen once do not stay cached
+func sweepIntrospectionCache() {
+	for range time.Tick(time.Minute) {
+		now := time.Now()
+		introspectionCache.Range(func(k, v interface{}) bool {
+			if !now.Before(v.(cachedIntrospection).expires) {
+				introspectionCache.Delete(k)
+			}
+			return true
+		})
+	}
+}
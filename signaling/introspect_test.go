@@ -0,0 +1,137 @@
+package signaling
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+// introspectionEndpoint is a mock RFC 7662 endpoint answering for a fixed set of tokens and counting calls per token
+type introspectionEndpoint struct {
+	mu        sync.Mutex
+	responses map[string]introspectionResponse
+	calls     map[string]int
+}
+
+// setIntrospection checks connection tokens against a mock introspection endpoint for the rest of the test
+func setIntrospection(t *testing.T, responses map[string]introspectionResponse) *introspectionEndpoint {
+	endpoint := &introspectionEndpoint{responses: responses, calls: make(map[string]int)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id, secret, ok := r.BasicAuth(); !ok || id != "signaling" || secret != "introspect-secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		token := r.PostFormValue("token")
+		endpoint.mu.Lock()
+		endpoint.calls[token]++
+		resp, ok := endpoint.responses[token]
+		endpoint.mu.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+
+	previousURL, previousID, previousSecret, previousTTL := introspectURL, introspectClientID, introspectClientSecret, introspectCacheTTL
+	introspectURL, introspectClientID, introspectClientSecret = server.URL, "signaling", "introspect-secret"
+	t.Cleanup(func() {
+		introspectURL, introspectClientID, introspectClientSecret, introspectCacheTTL = previousURL, previousID, previousSecret, previousTTL
+		introspectionCache.Range(func(k, _ interface{}) bool {
+			introspectionCache.Delete(k)
+			return true
+		})
+	})
+	return endpoint
+}
+
+// callsFor returns how many times token has been introspected
+func (e *introspectionEndpoint) callsFor(token string) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.calls[token]
+}
+
+// awaitDisconnect waits until the client with id has been cleaned up
+func awaitDisconnect(t *testing.T, id string) {
+	t.Helper()
+	for deadline := time.Now().Add(testTimeout); findClient(id) != nil; time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("client %s never cleaned up", id)
+		}
+	}
+}
+
+func TestIntrospectedBearerToken(t *testing.T) {
+	ts := NewTestServer(t)
+	endpoint := setIntrospection(t, map[string]introspectionResponse{
+		"opaque-alice": {Active: true, Sub: "oauth-alice", Scope: "room:oauth-call role:agent", Exp: time.Now().Add(time.Hour).Unix()},
+	})
+	host := ts.DialHeader(url.Values{}, http.Header{"Authorization": {"Bearer opaque-alice"}})
+	claims := findClient("oauth-alice").claims()
+	if len(claims.Rooms) != 1 || claims.Rooms[0] != "oauth-call" || claims.Role != "agent" {
+		t.Fatalf("introspected claims %+v", claims)
+	}
+	ts.Send(host, Message{Type: "offer", CallID: "oauth-call", Data: sdpData("offer")})
+	ts.waitForRoom("oauth-call", 1)
+	ts.Send(host, Message{Type: "offer", CallID: "oauth-elsewhere", Data: sdpData("offer")})
+	ts.AssertError(host, "room_forbidden")
+
+	// a reconnect inside the cache TTL is not introspected again
+	host.Close()
+	awaitDisconnect(t, "oauth-alice")
+	ts.Dial(url.Values{"token": {"opaque-alice"}})
+	if calls := endpoint.callsFor("opaque-alice"); calls != 1 {
+		t.Fatalf("opaque-alice introspected %d times, want 1", calls)
+	}
+}
+
+func TestIntrospectionCacheExpires(t *testing.T) {
+	ts := NewTestServer(t)
+	endpoint := setIntrospection(t, map[string]introspectionResponse{
+		"opaque-bob": {Active: true, Sub: "oauth-bob"},
+	})
+	introspectCacheTTL = 50 * time.Millisecond
+	ts.Dial(url.Values{"token": {"opaque-bob"}}).Close()
+	awaitDisconnect(t, "oauth-bob")
+	time.Sleep(introspectCacheTTL)
+	ts.Dial(url.Values{"token": {"opaque-bob"}})
+	if calls := endpoint.callsFor("opaque-bob"); calls != 2 {
+		t.Fatalf("opaque-bob introspected %d times after its cache entry expired, want 2", calls)
+	}
+	if rooms := findClient("oauth-bob").claims().Rooms; len(rooms) != 1 || rooms[0] != "*" {
+		t.Fatalf("token without room scopes grants %v, want every room", rooms)
+	}
+}
+
+func TestIntrospectionRejects(t *testing.T) {
+	ts := NewTestServer(t)
+	endpoint := setIntrospection(t, map[string]introspectionResponse{
+		"opaque-inactive": {Active: false, Sub: "oauth-inactive"},
+		"opaque-expired":  {Active: true, Sub: "oauth-expired", Exp: time.Now().Add(-time.Minute).Unix()},
+		"opaque-nobody":   {Active: true},
+	})
+	for _, token := range []string{"opaque-inactive", "opaque-expired", "opaque-nobody", "opaque-unknown"} {
+		if status := ts.DialStatus(url.Values{"token": {token}}); status != http.StatusUnauthorized {
+			t.Errorf("%s got %d, want 401", token, status)
+		}
+	}
+	// rejections are not cached
+	ts.DialStatus(url.Values{"token": {"opaque-inactive"}})
+	if calls := endpoint.callsFor("opaque-inactive"); calls != 2 {
+		t.Fatalf("opaque-inactive introspected %d times, want 2", calls)
+	}
+
+	introspectClientSecret = "wrong-secret"
+	endpoint.mu.Lock()
+	endpoint.responses["opaque-valid"] = introspectionResponse{Active: true, Sub: "oauth-valid"}
+	endpoint.mu.Unlock()
+	if status := ts.DialStatus(url.Values{"token": {"opaque-valid"}}); status != http.StatusUnauthorized {
+		t.Fatalf("token checked with the wrong client secret got %d, want 401", status)
+	}
+}
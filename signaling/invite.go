@@ -0,0 +1,113 @@
+package signaling
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Invite link configuration, invites are disabled when INVITE_SECRET is empty
+var (
+	inviteSecret  = envString("INVITE_SECRET", "")
+	serverBaseURL = envString("SERVER_BASE_URL", "")
+	inviteTTL     = time.Duration(envInt("INVITE_TTL_SECONDS", 3600)) * time.Second
+)
+
+// inviteClaims are the claims of an invite link token
+type inviteClaims struct {
+	CallID string `json:"callId"`
+	jwt.RegisteredClaims
+}
+
+// newInviteToken signs a token that lets its holder join callID until expires
+func newInviteToken(callID string, expires time.Time) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, inviteClaims{
+		CallID: callID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expires),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	})
+	return token.SignedString([]byte(inviteSecret))
+}
+
+// parseInviteToken validates an invite token and returns the call it grants access to
+func parseInviteToken(tokenString string) (string, error) {
+	claims := &inviteClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(*jwt.Token) (interface{}, error) {
+		return []byte(inviteSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return "", err
+	}
+	if claims.CallID == "" {
+		return "", errors.New("token has no callId")
+	}
+	return claims.CallID, nil
+}
+
+// inviteURL builds the shareable join link for a token
+func inviteURL(token string) string {
+	base := serverBaseURL
+	if !strings.Contains(base, "://") {
+		base = "https://" + base
+	}
+	return strings.TrimRight(base, "/") + "/join?token=" + url.QueryEscape(token)
+}
+
+// handleGetInviteLink gives the room host a signed link others can use to join
+func handleGetInviteLink(sender *wsConn, msg Message) {
+	if inviteSecret == "" || serverBaseURL == "" {
+		sendError(sender, "invites_unavailable")
+		return
+	}
+
+	room, unlock := rlockRoom(msg.CallID)
+	if room == nil {
+		sendError(sender, "Call not found")
+		return
+	}
+	isHost := room.host == sender
+	unlock()
+	if !isHost {
+		sendError(sender, "not_host")
+		return
+	}
+
+	expires := time.Now().Add(inviteTTL)
+	token, err := newInviteToken(msg.CallID, expires)
+	if err != nil {
+		log.Printf("Error signing invite for call %s: %v", msg.CallID, err)
+		sendError(sender, "invites_unavailable")
+		return
+	}
+	if err := sender.WriteJSON(Message{
+		Type:      "invite_link",
+		CallID:    msg.CallID,
+		URL:       inviteURL(token),
+		ExpiresAt: expires.UTC().Format(time.RFC3339),
+	}); err != nil {
+		log.Printf("Error sending invite_link to %v: %v", sender.RemoteAddr(), err)
+		go cleanupClient(sender)
+	}
+}
+
+// handleJoinLink validates an invite token and sends the browser to the app with the call to join
+func handleJoinLink(w http.ResponseWriter, r *http.Request) {
+	if inviteSecret == "" {
+		http.NotFound(w, r)
+		return
+	}
+	callID, err := parseInviteToken(r.URL.Query().Get("token"))
+	if err != nil {
+		log.Printf("Rejected invite from %v: %v", r.RemoteAddr, err)
+		http.Error(w, "Invalid or expired invite link", http.StatusForbidden)
+		return
+	}
+	http.Redirect(w, r, "/?callId="+url.QueryEscape(callID), http.StatusFound)
+}
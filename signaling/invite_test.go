@@ -0,0 +1,107 @@
+package signaling
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setInvites enables invite links signed with secret and pointing at base for the rest of the test
+func setInvites(t *testing.T, secret, base string) {
+	previousSecret, previousBase := inviteSecret, serverBaseURL
+	inviteSecret, serverBaseURL = secret, base
+	t.Cleanup(func() { inviteSecret, serverBaseURL = previousSecret, previousBase })
+}
+
+// followJoinLink requests the /join path of link from the test server without following the redirect
+func (ts *TestServer) followJoinLink(link string) *http.Response {
+	ts.t.Helper()
+	parsed, err := url.Parse(link)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(ts.URL() + parsed.RequestURI())
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	ts.t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestInviteLinkTokenJoinsCall(t *testing.T) {
+	setInvites(t, "invite-secret", "calls.example.com")
+	ts := NewTestServer(t)
+	host := ts.Connect()
+	ts.Send(host, Message{Type: "offer", CallID: "invite-call", Data: sdpData("offer")})
+	ts.waitForRoom("invite-call", 1)
+
+	ts.Send(host, Message{Type: "get_invite_link", CallID: "invite-call"})
+	msg := ts.AssertMessageReceived(host, "invite_link", testTimeout)
+	if !strings.HasPrefix(msg.URL, "https://calls.example.com/join?token=") {
+		t.Fatalf("invite url %q", msg.URL)
+	}
+	expires, err := time.Parse(time.RFC3339, msg.ExpiresAt)
+	if err != nil || expires.Sub(time.Now()) > inviteTTL || expires.Sub(time.Now()) < inviteTTL-time.Minute {
+		t.Fatalf("invite expiresAt %q, want about %v from now", msg.ExpiresAt, inviteTTL)
+	}
+
+	link, _ := url.Parse(msg.URL)
+	if callID, err := parseInviteToken(link.Query().Get("token")); err != nil || callID != "invite-call" {
+		t.Fatalf("invite token grants %q, %v", callID, err)
+	}
+	resp := ts.followJoinLink(msg.URL)
+	if resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != "/?callId=invite-call" {
+		t.Fatalf("join link answered %d to %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+}
+
+func TestInviteTokenRejected(t *testing.T) {
+	setInvites(t, "invite-secret", "calls.example.com")
+	ts := NewTestServer(t)
+
+	expired, err := newInviteToken("invite-expired", time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid, err := newInviteToken("invite-tampered", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inviteSecret = "another-secret"
+	forged, err := newInviteToken("invite-tampered", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inviteSecret = "invite-secret"
+
+	for name, token := range map[string]string{"expired": expired, "forged": forged, "truncated": valid[:len(valid)-4], "empty": ""} {
+		if _, err := parseInviteToken(token); err == nil {
+			t.Errorf("%s token accepted", name)
+		}
+		if resp := ts.followJoinLink(inviteURL(token)); resp.StatusCode != http.StatusForbidden {
+			t.Errorf("%s token: join link answered %d", name, resp.StatusCode)
+		}
+	}
+}
+
+func TestInviteLinkRules(t *testing.T) {
+	ts := NewTestServer(t)
+	host, guest := ts.Connect(), ts.Connect()
+	ts.startCall(host, guest, "invite-rules")
+
+	ts.Send(host, Message{Type: "get_invite_link", CallID: "invite-rules"})
+	ts.AssertError(host, "invites_unavailable")
+
+	setInvites(t, "invite-secret", "https://calls.example.com/")
+	ts.Send(guest, Message{Type: "get_invite_link", CallID: "invite-rules"})
+	ts.AssertError(guest, "not_host")
+	ts.Send(host, Message{Type: "get_invite_link", CallID: "invite-missing"})
+	ts.AssertError(host, "Call not found")
+	ts.Send(host, Message{Type: "get_invite_link", CallID: "invite-rules"})
+	if msg := ts.AssertMessageReceived(host, "invite_link", testTimeout); !strings.HasPrefix(msg.URL, "https://calls.example.com/join?token=") {
+		t.Fatalf("invite url %q", msg.URL)
+	}
+}
@@ -0,0 +1,83 @@
+package signaling
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+	"unicode/utf8"
+)
+
+// Issue report configuration
+var (
+	issueLogPath    = envString("ISSUE_LOG_PATH", "issues.jsonl")
+	issueWebhookURL = envString("ISSUE_WEBHOOK_URL", "")
+)
+
+// validIssues are the call quality problems a client can report
+var validIssues = map[string]bool{
+	"echo":         true,
+	"low_audio":    true,
+	"frozen_video": true,
+	"lag":          true,
+}
+
+// IssueReport is the details of an issue_report AuditEvent
+type IssueReport struct {
+	Issue          string `json:"issue"`
+	Details        string `json:"details,omitempty"`
+	Participants   int    `json:"participants"`
+	PingRTTMs      int64  `json:"pingRttMs"`
+	NetworkQuality int    `json:"networkQuality,omitempty"`
+}
+
+// handleReportIssue records a call quality problem flagged by a participant, without telling the rest of the room
+func handleReportIssue(sender *wsConn, msg Message) {
+	if !validIssues[msg.Issue] || utf8.RuneCountInString(msg.Details) > 1000 {
+		sendError(sender, "invalid_issue")
+		return
+	}
+	if !allowMessage(sender, "report_issue", 5, time.Minute) {
+		return
+	}
+	client, ok := getClient(sender)
+	if !ok {
+		return
+	}
+	room, unlock := rlockRoom(msg.CallID)
+	if room == nil || !room.clients[sender] {
+		if room != nil {
+			unlock()
+		}
+		sendError(sender, "not_in_call")
+		return
+	}
+	report := IssueReport{
+		Issue:          msg.Issue,
+		Details:        msg.Details,
+		Participants:   len(room.clients),
+		NetworkQuality: room.quality[sender],
+	}
+	unlock()
+	client.mu.Lock()
+	report.PingRTTMs = client.pongLatency.Milliseconds()
+	client.mu.Unlock()
+
+	event := AuditEvent{
+		Time:     time.Now().UTC(),
+		Event:    "issue_report",
+		CallID:   msg.CallID,
+		ClientID: client.id,
+		Details:  report,
+	}
+	line, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Error encoding issue report from %v: %v", sender.RemoteAddr(), err)
+		return
+	}
+	log.Printf("Issue reported by %v in call %s: %s", sender.RemoteAddr(), msg.CallID, line)
+	appendJSONLine(issueLogPath, line)
+	reportedIssues.WithLabelValues(msg.Issue, client.platformName()).Inc()
+	if issueWebhookURL != "" {
+		postWebhook(issueWebhookURL, event)
+	}
+}
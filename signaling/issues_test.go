@@ -0,0 +1,112 @@
+package signaling
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// issueEvent is an issue_report AuditEvent as written to the log and the webhook
+type issueEvent struct {
+	Event    string      `json:"event"`
+	CallID   string      `json:"callId"`
+	ClientID string      `json:"clientId"`
+	Details  IssueReport `json:"details"`
+}
+
+// setIssueReporting logs issue reports to a file of the test's own and posts them to webhookURL for the rest
+// of the test, and returns the log's path
+func setIssueReporting(t *testing.T, webhookURL string) string {
+	previousPath, previousURL := issueLogPath, issueWebhookURL
+	issueLogPath, issueWebhookURL = filepath.Join(t.TempDir(), "issues.jsonl"), webhookURL
+	t.Cleanup(func() { issueLogPath, issueWebhookURL = previousPath, previousURL })
+	return issueLogPath
+}
+
+func TestReportIssueEachType(t *testing.T) {
+	hooks := make(chan string, 4)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		hooks <- string(body)
+	}))
+	t.Cleanup(receiver.Close)
+	logPath := setIssueReporting(t, receiver.URL)
+
+	ts := NewTestServer(t)
+	reporter, peer := ts.ConnectWithClientID("issue-reporter"), ts.Connect()
+	ts.startCall(reporter, peer, "issue-call")
+	ts.waitForRoom("issue-call", 2)
+
+	issues := []string{"echo", "low_audio", "frozen_video", "lag"}
+	for _, issue := range issues {
+		t.Run(issue, func(t *testing.T) {
+			series := fmt.Sprintf(`videochat_reported_issues_total{issue=%q,platform=%q}`, issue, unknownPlatform)
+			before := ts.scrapeMetric(series)
+			ts.Send(reporter, Message{Type: "report_issue", CallID: "issue-call", Issue: issue, Details: "noticed " + issue})
+
+			var event issueEvent
+			select {
+			case body := <-hooks:
+				if err := json.Unmarshal([]byte(body), &event); err != nil {
+					t.Fatalf("webhook body %s: %v", body, err)
+				}
+			case <-time.After(testTimeout):
+				t.Fatal("issue webhook never arrived")
+			}
+			if event.Event != "issue_report" || event.CallID != "issue-call" || event.ClientID != "issue-reporter" ||
+				event.Details.Issue != issue || event.Details.Details != "noticed "+issue || event.Details.Participants != 2 {
+				t.Fatalf("webhook got %+v", event)
+			}
+			if after := ts.scrapeMetric(series); after != before+1 {
+				t.Fatalf("%s went from %v to %v", series, before, after)
+			}
+		})
+	}
+	ts.RequireNoMessageOfType(peer, "report_issue", 50*time.Millisecond)
+
+	f, err := os.Open(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var logged []string
+	for scanner := bufio.NewScanner(f); scanner.Scan(); {
+		var event issueEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("log line %s: %v", scanner.Text(), err)
+		}
+		logged = append(logged, event.Details.Issue)
+	}
+	if strings.Join(logged, ",") != strings.Join(issues, ",") {
+		t.Fatalf("issue log holds %v, want %v", logged, issues)
+	}
+}
+
+func TestReportIssueRules(t *testing.T) {
+	setIssueReporting(t, "")
+	ts := NewTestServer(t)
+	reporter, peer := ts.Connect(), ts.Connect()
+	ts.startCall(reporter, peer, "issue-rules")
+
+	ts.Send(reporter, Message{Type: "report_issue", CallID: "issue-rules", Issue: "bad_vibes"})
+	ts.AssertError(reporter, "invalid_issue")
+	ts.Send(reporter, Message{Type: "report_issue", CallID: "issue-rules", Issue: "lag", Details: strings.Repeat("x", 1001)})
+	ts.AssertError(reporter, "invalid_issue")
+	ts.Send(reporter, Message{Type: "report_issue", CallID: "another-call", Issue: "lag"})
+	ts.AssertError(reporter, "not_in_call")
+
+	// the not_in_call report above counted towards the limit of five a minute
+	for i := 0; i < 4; i++ {
+		ts.Send(reporter, Message{Type: "report_issue", CallID: "issue-rules", Issue: "lag"})
+	}
+	ts.Send(reporter, Message{Type: "report_issue", CallID: "issue-rules", Issue: "lag"})
+	ts.AssertError(reporter, "rate_limited")
+}
@@ -0,0 +1,97 @@
+package signaling
+
+import (
+	"log"
+	"time"
+)
+
+// Latency tracking configuration
+var (
+	highRTT = time.Duration(envInt("HIGH_RTT_MS", 5000)) * time.Millisecond
+	// highRTTStreak is how many consecutive slow pongs flag a client as high latency
+	highRTTStreak = 3
+)
+
+// recordPing notes when a ping was sent so the matching pong can be timed
+func (c *Client) recordPing(at time.Time) {
+	c.mu.Lock()
+	c.pingTime = at
+	c.mu.Unlock()
+}
+
+// recordPong measures the round trip of the outstanding ping
+func (c *Client) recordPong() {
+	c.mu.Lock()
+	if c.pingTime.IsZero() {
+		c.mu.Unlock()
+		return
+	}
+	c.lastPongAt = time.Now()
+	c.pongLatency = c.lastPongAt.Sub(c.pingTime)
+	c.pingTime = time.Time{}
+	if c.pongLatency > highRTT {
+		c.highRTTCount++
+	} else {
+		c.highRTTCount = 0
+	}
+	latency := c.pongLatency
+	c.mu.Unlock()
+
+	pingRTT.WithLabelValues(c.id).Observe(float64(latency.Milliseconds()))
+}
+
+// recordMessage notes that the client just sent a message
+func (c *Client) recordMessage() {
+	c.mu.Lock()
+	c.lastMessageAt = time.Now()
+	c.mu.Unlock()
+}
+
+// lastSeen returns when the client last sent a message or answered a ping
+func (c *Client) lastSeen() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lastPongAt.After(c.lastMessageAt) {
+		return c.lastPongAt
+	}
+	return c.lastMessageAt
+}
+
+// highLatency reports whether the client's recent pongs have all been slower than HIGH_RTT_MS
+func (c *Client) highLatency() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.highRTTCount >= highRTTStreak
+}
+
+// handleEcho answers an echo request immediately so clients can time the signaling round trip
+func handleEcho(sender *wsConn, msg Message) {
+	receivedAt := time.Now()
+	if !allowMessage(sender, "echo", 10, time.Second) {
+		return
+	}
+	if err := sender.WriteJSON(Message{
+		Type:             "echo_reply",
+		Seq:              msg.Seq,
+		SentAt:           msg.SentAt,
+		ServerReceivedAt: receivedAt.UTC().Format(time.RFC3339Nano),
+	}); err != nil {
+		log.Printf("Error sending echo_reply to %v: %v", sender.RemoteAddr(), err)
+		go cleanupClient(sender)
+		return
+	}
+
+	sentAt, err := time.Parse(time.RFC3339Nano, msg.SentAt)
+	if err != nil {
+		return
+	}
+	if client, ok := getClient(sender); ok {
+		delay := receivedAt.Sub(sentAt)
+		if delay < 0 {
+			delay = 0
+		}
+		client.mu.Lock()
+		client.echoDelays.add(delay)
+		client.mu.Unlock()
+	}
+}
@@ -0,0 +1,85 @@
+package signaling
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestEchoReplyWithinFiveMilliseconds(t *testing.T) {
+	// batching would hold every reply for its full window, which is what this test must not measure
+	setBatchWriteDelay(t, 0)
+	ts := NewTestServer(t)
+	conn := ts.Connect()
+	// take the fastest of a few round trips so one scheduling hiccup on a busy machine does not fail the test
+	fastest := time.Hour
+	for seq := int64(1); seq <= 5; seq++ {
+		start := time.Now()
+		ts.Send(conn, Message{Type: "echo", Seq: seq, SentAt: start.UTC().Format(time.RFC3339Nano)})
+		msg := ts.AssertMessageReceived(conn, "echo_reply", testTimeout)
+		if rtt := time.Since(start); rtt < fastest {
+			fastest = rtt
+		}
+		if msg.Seq != seq {
+			t.Fatalf("echo_reply seq %d, want %d", msg.Seq, seq)
+		}
+	}
+	if fastest > 5*time.Millisecond {
+		t.Fatalf("fastest echo round trip took %v, want under 5ms", fastest)
+	}
+}
+
+func TestEchoRateLimited(t *testing.T) {
+	ts := NewTestServer(t)
+	conn := ts.Connect()
+	for seq := int64(1); seq <= 10; seq++ {
+		ts.Send(conn, Message{Type: "echo", Seq: seq})
+		ts.AssertMessageReceived(conn, "echo_reply", testTimeout)
+	}
+	ts.Send(conn, Message{Type: "echo", Seq: 11})
+	ts.AssertError(conn, "rate_limited")
+	ts.RequireNoMessageOfType(conn, "echo_reply", 100*time.Millisecond)
+}
+
+func TestEchoDelaysReachClientStats(t *testing.T) {
+	ts := NewTestServer(t)
+	setAdminToken(t, "echo-admin")
+	conn := ts.ConnectWithClientID("echo-stats")
+	now := time.Now()
+	sentAt := []time.Duration{40, 40, 40, 40, 40, 40, 40, 40, 300}
+	for i, ago := range sentAt {
+		ts.Send(conn, Message{Type: "echo", Seq: int64(i), SentAt: now.Add(-ago * time.Millisecond).UTC().Format(time.RFC3339Nano)})
+		ts.AssertMessageReceived(conn, "echo_reply", testTimeout)
+	}
+	// echoes without a parseable sentAt are answered but not counted, and this one's reply shows
+	// the delays of the others have been recorded
+	ts.Send(conn, Message{Type: "echo", Seq: int64(len(sentAt))})
+	ts.AssertMessageReceived(conn, "echo_reply", testTimeout)
+
+	resp := ts.adminRequest("GET", "/api/v1/clients/echo-stats", "echo-admin")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("client status %d", resp.StatusCode)
+	}
+	var desc ClientSnapshot
+	if err := json.NewDecoder(resp.Body).Decode(&desc); err != nil {
+		t.Fatal(err)
+	}
+	if desc.EchoP50Ms < 40 || desc.EchoP50Ms >= 300 || desc.EchoP95Ms < 300 || desc.EchoP99Ms < 300 {
+		t.Fatalf("echo percentiles p50=%d p95=%d p99=%d", desc.EchoP50Ms, desc.EchoP95Ms, desc.EchoP99Ms)
+	}
+}
+
+func TestDurationRingKeepsLatest(t *testing.T) {
+	ring := newDurationRing(100)
+	for i := 0; i < 150; i++ {
+		ring.add(time.Duration(i))
+	}
+	values := ring.values()
+	if len(values) != 100 || values[0] != 50 || values[99] != 149 {
+		t.Fatalf("ring holds %d values from %v to %v, want 100 from 50 to 149", len(values), values[0], values[len(values)-1])
+	}
+	if p50, p99 := percentile(values, 50), percentile(values, 99); p50 != 99 || p99 != 148 {
+		t.Fatalf("p50=%v p99=%v, want 99 and 148", p50, p99)
+	}
+}
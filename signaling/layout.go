@@ -0,0 +1,92 @@
+package signaling
+
+import "log"
+
+// validLayouts are the layouts a host can pick for the room
+var validLayouts = map[string]bool{
+	"grid":      true,
+	"spotlight": true,
+}
+
+// handleSetLayout lets the room host choose the layout every participant shows
+func handleSetLayout(sender *wsConn, msg Message) {
+	if !validLayouts[msg.Layout] {
+		sendError(sender, "invalid_layout")
+		return
+	}
+
+	room, unlock := lockRoom(msg.CallID)
+	if room == nil {
+		sendError(sender, "Call not found")
+		return
+	}
+	if room.host != sender {
+		unlock()
+		sendError(sender, "not_host")
+		return
+	}
+	if msg.PinnedClientID != "" && !room.hasMember(msg.PinnedClientID) {
+		unlock()
+		sendError(sender, "invalid_pinned_client")
+		return
+	}
+	room.layout = msg.Layout
+	room.pinnedClientID = msg.PinnedClientID
+	unlock()
+
+	broadcastToRoom(sender, Message{
+		Type:           "layout_changed",
+		CallID:         msg.CallID,
+		Layout:         msg.Layout,
+		PinnedClientID: msg.PinnedClientID,
+	})
+	log.Printf("Host %v set layout %s in room %s", sender.RemoteAddr(), msg.Layout, msg.CallID)
+}
+
+// suggestedLayout picks a grid shape for n participants
+func suggestedLayout(n int) string {
+	switch {
+	case n <= 1:
+		return "solo"
+	case n == 2:
+		return "side-by-side"
+	case n <= 4:
+		return "2x2"
+	case n <= 9:
+		return "3x3"
+	default:
+		return "gallery"
+	}
+}
+
+// pushLayoutHint sends layout_hint to every member of the room when its participant count has changed
+func pushLayoutHint(callID string) {
+	room, unlock := lockRoom(callID)
+	if room == nil {
+		return
+	}
+	if room.ParticipantCount == len(room.clients) {
+		unlock()
+		return
+	}
+	room.ParticipantCount = len(room.clients)
+	count := room.ParticipantCount
+	members := make([]*wsConn, 0, count)
+	for client := range room.clients {
+		members = append(members, client)
+	}
+	unlock()
+
+	hint := Message{
+		Type:             "layout_hint",
+		CallID:           callID,
+		ParticipantCount: count,
+		SuggestedLayout:  suggestedLayout(count),
+	}
+	for _, client := range members {
+		if err := client.WriteJSON(hint); err != nil {
+			log.Printf("Error sending layout_hint to %v: %v", client.RemoteAddr(), err)
+			go cleanupClient(client)
+		}
+	}
+}
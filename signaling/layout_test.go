@@ -0,0 +1,69 @@
+package signaling
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestSuggestedLayout(t *testing.T) {
+	tests := []struct {
+		participants int
+		want         string
+	}{
+		{0, "solo"},
+		{1, "solo"},
+		{2, "side-by-side"},
+		{3, "2x2"},
+		{4, "2x2"},
+		{5, "3x3"},
+		{9, "3x3"},
+		{10, "gallery"},
+		{50, "gallery"},
+	}
+	for _, tt := range tests {
+		if got := suggestedLayout(tt.participants); got != tt.want {
+			t.Errorf("suggestedLayout(%d) = %q, want %q", tt.participants, got, tt.want)
+		}
+	}
+}
+
+// awaitLayoutHint skips earlier layout hints on conn until the one for participants arrives
+func (ts *TestServer) awaitLayoutHint(conn *websocket.Conn, participants int) Message {
+	ts.t.Helper()
+	for deadline := time.Now().Add(testTimeout); time.Now().Before(deadline); {
+		msg := ts.AssertMessageReceived(conn, "layout_hint", time.Until(deadline))
+		if msg.ParticipantCount == participants {
+			return msg
+		}
+	}
+	ts.t.Fatalf("no layout_hint for %d participants", participants)
+	return Message{}
+}
+
+func TestLayoutHintFollowsParticipantCount(t *testing.T) {
+	ts := NewTestServer(t)
+	caller, callee, third := ts.Connect(), ts.Connect(), ts.Connect()
+	ts.startCall(caller, callee, "layout-hint")
+	for _, conn := range []*websocket.Conn{caller, callee} {
+		if msg := ts.awaitLayoutHint(conn, 2); msg.SuggestedLayout != "side-by-side" {
+			t.Fatalf("two participants got layout %q", msg.SuggestedLayout)
+		}
+	}
+
+	ts.Send(third, Message{Type: "join_call", CallID: "layout-hint"})
+	for _, conn := range []*websocket.Conn{caller, callee, third} {
+		if msg := ts.awaitLayoutHint(conn, 3); msg.SuggestedLayout != "2x2" || msg.CallID != "layout-hint" {
+			t.Fatalf("three participants got %+v", msg)
+		}
+	}
+
+	ts.Send(third, Message{Type: "hangup", CallID: "layout-hint"})
+	for _, conn := range []*websocket.Conn{caller, callee} {
+		if msg := ts.awaitLayoutHint(conn, 2); msg.SuggestedLayout != "side-by-side" {
+			t.Fatalf("after a hangup got layout %q", msg.SuggestedLayout)
+		}
+	}
+	ts.RequireNoMessageOfType(third, "layout_hint", 100*time.Millisecond)
+}
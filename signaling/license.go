@@ -0,0 +1,57 @@
+package signaling
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// License tier configuration, licensing is disabled when LICENSE_JWT_SECRET is empty
+var (
+	licenseSecret      = envString("LICENSE_JWT_SECRET", "")
+	freeTierMaxClients = envInt("FREE_TIER_MAX_CLIENTS", 2)
+)
+
+// License is the plan a client connected under
+type License struct {
+	MaxRoomSize int // largest room the client may join, 0 means no license limit
+}
+
+// licenseClaims are the claims of a license token
+type licenseClaims struct {
+	MaxRoomSize int `json:"max_room_size"`
+	jwt.RegisteredClaims
+}
+
+// parseLicense reads the optional Authorization: Bearer license token, falling back to the free tier without one
+func parseLicense(r *http.Request) (License, error) {
+	if licenseSecret == "" {
+		return License{}, nil
+	}
+	tokenString, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || tokenString == "" {
+		return License{MaxRoomSize: freeTierMaxClients}, nil
+	}
+	claims := &licenseClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(*jwt.Token) (interface{}, error) {
+		return []byte(licenseSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return License{}, err
+	}
+	if claims.MaxRoomSize <= 0 {
+		return License{}, errors.New("token has no max_room_size")
+	}
+	return License{MaxRoomSize: claims.MaxRoomSize}, nil
+}
+
+// fullFor reports whether the room is at the lower of its own capacity and the joining client's license limit
+func (r *Room) fullFor(license License) bool {
+	limit := r.options.MaxClients
+	if license.MaxRoomSize > 0 && (limit == 0 || license.MaxRoomSize < limit) {
+		limit = license.MaxRoomSize
+	}
+	return limit > 0 && len(r.clients) >= limit
+}
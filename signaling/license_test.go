@@ -0,0 +1,106 @@
+package signaling
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+)
+
+const testLicenseSecret = "license-test-secret"
+
+// enableLicenses turns license tokens on for the rest of the test
+func enableLicenses(t *testing.T) {
+	previous := licenseSecret
+	licenseSecret = testLicenseSecret
+	t.Cleanup(func() { licenseSecret = previous })
+}
+
+// licenseHeader carries a license allowing rooms of maxRoomSize, or none for the free tier when it is 0
+func licenseHeader(ts *TestServer, maxRoomSize int) http.Header {
+	ts.t.Helper()
+	header := http.Header{}
+	if maxRoomSize > 0 {
+		license, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"max_room_size": maxRoomSize,
+			"exp":           time.Now().Add(time.Hour).Unix(),
+		}).SignedString([]byte(testLicenseSecret))
+		if err != nil {
+			ts.t.Fatal(err)
+		}
+		header.Set("Authorization", "Bearer "+license)
+	}
+	return header
+}
+
+// connectLicensed connects a client with a license allowing rooms of maxRoomSize, or on the free tier when it is 0
+func connectLicensed(ts *TestServer, id string, maxRoomSize int) *websocket.Conn {
+	ts.t.Helper()
+	return ts.DialHeader(url.Values{"token": {ts.Token(id, jwt.MapClaims{"rooms": "*"})}}, licenseHeader(ts, maxRoomSize))
+}
+
+func TestLicenseCapsEveryAdmissionPath(t *testing.T) {
+	ts := NewTestServer(t)
+	enableLicenses(t)
+	host := connectLicensed(ts, "license-host", 10)
+	ts.Send(host, Message{Type: "offer", CallID: "license-cap", Data: sdpData("offer")})
+	ts.waitForRoom("license-cap", 1)
+	second := connectLicensed(ts, "license-second", 10)
+	ts.Send(second, Message{Type: "join_call", CallID: "license-cap"})
+	ts.AssertMessageReceived(second, "call_joined", testTimeout)
+
+	// the free tier allows rooms of 2, which this one already is
+	for i, msg := range []Message{
+		{Type: "join_call", CallID: "license-cap"},
+		{Type: "accept_call", CallID: "license-cap"},
+		{Type: "answer", CallID: "license-cap", Data: sdpData("answer")},
+	} {
+		free := connectLicensed(ts, "license-free-"+string(rune('a'+i)), 0)
+		ts.Send(free, msg)
+		ts.AssertError(free, "room_full")
+	}
+
+	pro := connectLicensed(ts, "license-pro", 3)
+	ts.Send(pro, Message{Type: "accept_call", CallID: "license-cap"})
+	ts.AssertMessageReceived(pro, "call_joined", testTimeout)
+	small := connectLicensed(ts, "license-small", 3)
+	ts.Send(small, Message{Type: "join_call", CallID: "license-cap"})
+	ts.AssertError(small, "room_full")
+}
+
+func TestInvalidLicenseRejected(t *testing.T) {
+	ts := NewTestServer(t)
+	enableLicenses(t)
+	token := ts.Token("license-bad", jwt.MapClaims{"rooms": "*"})
+	_, resp, err := websocket.DefaultDialer.Dial(ts.wsURL(url.Values{"token": {token}}), http.Header{"Authorization": {"Bearer not-a-license"}})
+	if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("dial with a bad license: %v, want 401", err)
+	}
+}
+
+func TestLicenseCapsLongPollClients(t *testing.T) {
+	ts := NewTestServer(t)
+	enableLicenses(t)
+	host := connectLicensed(ts, "license-poll-host", 10)
+	ts.Send(host, Message{Type: "offer", CallID: "license-poll", Data: sdpData("offer")})
+	ts.waitForRoom("license-poll", 1)
+	second := connectLicensed(ts, "license-poll-second", 10)
+	ts.Send(second, Message{Type: "join_call", CallID: "license-poll"})
+	ts.AssertMessageReceived(second, "call_joined", testTimeout)
+
+	free := ts.connectPolling("license-poll-free")
+	free.send(Message{Type: "join_call", CallID: "license-poll"})
+	if msg := free.await("error"); msg.Code != "room_full" {
+		t.Fatalf("free tier long-poll client got error %q, want room_full", msg.Code)
+	}
+	pro := ts.connectPollingHeader("license-poll-pro", licenseHeader(ts, 3))
+	pro.send(Message{Type: "join_call", CallID: "license-poll"})
+	pro.await("call_joined")
+
+	if resp := ts.pollConnect("license-poll-bad", http.Header{"Authorization": {"Bearer not-a-license"}}); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("long-poll connect with a bad license answered %d, want 401", resp.StatusCode)
+	}
+}
@@ -0,0 +1,322 @@
+package signaling
+
+import (
+	"errors"
+	"log"
+	"net/url"
+	"time"
+	"unicode/utf8"
+)
+
+// errInLobby is returned when a client asks to enter a room in lobby mode before a moderator has admitted it
+var errInLobby = errors.New("in_lobby")
+
+// maxLobbyMessageLength bounds lobby_message, message_lobby and the note of set_lobby_media, in characters
+const maxLobbyMessageLength = 500
+
+// LobbyMedia is the welcome video and note the room host shows clients waiting in the lobby
+type LobbyMedia struct {
+	VideoURL string `json:"videoUrl"`
+	Message  string `json:"message,omitempty"`
+}
+
+// moderates reports whether conn is the room's host or one of its co-hosts; callers hold the room lock
+func (r *Room) moderates(conn *wsConn) bool {
+	return conn != nil && (r.host == conn || r.cohosts[conn])
+}
+
+// moderators returns the room's host and co-hosts; callers hold the room lock
+func (r *Room) moderators() []*wsConn {
+	var moderators []*wsConn
+	if r.host != nil {
+		moderators = append(moderators, r.host)
+	}
+	for conn := range r.cohosts {
+		if conn != r.host {
+			moderators = append(moderators, conn)
+		}
+	}
+	return moderators
+}
+
+// waiting reports whether conn is in the room's lobby; callers hold the room lock
+func (r *Room) waiting(conn *wsConn) bool {
+	for _, w := range r.lobby {
+		if w == conn {
+			return true
+		}
+	}
+	return false
+}
+
+// leaveLobby takes conn out of the room's lobby; callers hold the room lock
+func (r *Room) leaveLobby(conn *wsConn) {
+	for i, w := range r.lobby {
+		if w == conn {
+			r.lobby = append(r.lobby[:i], r.lobby[i+1:]...)
+			return
+		}
+	}
+}
+
+// enterLobby puts conn in the lobby of the room for callID, telling it with lobby_waiting, followed by
+// the room's lobby_media if it has any, and the host and co-hosts with lobby_join_request
+func enterLobby(conn *wsConn, callID string) {
+	room, unlock := lockRoom(callID)
+	if room == nil {
+		sendError(conn, "Call not found")
+		return
+	}
+	if !room.waiting(conn) {
+		room.lobby = append(room.lobby, conn)
+	}
+	moderators := room.moderators()
+	media := room.options.LobbyMedia
+	unlock()
+
+	clientsMu.Lock()
+	if client, ok := getClient(conn); ok {
+		client.lobbies[callID] = true
+	}
+	clientsMu.Unlock()
+
+	if err := conn.WriteJSON(Message{Type: "lobby_waiting", CallID: callID}); err != nil {
+		log.Printf("Error sending lobby_waiting to %v: %v", conn.RemoteAddr(), err)
+		go cleanupClient(conn)
+		return
+	}
+	if media != nil {
+		sendLobbyMedia(conn, callID, media)
+	}
+	request := Message{Type: "lobby_join_request", CallID: callID, ClientID: clientID(conn)}
+	for _, moderator := range moderators {
+		if err := moderator.WriteJSON(request); err != nil {
+			log.Printf("Error sending lobby_join_request to %v: %v", moderator.RemoteAddr(), err)
+			go cleanupClient(moderator)
+		}
+	}
+	log.Printf("Client %v is waiting in the lobby of room %s", conn.RemoteAddr(), callID)
+}
+
+// leaveLobbies takes a client that hung up or disconnected out of the lobbies of the rooms for callIDs,
+// forgetting any admission it had not used yet
+func leaveLobbies(conn *wsConn, callIDs []string) {
+	for _, callID := range callIDs {
+		if room, unlock := lockRoom(callID); room != nil {
+			room.leaveLobby(conn)
+			if !room.clients[conn] {
+				delete(room.admitted, conn)
+			}
+			unlock()
+		}
+	}
+}
+
+// handleAdmitFromLobby lets the host or a co-host admit a lobby waiter, who is sent lobby_admitted
+// and may then join the call
+func handleAdmitFromLobby(sender *wsConn, msg Message) {
+	room, unlock := lockRoom(msg.CallID)
+	if room == nil {
+		sendError(sender, "Call not found")
+		return
+	}
+	if !room.moderates(sender) {
+		unlock()
+		sendError(sender, "not_host")
+		return
+	}
+	var admitted *wsConn
+	for _, w := range room.lobby {
+		if clientID(w) == msg.ClientID {
+			admitted = w
+			break
+		}
+	}
+	if admitted == nil {
+		unlock()
+		sendError(sender, "not_in_lobby")
+		return
+	}
+	room.leaveLobby(admitted)
+	room.admitted[admitted] = true
+	unlock()
+
+	if err := admitted.WriteJSON(Message{Type: "lobby_admitted", CallID: msg.CallID}); err != nil {
+		log.Printf("Error sending lobby_admitted to %v: %v", admitted.RemoteAddr(), err)
+		go cleanupClient(admitted)
+	}
+	log.Printf("Client %v admitted %s from the lobby of room %s", sender.RemoteAddr(), msg.ClientID, msg.CallID)
+}
+
+// handleAddCohost lets the room host make another member a co-host, who can admit lobby waiters
+// and receives their lobby messages
+func handleAddCohost(sender *wsConn, msg Message) {
+	room, unlock := lockRoom(msg.CallID)
+	if room == nil {
+		sendError(sender, "Call not found")
+		return
+	}
+	if room.host != sender {
+		unlock()
+		sendError(sender, "not_host")
+		return
+	}
+	var cohost *wsConn
+	for member := range room.clients {
+		if clientID(member) == msg.ClientID {
+			cohost = member
+			break
+		}
+	}
+	if cohost == nil {
+		unlock()
+		sendError(sender, "not_in_call")
+		return
+	}
+	room.cohosts[cohost] = true
+	unlock()
+
+	if err := cohost.WriteJSON(Message{Type: "cohost_added", CallID: msg.CallID}); err != nil {
+		log.Printf("Error sending cohost_added to %v: %v", cohost.RemoteAddr(), err)
+		go cleanupClient(cohost)
+	}
+	log.Printf("Host %v made %s a co-host of room %s", sender.RemoteAddr(), msg.ClientID, msg.CallID)
+}
+
+// handleLobbyMessage passes a short note from a lobby waiter to the room's host and co-hosts only,
+// so callers can say who they are before being admitted
+func handleLobbyMessage(sender *wsConn, msg Message) {
+	if msg.Data == "" || utf8.RuneCountInString(msg.Data) > maxLobbyMessageLength {
+		sendError(sender, "invalid_lobby_message")
+		return
+	}
+	room, unlock := rlockRoom(msg.CallID)
+	if room == nil {
+		sendError(sender, "Call not found")
+		return
+	}
+	if !room.waiting(sender) {
+		unlock()
+		sendError(sender, "not_in_lobby")
+		return
+	}
+	moderators := room.moderators()
+	unlock()
+	if !allowMessage(sender, "lobby_message", 1, time.Second) {
+		return
+	}
+
+	received := Message{Type: "lobby_message_received", CallID: msg.CallID, From: clientID(sender), Data: msg.Data}
+	for _, moderator := range moderators {
+		if err := moderator.WriteJSON(received); err != nil {
+			log.Printf("Error sending lobby_message_received to %v: %v", moderator.RemoteAddr(), err)
+			go cleanupClient(moderator)
+		}
+	}
+}
+
+// handleMessageLobby lets the room host send a short note to everyone waiting in the lobby, such as that
+// the meeting is running late
+func handleMessageLobby(sender *wsConn, msg Message) {
+	if msg.Data == "" || utf8.RuneCountInString(msg.Data) > maxLobbyMessageLength {
+		sendError(sender, "invalid_lobby_message")
+		return
+	}
+	room, unlock := rlockRoom(msg.CallID)
+	if room == nil {
+		sendError(sender, "Call not found")
+		return
+	}
+	if room.host != sender {
+		unlock()
+		sendError(sender, "not_host")
+		return
+	}
+	waiters := append([]*wsConn(nil), room.lobby...)
+	unlock()
+	if !allowMessage(sender, "message_lobby", 1, 30*time.Second) {
+		return
+	}
+
+	fromHost := Message{Type: "message_from_host", CallID: msg.CallID, Data: msg.Data}
+	for _, waiter := range waiters {
+		if err := waiter.WriteJSON(fromHost); err != nil {
+			log.Printf("Error sending message_from_host to %v: %v", waiter.RemoteAddr(), err)
+			go cleanupClient(waiter)
+		}
+	}
+	log.Printf("Host %v messaged %d lobby waiters of room %s", sender.RemoteAddr(), len(waiters), msg.CallID)
+}
+
+// handleSetLobbyMedia lets the host of a room in lobby mode set, or replace, the welcome video and note
+// shown to lobby waiters; the current waiters are sent the new media straight away
+func handleSetLobbyMedia(sender *wsConn, msg Message) {
+	videoURL, err := url.Parse(msg.VideoURL)
+	if err != nil || videoURL.Scheme != "https" || videoURL.Host == "" || utf8.RuneCountInString(msg.MessageText) > maxLobbyMessageLength {
+		sendError(sender, "invalid_lobby_media")
+		return
+	}
+	room, unlock := lockRoom(msg.CallID)
+	if room == nil {
+		sendError(sender, "Call not found")
+		return
+	}
+	if room.host != sender {
+		unlock()
+		sendError(sender, "not_host")
+		return
+	}
+	if !room.options.Lobby {
+		unlock()
+		sendError(sender, "lobby_disabled")
+		return
+	}
+	media := &LobbyMedia{VideoURL: msg.VideoURL, Message: msg.MessageText}
+	room.options.LobbyMedia = media
+	waiters := append([]*wsConn(nil), room.lobby...)
+	unlock()
+
+	for _, waiter := range waiters {
+		sendLobbyMedia(waiter, msg.CallID, media)
+	}
+	log.Printf("Host %v set the lobby media of room %s", sender.RemoteAddr(), msg.CallID)
+}
+
+// sendLobbyMedia shows a lobby waiter the room's welcome video and note
+func sendLobbyMedia(conn *wsConn, callID string, media *LobbyMedia) {
+	if err := conn.WriteJSON(Message{Type: "lobby_media", CallID: callID, VideoURL: media.VideoURL, MessageText: media.Message}); err != nil {
+		log.Printf("Error sending lobby_media to %v: %v", conn.RemoteAddr(), err)
+		go cleanupClient(conn)
+	}
+}
+
+// handleSetLobby lets the room host turn lobby mode on or off; turning it off drops the lobby media and
+// sends every waiter lobby_admitted, as nothing keeps them out any more
+func handleSetLobby(sender *wsConn, msg Message) {
+	room, unlock := lockRoom(msg.CallID)
+	if room == nil {
+		sendError(sender, "Call not found")
+		return
+	}
+	if room.host != sender {
+		unlock()
+		sendError(sender, "not_host")
+		return
+	}
+	room.options.Lobby = msg.Lobby
+	var waiters []*wsConn
+	if !msg.Lobby {
+		room.options.LobbyMedia = nil
+		waiters = room.lobby
+		room.lobby = nil
+	}
+	unlock()
+
+	for _, waiter := range waiters {
+		if err := waiter.WriteJSON(Message{Type: "lobby_admitted", CallID: msg.CallID}); err != nil {
+			log.Printf("Error sending lobby_admitted to %v: %v", waiter.RemoteAddr(), err)
+			go cleanupClient(waiter)
+		}
+	}
+	log.Printf("Host %v set lobby mode of room %s to %v", sender.RemoteAddr(), msg.CallID, msg.Lobby)
+}
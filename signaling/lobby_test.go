@@ -0,0 +1,224 @@
+package signaling
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// openLobbyRoom has host create callID in lobby mode
+func (ts *TestServer) openLobbyRoom(host *websocket.Conn, callID string) {
+	ts.t.Helper()
+	ts.Send(host, Message{Type: "offer", CallID: callID, Data: sdpData("offer"), Lobby: true})
+	ts.waitForRoom(callID, 1)
+}
+
+// enterLobby has conn ask to join callID and checks it was put in the lobby
+func (ts *TestServer) enterLobby(conn *websocket.Conn, callID string) {
+	ts.t.Helper()
+	ts.Send(conn, Message{Type: "accept_call", CallID: callID})
+	ts.AssertMessageReceived(conn, "lobby_waiting", testTimeout)
+}
+
+// admitMember has host admit the lobby waiter guest, whose client ID is guestID, and guest join callID
+func (ts *TestServer) admitMember(host, guest *websocket.Conn, guestID, callID string) {
+	ts.t.Helper()
+	ts.enterLobby(guest, callID)
+	ts.Send(host, Message{Type: "admit_from_lobby", CallID: callID, ClientID: guestID})
+	ts.AssertMessageReceived(guest, "lobby_admitted", testTimeout)
+	ts.Send(guest, Message{Type: "accept_call", CallID: callID})
+	ts.AssertMessageReceived(guest, "call_joined", testTimeout)
+}
+
+func TestLobbyHoldsJoinerUntilAdmitted(t *testing.T) {
+	ts := NewTestServer(t)
+	host, guest := ts.Connect(), ts.ConnectWithClientID("lobby-guest")
+	ts.openLobbyRoom(host, "lobby-admit")
+
+	ts.enterLobby(guest, "lobby-admit")
+	if msg := ts.AssertMessageReceived(host, "lobby_join_request", testTimeout); msg.ClientID != "lobby-guest" {
+		t.Fatalf("lobby_join_request from %q, want lobby-guest", msg.ClientID)
+	}
+	ts.RequireNoMessageOfType(guest, "offer", 100*time.Millisecond)
+
+	ts.Send(guest, Message{Type: "admit_from_lobby", CallID: "lobby-admit", ClientID: "lobby-guest"})
+	ts.AssertError(guest, "not_host")
+
+	ts.Send(host, Message{Type: "admit_from_lobby", CallID: "lobby-admit", ClientID: "lobby-guest"})
+	ts.AssertMessageReceived(guest, "lobby_admitted", testTimeout)
+	ts.Send(guest, Message{Type: "accept_call", CallID: "lobby-admit"})
+	ts.AssertMessageReceived(guest, "offer", testTimeout)
+	ts.AssertMessageReceived(host, "peer_joined", testTimeout)
+}
+
+func TestLobbyMessageReachesHostAndCohostsOnly(t *testing.T) {
+	ts := NewTestServer(t)
+	host, cohost, member := ts.Connect(), ts.ConnectWithClientID("lobby-cohost"), ts.ConnectWithClientID("lobby-member")
+	waiter := ts.ConnectWithClientID("lobby-waiter")
+	ts.openLobbyRoom(host, "lobby-message")
+	ts.admitMember(host, cohost, "lobby-cohost", "lobby-message")
+	ts.admitMember(host, member, "lobby-member", "lobby-message")
+	ts.Send(host, Message{Type: "add_cohost", CallID: "lobby-message", ClientID: "lobby-cohost"})
+	ts.AssertMessageReceived(cohost, "cohost_added", testTimeout)
+
+	ts.enterLobby(waiter, "lobby-message")
+	ts.Send(waiter, Message{Type: "lobby_message", CallID: "lobby-message", Data: "It's Dana from accounting"})
+	for _, moderator := range []*websocket.Conn{host, cohost} {
+		msg := ts.AssertMessageReceived(moderator, "lobby_message_received", testTimeout)
+		if msg.From != "lobby-waiter" || msg.Data != "It's Dana from accounting" {
+			t.Fatalf("lobby_message_received %+v", msg)
+		}
+	}
+	ts.RequireNoMessageOfType(member, "lobby_message_received", 100*time.Millisecond)
+}
+
+func TestLobbyMessageRules(t *testing.T) {
+	ts := NewTestServer(t)
+	host, waiter, outsider := ts.Connect(), ts.Connect(), ts.Connect()
+	ts.openLobbyRoom(host, "lobby-rules")
+
+	ts.Send(outsider, Message{Type: "lobby_message", CallID: "lobby-rules", Data: "hello"})
+	ts.AssertError(outsider, "not_in_lobby")
+
+	ts.enterLobby(waiter, "lobby-rules")
+	ts.Send(waiter, Message{Type: "lobby_message", CallID: "lobby-rules", Data: strings.Repeat("a", maxLobbyMessageLength+1)})
+	ts.AssertError(waiter, "invalid_lobby_message")
+
+	ts.Send(waiter, Message{Type: "lobby_message", CallID: "lobby-rules", Data: "first"})
+	ts.Send(waiter, Message{Type: "lobby_message", CallID: "lobby-rules", Data: "second"})
+	ts.AssertError(waiter, "rate_limited")
+	if msg := ts.AssertMessageReceived(host, "lobby_message_received", testTimeout); msg.Data != "first" {
+		t.Fatalf("host got %q, want first", msg.Data)
+	}
+	ts.RequireNoMessageOfType(host, "lobby_message_received", 100*time.Millisecond)
+}
+
+func TestLobbyWaiterDisconnectLeavesLobby(t *testing.T) {
+	ts := NewTestServer(t)
+	host, waiter := ts.Connect(), ts.ConnectWithClientID("lobby-gone")
+	ts.openLobbyRoom(host, "lobby-disconnect")
+	ts.enterLobby(waiter, "lobby-disconnect")
+	waiter.Close()
+
+	for deadline := time.Now().Add(testTimeout); ; time.Sleep(5 * time.Millisecond) {
+		room, unlock := rlockRoom("lobby-disconnect")
+		waiting := len(room.lobby)
+		unlock()
+		if waiting == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d clients still in the lobby after the waiter disconnected", waiting)
+		}
+	}
+	ts.Send(host, Message{Type: "admit_from_lobby", CallID: "lobby-disconnect", ClientID: "lobby-gone"})
+	ts.AssertError(host, "not_in_lobby")
+}
+
+func TestMessageLobbyReachesEveryWaiter(t *testing.T) {
+	ts := NewTestServer(t)
+	host, member := ts.Connect(), ts.ConnectWithClientID("message-lobby-member")
+	ts.openLobbyRoom(host, "message-lobby")
+	ts.admitMember(host, member, "message-lobby-member", "message-lobby")
+	waiters := make([]*websocket.Conn, 5)
+	for i := range waiters {
+		waiters[i] = ts.Connect()
+		ts.enterLobby(waiters[i], "message-lobby")
+	}
+
+	ts.Send(host, Message{Type: "message_lobby", CallID: "message-lobby", Data: "Running 10 minutes late, sorry"})
+	for i, waiter := range waiters {
+		if msg := ts.AssertMessageReceived(waiter, "message_from_host", testTimeout); msg.Data != "Running 10 minutes late, sorry" || msg.CallID != "message-lobby" {
+			t.Fatalf("waiter %d got message_from_host %+v", i, msg)
+		}
+	}
+	ts.RequireNoMessageOfType(member, "message_from_host", 100*time.Millisecond)
+}
+
+func TestMessageLobbyRules(t *testing.T) {
+	ts := NewTestServer(t)
+	host, member, waiter := ts.Connect(), ts.ConnectWithClientID("message-lobby-rules-member"), ts.Connect()
+	ts.openLobbyRoom(host, "message-lobby-rules")
+	ts.admitMember(host, member, "message-lobby-rules-member", "message-lobby-rules")
+	ts.enterLobby(waiter, "message-lobby-rules")
+
+	ts.Send(member, Message{Type: "message_lobby", CallID: "message-lobby-rules", Data: "not the host"})
+	ts.AssertError(member, "not_host")
+	ts.Send(host, Message{Type: "message_lobby", CallID: "message-lobby-rules", Data: strings.Repeat("a", maxLobbyMessageLength+1)})
+	ts.AssertError(host, "invalid_lobby_message")
+
+	ts.Send(host, Message{Type: "message_lobby", CallID: "message-lobby-rules", Data: "first"})
+	ts.Send(host, Message{Type: "message_lobby", CallID: "message-lobby-rules", Data: "second"})
+	ts.AssertError(host, "rate_limited")
+	if msg := ts.AssertMessageReceived(waiter, "message_from_host", testTimeout); msg.Data != "first" {
+		t.Fatalf("waiter got %q, want first", msg.Data)
+	}
+	ts.RequireNoMessageOfType(waiter, "message_from_host", 100*time.Millisecond)
+}
+
+func TestLobbyMediaShownToWaiters(t *testing.T) {
+	ts := NewTestServer(t)
+	host, early, late := ts.Connect(), ts.Connect(), ts.Connect()
+	ts.openLobbyRoom(host, "lobby-media")
+	ts.enterLobby(early, "lobby-media")
+
+	ts.Send(host, Message{Type: "set_lobby_media", CallID: "lobby-media", VideoURL: "https://cdn.example.com/welcome.mp4", MessageText: "Please wait..."})
+	if msg := ts.AssertMessageReceived(early, "lobby_media", testTimeout); msg.VideoURL != "https://cdn.example.com/welcome.mp4" || msg.MessageText != "Please wait..." {
+		t.Fatalf("waiter already in the lobby got lobby_media %+v", msg)
+	}
+	ts.enterLobby(late, "lobby-media")
+	if msg := ts.AssertMessageReceived(late, "lobby_media", testTimeout); msg.VideoURL != "https://cdn.example.com/welcome.mp4" {
+		t.Fatalf("waiter entering the lobby got lobby_media %+v", msg)
+	}
+
+	ts.Send(host, Message{Type: "set_lobby_media", CallID: "lobby-media", VideoURL: "https://cdn.example.com/late.mp4", MessageText: "Nearly there"})
+	if msg := ts.AssertMessageReceived(late, "lobby_media", testTimeout); msg.VideoURL != "https://cdn.example.com/late.mp4" || msg.MessageText != "Nearly there" {
+		t.Fatalf("lobby_media after an update %+v", msg)
+	}
+	info, _ := NewServer().GetRoom("lobby-media")
+	if media := info.Options.LobbyMedia; media == nil || media.VideoURL != "https://cdn.example.com/late.mp4" || media.Message != "Nearly there" {
+		t.Fatalf("room options carry lobby media %+v", media)
+	}
+}
+
+func TestLobbyMediaRules(t *testing.T) {
+	ts := NewTestServer(t)
+	host, member, plain := ts.Connect(), ts.ConnectWithClientID("lobby-media-member"), ts.Connect()
+	ts.openLobbyRoom(host, "lobby-media-rules")
+	ts.admitMember(host, member, "lobby-media-member", "lobby-media-rules")
+
+	for _, videoURL := range []string{"http://cdn.example.com/welcome.mp4", "javascript:alert(1)", "https://", ""} {
+		ts.Send(host, Message{Type: "set_lobby_media", CallID: "lobby-media-rules", VideoURL: videoURL})
+		ts.AssertError(host, "invalid_lobby_media")
+	}
+	ts.Send(member, Message{Type: "set_lobby_media", CallID: "lobby-media-rules", VideoURL: "https://cdn.example.com/welcome.mp4"})
+	ts.AssertError(member, "not_host")
+
+	ts.Send(plain, Message{Type: "offer", CallID: "lobby-media-no-lobby", Data: sdpData("offer")})
+	ts.waitForRoom("lobby-media-no-lobby", 1)
+	ts.Send(plain, Message{Type: "set_lobby_media", CallID: "lobby-media-no-lobby", VideoURL: "https://cdn.example.com/welcome.mp4"})
+	ts.AssertError(plain, "lobby_disabled")
+}
+
+func TestDisablingLobbyRemovesMediaAndAdmitsWaiters(t *testing.T) {
+	ts := NewTestServer(t)
+	host, waiter := ts.Connect(), ts.Connect()
+	ts.openLobbyRoom(host, "lobby-off")
+	ts.Send(host, Message{Type: "set_lobby_media", CallID: "lobby-off", VideoURL: "https://cdn.example.com/welcome.mp4"})
+	ts.enterLobby(waiter, "lobby-off")
+	ts.AssertMessageReceived(waiter, "lobby_media", testTimeout)
+
+	ts.Send(host, Message{Type: "set_lobby", CallID: "lobby-off", Lobby: false})
+	ts.AssertMessageReceived(waiter, "lobby_admitted", testTimeout)
+	ts.Send(waiter, Message{Type: "accept_call", CallID: "lobby-off"})
+	ts.AssertMessageReceived(waiter, "call_joined", testTimeout)
+	if info, _ := NewServer().GetRoom("lobby-off"); info.Options.Lobby || info.Options.LobbyMedia != nil {
+		t.Fatalf("room options after disabling the lobby %+v", info.Options)
+	}
+
+	ts.Send(host, Message{Type: "set_lobby", CallID: "lobby-off", Lobby: true})
+	ts.Send(host, Message{Type: "set_lobby_media", CallID: "lobby-off", VideoURL: "https://cdn.example.com/welcome.mp4"})
+	ts.RequireNoMessageOfType(host, "error", 100*time.Millisecond)
+}
@@ -0,0 +1,179 @@
+package signaling
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Long-poll receive timeouts, in seconds
+const (
+	defaultPollTimeout = 25
+	maxPollTimeout     = 60
+)
+
+var errPollTimeout = errors.New("long-poll client stopped polling")
+
+// pollConn is the transport of a client using the HTTP long-polling fallback instead of a WebSocket
+type pollConn struct {
+	addr     pollAddr
+	token    string      // secret the client must send as Authorization: Bearer, since its client ID is shared with peers
+	frames   chan []byte // frames written by the client's writer, waiting for a receive request
+	lastPoll atomic.Int64
+}
+
+// pollAddr is the remote address of a long-poll client
+type pollAddr string
+
+func (a pollAddr) Network() string { return "http" }
+func (a pollAddr) String() string  { return string(a) }
+
+// deliver queues a frame for the client's next receive request
+func (p *pollConn) deliver(data []byte) error {
+	select {
+	case p.frames <- data:
+		return nil
+	default:
+		return errSendQueueFull
+	}
+}
+
+// touch records that the client has just made a request
+func (p *pollConn) touch() {
+	p.lastPoll.Store(time.Now().UnixNano())
+}
+
+// checkAlive fails once the client has gone longer than readTimeout without a request
+func (p *pollConn) checkAlive() error {
+	if time.Since(time.Unix(0, p.lastPoll.Load())) > readTimeout() {
+		return errPollTimeout
+	}
+	return nil
+}
+
+// handlePollConnect registers a long-poll client and returns its client ID and token
+func handlePollConnect(w http.ResponseWriter, r *http.Request) {
+	if atCapacity(int(clientCount.Load())) {
+		http.Error(w, "Server at capacity", http.StatusServiceUnavailable)
+		return
+	}
+	license, err := parseLicense(r)
+	if err != nil {
+		log.Printf("Rejecting long-poll client %v with invalid license token: %v", r.RemoteAddr, err)
+		http.Error(w, "Invalid license token", http.StatusUnauthorized)
+		return
+	}
+	auth, err := parseAuth(r)
+	if err != nil {
+		log.Printf("Rejecting long-poll client %v with invalid token: %v", r.RemoteAddr, err)
+		http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+		return
+	}
+	if !claimSubject(auth) {
+		log.Printf("Rejecting long-poll client %v: client ID %s is already connected", r.RemoteAddr, auth.Subject)
+		http.Error(w, "Client ID already connected", http.StatusConflict)
+		return
+	}
+	p := &pollConn{
+		addr:   pollAddr(r.RemoteAddr),
+		token:  newClientID() + newClientID(),
+		frames: make(chan []byte, sendQueueSize),
+	}
+	p.touch()
+	ws := newConn(nil, nil, p)
+	ws.forwardedAddr = forwardedAddr(r)
+	client, _ := registerClient(ws, remoteIP(r), auth)
+	client.License = license
+	writeJSONResponse(w, http.StatusOK, map[string]string{"clientId": client.id, "token": p.token})
+}
+
+// pollClient returns the long-poll client named in the path, writing an error unless the request carries its token
+func pollClient(w http.ResponseWriter, r *http.Request) (*Client, bool) {
+	client := findClient(r.PathValue("clientId"))
+	if client == nil || client.conn.poll == nil {
+		http.Error(w, "Client not found", http.StatusNotFound)
+		return nil, false
+	}
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(client.conn.poll.token)) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	client.conn.poll.touch()
+	return client, true
+}
+
+// handlePollSend dispatches one message from a long-poll client as if it had arrived over its WebSocket
+func handlePollSend(w http.ResponseWriter, r *http.Request) {
+	client, ok := pollClient(w, r)
+	if !ok {
+		return
+	}
+	var msg Message
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&msg); err != nil {
+		http.Error(w, "Invalid message", http.StatusBadRequest)
+		return
+	}
+	client.recordMessage()
+	dispatchMessage(client.conn, msg)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlePollReceive waits up to ?timeout= seconds for messages to a long-poll client and returns them as a JSON array
+func handlePollReceive(w http.ResponseWriter, r *http.Request) {
+	client, ok := pollClient(w, r)
+	if !ok {
+		return
+	}
+	timeout := defaultPollTimeout
+	if v := r.URL.Query().Get("timeout"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid timeout", http.StatusBadRequest)
+			return
+		}
+		timeout = min(n, maxPollTimeout)
+	}
+
+	p := client.conn.poll
+	var frames [][]byte
+	timer := time.NewTimer(time.Duration(timeout) * time.Second)
+	defer timer.Stop()
+	select {
+	case frame := <-p.frames:
+		frames = append(frames, frame)
+	case <-timer.C:
+	case <-client.conn.done:
+	case <-r.Context().Done():
+		return
+	}
+	for drained := false; !drained; {
+		select {
+		case frame := <-p.frames:
+			frames = append(frames, frame)
+		default:
+			drained = true
+		}
+	}
+	p.touch()
+
+	messages := []json.RawMessage{}
+	for _, frame := range frames {
+		var batch []json.RawMessage
+		if len(frame) > 0 && frame[0] == '[' && json.Unmarshal(frame, &batch) == nil {
+			messages = append(messages, batch...)
+			continue
+		}
+		messages = append(messages, frame)
+	}
+	writeJSONResponse(w, http.StatusOK, messages)
+	if len(messages) > 0 {
+		log.Printf("Delivered %d messages to long-poll client %s", len(messages), client.id)
+	}
+}
@@ -0,0 +1,185 @@
+package signaling
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// pollingClient is a client of the long-polling fallback, with the messages it has received but not yet looked at
+type pollingClient struct {
+	ts      *TestServer
+	id      string
+	token   string
+	pending []Message
+}
+
+// pollConnect makes a long-poll connect request as id with the extra headers and returns the response
+func (ts *TestServer) pollConnect(id string, header http.Header) *http.Response {
+	ts.t.Helper()
+	req, err := http.NewRequest("POST", ts.URL()+"/api/v1/poll/connect?token="+ts.Token(id, jwt.MapClaims{"rooms": "*"}), nil)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	ts.t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// connectPolling registers a long-poll client as id and disconnects it when the test ends
+func (ts *TestServer) connectPolling(id string) *pollingClient {
+	ts.t.Helper()
+	return ts.connectPollingHeader(id, nil)
+}
+
+// connectPollingHeader is connectPolling with extra headers on the connect request
+func (ts *TestServer) connectPollingHeader(id string, header http.Header) *pollingClient {
+	ts.t.Helper()
+	resp := ts.pollConnect(id, header)
+	if resp.StatusCode != http.StatusOK {
+		ts.t.Fatalf("long-poll connect status %d", resp.StatusCode)
+	}
+	var registered struct {
+		ClientID string `json:"clientId"`
+		Token    string `json:"token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&registered); err != nil {
+		ts.t.Fatal(err)
+	}
+	ts.t.Cleanup(func() {
+		if client := findClient(registered.ClientID); client != nil {
+			cleanupClient(client.conn)
+		}
+	})
+	return &pollingClient{ts: ts, id: registered.ClientID, token: registered.Token}
+}
+
+// request makes an authorized request for the client's path under /api/v1/poll and returns the response
+func (pc *pollingClient) request(method, path, token string, body []byte) *http.Response {
+	pc.ts.t.Helper()
+	req, err := http.NewRequest(method, fmt.Sprintf("%s/api/v1/poll/%s/%s", pc.ts.URL(), pc.id, path), bytes.NewReader(body))
+	if err != nil {
+		pc.ts.t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		pc.ts.t.Fatal(err)
+	}
+	pc.ts.t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// send posts msg as the client
+func (pc *pollingClient) send(msg Message) {
+	pc.ts.t.Helper()
+	body, _ := json.Marshal(msg)
+	if resp := pc.request("POST", "send", pc.token, body); resp.StatusCode != http.StatusNoContent {
+		pc.ts.t.Fatalf("long-poll send status %d", resp.StatusCode)
+	}
+}
+
+// poll makes one receive request waiting up to timeout seconds and returns the messages it got
+func (pc *pollingClient) poll(timeout int) []Message {
+	pc.ts.t.Helper()
+	resp := pc.request("GET", fmt.Sprintf("receive?timeout=%d", timeout), pc.token, nil)
+	if resp.StatusCode != http.StatusOK {
+		pc.ts.t.Fatalf("long-poll receive status %d", resp.StatusCode)
+	}
+	var messages []Message
+	if err := json.NewDecoder(resp.Body).Decode(&messages); err != nil {
+		pc.ts.t.Fatal(err)
+	}
+	return messages
+}
+
+// await polls until a message of msgType arrives, keeping the others for later calls
+func (pc *pollingClient) await(msgType string) Message {
+	pc.ts.t.Helper()
+	for deadline := time.Now().Add(testTimeout); ; {
+		for i, msg := range pc.pending {
+			if msg.Type == msgType {
+				pc.pending = append(pc.pending[:i], pc.pending[i+1:]...)
+				return msg
+			}
+		}
+		if time.Now().After(deadline) {
+			pc.ts.t.Fatalf("long-poll client %s got no %s", pc.id, msgType)
+		}
+		pc.pending = append(pc.pending, pc.poll(1)...)
+	}
+}
+
+func TestLongPollClientJoinsWebSocketCall(t *testing.T) {
+	ts := NewTestServer(t)
+	host := ts.ConnectWithClientID("poll-host")
+	ts.Send(host, Message{Type: "offer", CallID: "poll-call", Data: sdpData("offer")})
+	ts.waitForRoom("poll-call", 1)
+
+	guest := ts.connectPolling("poll-guest")
+	if guest.id != "poll-guest" || guest.token == "" {
+		t.Fatalf("long-poll client registered as %q with token %q", guest.id, guest.token)
+	}
+	guest.send(Message{Type: "join_call", CallID: "poll-call"})
+	if msg := guest.await("offer"); msg.Data != sdpData("offer") {
+		t.Fatalf("long-poll client got offer %q", msg.Data)
+	}
+	guest.await("call_joined")
+	if msg := ts.AssertMessageReceived(host, "peer_joined", testTimeout); msg.ClientID != "poll-guest" {
+		t.Fatalf("peer_joined for %q", msg.ClientID)
+	}
+
+	guest.send(Message{Type: "answer", CallID: "poll-call", Data: sdpData("answer")})
+	ts.AssertMessageReceived(host, "answer", testTimeout)
+	ts.Send(host, Message{Type: "relay", CallID: "poll-call", Data: "over websocket"})
+	if msg := guest.await("relay"); msg.From != "poll-host" || msg.Data != "over websocket" {
+		t.Fatalf("long-poll client got relay %+v", msg)
+	}
+}
+
+func TestLongPollReceiveRules(t *testing.T) {
+	ts := NewTestServer(t)
+	client := ts.connectPolling("poll-rules")
+	client.await("capabilities")
+
+	// a receive returns early only with messages, so once the greeting and any user_count are collected
+	// it must hold an empty answer for the whole timeout
+	for tries := 0; ; tries++ {
+		start := time.Now()
+		messages := client.poll(1)
+		if len(messages) == 0 {
+			if waited := time.Since(start); waited < 900*time.Millisecond {
+				t.Fatalf("empty receive with timeout=1 returned after %v", waited)
+			}
+			break
+		}
+		if tries == 3 {
+			t.Fatalf("receive never went idle, last got %d messages", len(messages))
+		}
+	}
+
+	if resp := client.request("GET", "receive?timeout=-1", client.token, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("negative timeout answered %d", resp.StatusCode)
+	}
+	if resp := client.request("GET", "receive?timeout=0", "wrong-token", nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("wrong token answered %d", resp.StatusCode)
+	}
+	if resp := client.request("POST", "send", client.token, []byte("{")); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("malformed message answered %d", resp.StatusCode)
+	}
+	stranger := &pollingClient{ts: ts, id: "poll-nobody", token: client.token}
+	if resp := stranger.request("GET", "receive?timeout=0", client.token, nil); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("unknown client answered %d", resp.StatusCode)
+	}
+}
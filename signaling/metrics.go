@@ -0,0 +1,41 @@
+package signaling
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Prometheus metrics, served on /metrics
+var (
+	pingRTT = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "videochat_ping_rtt_ms",
+		Help:    "Round-trip time of WebSocket ping/pong frames in milliseconds.",
+		Buckets: []float64{10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000},
+	}, []string{"clientID"})
+
+	networkQuality = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "videochat_network_quality_level",
+		Help:    "Network quality levels (1-5) reported by clients.",
+		Buckets: []float64{1, 2, 3, 4, 5},
+	})
+
+	reportedIssues = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "videochat_reported_issues_total",
+		Help: "Call quality issues reported by clients, by issue and the reporter's platform.",
+	}, []string{"issue", "platform"})
+
+	dataRelayMessages = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "videochat_data_relay_messages_total",
+		Help: "Data channel messages relayed through the signaling server with data_relay.",
+	})
+
+	positionUpdates = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "videochat_position_updates_total",
+		Help: "Spatial audio position updates relayed with position_update.",
+	})
+
+	roomsByTag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "videochat_rooms_by_tag",
+		Help: "Open rooms carrying each tag set with tag_room.",
+	}, []string{"tag"})
+)
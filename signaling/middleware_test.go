@@ -0,0 +1,60 @@
+package signaling
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestStaticFilesCarrySecurityHeaders(t *testing.T) {
+	previous := contentSecurityPolicy
+	contentSecurityPolicy = newContentSecurityPolicy([]string{"wss://signal.example.com"})
+	t.Cleanup(func() { contentSecurityPolicy = previous })
+	static := http.FS(fstest.MapFS{"index.html": {Data: []byte("<!doctype html><title>call</title>")}})
+	server := httptest.NewServer(NewServer().Handler(static))
+	t.Cleanup(server.Close)
+
+	resp, err := http.Get(server.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("index status %d", resp.StatusCode)
+	}
+	for key, value := range map[string]string{
+		"X-Frame-Options":        "SAMEORIGIN",
+		"X-Content-Type-Options": "nosniff",
+		"Referrer-Policy":        "strict-origin-when-cross-origin",
+		"Permissions-Policy":     "camera=(self), microphone=(self), geolocation=()",
+	} {
+		if got := resp.Header.Get(key); got != value {
+			t.Errorf("%s: %q, want %q", key, got, value)
+		}
+	}
+
+	directives := make(map[string]string)
+	for _, directive := range strings.Split(resp.Header.Get("Content-Security-Policy"), "; ") {
+		name, sources, _ := strings.Cut(directive, " ")
+		directives[name] = sources
+	}
+	for name, sources := range map[string]string{
+		"default-src":     "'self'",
+		"script-src":      "'self'",
+		"connect-src":     "'self' wss://signal.example.com",
+		"frame-ancestors": "'self'",
+		"object-src":      "'none'",
+	} {
+		if directives[name] != sources {
+			t.Errorf("CSP %s %q, want %q", name, directives[name], sources)
+		}
+	}
+}
+
+func TestContentSecurityPolicyWithoutConnectSrc(t *testing.T) {
+	if csp := newContentSecurityPolicy(nil); !strings.Contains(csp, "connect-src 'self';") {
+		t.Fatalf("CSP without CSP_CONNECT_SRC: %s", csp)
+	}
+}
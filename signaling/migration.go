@@ -0,0 +1,196 @@
+package signaling
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Room migration configuration; every server a room may move between shares the secret
+var (
+	migrationSecret   = envString("MIGRATION_SECRET", "")
+	migrationTokenTTL = time.Duration(envInt("MIGRATION_TOKEN_TTL_SECONDS", 300)) * time.Second
+)
+
+// RoomState is the part of a room carried to the target server in a migration token
+type RoomState struct {
+	MaxClients     int             `json:"maxClients,omitempty"`
+	Layout         string          `json:"layout,omitempty"`
+	PinnedClientID string          `json:"pinnedClientId,omitempty"`
+	SharedDocument *SharedDocument `json:"sharedDocument,omitempty"`
+
+	RetentionPolicy string   `json:"retentionPolicy,omitempty"`
+	Tags            []string `json:"tags,omitempty"`
+}
+
+// migrationClaims are signed into each member's migration token
+type migrationClaims struct {
+	CallID   string    `json:"callId"`
+	ClientID string    `json:"clientId"` // the member's client ID on the old server
+	Host     bool      `json:"host,omitempty"`
+	Room     RoomState `json:"room"`
+	jwt.RegisteredClaims
+}
+
+// MigrateRoomRequest is the body of POST /api/v1/admin/migrate-room
+type MigrateRoomRequest struct {
+	CallID       string `json:"callId"`
+	TargetServer string `json:"targetServer"`
+}
+
+// validateTargetServer checks that a migration target is a WebSocket URL
+func validateTargetServer(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "ws" && u.Scheme != "wss") || u.Host == "" {
+		return errors.New("targetServer must be a ws:// or wss:// URL")
+	}
+	return nil
+}
+
+// handleMigrateRoom moves a room to another server: relaying in the room stops and every member is sent
+// server_migration with a token the target server restores the room from
+func handleMigrateRoom(w http.ResponseWriter, r *http.Request) {
+	if migrationSecret == "" {
+		http.Error(w, "Room migration is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	var req MigrateRoomRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := validateTargetServer(req.TargetServer); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	room, unlock := lockRoom(req.CallID)
+	if room == nil {
+		http.Error(w, "Room not found", http.StatusNotFound)
+		return
+	}
+	if room.migratingTo != "" {
+		unlock()
+		http.Error(w, "Room is already migrating", http.StatusConflict)
+		return
+	}
+	room.migratingTo = req.TargetServer
+	state := RoomState{
+		MaxClients:     room.options.MaxClients,
+		Layout:         room.layout,
+		PinnedClientID: room.pinnedClientID,
+		SharedDocument: room.SharedDocument,
+
+		RetentionPolicy: room.options.RetentionPolicy,
+		Tags:            room.Tags,
+	}
+	expiresAt := jwt.NewNumericDate(time.Now().Add(migrationTokenTTL))
+	tokens := make(map[*wsConn]string, len(room.clients))
+	for member := range room.clients {
+		claims := migrationClaims{
+			CallID:           req.CallID,
+			ClientID:         room.memberIDs[member],
+			Host:             room.host == member,
+			Room:             state,
+			RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: expiresAt},
+		}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(migrationSecret))
+		if err != nil {
+			log.Printf("Error signing migration token for room %s: %v", req.CallID, err)
+			continue
+		}
+		tokens[member] = token
+	}
+	unlock()
+
+	for member, token := range tokens {
+		if err := member.WriteJSON(Message{
+			Type:           "server_migration",
+			CallID:         req.CallID,
+			TargetServer:   req.TargetServer,
+			MigrationToken: token,
+		}); err != nil {
+			log.Printf("Error sending server_migration to %v: %v", member.RemoteAddr(), err)
+			go cleanupClient(member)
+		}
+	}
+	audit("room_migrated", req.CallID, "", map[string]interface{}{"targetServer": req.TargetServer, "clients": len(tokens)})
+	log.Printf("Migrating room %s with %d clients to %s, requested by %v", req.CallID, len(tokens), req.TargetServer, r.RemoteAddr)
+	writeJSONResponse(w, http.StatusAccepted, map[string]interface{}{"callId": req.CallID, "targetServer": req.TargetServer, "clients": len(tokens)})
+}
+
+// handleRestoreRoom lets a client arriving from a migration rejoin its room on this server,
+// recreating the room from the token when it is the first member back
+func handleRestoreRoom(sender *wsConn, msg Message) {
+	if migrationSecret == "" {
+		sendError(sender, "migration_unavailable")
+		return
+	}
+	claims := &migrationClaims{}
+	_, err := jwt.ParseWithClaims(msg.MigrationToken, claims, func(*jwt.Token) (interface{}, error) {
+		return []byte(migrationSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil || claims.CallID == "" {
+		log.Printf("Rejected migration token from %v: %v", sender.RemoteAddr(), err)
+		sendError(sender, "invalid_migration_token")
+		return
+	}
+
+	room, created, unlock := lockOrCreateRoom(claims.CallID)
+	if room.restoredIDs[claims.ClientID] {
+		unlock()
+		sendError(sender, "invalid_migration_token")
+		return
+	}
+	if err := admitToRoom(claims.CallID, room, created, sender); err != nil {
+		unlock()
+		log.Printf("Hook rejected restore of call %s from %v: %v", claims.CallID, sender.RemoteAddr(), err)
+		sendError(sender, err.Error())
+		return
+	}
+	if created {
+		room.options.MaxClients = claims.Room.MaxClients
+		room.layout = claims.Room.Layout
+		room.pinnedClientID = claims.Room.PinnedClientID
+		room.SharedDocument = claims.Room.SharedDocument
+		room.options.RetentionPolicy = claims.Room.RetentionPolicy
+		if tags, ok := normalizeRoomTags(claims.Room.Tags); ok {
+			room.Tags = tags
+			countRoomTags(nil, tags)
+		}
+	}
+	if room.restoredIDs == nil {
+		room.restoredIDs = make(map[string]bool)
+	}
+	room.restoredIDs[claims.ClientID] = true
+	if claims.Host || room.host == nil {
+		room.host = sender
+	}
+	room.addClient(sender)
+	room.LastActivity = time.Now()
+	joined := room.joinedMessage(claims.CallID, sender)
+	unlock()
+
+	clientsMu.Lock()
+	if client, ok := getClient(sender); ok {
+		client.callIDs[claims.CallID] = true
+		delete(idleClients, sender)
+	}
+	clientsMu.Unlock()
+
+	if err := sender.WriteJSON(joined); err != nil {
+		log.Printf("Error sending call_joined to %v: %v", sender.RemoteAddr(), err)
+		go cleanupClient(sender)
+		return
+	}
+	announcePeerJoined(sender, claims.CallID)
+	log.Printf("Client %v restored into migrated room %s (was %s)", sender.RemoteAddr(), claims.CallID, claims.ClientID)
+}
@@ -0,0 +1,121 @@
+package signaling
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// setMigrationSecret replaces MIGRATION_SECRET for the rest of the test
+func setMigrationSecret(t *testing.T, secret string) {
+	previous := migrationSecret
+	migrationSecret = secret
+	t.Cleanup(func() { migrationSecret = previous })
+}
+
+// migrateRoom posts req to the admin migrate-room endpoint and returns the response status
+func (ts *TestServer) migrateRoom(token string, req MigrateRoomRequest) int {
+	ts.t.Helper()
+	body, err := json.Marshal(req)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	httpReq, err := http.NewRequest("POST", ts.URL()+"/api/v1/admin/migrate-room", bytes.NewReader(body))
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestMigrateAndRestoreRoom(t *testing.T) {
+	ts := NewTestServer(t)
+	setAdminToken(t, "migration-admin")
+	setMigrationSecret(t, "migration-secret")
+	host, member := ts.ConnectWithClientID("migration-host"), ts.ConnectWithClientID("migration-member")
+	ts.startCall(host, member, "migration-call")
+	ts.Send(host, Message{Type: "share_document", CallID: "migration-call", URL: "https://docs.example.com/deck"})
+	ts.AssertMessageReceived(member, "document_shared", testTimeout)
+
+	target := "wss://backup.example.com/ws"
+	if status := ts.migrateRoom("migration-admin", MigrateRoomRequest{CallID: "migration-call", TargetServer: target}); status != http.StatusAccepted {
+		t.Fatalf("migrate-room status %d, want 202", status)
+	}
+	tokens := make(map[*websocket.Conn]string)
+	for _, conn := range []*websocket.Conn{host, member} {
+		msg := ts.AssertMessageReceived(conn, "server_migration", testTimeout)
+		if msg.TargetServer != target || msg.MigrationToken == "" {
+			t.Fatalf("server_migration %+v", msg)
+		}
+		tokens[conn] = msg.MigrationToken
+	}
+	if status := ts.migrateRoom("migration-admin", MigrateRoomRequest{CallID: "migration-call", TargetServer: target}); status != http.StatusConflict {
+		t.Fatalf("second migrate-room status %d, want 409", status)
+	}
+
+	// this server plays the target once the old room is gone
+	host.Close()
+	member.Close()
+	for deadline := time.Now().Add(testTimeout); ; time.Sleep(5 * time.Millisecond) {
+		room, unlock := rlockRoom("migration-call")
+		if room == nil {
+			break
+		}
+		unlock()
+		if time.Now().After(deadline) {
+			t.Fatal("migrated room never emptied")
+		}
+	}
+
+	newMember, newHost := ts.Connect(), ts.ConnectWithClientID("migration-new-host")
+	ts.Send(newMember, Message{Type: "restore_room", MigrationToken: tokens[member]})
+	joined := ts.AssertMessageReceived(newMember, "call_joined", testTimeout)
+	if joined.SharedDocument == nil || joined.SharedDocument.URL != "https://docs.example.com/deck" {
+		t.Fatalf("restored room has shared document %+v", joined.SharedDocument)
+	}
+	ts.Send(newHost, Message{Type: "restore_room", MigrationToken: tokens[host]})
+	ts.AssertMessageReceived(newHost, "call_joined", testTimeout)
+	ts.AssertMessageReceived(newMember, "peer_joined", testTimeout)
+
+	room, unlock := rlockRoom("migration-call")
+	hostRestored := room.host != nil && room.memberIDs[room.host] == "migration-new-host"
+	unlock()
+	if !hostRestored {
+		t.Fatal("the old host did not get the host role back")
+	}
+
+	replay := ts.Connect()
+	ts.Send(replay, Message{Type: "restore_room", MigrationToken: tokens[host]})
+	ts.AssertError(replay, "invalid_migration_token")
+}
+
+func TestMigrateRoomRules(t *testing.T) {
+	ts := NewTestServer(t)
+	setAdminToken(t, "migration-admin")
+	conn := ts.Connect()
+
+	if status := ts.migrateRoom("migration-admin", MigrateRoomRequest{CallID: "migration-none", TargetServer: "wss://backup.example.com/ws"}); status != http.StatusServiceUnavailable {
+		t.Fatalf("status %d without MIGRATION_SECRET, want 503", status)
+	}
+	ts.Send(conn, Message{Type: "restore_room", MigrationToken: "anything"})
+	ts.AssertError(conn, "migration_unavailable")
+
+	setMigrationSecret(t, "migration-secret")
+	if status := ts.migrateRoom("migration-admin", MigrateRoomRequest{CallID: "migration-none", TargetServer: "https://backup.example.com"}); status != http.StatusBadRequest {
+		t.Fatalf("status %d for an https target, want 400", status)
+	}
+	if status := ts.migrateRoom("migration-admin", MigrateRoomRequest{CallID: "migration-none", TargetServer: "wss://backup.example.com/ws"}); status != http.StatusNotFound {
+		t.Fatalf("status %d for an unknown room, want 404", status)
+	}
+	ts.Send(conn, Message{Type: "restore_room", MigrationToken: "not-a-token"})
+	ts.AssertError(conn, "invalid_migration_token")
+}
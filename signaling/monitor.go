@@ -0,0 +1,63 @@
+package signaling
+
+import (
+	"crypto/subtle"
+	"log"
+)
+
+// monitorToken authorizes passive room monitors, monitoring is disabled when it is empty
+var monitorToken = envString("MONITOR_TOKEN", "")
+
+// handleMonitorRoom attaches a client to a room as an invisible observer
+func handleMonitorRoom(sender *wsConn, msg Message) {
+	if monitorToken == "" || subtle.ConstantTimeCompare([]byte(msg.MonitorToken), []byte(monitorToken)) != 1 {
+		log.Printf("Rejected monitor request for call %s from %v", msg.CallID, sender.RemoteAddr())
+		sendError(sender, "unauthorized")
+		return
+	}
+	client, ok := getClient(sender)
+	if !ok {
+		return
+	}
+
+	room, unlock := lockRoom(msg.CallID)
+	if room == nil {
+		sendError(sender, "Call not found")
+		return
+	}
+	room.monitors[client.id] = client
+	unlock()
+
+	clientsMu.Lock()
+	client.monitoring[msg.CallID] = true
+	delete(idleClients, sender)
+	clientsMu.Unlock()
+
+	if err := sender.WriteJSON(Message{Type: "monitoring", CallID: msg.CallID}); err != nil {
+		log.Printf("Error sending monitoring to %v: %v", sender.RemoteAddr(), err)
+		go cleanupClient(sender)
+		return
+	}
+	log.Printf("Client %v monitoring call %s", sender.RemoteAddr(), msg.CallID)
+}
+
+// copyToMonitors sends a copy of a message relayed in a room to the room's monitors and event streams
+func copyToMonitors(msg Message) {
+	publishEvent(msg)
+	room, unlock := rlockRoom(msg.CallID)
+	if room == nil {
+		return
+	}
+	monitors := make([]*wsConn, 0, len(room.monitors))
+	for _, monitor := range room.monitors {
+		monitors = append(monitors, monitor.conn)
+	}
+	unlock()
+
+	for _, conn := range monitors {
+		if err := conn.WriteJSON(msg); err != nil {
+			log.Printf("Error copying %s to monitor %v: %v", msg.Type, conn.RemoteAddr(), err)
+			go cleanupClient(conn)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package signaling
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// activeCallIDs returns the calls the client with id is in, sorted
+func activeCallIDs(id string) string {
+	client := findClient(id)
+	if client == nil {
+		return ""
+	}
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	var callIDs []string
+	for callID := range client.callIDs {
+		callIDs = append(callIDs, callID)
+	}
+	sort.Strings(callIDs)
+	return strings.Join(callIDs, ",")
+}
+
+// monitorTwoLines puts the supervisor in a call with each line
+func (ts *TestServer) monitorTwoLines() (supervisor, lineA, lineB *websocket.Conn) {
+	ts.t.Helper()
+	supervisor, lineA, lineB = ts.ConnectWithClientID("multi-supervisor"), ts.Connect(), ts.Connect()
+	ts.startCall(lineA, supervisor, "multi-a")
+	ts.startCall(lineB, supervisor, "multi-b")
+	if got := activeCallIDs("multi-supervisor"); got != "multi-a,multi-b" {
+		ts.t.Fatalf("supervisor is in %q, want multi-a,multi-b", got)
+	}
+	return supervisor, lineA, lineB
+}
+
+func TestMultiCallKeepsRoomsApart(t *testing.T) {
+	ts := NewTestServer(t)
+	supervisor, lineA, lineB := ts.monitorTwoLines()
+
+	ts.Send(lineA, Message{Type: "relay", CallID: "multi-a", Data: "line a"})
+	if msg := ts.AssertMessageReceived(supervisor, "relay", testTimeout); msg.CallID != "multi-a" || msg.Data != "line a" {
+		t.Fatalf("supervisor got %+v", msg)
+	}
+	ts.Send(supervisor, Message{Type: "relay", CallID: "multi-b", Data: "to line b"})
+	if msg := ts.AssertMessageReceived(lineB, "relay", testTimeout); msg.CallID != "multi-b" {
+		t.Fatalf("line b got %+v", msg)
+	}
+	ts.RequireNoMessageOfType(lineA, "relay", 100*time.Millisecond)
+}
+
+func TestMultiCallHangupLeavesOneCall(t *testing.T) {
+	ts := NewTestServer(t)
+	supervisor, lineA, lineB := ts.monitorTwoLines()
+
+	ts.Send(supervisor, Message{Type: "hangup"})
+	ts.AssertError(supervisor, "missing_call_id")
+	ts.Send(supervisor, Message{Type: "hangup", CallID: "multi-a"})
+	ts.AssertMessageReceived(lineA, "peer_disconnected", testTimeout)
+	ts.RequireNoMessageOfType(lineB, "peer_disconnected", 100*time.Millisecond)
+	if got := activeCallIDs("multi-supervisor"); got != "multi-b" {
+		t.Fatalf("after hanging up multi-a the supervisor is in %q", got)
+	}
+
+	ts.Send(lineB, Message{Type: "relay", CallID: "multi-b", Data: "still here"})
+	ts.AssertMessageReceived(supervisor, "relay", testTimeout)
+}
+
+func TestMultiCallDisconnectLeavesEveryCall(t *testing.T) {
+	ts := NewTestServer(t)
+	supervisor, lineA, lineB := ts.monitorTwoLines()
+
+	supervisor.Close()
+	for _, line := range []*websocket.Conn{lineA, lineB} {
+		ts.AssertMessageReceived(line, "peer_disconnected", testTimeout)
+	}
+	for _, callID := range []string{"multi-a", "multi-b"} {
+		if room, unlock := rlockRoom(callID); room != nil {
+			members := len(room.clients)
+			unlock()
+			if members != 1 {
+				t.Fatalf("room %s has %d members after the supervisor left", callID, members)
+			}
+		}
+	}
+}
+
+func TestIncomingCallSkipsClientsInAnyCall(t *testing.T) {
+	ts := NewTestServer(t)
+	setRingTimeout(t, time.Minute)
+	busy, peer := ts.Connect(), ts.Connect()
+	idle := ts.Connect()
+	ts.startCall(peer, busy, "multi-busy")
+
+	caller := ts.Connect()
+	ts.Send(caller, Message{Type: "incoming_call", CallID: "multi-ring"})
+	ts.AssertMessageReceived(idle, "incoming_call", testTimeout)
+	for _, conn := range []*websocket.Conn{busy, peer} {
+		ts.RequireNoMessageOfType(conn, "incoming_call", 50*time.Millisecond)
+	}
+}
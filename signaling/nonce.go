@@ -0,0 +1,60 @@
+package signaling
+
+import "time"
+
+// Replay protection configuration
+var (
+	// enforceNonces rejects messages without a nonce and sentAt; duplicates of a nonce are rejected either way
+	enforceNonces = envString("ENFORCE_NONCES", "false") == "true"
+	// nonceWindow is how far a nonced message's sentAt may be from the server clock, and so how long its nonce is kept
+	nonceWindow = time.Duration(envInt("NONCE_WINDOW_SECONDS", 300)) * time.Second
+	// maxNonces is how many nonces a client may have inside the replay window at once
+	maxNonces = envInt("MAX_NONCES_PER_CLIENT", 1000)
+)
+
+// checkNonce reports whether a message from the client may be dispatched. A message with a nonce must have
+// been sent, per its RFC 3339 sentAt, within nonceWindow of now, and its nonce must not have been seen from
+// the client while that window lasts; a replay after the window is rejected by its timestamp instead. Without
+// ENFORCE_NONCES a message may leave out the nonce, or just sentAt, in which case the nonce is kept for
+// nonceWindow from now. A client already holding maxNonces unexpired nonces is refused further nonced
+// messages until some expire. The error code to send is returned with false.
+func (c *Client) checkNonce(nonce, sentAt string) (bool, string) {
+	if nonce == "" {
+		if enforceNonces {
+			return false, "missing_nonce"
+		}
+		return true, ""
+	}
+	if len(nonce) > 64 {
+		return false, "invalid_nonce"
+	}
+
+	now := time.Now()
+	sent := now
+	if sentAt != "" || enforceNonces {
+		t, err := time.Parse(time.RFC3339Nano, sentAt)
+		if err != nil || t.Before(now.Add(-nonceWindow)) || t.After(now.Add(nonceWindow)) {
+			return false, "stale_message"
+		}
+		sent = t
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if forgetAt, seen := c.nonces[nonce]; seen && now.Before(forgetAt) {
+		return false, "duplicate_message"
+	}
+	if len(c.nonces) >= maxNonces {
+		for n, forgetAt := range c.nonces {
+			if !now.Before(forgetAt) {
+				delete(c.nonces, n)
+			}
+		}
+		if len(c.nonces) >= maxNonces {
+			return false, "replay_window_full"
+		}
+	}
+	// past sent+nonceWindow a replay fails the timestamp check, so the nonce need not be kept any longer
+	c.nonces[nonce] = sent.Add(nonceWindow)
+	return true, ""
+}
@@ -0,0 +1,139 @@
+package signaling
+
+import (
+	"testing"
+	"time"
+)
+
+// setNonceWindow replaces NONCE_WINDOW_SECONDS for the rest of the test
+func setNonceWindow(t *testing.T, window time.Duration) {
+	previous := nonceWindow
+	nonceWindow = window
+	t.Cleanup(func() { nonceWindow = previous })
+}
+
+// nonceClient returns a client with an empty replay window, as registerClient makes it
+func nonceClient() *Client {
+	return &Client{nonces: make(map[string]time.Time)}
+}
+
+func TestReplayedMessageRejected(t *testing.T) {
+	ts := NewTestServer(t)
+	conn := ts.Connect()
+	echo := Message{Type: "echo", Seq: 1, Nonce: "replay-1", SentAt: time.Now().UTC().Format(time.RFC3339Nano)}
+	ts.Send(conn, echo)
+	ts.AssertMessageReceived(conn, "echo_reply", testTimeout)
+	ts.Send(conn, echo)
+	ts.AssertError(conn, "duplicate_message")
+
+	// nonces are per client, so another client may use the same one
+	other := ts.Connect()
+	ts.Send(other, echo)
+	ts.AssertMessageReceived(other, "echo_reply", testTimeout)
+}
+
+func TestNoncesBoundedPerClient(t *testing.T) {
+	previous := maxNonces
+	maxNonces = 3
+	t.Cleanup(func() { maxNonces = previous })
+	setNonceWindow(t, 50*time.Millisecond)
+	client := nonceClient()
+	sentAt := time.Now().Format(time.RFC3339Nano)
+	for _, nonce := range []string{"bounded-1", "bounded-2", "bounded-3"} {
+		if ok, code := client.checkNonce(nonce, sentAt); !ok {
+			t.Fatalf("%s rejected: %s", nonce, code)
+		}
+	}
+	if ok, code := client.checkNonce("bounded-4", sentAt); ok || code != "replay_window_full" {
+		t.Fatalf("nonce past the cap: %v %s, want replay_window_full", ok, code)
+	}
+	if ok, _ := nonceClient().checkNonce("bounded-4", sentAt); !ok {
+		t.Fatal("one client's full window refused another client's nonce")
+	}
+	// expired nonces make room again
+	time.Sleep(100 * time.Millisecond)
+	if ok, code := client.checkNonce("bounded-4", time.Now().Format(time.RFC3339Nano)); !ok {
+		t.Fatalf("nonce after the window passed rejected: %s", code)
+	}
+	if len(client.nonces) != 1 {
+		t.Fatalf("%d nonces kept, want only the latest", len(client.nonces))
+	}
+}
+
+func TestNoncesFreedOnDisconnect(t *testing.T) {
+	ts := NewTestServer(t)
+	conn := ts.ConnectWithClientID("nonce-leaver")
+	ts.Send(conn, Message{Type: "echo", Nonce: "leaver-1", SentAt: time.Now().Format(time.RFC3339Nano)})
+	ts.AssertMessageReceived(conn, "echo_reply", testTimeout)
+	conn.Close()
+	for deadline := time.Now().Add(testTimeout); findClient("nonce-leaver") != nil; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("client still registered after disconnecting")
+		}
+	}
+	// a reconnect is a new client with an empty window
+	again := ts.ConnectWithClientID("nonce-leaver")
+	ts.Send(again, Message{Type: "echo", Nonce: "leaver-1", SentAt: time.Now().Format(time.RFC3339Nano)})
+	ts.AssertMessageReceived(again, "echo_reply", testTimeout)
+}
+
+func TestMessageOutsideNonceWindowRejected(t *testing.T) {
+	ts := NewTestServer(t)
+	conn := ts.Connect()
+	for _, sentAt := range []time.Time{time.Now().Add(-time.Hour), time.Now().Add(time.Hour)} {
+		ts.Send(conn, Message{Type: "echo", Nonce: "window-" + sentAt.Format("150405"), SentAt: sentAt.Format(time.RFC3339Nano)})
+		ts.AssertError(conn, "stale_message")
+	}
+}
+
+func TestNonceForgottenOnlyOnceStale(t *testing.T) {
+	setNonceWindow(t, 50*time.Millisecond)
+	client := nonceClient()
+	sentAt := time.Now().Format(time.RFC3339Nano)
+	if ok, code := client.checkNonce("forget-1", sentAt); !ok {
+		t.Fatalf("first use rejected: %s", code)
+	}
+	if ok, code := client.checkNonce("forget-1", sentAt); ok || code != "duplicate_message" {
+		t.Fatalf("replay inside the window: %v %s, want duplicate_message", ok, code)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if ok, code := client.checkNonce("forget-1", sentAt); ok || code != "stale_message" {
+		t.Fatalf("replay after the window: %v %s, want stale_message", ok, code)
+	}
+}
+
+func TestEnforcedNonces(t *testing.T) {
+	previous := enforceNonces
+	enforceNonces = true
+	t.Cleanup(func() { enforceNonces = previous })
+	client := nonceClient()
+	for _, tc := range []struct {
+		nonce, sentAt, code string
+	}{
+		{"", time.Now().Format(time.RFC3339Nano), "missing_nonce"},
+		{"enforced-1", "", "stale_message"},
+		{"enforced-2", "yesterday", "stale_message"},
+		{"enforced-3", time.Now().Format(time.RFC3339Nano), ""},
+	} {
+		ok, code := client.checkNonce(tc.nonce, tc.sentAt)
+		if ok != (tc.code == "") || code != tc.code {
+			t.Errorf("checkNonce(%q, %q) = %v %q, want %q", tc.nonce, tc.sentAt, ok, code, tc.code)
+		}
+	}
+}
+
+func TestOptionalNonces(t *testing.T) {
+	client := nonceClient()
+	if ok, _ := client.checkNonce("", ""); !ok {
+		t.Fatal("message without a nonce rejected while nonces are optional")
+	}
+	if ok, _ := client.checkNonce("optional-1", ""); !ok {
+		t.Fatal("nonce without sentAt rejected while nonces are optional")
+	}
+	if ok, code := client.checkNonce("optional-1", ""); ok || code != "duplicate_message" {
+		t.Fatalf("replayed nonce without sentAt: %v %s, want duplicate_message", ok, code)
+	}
+	if ok, code := client.checkNonce(string(make([]byte, 65)), ""); ok || code != "invalid_nonce" {
+		t.Fatalf("long nonce: %v %s, want invalid_nonce", ok, code)
+	}
+}
@@ -0,0 +1,33 @@
+package signaling
+
+import (
+	"log"
+	"time"
+)
+
+// peerPingInterval is how often a client may ping, or answer, any one peer
+const peerPingInterval = 5 * time.Second
+
+// handlePeerPing forwards peer_ping and peer_pong to the named room member, stamped with when the server relayed it
+func handlePeerPing(sender *wsConn, msg Message) {
+	if !allowMessage(sender, msg.Type+":"+msg.To, 1, peerPingInterval) {
+		return
+	}
+	target := findRoomMember(msg.CallID, sender, msg.To)
+	if target == nil {
+		sendError(sender, "peer_not_found")
+		return
+	}
+	if err := target.WriteJSON(Message{
+		Type:      msg.Type,
+		CallID:    msg.CallID,
+		From:      clientID(sender),
+		To:        msg.To,
+		Seq:       msg.Seq,
+		SentAt:    msg.SentAt,
+		RelayedAt: time.Now().UTC().Format(time.RFC3339Nano),
+	}); err != nil {
+		log.Printf("Error relaying %s to %v: %v", msg.Type, target.RemoteAddr(), err)
+		go cleanupClient(target)
+	}
+}
@@ -0,0 +1,52 @@
+package signaling
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPeerPingRouting(t *testing.T) {
+	ts := NewTestServer(t)
+	alice, bob, carol := ts.ConnectWithClientID("ping-alice"), ts.ConnectWithClientID("ping-bob"), ts.ConnectWithClientID("ping-carol")
+	ts.startCall(alice, bob, "ping-call")
+	ts.Send(carol, Message{Type: "join_call", CallID: "ping-call"})
+	ts.AssertMessageReceived(carol, "call_joined", testTimeout)
+	outsider := ts.ConnectWithClientID("ping-outsider")
+
+	sentAt := time.Now().UTC().Format(time.RFC3339Nano)
+	ts.Send(alice, Message{Type: "peer_ping", CallID: "ping-call", To: "ping-bob", Seq: 1, SentAt: sentAt})
+	ping := ts.AssertMessageReceived(bob, "peer_ping", testTimeout)
+	if ping.From != "ping-alice" || ping.To != "ping-bob" || ping.CallID != "ping-call" || ping.Seq != 1 || ping.SentAt != sentAt {
+		t.Fatalf("bob got peer_ping %+v", ping)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, ping.RelayedAt); err != nil {
+		t.Fatalf("peer_ping relayedAt %q: %v", ping.RelayedAt, err)
+	}
+
+	ts.Send(bob, Message{Type: "peer_pong", CallID: "ping-call", To: ping.From, Seq: ping.Seq})
+	pong := ts.AssertMessageReceived(alice, "peer_pong", testTimeout)
+	if pong.From != "ping-bob" || pong.Seq != 1 {
+		t.Fatalf("alice got peer_pong %+v", pong)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, pong.RelayedAt); err != nil {
+		t.Fatalf("peer_pong relayedAt %q: %v", pong.RelayedAt, err)
+	}
+	ts.RequireNoMessageOfType(carol, "peer_ping", 50*time.Millisecond)
+	ts.RequireNoMessageOfType(carol, "peer_pong", 50*time.Millisecond)
+	ts.RequireNoMessageOfType(outsider, "peer_ping", 50*time.Millisecond)
+
+	// the limit is per peer, so carol can still be pinged while bob cannot
+	ts.Send(alice, Message{Type: "peer_ping", CallID: "ping-call", To: "ping-bob", Seq: 2})
+	ts.AssertError(alice, "rate_limited")
+	ts.Send(alice, Message{Type: "peer_ping", CallID: "ping-call", To: "ping-carol", Seq: 2})
+	if msg := ts.AssertMessageReceived(carol, "peer_ping", testTimeout); msg.From != "ping-alice" || msg.Seq != 2 {
+		t.Fatalf("carol got peer_ping %+v", msg)
+	}
+	ts.RequireNoMessageOfType(bob, "peer_ping", 50*time.Millisecond)
+
+	ts.Send(alice, Message{Type: "peer_ping", CallID: "ping-call", To: "ping-outsider", Seq: 3})
+	ts.AssertError(alice, "peer_not_found")
+	ts.Send(outsider, Message{Type: "peer_ping", CallID: "ping-call", To: "ping-alice", Seq: 1})
+	ts.AssertError(outsider, "peer_not_found")
+	ts.RequireNoMessageOfType(alice, "peer_ping", 50*time.Millisecond)
+}
@@ -0,0 +1,138 @@
+package signaling
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Load balancing configuration
+var (
+	maxClients        = envInt("MAX_CLIENTS", 0) // 0 means unlimited
+	redirectThreshold = envFloat("REDIRECT_THRESHOLD", 0.8)
+	peerServers       = envList("PEER_SERVERS")
+	peerIndex         int
+	peersMu           sync.Mutex
+)
+
+// Kubernetes configuration
+var (
+	podIP               = envString("KUBERNETES_POD_IP", "")
+	podNamespace        = envString("KUBERNETES_NAMESPACE", "default")
+	headlessService     = envString("KUBERNETES_HEADLESS_SERVICE", "")
+	headlessServicePort = envString("KUBERNETES_PORT_NAME", "http")
+)
+
+// nextPeerServer returns the next peer server, as configured, in round-robin order
+func nextPeerServer() string {
+	peersMu.Lock()
+	defer peersMu.Unlock()
+	if len(peerServers) == 0 {
+		return ""
+	}
+	peer := peerServers[peerIndex%len(peerServers)]
+	peerIndex++
+	return peer
+}
+
+// peerURL returns the /ws endpoint of peer for a client that connected with r, using http or https for redirects
+// and ws or wss when websocket is true. A peer's own scheme decides whether it is secure; a peer given as a bare
+// host is reached as securely as r arrived.
+func peerURL(peer string, r *http.Request, websocket bool) string {
+	secure := requestSecure(r)
+	host := peer
+	if scheme, rest, ok := strings.Cut(peer, "://"); ok {
+		secure = scheme == "https" || scheme == "wss"
+		host = rest
+	}
+	scheme := "http"
+	if websocket {
+		scheme = "ws"
+	}
+	if secure {
+		scheme += "s"
+	}
+	return scheme + "://" + strings.TrimRight(host, "/") + "/ws"
+}
+
+// requestSecure reports whether r arrived over TLS, directly or, per X-Forwarded-Proto, at a trusted proxy
+func requestSecure(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+	if !trustProxy || !strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+		return false
+	}
+	if len(trustedProxies) == 0 {
+		return true
+	}
+	peer, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		peer = r.RemoteAddr
+	}
+	ip := net.ParseIP(peer)
+	return ip != nil && isTrustedProxy(ip)
+}
+
+// overRedirectThreshold reports whether count clients is enough to start pointing clients elsewhere
+func overRedirectThreshold(count int) bool {
+	return maxClients > 0 && float64(count) > float64(maxClients)*redirectThreshold
+}
+
+// atCapacity reports whether count clients has reached MAX_CLIENTS
+func atCapacity(count int) bool {
+	return maxClients > 0 && count >= maxClients
+}
+
+// lookupSRV resolves SRV records, replaced in tests
+var lookupSRV = net.LookupSRV
+
+// discoverPeers finds sibling pods through the SRV records of a headless service
+func discoverPeers(namespace, serviceName string) ([]string, error) {
+	name := fmt.Sprintf("%s.%s.svc.cluster.local", serviceName, namespace)
+	_, addrs, err := lookupSRV(headlessServicePort, "tcp", name)
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, _ := os.Hostname()
+	var peers []string
+	for _, addr := range addrs {
+		target := strings.TrimSuffix(addr.Target, ".")
+		if isSelf(target, hostname) {
+			continue
+		}
+		peers = append(peers, "ws://"+net.JoinHostPort(target, strconv.Itoa(int(addr.Port))))
+	}
+	return peers, nil
+}
+
+// isSelf reports whether an SRV target points at this pod
+func isSelf(target, hostname string) bool {
+	if hostname != "" && strings.HasPrefix(target, hostname+".") {
+		return true
+	}
+	return podIP != "" && strings.HasPrefix(target, strings.ReplaceAll(podIP, ".", "-")+".")
+}
+
+// refreshPeers periodically replaces the peer server list with the pods behind the headless service
+func refreshPeers() {
+	for {
+		peers, err := discoverPeers(podNamespace, headlessService)
+		if err != nil {
+			log.Printf("Peer discovery for %s/%s failed: %v", podNamespace, headlessService, err)
+		} else {
+			peersMu.Lock()
+			peerServers = peers
+			peersMu.Unlock()
+			log.Printf("Discovered %d peers for %s/%s", len(peers), podNamespace, headlessService)
+		}
+		time.Sleep(30 * time.Second)
+	}
+}
@@ -0,0 +1,132 @@
+package signaling
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+)
+
+func TestPeerURLMapsScheme(t *testing.T) {
+	plain := httptest.NewRequest("GET", "http://example.com/ws", nil)
+	secure := httptest.NewRequest("GET", "https://example.com/ws", nil)
+	secure.TLS = &tls.ConnectionState{}
+	for _, tc := range []struct {
+		peer      string
+		secure    bool
+		websocket bool
+		want      string
+	}{
+		{"wss://peer.example.com", false, false, "https://peer.example.com/ws"},
+		{"wss://peer.example.com/", false, true, "wss://peer.example.com/ws"},
+		{"ws://10.0.0.2:8000", true, false, "http://10.0.0.2:8000/ws"},
+		{"https://peer.example.com", false, true, "wss://peer.example.com/ws"},
+		{"http://peer.example.com", true, true, "ws://peer.example.com/ws"},
+		{"peer.example.com:8000", true, false, "https://peer.example.com:8000/ws"},
+		{"peer.example.com:8000", false, true, "ws://peer.example.com:8000/ws"},
+	} {
+		r := plain
+		if tc.secure {
+			r = secure
+		}
+		if got := peerURL(tc.peer, r, tc.websocket); got != tc.want {
+			t.Errorf("peerURL(%q, secure=%v, websocket=%v) = %q, want %q", tc.peer, tc.secure, tc.websocket, got, tc.want)
+		}
+	}
+}
+
+func TestCapacityRedirectUsesHTTPScheme(t *testing.T) {
+	ts := NewTestServer(t)
+	ts.Connect()
+	setPeerServers(t, "wss://peer.example.com")
+	previousMax := maxClients
+	maxClients = int(clientCount.Load())
+	t.Cleanup(func() { maxClients = previousMax })
+
+	token := ts.Token("redirected", jwt.MapClaims{"rooms": "*"})
+	_, resp, err := websocket.DefaultDialer.Dial(ts.wsURL(url.Values{"token": {token}}), nil)
+	if err == nil || resp == nil || resp.StatusCode != 307 {
+		t.Fatalf("dial at capacity: %v, want a 307 redirect", err)
+	}
+	want := "https://peer.example.com/ws?token=" + token
+	if got := resp.Header.Get("Location"); got != want {
+		t.Fatalf("redirected to %q, want %q", got, want)
+	}
+}
+
+func TestReconnectHintUsesWebSocketScheme(t *testing.T) {
+	ts := NewTestServer(t)
+	setPeerServers(t, "https://peer.example.com")
+	previousMax, previousThreshold := maxClients, redirectThreshold
+	maxClients, redirectThreshold = int(clientCount.Load())+10, 0
+	t.Cleanup(func() { maxClients, redirectThreshold = previousMax, previousThreshold })
+
+	conn := ts.Connect()
+	if msg := ts.AssertMessageReceived(conn, "reconnect_hint", testTimeout); msg.URL != "wss://peer.example.com/ws" {
+		t.Fatalf("reconnect_hint to %q, want wss://peer.example.com/ws", msg.URL)
+	}
+}
+
+// setPeerServers replaces PEER_SERVERS for the rest of the test
+func setPeerServers(t *testing.T, peers ...string) {
+	peersMu.Lock()
+	previous := peerServers
+	peerServers = peers
+	peersMu.Unlock()
+	t.Cleanup(func() {
+		peersMu.Lock()
+		peerServers = previous
+		peersMu.Unlock()
+	})
+}
+
+func TestDiscoverPeersSkipsSelf(t *testing.T) {
+	hostname, _ := os.Hostname()
+	previousIP, previousLookup := podIP, lookupSRV
+	podIP = "10.1.2.3"
+	t.Cleanup(func() { podIP, lookupSRV = previousIP, previousLookup })
+	lookupSRV = func(service, proto, name string) (string, []*net.SRV, error) {
+		if service != headlessServicePort || proto != "tcp" || name != "signaling.calls.svc.cluster.local" {
+			return "", nil, errors.New("unexpected lookup of _" + service + "._" + proto + "." + name)
+		}
+		return name, []*net.SRV{
+			{Target: "10-1-2-4.signaling.calls.svc.cluster.local.", Port: 8000},
+			{Target: "10-1-2-3.signaling.calls.svc.cluster.local.", Port: 8000},
+			{Target: hostname + ".signaling.calls.svc.cluster.local.", Port: 8000},
+			{Target: "10-1-2-5.signaling.calls.svc.cluster.local.", Port: 9000},
+		}, nil
+	}
+
+	peers, err := discoverPeers("calls", "signaling")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"ws://10-1-2-4.signaling.calls.svc.cluster.local:8000",
+		"ws://10-1-2-5.signaling.calls.svc.cluster.local:9000",
+	}
+	if !reflect.DeepEqual(peers, want) {
+		t.Fatalf("discovered %v, want %v", peers, want)
+	}
+	if addr := ListenAddr(); addr != "10.1.2.3:8000" {
+		t.Fatalf("ListenAddr() = %q with a pod IP, want 10.1.2.3:8000", addr)
+	}
+}
+
+func TestDiscoverPeersLookupFailure(t *testing.T) {
+	previous := lookupSRV
+	t.Cleanup(func() { lookupSRV = previous })
+	lookupSRV = func(string, string, string) (string, []*net.SRV, error) {
+		return "", nil, &net.DNSError{Err: "no such host", IsNotFound: true}
+	}
+	if peers, err := discoverPeers("calls", "signaling"); err == nil {
+		t.Fatalf("discovered %v when the lookup failed", peers)
+	}
+}
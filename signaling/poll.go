@@ -0,0 +1,156 @@
+package signaling
+
+import (
+	"log"
+	"strings"
+)
+
+// Poll is a question the host has put to the room
+type Poll struct {
+	Question string
+	Options  []string
+	Results  map[string]int
+	voters   map[*wsConn]bool
+}
+
+// newPoll validates a create_poll request and builds the poll, reporting false if it is malformed
+func newPoll(question string, options []string) (*Poll, bool) {
+	question = strings.TrimSpace(question)
+	if question == "" || len(question) > 500 || len(options) < 2 || len(options) > 10 {
+		return nil, false
+	}
+	results := make(map[string]int, len(options))
+	for _, option := range options {
+		if option == "" || len(option) > 100 {
+			return nil, false
+		}
+		if _, dup := results[option]; dup {
+			return nil, false
+		}
+		results[option] = 0
+	}
+	return &Poll{
+		Question: question,
+		Options:  options,
+		Results:  results,
+		voters:   make(map[*wsConn]bool),
+	}, true
+}
+
+// results returns a copy of the poll's tally
+func (p *Poll) results() map[string]int {
+	results := make(map[string]int, len(p.Results))
+	for option, votes := range p.Results {
+		results[option] = votes
+	}
+	return results
+}
+
+// handleCreatePoll lets the room host open a poll and shows it to the room
+func handleCreatePoll(sender *wsConn, msg Message) {
+	poll, ok := newPoll(msg.Question, msg.Options)
+	if !ok {
+		sendError(sender, "invalid_poll")
+		return
+	}
+
+	room, unlock := lockRoom(msg.CallID)
+	if room == nil {
+		sendError(sender, "Call not found")
+		return
+	}
+	if room.host != sender {
+		unlock()
+		sendError(sender, "not_host")
+		return
+	}
+	if room.ActivePoll != nil {
+		unlock()
+		sendError(sender, "poll_already_active")
+		return
+	}
+	room.ActivePoll = poll
+	unlock()
+
+	broadcastToRoom(sender, Message{
+		Type:     "poll",
+		CallID:   msg.CallID,
+		Question: poll.Question,
+		Options:  poll.Options,
+	})
+	log.Printf("Host %v opened a poll in room %s", sender.RemoteAddr(), msg.CallID)
+}
+
+// handleVote counts a member's vote on the active poll and shares the running tally
+func handleVote(sender *wsConn, msg Message) {
+	room, unlock := lockRoom(msg.CallID)
+	if room == nil {
+		sendError(sender, "Call not found")
+		return
+	}
+	poll := room.ActivePoll
+	var rejected string
+	switch {
+	case !room.clients[sender]:
+		rejected = "not_in_call"
+	case poll == nil:
+		rejected = "no_active_poll"
+	case poll.voters[sender]:
+		rejected = "already_voted"
+	}
+	if rejected == "" {
+		if _, ok := poll.Results[msg.Option]; !ok {
+			rejected = "invalid_option"
+		}
+	}
+	if rejected != "" {
+		unlock()
+		sendError(sender, rejected)
+		return
+	}
+	poll.voters[sender] = true
+	poll.Results[msg.Option]++
+	results := poll.results()
+	unlock()
+
+	broadcastToRoom(sender, Message{
+		Type:    "poll_update",
+		CallID:  msg.CallID,
+		Results: results,
+	})
+}
+
+// handleClosePoll lets the host end the active poll, sharing and auditing the final results
+func handleClosePoll(sender *wsConn, msg Message) {
+	room, unlock := lockRoom(msg.CallID)
+	if room == nil {
+		sendError(sender, "Call not found")
+		return
+	}
+	if room.host != sender {
+		unlock()
+		sendError(sender, "not_host")
+		return
+	}
+	poll := room.ActivePoll
+	if poll == nil {
+		unlock()
+		sendError(sender, "no_active_poll")
+		return
+	}
+	room.ActivePoll = nil
+	results := poll.results()
+	unlock()
+
+	broadcastToRoom(sender, Message{
+		Type:     "poll_closed",
+		CallID:   msg.CallID,
+		Question: poll.Question,
+		Results:  results,
+	})
+	audit("poll_closed", msg.CallID, clientID(sender), map[string]interface{}{
+		"question": poll.Question,
+		"results":  results,
+	})
+	log.Printf("Host %v closed the poll in room %s", sender.RemoteAddr(), msg.CallID)
+}
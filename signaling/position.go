@@ -0,0 +1,47 @@
+package signaling
+
+import "time"
+
+// maxCoordinate bounds each axis of a position_update
+const maxCoordinate = 1000.0
+
+// Position is a client's location in a room's virtual space, used for spatial audio
+type Position struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	Z float64 `json:"z"`
+}
+
+// validCoordinate reports whether c is present and within ±maxCoordinate
+func validCoordinate(c *float64) bool {
+	return c != nil && *c >= -maxCoordinate && *c <= maxCoordinate
+}
+
+// handlePositionUpdate records a client's position in the room's virtual space and relays it to the room
+func handlePositionUpdate(sender *wsConn, msg Message) {
+	if !validCoordinate(msg.X) || !validCoordinate(msg.Y) || !validCoordinate(msg.Z) {
+		sendError(sender, "invalid_position")
+		return
+	}
+	if !allowMessage(sender, "position_update", 10, time.Second) {
+		return
+	}
+	if !relayToRoom(sender, Message{
+		Type:     "position_update",
+		CallID:   msg.CallID,
+		ClientID: clientID(sender),
+		X:        msg.X,
+		Y:        msg.Y,
+		Z:        msg.Z,
+	}) {
+		return
+	}
+
+	if room, unlock := lockRoom(msg.CallID); room != nil {
+		if room.clients[sender] {
+			room.positions[sender] = Position{X: *msg.X, Y: *msg.Y, Z: *msg.Z}
+		}
+		unlock()
+	}
+	positionUpdates.Inc()
+}
@@ -0,0 +1,52 @@
+package signaling
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPositionRelayedAndRemembered(t *testing.T) {
+	ts := NewTestServer(t)
+	mover, listener := ts.ConnectWithClientID("position-mover"), ts.Connect()
+	ts.startCall(mover, listener, "position-call")
+
+	x, y, z := 1.5, 2.0, -3.25
+	ts.Send(mover, Message{Type: "position_update", CallID: "position-call", X: &x, Y: &y, Z: &z})
+	msg := ts.AssertMessageReceived(listener, "position_update", testTimeout)
+	if msg.ClientID != "position-mover" || msg.X == nil || *msg.X != x || msg.Y == nil || *msg.Y != y || msg.Z == nil || *msg.Z != z {
+		t.Fatalf("position_update %+v", msg)
+	}
+	ts.RequireNoMessageOfType(mover, "position_update", 50*time.Millisecond)
+	// the mover's messages are handled in order, so the position is stored once echo is answered
+	ts.Send(mover, Message{Type: "echo", Seq: 1})
+	ts.AssertMessageReceived(mover, "echo_reply", testTimeout)
+
+	joiner := ts.Connect()
+	ts.Send(joiner, Message{Type: "join_call", CallID: "position-call"})
+	joined := ts.AssertMessageReceived(joiner, "call_joined", testTimeout)
+	for _, peer := range joined.Peers {
+		if peer.ClientID == "position-mover" {
+			if peer.Position == nil || *peer.Position != (Position{X: x, Y: y, Z: z}) {
+				t.Fatalf("call_joined has position %+v for the mover", peer.Position)
+			}
+			return
+		}
+	}
+	t.Fatalf("call_joined peers %+v miss the mover", joined.Peers)
+}
+
+func TestPositionUpdateRules(t *testing.T) {
+	ts := NewTestServer(t)
+	mover, listener := ts.Connect(), ts.Connect()
+	ts.startCall(mover, listener, "position-rules")
+
+	inside, outside := 10.0, 1000.5
+	for _, msg := range []Message{
+		{Type: "position_update", CallID: "position-rules", X: &inside, Y: &inside},
+		{Type: "position_update", CallID: "position-rules", X: &inside, Y: &outside, Z: &inside},
+	} {
+		ts.Send(mover, msg)
+		ts.AssertError(mover, "invalid_position")
+	}
+	ts.RequireNoMessageOfType(listener, "position_update", 50*time.Millisecond)
+}
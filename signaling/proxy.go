@@ -0,0 +1,96 @@
+package signaling
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Reverse proxy configuration
+var (
+	trustProxy     = envString("TRUST_PROXY", "") == "true"
+	trustedProxies = parseCIDRs("TRUSTED_PROXIES")
+)
+
+// parseCIDRs parses the comma-separated CIDR list in environment variable name, skipping invalid entries
+func parseCIDRs(name string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, v := range envList(name) {
+		if !strings.Contains(v, "/") {
+			if ip := net.ParseIP(v); ip != nil && ip.To4() != nil {
+				v += "/32"
+			} else {
+				v += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(v)
+		if err != nil {
+			log.Printf("Ignoring invalid %s entry %q", name, v)
+			continue
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+// isTrustedProxy reports whether ip belongs to TRUSTED_PROXIES
+func isTrustedProxy(ip net.IP) bool {
+	for _, n := range trustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteIP returns the address of the client behind any trusted proxies.
+// X-Forwarded-For is walked from the right, skipping trusted proxies, so entries a client
+// prepends itself are never used. Without TRUSTED_PROXIES only the direct peer is trusted.
+func remoteIP(r *http.Request) string {
+	peer, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		peer = r.RemoteAddr
+	}
+	if !trustProxy {
+		return peer
+	}
+	if len(trustedProxies) > 0 {
+		if ip := net.ParseIP(peer); ip == nil || !isTrustedProxy(ip) {
+			return peer
+		}
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(hops[i])
+		if ip == nil {
+			break
+		}
+		if i > 0 && isTrustedProxy(ip) {
+			continue
+		}
+		return ip.String()
+	}
+	return peer
+}
+
+// forwardedAddr returns the client address remoteIP found behind a trusted proxy, or nil when it is the direct peer
+func forwardedAddr(r *http.Request) net.Addr {
+	if !trustProxy {
+		return nil
+	}
+	ip := net.ParseIP(remoteIP(r))
+	if ip == nil {
+		return nil
+	}
+	if peer, _, err := net.SplitHostPort(r.RemoteAddr); err == nil && net.ParseIP(peer).Equal(ip) {
+		return nil
+	}
+	return &net.IPAddr{IP: ip}
+}
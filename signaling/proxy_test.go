@@ -0,0 +1,104 @@
+package signaling
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// setTrustProxy replaces TRUST_PROXY and TRUSTED_PROXIES for the rest of the test
+func setTrustProxy(t *testing.T, trust bool, cidrs ...string) {
+	previousTrust, previousProxies := trustProxy, trustedProxies
+	trustProxy, trustedProxies = trust, nil
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		trustedProxies = append(trustedProxies, n)
+	}
+	t.Cleanup(func() { trustProxy, trustedProxies = previousTrust, previousProxies })
+}
+
+func TestRemoteIPWithSpoofedForwardedFor(t *testing.T) {
+	tests := []struct {
+		name      string
+		trust     bool
+		proxies   []string
+		peer      string
+		forwarded []string
+		want      string
+	}{
+		{"proxy not trusted", false, nil, "10.0.0.5:4000", []string{"203.0.113.7"}, "10.0.0.5"},
+		{"no header", true, nil, "10.0.0.5:4000", nil, "10.0.0.5"},
+		{"single hop", true, nil, "10.0.0.5:4000", []string{"203.0.113.7"}, "203.0.113.7"},
+		{"client prepends a spoofed hop", true, nil, "10.0.0.5:4000", []string{"6.6.6.6, 203.0.113.7"}, "203.0.113.7"},
+		{"spoofed hop in its own header", true, nil, "10.0.0.5:4000", []string{"6.6.6.6", "203.0.113.7"}, "203.0.113.7"},
+		{"trusted proxy chain", true, []string{"10.0.0.0/8"}, "10.0.0.5:4000", []string{"6.6.6.6, 203.0.113.7, 10.0.0.7"}, "203.0.113.7"},
+		{"peer outside trusted proxies", true, []string{"10.0.0.0/8"}, "192.0.2.9:4000", []string{"203.0.113.7"}, "192.0.2.9"},
+		{"garbage hop", true, nil, "10.0.0.5:4000", []string{"203.0.113.7, not-an-ip"}, "10.0.0.5"},
+		{"only trusted hops", true, []string{"10.0.0.0/8"}, "10.0.0.5:4000", []string{"10.0.0.1, 10.0.0.2"}, "10.0.0.1"},
+		{"ipv6", true, nil, "[2001:db8::1]:4000", []string{"2001:db8::42"}, "2001:db8::42"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTrustProxy(t, tt.trust, tt.proxies...)
+			r := httptest.NewRequest("GET", "/ws", nil)
+			r.RemoteAddr = tt.peer
+			for _, header := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", header)
+			}
+			if got := remoteIP(r); got != tt.want {
+				t.Fatalf("remoteIP = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForwardedClientIPUsedForConnection(t *testing.T) {
+	setTrustProxy(t, true, "127.0.0.1/32")
+	ts := NewTestServer(t)
+	header := http.Header{"X-Forwarded-For": {"6.6.6.6, 203.0.113.7"}}
+	ts.DialHeader(url.Values{"token": {ts.Token("proxied-client", jwt.MapClaims{"rooms": "*"})}}, header)
+
+	client := findClient("proxied-client")
+	if client.ip != "203.0.113.7" || client.conn.RemoteAddr().String() != "203.0.113.7" {
+		t.Fatalf("proxied client has ip %s and address %v, want 203.0.113.7", client.ip, client.conn.RemoteAddr())
+	}
+
+	setTrustProxy(t, false)
+	ts.DialHeader(url.Values{"token": {ts.Token("direct-client", jwt.MapClaims{"rooms": "*"})}}, header)
+	if client := findClient("direct-client"); client.ip != "127.0.0.1" {
+		t.Fatalf("client of an untrusted proxy has ip %s, want the peer address", client.ip)
+	}
+}
+
+func TestForwardedProtoPicksPeerScheme(t *testing.T) {
+	tests := []struct {
+		name    string
+		trust   bool
+		proxies []string
+		peer    string
+		want    string
+	}{
+		{"proxy not trusted", false, nil, "10.0.0.5:4000", "ws://peer.example:8080/ws"},
+		{"any direct peer trusted", true, nil, "10.0.0.5:4000", "wss://peer.example:8080/ws"},
+		{"peer in trusted proxies", true, []string{"10.0.0.0/8"}, "10.0.0.5:4000", "wss://peer.example:8080/ws"},
+		{"peer outside trusted proxies", true, []string{"10.0.0.0/8"}, "192.0.2.9:4000", "ws://peer.example:8080/ws"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTrustProxy(t, tt.trust, tt.proxies...)
+			r := httptest.NewRequest("GET", "/ws", nil)
+			r.RemoteAddr = tt.peer
+			r.Header.Set("X-Forwarded-Proto", "https")
+			if got := peerURL("peer.example:8080", r, true); got != tt.want {
+				t.Fatalf("peerURL = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
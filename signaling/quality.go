@@ -0,0 +1,30 @@
+package signaling
+
+import "time"
+
+// handleNetworkQuality records a client's reported network quality and relays it to the room
+func handleNetworkQuality(sender *wsConn, msg Message) {
+	if msg.Level < 1 || msg.Level > 5 {
+		sendError(sender, "invalid_network_quality")
+		return
+	}
+	if !allowMessage(sender, "network_quality", 1, time.Second) {
+		return
+	}
+	if !relayToRoom(sender, Message{
+		Type:     "network_quality",
+		CallID:   msg.CallID,
+		ClientID: clientID(sender),
+		Level:    msg.Level,
+	}) {
+		return
+	}
+
+	if room, unlock := lockRoom(msg.CallID); room != nil {
+		if room.clients[sender] {
+			room.quality[sender] = msg.Level
+		}
+		unlock()
+	}
+	networkQuality.Observe(float64(msg.Level))
+}
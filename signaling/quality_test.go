@@ -0,0 +1,81 @@
+package signaling
+
+import (
+	"bufio"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scrapeMetric reads the value of the sample named series, labels included, from /metrics, or 0 when it is absent
+func (ts *TestServer) scrapeMetric(series string) float64 {
+	ts.t.Helper()
+	resp, err := http.Get(ts.URL() + "/metrics")
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), series+" "); ok {
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				ts.t.Fatalf("metric %s: %v", series, err)
+			}
+			return v
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		ts.t.Fatal(err)
+	}
+	return 0
+}
+
+func TestNetworkQualityRelayedAndRemembered(t *testing.T) {
+	ts := NewTestServer(t)
+	caller, callee, joiner := ts.ConnectWithClientID("quality-caller"), ts.Connect(), ts.Connect()
+	ts.startCall(caller, callee, "quality-call")
+	poor := ts.scrapeMetric(`videochat_network_quality_level_bucket{le="2"}`)
+
+	ts.Send(caller, Message{Type: "network_quality", CallID: "quality-call", Level: 2})
+	if msg := ts.AssertMessageReceived(callee, "network_quality", testTimeout); msg.ClientID != "quality-caller" || msg.Level != 2 {
+		t.Fatalf("network_quality %+v", msg)
+	}
+	ts.RequireNoMessageOfType(caller, "network_quality", 50*time.Millisecond)
+
+	ts.Send(joiner, Message{Type: "join_call", CallID: "quality-call"})
+	joined := ts.AssertMessageReceived(joiner, "call_joined", testTimeout)
+	found := false
+	for _, peer := range joined.Peers {
+		if peer.ClientID == "quality-caller" {
+			found = true
+			if peer.NetworkQuality != 2 {
+				t.Fatalf("call_joined has quality %d for the caller, want 2", peer.NetworkQuality)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("call_joined peers %+v miss the caller", joined.Peers)
+	}
+	if got := ts.scrapeMetric(`videochat_network_quality_level_bucket{le="2"}`); got != poor+1 {
+		t.Fatalf("quality level 2 bucket went from %v to %v", poor, got)
+	}
+}
+
+func TestNetworkQualityRules(t *testing.T) {
+	ts := NewTestServer(t)
+	caller, callee := ts.Connect(), ts.Connect()
+	ts.startCall(caller, callee, "quality-rules")
+
+	for _, level := range []int{0, 6} {
+		ts.Send(caller, Message{Type: "network_quality", CallID: "quality-rules", Level: level})
+		ts.AssertError(caller, "invalid_network_quality")
+	}
+	ts.Send(caller, Message{Type: "network_quality", CallID: "quality-rules", Level: 4})
+	ts.AssertMessageReceived(callee, "network_quality", testTimeout)
+	ts.Send(caller, Message{Type: "network_quality", CallID: "quality-rules", Level: 5})
+	ts.AssertError(caller, "rate_limited")
+	ts.RequireNoMessageOfType(callee, "network_quality", 100*time.Millisecond)
+}
@@ -0,0 +1,164 @@
+package signaling
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"math"
+	"time"
+)
+
+const (
+	// qualityTestInterval is how often the client is expected to send a quality_test_echo
+	qualityTestInterval = 100 * time.Millisecond
+	// qualityTestGrace is how long after the test duration late echoes are still counted
+	qualityTestGrace = time.Second
+	// maxQualityTestSeconds bounds the duration of quality_test_start
+	maxQualityTestSeconds = 30
+)
+
+// qualityTest is a client's running pre-call network test; guarded by the client's mu
+type qualityTest struct {
+	token       string
+	expected    int            // echoes the client should send, one per qualityTestInterval
+	received    map[int64]bool // sequence numbers seen, 1 to expected
+	lastTransit time.Duration  // receive time minus the client's sentAt of the previous echo
+	jitter      float64        // RFC 3550 interarrival jitter, in milliseconds
+	rtts        []float64      // round trips the client measured for earlier echoes, in milliseconds
+}
+
+// recommendation grades a quality test result for the user
+func recommendation(packetLoss, rttMs, jitterMs float64) string {
+	switch {
+	case packetLoss <= 0.02 && rttMs <= 150 && jitterMs <= 30:
+		return "good"
+	case packetLoss <= 0.1 && rttMs <= 400 && jitterMs <= 100:
+		return "fair"
+	default:
+		return "poor"
+	}
+}
+
+// handleQualityTestStart starts a pre-call network test, answering with the token the client's echoes must carry
+func handleQualityTestStart(sender *wsConn, msg Message) {
+	duration := msg.Duration
+	if duration == 0 {
+		duration = 5
+	}
+	if duration < 1 || duration > maxQualityTestSeconds {
+		sendError(sender, "invalid_quality_test")
+		return
+	}
+	if !allowMessage(sender, "quality_test_start", 1, 10*time.Second) {
+		return
+	}
+	client, ok := getClient(sender)
+	if !ok {
+		return
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.Printf("Error generating quality test token: %v", err)
+		return
+	}
+	test := &qualityTest{
+		token:    hex.EncodeToString(b),
+		expected: int(time.Duration(duration) * time.Second / qualityTestInterval),
+		received: make(map[int64]bool),
+	}
+
+	client.mu.Lock()
+	if client.qualityTest != nil {
+		client.mu.Unlock()
+		sendError(sender, "quality_test_running")
+		return
+	}
+	client.qualityTest = test
+	client.mu.Unlock()
+
+	if err := sender.WriteJSON(Message{Type: "quality_test_token", Token: test.token, Duration: duration}); err != nil {
+		log.Printf("Error sending quality_test_token to %v: %v", sender.RemoteAddr(), err)
+		go cleanupClient(sender)
+		return
+	}
+	time.AfterFunc(time.Duration(duration)*time.Second+qualityTestGrace, func() { finishQualityTest(sender, test) })
+}
+
+// handleQualityTestEcho answers one echo of a running quality test and records its arrival
+func handleQualityTestEcho(sender *wsConn, msg Message) {
+	receivedAt := time.Now()
+	if !allowMessage(sender, "quality_test_echo", 20, time.Second) {
+		return
+	}
+	client, ok := getClient(sender)
+	if !ok {
+		return
+	}
+	client.mu.Lock()
+	test := client.qualityTest
+	if test == nil || msg.Token != test.token || msg.Seq < 1 || msg.Seq > int64(test.expected) || test.received[msg.Seq] {
+		client.mu.Unlock()
+		return
+	}
+	test.received[msg.Seq] = true
+	if sentAt, err := time.Parse(time.RFC3339Nano, msg.SentAt); err == nil {
+		// differences of transit times cancel out clock skew between client and server
+		transit := receivedAt.Sub(sentAt)
+		if len(test.received) > 1 {
+			d := math.Abs(float64(transit-test.lastTransit) / float64(time.Millisecond))
+			test.jitter += (d - test.jitter) / 16
+		}
+		test.lastTransit = transit
+	}
+	if msg.RTTMs > 0 && msg.RTTMs < 60000 {
+		test.rtts = append(test.rtts, msg.RTTMs)
+	}
+	client.mu.Unlock()
+
+	if err := sender.WriteJSON(Message{
+		Type:             "quality_test_echo",
+		Seq:              msg.Seq,
+		SentAt:           msg.SentAt,
+		ServerReceivedAt: receivedAt.UTC().Format(time.RFC3339Nano),
+	}); err != nil {
+		log.Printf("Error sending quality_test_echo to %v: %v", sender.RemoteAddr(), err)
+		go cleanupClient(sender)
+	}
+}
+
+// finishQualityTest sends the client the result of its quality test once the duration has passed
+func finishQualityTest(conn *wsConn, test *qualityTest) {
+	client, ok := getClient(conn)
+	if !ok {
+		return
+	}
+	client.mu.Lock()
+	if client.qualityTest != test {
+		client.mu.Unlock()
+		return
+	}
+	client.qualityTest = nil
+	packetLoss := math.Round((1-float64(len(test.received))/float64(test.expected))*1000) / 1000
+	jitter := math.Round(test.jitter)
+	var rtt float64
+	for _, r := range test.rtts {
+		rtt += r
+	}
+	if len(test.rtts) > 0 {
+		rtt = math.Round(rtt / float64(len(test.rtts)))
+	}
+	client.mu.Unlock()
+
+	if err := conn.WriteJSON(Message{
+		Type:           "quality_test_result",
+		PacketLoss:     &packetLoss,
+		AvgRTTMs:       &rtt,
+		JitterMs:       &jitter,
+		Recommendation: recommendation(packetLoss, rtt, jitter),
+	}); err != nil {
+		log.Printf("Error sending quality_test_result to %v: %v", conn.RemoteAddr(), err)
+		go cleanupClient(conn)
+		return
+	}
+	log.Printf("Quality test for client %s: %.0f%% loss, %.0fms RTT, %.0fms jitter", client.id, packetLoss*100, rtt, jitter)
+}
@@ -0,0 +1,92 @@
+package signaling
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQualityTestSequence(t *testing.T) {
+	ts := NewTestServer(t)
+	conn := ts.Connect()
+	ts.Send(conn, Message{Type: "quality_test_start", Duration: 1})
+	started := ts.AssertMessageReceived(conn, "quality_test_token", testTimeout)
+	if len(started.Token) != 32 || started.Duration != 1 {
+		t.Fatalf("quality_test_token %+v", started)
+	}
+
+	// a one second test expects ten echoes; the fifth is lost
+	for seq := int64(1); seq <= 10; seq++ {
+		time.Sleep(qualityTestInterval)
+		if seq == 5 {
+			continue
+		}
+		sentAt := time.Now().UTC().Format(time.RFC3339Nano)
+		ts.Send(conn, Message{Type: "quality_test_echo", Token: started.Token, Seq: seq, SentAt: sentAt, RTTMs: 40})
+		echo := ts.AssertMessageReceived(conn, "quality_test_echo", testTimeout)
+		if echo.Seq != seq || echo.SentAt != sentAt {
+			t.Fatalf("quality_test_echo %+v for seq %d", echo, seq)
+		}
+		if _, err := time.Parse(time.RFC3339Nano, echo.ServerReceivedAt); err != nil {
+			t.Fatalf("serverReceivedAt %q: %v", echo.ServerReceivedAt, err)
+		}
+	}
+	// echoes with the wrong token, a repeated or an out of range sequence number are not counted or answered
+	for _, echo := range []Message{
+		{Token: "wrong-token", Seq: 5},
+		{Token: started.Token, Seq: 4},
+		{Token: started.Token, Seq: 11},
+	} {
+		echo.Type = "quality_test_echo"
+		ts.Send(conn, echo)
+	}
+	ts.RequireNoMessageOfType(conn, "quality_test_echo", 50*time.Millisecond)
+
+	result := ts.AssertMessageReceived(conn, "quality_test_result", time.Second+qualityTestGrace+testTimeout)
+	if result.PacketLoss == nil || *result.PacketLoss != 0.1 || result.AvgRTTMs == nil || *result.AvgRTTMs != 40 || result.JitterMs == nil {
+		t.Fatalf("quality_test_result %+v", result)
+	}
+	if *result.JitterMs > 30 {
+		t.Fatalf("jitter %vms for echoes sent on schedule", *result.JitterMs)
+	}
+	if result.Recommendation != "fair" {
+		t.Fatalf("recommendation %q for 10%% loss, want fair", result.Recommendation)
+	}
+}
+
+func TestQualityTestStartRules(t *testing.T) {
+	ts := NewTestServer(t)
+	conn := ts.ConnectWithClientID("quality-test-rules")
+	ts.Send(conn, Message{Type: "quality_test_start", Duration: maxQualityTestSeconds + 1})
+	ts.AssertError(conn, "invalid_quality_test")
+
+	ts.Send(conn, Message{Type: "quality_test_start"})
+	if msg := ts.AssertMessageReceived(conn, "quality_test_token", testTimeout); msg.Duration != 5 {
+		t.Fatalf("default duration %d, want 5", msg.Duration)
+	}
+	ts.Send(conn, Message{Type: "quality_test_start"})
+	ts.AssertError(conn, "rate_limited")
+	expireRateWindow(t, "quality-test-rules", "quality_test_start")
+	ts.Send(conn, Message{Type: "quality_test_start"})
+	ts.AssertError(conn, "quality_test_running")
+}
+
+func TestQualityTestRecommendation(t *testing.T) {
+	tests := []struct {
+		loss, rtt, jitter float64
+		want              string
+	}{
+		{0, 45, 5, "good"},
+		{0.02, 150, 30, "good"},
+		{0.03, 45, 5, "fair"},
+		{0, 300, 5, "fair"},
+		{0, 45, 80, "fair"},
+		{0.2, 45, 5, "poor"},
+		{0, 500, 5, "poor"},
+		{0, 45, 150, "poor"},
+	}
+	for _, tt := range tests {
+		if got := recommendation(tt.loss, tt.rtt, tt.jitter); got != tt.want {
+			t.Errorf("recommendation(%v, %v, %v) = %s, want %s", tt.loss, tt.rtt, tt.jitter, got, tt.want)
+		}
+	}
+}
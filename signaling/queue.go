@@ -0,0 +1,133 @@
+package signaling
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// waitingCall is a caller waiting for someone to accept their call
+type waitingCall struct {
+	conn       *wsConn
+	callID     string
+	enqueuedAt time.Time
+}
+
+// Waiting queue state
+var (
+	waitingQueue    []*waitingCall
+	acceptLatencies = newDurationRing(50)
+	queueMu         sync.Mutex
+)
+
+const (
+	// acceptLatencyAlpha weights recent accept latencies in the moving average
+	acceptLatencyAlpha = 0.3
+	// defaultAcceptLatency is the wait estimate used before any call has been accepted
+	defaultAcceptLatency = 30 * time.Second
+)
+
+// enqueueCall adds a caller to the waiting queue and updates everyone's position
+func enqueueCall(conn *wsConn, callID string) {
+	queueMu.Lock()
+	for _, wc := range waitingQueue {
+		if wc.callID == callID {
+			queueMu.Unlock()
+			return
+		}
+	}
+	waitingQueue = append(waitingQueue, &waitingCall{conn: conn, callID: callID, enqueuedAt: time.Now()})
+	queueMu.Unlock()
+
+	broadcastQueuePositions()
+}
+
+// admitCall removes an accepted call from the queue and records how long it waited
+func admitCall(callID string) {
+	queueMu.Lock()
+	var waited time.Duration
+	admitted := false
+	for i, wc := range waitingQueue {
+		if wc.callID == callID {
+			now := time.Now()
+			waited = now.Sub(wc.enqueuedAt)
+			acceptLatencies.add(waited)
+			recordCall(callRecord{CallID: callID, EnqueuedAt: wc.enqueuedAt, AcceptedAt: now})
+			waitingQueue = append(waitingQueue[:i], waitingQueue[i+1:]...)
+			admitted = true
+			break
+		}
+	}
+	queueMu.Unlock()
+
+	if admitted {
+		log.Printf("Call %s admitted after %v", callID, waited)
+		broadcastQueuePositions()
+	}
+}
+
+// leaveQueue removes a caller's queued calls, or only callID when it is not empty
+func leaveQueue(conn *wsConn, callID string) {
+	queueMu.Lock()
+	removed := false
+	for i := 0; i < len(waitingQueue); i++ {
+		wc := waitingQueue[i]
+		if wc.conn == conn && (callID == "" || wc.callID == callID) {
+			recordCall(callRecord{CallID: wc.callID, EnqueuedAt: wc.enqueuedAt, AbandonedAt: time.Now()})
+			waitingQueue = append(waitingQueue[:i], waitingQueue[i+1:]...)
+			removed = true
+			i--
+		}
+	}
+	queueMu.Unlock()
+
+	if removed {
+		broadcastQueuePositions()
+	}
+}
+
+// callQueued reports whether callID is still waiting to be accepted
+func callQueued(callID string) bool {
+	queueMu.Lock()
+	defer queueMu.Unlock()
+	for _, wc := range waitingQueue {
+		if wc.callID == callID {
+			return true
+		}
+	}
+	return false
+}
+
+// estimatedAcceptLatency returns an exponential moving average of recent accept latencies
+func estimatedAcceptLatency() time.Duration {
+	samples := acceptLatencies.values()
+	if len(samples) == 0 {
+		return defaultAcceptLatency
+	}
+	ema := float64(samples[0])
+	for _, d := range samples[1:] {
+		ema = acceptLatencyAlpha*float64(d) + (1-acceptLatencyAlpha)*ema
+	}
+	return time.Duration(ema)
+}
+
+// broadcastQueuePositions tells every waiting caller where they are in the queue
+func broadcastQueuePositions() {
+	queueMu.Lock()
+	latency := estimatedAcceptLatency()
+	queue := append([]*waitingCall(nil), waitingQueue...)
+	queueMu.Unlock()
+
+	for i, wc := range queue {
+		position := i + 1
+		if err := wc.conn.WriteJSON(Message{
+			Type:                 "queue_position",
+			CallID:               wc.callID,
+			Position:             position,
+			EstimatedWaitSeconds: int((time.Duration(position) * latency).Seconds()),
+		}); err != nil {
+			log.Printf("Error sending queue_position to %v: %v", wc.conn.RemoteAddr(), err)
+			go cleanupClient(wc.conn)
+		}
+	}
+}
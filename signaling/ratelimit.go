@@ -0,0 +1,54 @@
+package signaling
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// rateLimiter counts events per kind in fixed windows
+type rateLimiter struct {
+	mu      sync.Mutex
+	windows map[string]*rateWindow
+}
+
+// rateWindow is the current counting window for one kind of event
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// allow reports whether another event of kind fits within limit events per period
+func (rl *rateLimiter) allow(kind string, limit int, per time.Duration) bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if rl.windows == nil {
+		rl.windows = make(map[string]*rateWindow)
+	}
+	now := time.Now()
+	w, ok := rl.windows[kind]
+	if !ok || now.Sub(w.start) >= per {
+		rl.windows[kind] = &rateWindow{start: now, count: 1}
+		return true
+	}
+	if w.count >= limit {
+		return false
+	}
+	w.count++
+	return true
+}
+
+// allowMessage applies the sender's rate limit for kind and tells the sender when it is exceeded
+func allowMessage(ws *wsConn, kind string, limit int, per time.Duration) bool {
+	client, ok := getClient(ws)
+	if !ok {
+		return false
+	}
+	if client.limits.allow(kind, limit, per) {
+		return true
+	}
+
+	log.Printf("Rate limited %s from %v", kind, ws.RemoteAddr())
+	sendError(ws, "rate_limited")
+	return false
+}
@@ -0,0 +1,35 @@
+package signaling
+
+import "time"
+
+// allowedReactions are the emoji clients may react with
+var allowedReactions = map[string]bool{
+	"👍": true, "👎": true, "👏": true, "🙌": true, "❤️": true,
+	"😂": true, "🤣": true, "😮": true, "😢": true, "😡": true,
+	"😍": true, "🤔": true, "🎉": true, "🔥": true, "💯": true,
+	"👋": true, "🙏": true, "👀": true, "✅": true, "❌": true,
+}
+
+// handleReaction relays an emoji reaction to the room and counts it
+func handleReaction(sender *wsConn, msg Message) {
+	if !allowedReactions[msg.Emoji] {
+		sendError(sender, "invalid_reaction")
+		return
+	}
+	if !allowMessage(sender, "reaction", 2, time.Second) {
+		return
+	}
+	if !relayToRoom(sender, Message{
+		Type:     "reaction",
+		CallID:   msg.CallID,
+		ClientID: clientID(sender),
+		Emoji:    msg.Emoji,
+	}) {
+		return
+	}
+
+	if room, unlock := lockRoom(msg.CallID); room != nil {
+		room.reactions[msg.Emoji]++
+		unlock()
+	}
+}
@@ -0,0 +1,32 @@
+package signaling
+
+import "log"
+
+// deliveryReceipt collects which room members a relayed message reached, for senders that set ackRequested
+type deliveryReceipt struct {
+	deliveredTo []string
+	failedTo    []string
+}
+
+// record notes the outcome of queueing the message for member
+func (r *deliveryReceipt) record(member *wsConn, err error) {
+	if err != nil {
+		r.failedTo = append(r.failedTo, clientID(member))
+		return
+	}
+	r.deliveredTo = append(r.deliveredTo, clientID(member))
+}
+
+// send reports the receipt for the message with sequence number seq back to its sender
+func (r *deliveryReceipt) send(sender *wsConn, callID string, seq int64) {
+	if err := sender.WriteJSON(Message{
+		Type:        "message_ack",
+		CallID:      callID,
+		Seq:         seq,
+		DeliveredTo: append([]string{}, r.deliveredTo...),
+		FailedTo:    append([]string{}, r.failedTo...),
+	}); err != nil {
+		log.Printf("Error sending message_ack to %v: %v", sender.RemoteAddr(), err)
+		go cleanupClient(sender)
+	}
+}
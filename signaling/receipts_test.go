@@ -0,0 +1,40 @@
+package signaling
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRelayAckReportsDelivery(t *testing.T) {
+	ts := NewTestServer(t)
+	caller, callee := ts.Connect(), ts.ConnectWithClientID("receipts-callee")
+	ts.startCall(caller, callee, "receipts-call")
+
+	ts.Send(caller, Message{Type: "relay", CallID: "receipts-call", Data: "acked", AckRequested: true})
+	relayed := ts.AssertMessageReceived(callee, "relay", testTimeout)
+	if relayed.AckRequested {
+		t.Fatal("ackRequested was relayed to the peer")
+	}
+	ack := ts.AssertMessageReceived(caller, "message_ack", testTimeout)
+	if ack.CallID != "receipts-call" || ack.Seq != relayed.Seq || !slices.Equal(ack.DeliveredTo, []string{"receipts-callee"}) || len(ack.FailedTo) != 0 {
+		t.Fatalf("got %+v for relay with seq %d", ack, relayed.Seq)
+	}
+
+	ts.Send(caller, Message{Type: "relay", CallID: "receipts-call", Data: "unacked"})
+	ts.AssertMessageReceived(callee, "relay", testTimeout)
+	ts.RequireNoMessageOfType(caller, "message_ack", 50*time.Millisecond)
+}
+
+func TestICECandidateAck(t *testing.T) {
+	ts := NewTestServer(t)
+	caller, callee := ts.Connect(), ts.ConnectWithClientID("receipts-ice-callee")
+	ts.startCall(caller, callee, "receipts-ice")
+
+	ts.Send(caller, Message{Type: "ice-candidate", CallID: "receipts-ice", Data: `{"candidate":"c1"}`, AckRequested: true})
+	candidate := ts.AssertMessageReceived(callee, "ice-candidate", testTimeout)
+	ack := ts.AssertMessageReceived(caller, "message_ack", testTimeout)
+	if ack.Seq != candidate.Seq || !slices.Equal(ack.DeliveredTo, []string{"receipts-ice-callee"}) {
+		t.Fatalf("got %+v for candidate with seq %d", ack, candidate.Seq)
+	}
+}
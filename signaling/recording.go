@@ -0,0 +1,123 @@
+package signaling
+
+import (
+	"log"
+	"time"
+)
+
+// consentTimeout is how long participants have to answer recording_started before the recording is cancelled
+var consentTimeout = time.Duration(envInt("CONSENT_TIMEOUT_SECONDS", 30)) * time.Second
+
+// consentComplete reports whether every current member has consented to the recording; callers hold r.mu
+func (r *Room) consentComplete() bool {
+	for member := range r.clients {
+		if !r.ConsentStatus[r.memberIDs[member]] {
+			return false
+		}
+	}
+	return true
+}
+
+// cancelRecording stops the pending or running recording; callers hold r.mu
+func (r *Room) cancelRecording() {
+	r.RecordingActive = false
+	r.ConsentStatus = nil
+	r.recordingGen++
+}
+
+// handleRecordingStarted lets the host announce a recording, which everyone in the room must consent to
+func handleRecordingStarted(sender *wsConn, msg Message) {
+	room, unlock := lockRoom(msg.CallID)
+	if room == nil {
+		sendError(sender, "Call not found")
+		return
+	}
+	if room.host != sender {
+		unlock()
+		sendError(sender, "not_host")
+		return
+	}
+	if room.RecordingActive {
+		unlock()
+		sendError(sender, "recording_active")
+		return
+	}
+	hostID := room.memberIDs[sender]
+	recordedBy := msg.RecordedBy
+	if recordedBy == "" || len(recordedBy) > 64 {
+		recordedBy = hostID
+	}
+	room.RecordingActive = true
+	room.ConsentStatus = map[string]bool{hostID: true}
+	room.recordingGen++
+	gen := room.recordingGen
+	unlock()
+
+	broadcastToRoom(sender, Message{Type: "recording_started", CallID: msg.CallID, RecordedBy: recordedBy})
+	time.AfterFunc(consentTimeout, func() { expireConsent(msg.CallID, gen) })
+	log.Printf("Host %v started recording in room %s, waiting for consent", sender.RemoteAddr(), msg.CallID)
+}
+
+// handleRecordingConsent records a participant's answer, cancelling the recording on the first refusal
+func handleRecordingConsent(sender *wsConn, msg Message) {
+	if msg.Consent == nil {
+		sendError(sender, "invalid_consent")
+		return
+	}
+	room, unlock := lockRoom(msg.CallID)
+	if room == nil || !room.clients[sender] {
+		if room != nil {
+			unlock()
+		}
+		sendError(sender, "not_in_call")
+		return
+	}
+	if !room.RecordingActive {
+		unlock()
+		sendError(sender, "no_active_recording")
+		return
+	}
+	id := room.memberIDs[sender]
+	if !*msg.Consent {
+		room.cancelRecording()
+		unlock()
+		log.Printf("Client %v refused recording in room %s", sender.RemoteAddr(), msg.CallID)
+		broadcastToRoom(sender, Message{Type: "recording_cancelled", CallID: msg.CallID, ClientID: id, Reason: "refused"})
+		return
+	}
+	alreadyComplete := room.consentComplete()
+	room.ConsentStatus[id] = true
+	complete := !alreadyComplete && room.consentComplete()
+	unlock()
+
+	if complete {
+		log.Printf("Everyone in room %s consented to recording", msg.CallID)
+		broadcastToRoom(sender, Message{Type: "recording_consent_complete", CallID: msg.CallID})
+	}
+}
+
+// expireConsent cancels recording gen in callID if not everyone consented within consentTimeout
+func expireConsent(callID string, gen int) {
+	room, unlock := lockRoom(callID)
+	if room == nil {
+		return
+	}
+	if !room.RecordingActive || room.recordingGen != gen || room.consentComplete() {
+		unlock()
+		return
+	}
+	room.cancelRecording()
+	members := make([]*wsConn, 0, len(room.clients))
+	for member := range room.clients {
+		members = append(members, member)
+	}
+	unlock()
+
+	log.Printf("Recording in room %s cancelled: consent timed out", callID)
+	for _, member := range members {
+		if err := member.WriteJSON(Message{Type: "recording_cancelled", CallID: callID, Reason: "consent_timeout"}); err != nil {
+			log.Printf("Error sending recording_cancelled to %v: %v", member.RemoteAddr(), err)
+			go cleanupClient(member)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package signaling
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// setConsentTimeout replaces CONSENT_TIMEOUT_SECONDS for the rest of the test
+func setConsentTimeout(t *testing.T, timeout time.Duration) {
+	previous := consentTimeout
+	consentTimeout = timeout
+	t.Cleanup(func() { consentTimeout = previous })
+}
+
+// startRecording puts host, who created callID, and members in one call and has host announce a recording
+func (ts *TestServer) startRecording(callID string, host *websocket.Conn, members ...*websocket.Conn) {
+	ts.t.Helper()
+	ts.Send(host, Message{Type: "offer", CallID: callID, Data: sdpData("offer")})
+	ts.waitForRoom(callID, 1)
+	for _, member := range members {
+		ts.Send(member, Message{Type: "join_call", CallID: callID})
+		ts.AssertMessageReceived(member, "call_joined", testTimeout)
+	}
+	ts.Send(host, Message{Type: "recording_started", CallID: callID})
+	for _, member := range members {
+		if msg := ts.AssertMessageReceived(member, "recording_started", testTimeout); msg.RecordedBy == "" {
+			ts.t.Fatalf("recording_started %+v names no recorder", msg)
+		}
+	}
+}
+
+func TestRecordingConsentComplete(t *testing.T) {
+	ts := NewTestServer(t)
+	host, first, second := ts.Connect(), ts.Connect(), ts.Connect()
+	ts.startRecording("recording-consent", host, first, second)
+
+	agree := true
+	ts.Send(first, Message{Type: "recording_consent", CallID: "recording-consent", Consent: &agree})
+	ts.RequireNoMessageOfType(host, "recording_consent_complete", 50*time.Millisecond)
+	ts.Send(second, Message{Type: "recording_consent", CallID: "recording-consent", Consent: &agree})
+	for _, conn := range []*websocket.Conn{host, first, second} {
+		ts.AssertMessageReceived(conn, "recording_consent_complete", testTimeout)
+	}
+
+	ts.Send(host, Message{Type: "recording_started", CallID: "recording-consent"})
+	ts.AssertError(host, "recording_active")
+}
+
+func TestRecordingRefusalCancels(t *testing.T) {
+	ts := NewTestServer(t)
+	host, refuser := ts.Connect(), ts.ConnectWithClientID("recording-refuser")
+	ts.startRecording("recording-refused", host, refuser)
+
+	refuse := false
+	ts.Send(refuser, Message{Type: "recording_consent", CallID: "recording-refused", Consent: &refuse})
+	if msg := ts.AssertMessageReceived(host, "recording_cancelled", testTimeout); msg.Reason != "refused" || msg.ClientID != "recording-refuser" {
+		t.Fatalf("recording_cancelled %+v", msg)
+	}
+	ts.Send(refuser, Message{Type: "recording_consent", CallID: "recording-refused", Consent: &refuse})
+	ts.AssertError(refuser, "no_active_recording")
+}
+
+func TestRecordingConsentTimesOut(t *testing.T) {
+	ts := NewTestServer(t)
+	setConsentTimeout(t, 50*time.Millisecond)
+	host, silent := ts.Connect(), ts.Connect()
+	ts.startRecording("recording-timeout", host, silent)
+
+	for _, conn := range []*websocket.Conn{host, silent} {
+		if msg := ts.AssertMessageReceived(conn, "recording_cancelled", testTimeout); msg.Reason != "consent_timeout" {
+			t.Fatalf("recording_cancelled %+v", msg)
+		}
+	}
+}
+
+func TestRecordingRules(t *testing.T) {
+	ts := NewTestServer(t)
+	host, member, outsider := ts.Connect(), ts.Connect(), ts.Connect()
+	ts.startCall(host, member, "recording-rules")
+
+	ts.Send(member, Message{Type: "recording_started", CallID: "recording-rules"})
+	ts.AssertError(member, "not_host")
+	ts.Send(member, Message{Type: "recording_consent", CallID: "recording-rules"})
+	ts.AssertError(member, "invalid_consent")
+	agree := true
+	ts.Send(outsider, Message{Type: "recording_consent", CallID: "recording-rules", Consent: &agree})
+	ts.AssertError(outsider, "not_in_call")
+}
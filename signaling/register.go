@@ -0,0 +1,36 @@
+package signaling
+
+import "log"
+
+// handleRegister records the optional details a client sends about itself after connecting, including the sip_bridge role,
+// answering upgrade_required when its clientVersion is older than MIN_CLIENT_VERSION
+func handleRegister(sender *wsConn, msg Message) {
+	if msg.Language != "" && !validLang(msg.Language) {
+		sendError(sender, "invalid_language")
+		return
+	}
+	if msg.Role != "" && msg.Role != sipBridgeRole {
+		sendError(sender, "invalid_role")
+		return
+	}
+	client, ok := getClient(sender)
+	if !ok {
+		return
+	}
+	if msg.Role == sipBridgeRole && !registerSIPBridge(client, msg.Token) {
+		sendError(sender, "invalid_role")
+		return
+	}
+	client.mu.Lock()
+	client.lang = msg.Language
+	client.mu.Unlock()
+	log.Printf("Client %v registered with language %q, version %q", sender.RemoteAddr(), msg.Language, msg.ClientVersion)
+
+	if minClientVersion != "" && msg.ClientVersion != "" && versionOlder(msg.ClientVersion, minClientVersion) {
+		log.Printf("Client %v runs version %q, older than %s", sender.RemoteAddr(), msg.ClientVersion, minClientVersion)
+		if err := sender.WriteJSON(Message{Type: "upgrade_required", MinClientVersion: minClientVersion}); err != nil {
+			log.Printf("Error sending upgrade_required to %v: %v", sender.RemoteAddr(), err)
+			go cleanupClient(sender)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package signaling
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// topRelayTypes is how many message types relay_stats lists
+const topRelayTypes = 5
+
+// relayStats counts the signaling traffic relayed in a room since it was created or last reset
+type relayStats struct {
+	messages atomic.Int64
+	bytes    atomic.Int64
+
+	// guarded by the room's mu, like stamp
+	byType      map[string]int64
+	second      int64 // Unix second being counted for the peak rate
+	secondCount int
+	peakPerSec  int
+}
+
+// record counts one relayed message; callers hold the room's mu
+func (s *relayStats) record(msg Message) {
+	size := 0
+	if data, err := json.Marshal(msg); err == nil {
+		size = len(data)
+	}
+	s.messages.Add(1)
+	s.bytes.Add(int64(size))
+	if s.byType == nil {
+		s.byType = make(map[string]int64)
+	}
+	s.byType[msg.Type]++
+	now := time.Now().Unix()
+	if now != s.second {
+		s.second = now
+		s.secondCount = 0
+	}
+	s.secondCount++
+	if s.secondCount > s.peakPerSec {
+		s.peakPerSec = s.secondCount
+	}
+}
+
+// reset starts counting afresh; callers hold the room's mu
+func (s *relayStats) reset() {
+	s.messages.Store(0)
+	s.bytes.Store(0)
+	s.byType = nil
+	s.second = 0
+	s.secondCount = 0
+	s.peakPerSec = 0
+}
+
+// message builds relay_stats for the room; callers hold the room's mu
+func (s *relayStats) message(callID string) Message {
+	types := make([]string, 0, len(s.byType))
+	for t := range s.byType {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if s.byType[types[i]] != s.byType[types[j]] {
+			return s.byType[types[i]] > s.byType[types[j]]
+		}
+		return types[i] < types[j]
+	})
+	if len(types) > topRelayTypes {
+		types = types[:topRelayTypes]
+	}
+	top := make(map[string]int64, len(types))
+	for _, t := range types {
+		top[t] = s.byType[t]
+	}
+	return Message{
+		Type:            "relay_stats",
+		CallID:          callID,
+		MessagesRelayed: s.messages.Load(),
+		BytesRelayed:    s.bytes.Load(),
+		TopMessageTypes: top,
+		PeakMsgPerSec:   s.peakPerSec,
+	}
+}
+
+// handleGetRelayStats tells the host how much signaling traffic the room has relayed
+func handleGetRelayStats(sender *wsConn, msg Message) {
+	room, unlock := lockRoom(msg.CallID)
+	if room == nil {
+		sendError(sender, "Call not found")
+		return
+	}
+	if room.host != sender {
+		unlock()
+		sendError(sender, "not_host")
+		return
+	}
+	stats := room.stats.message(msg.CallID)
+	unlock()
+
+	if err := sender.WriteJSON(stats); err != nil {
+		log.Printf("Error sending relay_stats to %v: %v", sender.RemoteAddr(), err)
+		go cleanupClient(sender)
+	}
+}
+
+// handleResetStats lets the host zero the room's relay statistics
+func handleResetStats(sender *wsConn, msg Message) {
+	room, unlock := lockRoom(msg.CallID)
+	if room == nil {
+		sendError(sender, "Call not found")
+		return
+	}
+	if room.host != sender {
+		unlock()
+		sendError(sender, "not_host")
+		return
+	}
+	room.stats.reset()
+	unlock()
+	log.Printf("Host %v reset relay stats for room %s", sender.RemoteAddr(), msg.CallID)
+}
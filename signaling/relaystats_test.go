@@ -0,0 +1,85 @@
+package signaling
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestRelayStatsCountRelayedTraffic(t *testing.T) {
+	ts := NewTestServer(t)
+	host, guest := ts.Connect(), ts.Connect()
+	ts.startCall(host, guest, "stats-call")
+	ts.waitForRoom("stats-call", 2)
+	// start from zero so setting up the call does not count
+	ts.Send(host, Message{Type: "reset_stats", CallID: "stats-call"})
+
+	enabled := true
+	var sent []Message
+	for i := 0; i < 6; i++ {
+		sent = append(sent, Message{Type: "ice-candidate", CallID: "stats-call", Data: fmt.Sprintf(`{"candidate":"candidate:%d 1 udp 1 10.0.0.1 5000 typ host"}`, i)})
+	}
+	for i := 0; i < 5; i++ {
+		sent = append(sent, Message{Type: "relay", CallID: "stats-call", Data: fmt.Sprintf("relay %d", i)})
+	}
+	for i := 0; i < 4; i++ {
+		sent = append(sent, Message{Type: "custom_event", CallID: "stats-call", Event: "cursor", Data: fmt.Sprint(i)})
+	}
+	for i := 0; i < 3; i++ {
+		sent = append(sent, Message{Type: "transcription", CallID: "stats-call", Text: fmt.Sprintf("caption %d", i)})
+	}
+	sent = append(sent,
+		Message{Type: "reaction", CallID: "stats-call", Emoji: "🎉"},
+		Message{Type: "reaction", CallID: "stats-call", Emoji: "👍"},
+		Message{Type: "set_noise_cancellation", CallID: "stats-call", Enabled: &enabled},
+	)
+
+	// what the guest receives is the relayed message, so its encoded size is what the host's stats count
+	var bytes int64
+	for _, msg := range sent {
+		ts.Send(host, msg)
+		relayed := ts.AssertMessageReceived(guest, msg.Type, testTimeout)
+		data, err := json.Marshal(relayed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bytes += int64(len(data))
+	}
+
+	ts.Send(host, Message{Type: "get_relay_stats", CallID: "stats-call"})
+	stats := ts.AssertMessageReceived(host, "relay_stats", testTimeout)
+	if stats.CallID != "stats-call" || stats.MessagesRelayed != int64(len(sent)) || stats.BytesRelayed != bytes {
+		t.Fatalf("relay_stats counted %d messages and %d bytes, want %d and %d", stats.MessagesRelayed, stats.BytesRelayed, len(sent), bytes)
+	}
+	want := map[string]int64{"ice-candidate": 6, "relay": 5, "custom_event": 4, "transcription": 3, "reaction": 2}
+	if fmt.Sprint(stats.TopMessageTypes) != fmt.Sprint(want) {
+		t.Fatalf("topMessageTypes %v, want %v", stats.TopMessageTypes, want)
+	}
+	// the burst can straddle a second boundary, but then half of it still falls in one second
+	if stats.PeakMsgPerSec < (len(sent)+1)/2 || stats.PeakMsgPerSec > len(sent) {
+		t.Fatalf("peakMsgPerSec %d for a burst of %d", stats.PeakMsgPerSec, len(sent))
+	}
+
+	ts.Send(host, Message{Type: "reset_stats", CallID: "stats-call"})
+	ts.Send(host, Message{Type: "get_relay_stats", CallID: "stats-call"})
+	if stats := ts.AssertMessageReceived(host, "relay_stats", testTimeout); stats.MessagesRelayed != 0 || stats.BytesRelayed != 0 || len(stats.TopMessageTypes) != 0 || stats.PeakMsgPerSec != 0 {
+		t.Fatalf("relay_stats after reset %+v", stats)
+	}
+}
+
+func TestRelayStatsHostOnly(t *testing.T) {
+	ts := NewTestServer(t)
+	host, guest := ts.Connect(), ts.Connect()
+	ts.startCall(host, guest, "stats-host-only")
+	ts.waitForRoom("stats-host-only", 2)
+
+	ts.Send(guest, Message{Type: "get_relay_stats", CallID: "stats-host-only"})
+	ts.AssertError(guest, "not_host")
+	ts.Send(host, Message{Type: "relay", CallID: "stats-host-only", Data: "counted"})
+	ts.Send(guest, Message{Type: "reset_stats", CallID: "stats-host-only"})
+	ts.AssertError(guest, "not_host")
+	ts.Send(host, Message{Type: "get_relay_stats", CallID: "stats-host-only"})
+	if stats := ts.AssertMessageReceived(host, "relay_stats", testTimeout); stats.TopMessageTypes["relay"] != 1 {
+		t.Fatalf("guest reset the stats: %+v", stats)
+	}
+}
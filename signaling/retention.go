@@ -0,0 +1,89 @@
+package signaling
+
+import (
+	"log"
+	"time"
+)
+
+// Retention policies a room's chat can be created with; the empty policy keeps messages as the server always has,
+// in SQLite without expiry when SQLITE_PATH is set
+const (
+	retentionNone    = "none"    // relayed only, not even kept for retransmit_request
+	retentionSession = "session" // kept in the room's in-memory ring until the room ends
+)
+
+// retentionPeriods are the policies persisted to SQLite, with how long their messages are kept
+var retentionPeriods = map[string]time.Duration{
+	"7d":  7 * 24 * time.Hour,
+	"30d": 30 * 24 * time.Hour,
+}
+
+// validRetentionPolicy reports whether policy is one a room can be created with
+func validRetentionPolicy(policy string) bool {
+	_, persisted := retentionPeriods[policy]
+	return policy == "" || policy == retentionNone || policy == retentionSession || persisted
+}
+
+// persistsChat reports whether a room with policy stores its chat in SQLite; without a store the persisted
+// policies fall back to session retention
+func persistsChat(policy string) bool {
+	if chatStore == nil {
+		return false
+	}
+	_, persisted := retentionPeriods[policy]
+	return policy == "" || persisted
+}
+
+// chatExpiry is when a chat message sent now under policy is purged, zero for never
+func chatExpiry(policy string) time.Time {
+	period, ok := retentionPeriods[policy]
+	if !ok {
+		return time.Time{}
+	}
+	return time.Now().Add(period)
+}
+
+// roomRetention returns the retention policy of a call's room, and false when there is no such room
+func roomRetention(callID string) (string, bool) {
+	room, unlock := rlockRoom(callID)
+	if room == nil {
+		return "", false
+	}
+	defer unlock()
+	return room.options.RetentionPolicy, true
+}
+
+// sessionChatHistory returns the last limit chat messages still in the room's ring, oldest first; callers hold r.mu
+func (r *Room) sessionChatHistory(callID string, limit int) []ChatMessage {
+	var history []ChatMessage
+	for _, m := range r.history.since(0) {
+		if m.Type != "relay" {
+			continue
+		}
+		sentAt, _ := time.Parse(time.RFC3339Nano, m.ServerTimestamp)
+		history = append(history, ChatMessage{ID: m.Seq, CallID: callID, ClientID: m.From, Timestamp: sentAt, Payload: m.Data})
+	}
+	if len(history) > limit {
+		history = history[len(history)-limit:]
+	}
+	return history
+}
+
+// purgeExpiredChat deletes stored chat messages past their retention once an hour
+func purgeExpiredChat() {
+	for range time.Tick(time.Hour) {
+		purgeChatPass()
+	}
+}
+
+// purgeChatPass deletes the stored chat messages whose retention has run out
+func purgeChatPass() {
+	res, err := chatStore.Exec(`DELETE FROM chat_messages WHERE expires_at IS NOT NULL AND expires_at <= ?`, time.Now().UnixMilli())
+	if err != nil {
+		log.Printf("Error purging expired chat messages: %v", err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		log.Printf("Purged %d expired chat messages", n)
+	}
+}
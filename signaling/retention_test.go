@@ -0,0 +1,149 @@
+package signaling
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// chatInRoom starts callID with policy, relays two chat messages in it and has a third client join, returning
+// the chat_history that client was sent, or nil when it got none
+func (ts *TestServer) chatInRoom(callID, policy string) []ChatMessage {
+	ts.t.Helper()
+	caller, callee := ts.Connect(), ts.Connect()
+	ts.Send(caller, Message{Type: "offer", CallID: callID, Data: sdpData("offer"), RetentionPolicy: policy})
+	ts.waitForRoom(callID, 1)
+	ts.Send(callee, Message{Type: "accept_call", CallID: callID})
+	ts.AssertMessageReceived(callee, "offer", testTimeout)
+	for i := 1; i <= 2; i++ {
+		ts.Send(caller, Message{Type: "relay", CallID: callID, Data: fmt.Sprintf("%s chat %d", policy, i)})
+		ts.AssertMessageReceived(callee, "relay", testTimeout)
+	}
+
+	joiner := ts.Connect()
+	ts.Send(joiner, Message{Type: "join_call", CallID: callID})
+	ts.AssertMessageReceived(joiner, "call_joined", testTimeout)
+	msg, err := ts.receive(joiner, "chat_history", 100*time.Millisecond)
+	if err != nil {
+		return nil
+	}
+	return msg.Messages
+}
+
+// storedExpiries returns the expires_at of every stored chat message of callID, as Unix milliseconds or 0 for never
+func storedExpiries(t *testing.T, callID string) []int64 {
+	t.Helper()
+	rows, err := chatStore.Query(`SELECT expires_at FROM chat_messages WHERE call_id = ? ORDER BY id`, callID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var expiries []int64
+	for rows.Next() {
+		var expiresAt sql.NullInt64
+		if err := rows.Scan(&expiresAt); err != nil {
+			t.Fatal(err)
+		}
+		expiries = append(expiries, expiresAt.Int64)
+	}
+	return expiries
+}
+
+func TestRetentionNone(t *testing.T) {
+	setChatStore(t)
+	ts := NewTestServer(t)
+	if history := ts.chatInRoom("retention-none", retentionNone); history != nil {
+		t.Fatalf("joiner got chat_history %+v", history)
+	}
+	if stored := storedExpiries(t, "retention-none"); len(stored) != 0 {
+		t.Fatalf("%d messages stored", len(stored))
+	}
+	room, unlock := rlockRoom("retention-none")
+	kept := len(room.history.since(0))
+	unlock()
+	if kept != 0 {
+		t.Fatalf("%d messages kept for retransmission", kept)
+	}
+}
+
+func TestRetentionSession(t *testing.T) {
+	setChatStore(t)
+	ts := NewTestServer(t)
+	history := ts.chatInRoom("retention-session", retentionSession)
+	if len(history) != 2 || history[0].Payload != "session chat 1" || history[1].Payload != "session chat 2" || history[0].Timestamp.IsZero() {
+		t.Fatalf("joiner got chat_history %+v", history)
+	}
+	if stored := storedExpiries(t, "retention-session"); len(stored) != 0 {
+		t.Fatalf("%d session messages stored", len(stored))
+	}
+}
+
+func TestRetentionPersisted(t *testing.T) {
+	for policy, period := range retentionPeriods {
+		t.Run(policy, func(t *testing.T) {
+			setChatStore(t)
+			ts := NewTestServer(t)
+			callID := "retention-" + policy
+			before := time.Now().Truncate(time.Millisecond)
+			history := ts.chatInRoom(callID, policy)
+			if len(history) != 2 || history[1].Payload != policy+" chat 2" {
+				t.Fatalf("joiner got chat_history %+v", history)
+			}
+			stored := storedExpiries(t, callID)
+			if len(stored) != 2 {
+				t.Fatalf("%d messages stored, want 2", len(stored))
+			}
+			for _, expiresAt := range stored {
+				if expiry := time.UnixMilli(expiresAt); expiry.Before(before.Add(period)) || expiry.After(time.Now().Add(period)) {
+					t.Fatalf("message expires %v, want %v after it was sent", expiry, period)
+				}
+			}
+		})
+	}
+}
+
+func TestRetentionPersistedWithoutStore(t *testing.T) {
+	previous := chatStore
+	chatStore = nil
+	t.Cleanup(func() { chatStore = previous })
+	ts := NewTestServer(t)
+	if history := ts.chatInRoom("retention-no-store", "30d"); len(history) != 2 {
+		t.Fatalf("without a store 30d rooms keep session history, joiner got %+v", history)
+	}
+}
+
+func TestRetentionPolicyValidated(t *testing.T) {
+	ts := NewTestServer(t)
+	caller := ts.Connect()
+	ts.Send(caller, Message{Type: "offer", CallID: "retention-forever", Data: sdpData("offer"), RetentionPolicy: "forever"})
+	ts.AssertError(caller, "invalid_retention_policy")
+	ts.Send(caller, Message{Type: "incoming_call", CallID: "retention-forever", RetentionPolicy: "1y"})
+	ts.AssertError(caller, "invalid_retention_policy")
+	if _, ok := roomRetention("retention-forever"); ok {
+		t.Fatal("room created with an invalid retention policy")
+	}
+}
+
+func TestPurgeExpiredChat(t *testing.T) {
+	setChatStore(t)
+	saveChatMessage("purge-call", "purger", "expired", "7d")
+	saveChatMessage("purge-call", "purger", "current", "7d")
+	saveChatMessage("purge-call", "purger", "forever", "")
+	if _, err := chatStore.Exec(`UPDATE chat_messages SET expires_at = ? WHERE payload = 'expired'`, time.Now().Add(-time.Minute).UnixMilli()); err != nil {
+		t.Fatal(err)
+	}
+
+	// expired messages are hidden from history before the purge gets to them
+	history, err := chatHistory("purge-call", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].Payload != "current" {
+		t.Fatalf("history before purging %+v", history)
+	}
+	purgeChatPass()
+	if stored := storedExpiries(t, "purge-call"); len(stored) != 2 || stored[0] == 0 || stored[1] != 0 {
+		t.Fatalf("after purging stored expiries are %v, want the current and the unexpiring message", stored)
+	}
+}
@@ -0,0 +1,55 @@
+package signaling
+
+import (
+	"log"
+	"slices"
+	"time"
+)
+
+// retransmitBuffer is how many relayed messages each room keeps for retransmit_request
+var retransmitBuffer = envInt("RETRANSMIT_BUFFER", 100)
+
+// stamp gives a relayed message the room's next sequence number and keeps it for retransmission unless the room
+// retains nothing, marking the room active and counting it in the relay stats; callers hold r.mu
+func (r *Room) stamp(msg Message) Message {
+	msg.Seq = r.seqNum.Add(1)
+	if r.options.RetentionPolicy != retentionNone {
+		r.history.add(msg)
+	}
+	r.LastActivity = time.Now()
+	r.stats.record(msg)
+	return msg
+}
+
+// handleRetransmitRequest resends a member the room's buffered messages from fromSeq on, leaving out those relayed
+// before it joined and captions in languages it has not subscribed to
+func handleRetransmitRequest(sender *wsConn, msg Message) {
+	room, unlock := rlockRoom(msg.CallID)
+	if room == nil {
+		sendError(sender, "Call not found")
+		return
+	}
+	if !room.clients[sender] {
+		unlock()
+		sendError(sender, "not_in_call")
+		return
+	}
+	missed := room.history.since(max(msg.FromSeq, room.joinSeq[sender]))
+	unlock()
+
+	client, ok := getClient(sender)
+	if !ok {
+		return
+	}
+	missed = slices.DeleteFunc(missed, func(m Message) bool {
+		return m.Type == "caption" && !client.wantsCaption(m.Lang)
+	})
+	for _, m := range missed {
+		if err := sender.WriteJSON(m); err != nil {
+			log.Printf("Error retransmitting %s to %v: %v", m.Type, sender.RemoteAddr(), err)
+			go cleanupClient(sender)
+			return
+		}
+	}
+	log.Printf("Retransmitted %d messages from seq %d to %v in room %s", len(missed), msg.FromSeq, sender.RemoteAddr(), msg.CallID)
+}
@@ -0,0 +1,59 @@
+package signaling
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetransmitResendsMissedMessages(t *testing.T) {
+	ts := NewTestServer(t)
+	caller, callee := ts.Connect(), ts.Connect()
+	ts.startCall(caller, callee, "retransmit-call")
+	var seqs []int64
+	for _, data := range []string{"first", "second", "third"} {
+		ts.Send(caller, Message{Type: "relay", CallID: "retransmit-call", Data: data})
+		seqs = append(seqs, ts.AssertMessageReceived(callee, "relay", testTimeout).Seq)
+	}
+	if seqs[0] >= seqs[1] || seqs[1] >= seqs[2] {
+		t.Fatalf("relayed with seqs %v, want them increasing", seqs)
+	}
+
+	ts.Send(callee, Message{Type: "retransmit_request", CallID: "retransmit-call", FromSeq: seqs[1]})
+	for i, want := range []string{"second", "third"} {
+		if msg := ts.AssertMessageReceived(callee, "relay", testTimeout); msg.Data != want || msg.Seq != seqs[i+1] {
+			t.Fatalf("retransmitted %+v, want %s with seq %d", msg, want, seqs[i+1])
+		}
+	}
+	ts.RequireNoMessageOfType(callee, "relay", 50*time.Millisecond)
+
+	outsider := ts.Connect()
+	ts.Send(outsider, Message{Type: "retransmit_request", CallID: "retransmit-call"})
+	ts.AssertError(outsider, "not_in_call")
+}
+
+func TestRetransmitSkipsMessagesBeforeJoining(t *testing.T) {
+	ts := NewTestServer(t)
+	caller, callee := ts.Connect(), ts.Connect()
+	ts.startCall(caller, callee, "retransmit-late")
+	ts.Send(caller, Message{Type: "relay", CallID: "retransmit-late", Data: "before the late joiner"})
+	ts.AssertMessageReceived(callee, "relay", testTimeout)
+
+	late := ts.Connect()
+	ts.Send(late, Message{Type: "join_call", CallID: "retransmit-late"})
+	ts.AssertMessageReceived(late, "call_joined", testTimeout)
+	ts.Send(caller, Message{Type: "relay", CallID: "retransmit-late", Data: "after the late joiner"})
+	ts.AssertMessageReceived(late, "relay", testTimeout)
+	ts.AssertMessageReceived(callee, "relay", testTimeout)
+
+	ts.Send(late, Message{Type: "retransmit_request", CallID: "retransmit-late", FromSeq: 0})
+	if msg := ts.AssertMessageReceived(late, "relay", testTimeout); msg.Data != "after the late joiner" {
+		t.Fatalf("late joiner was retransmitted %q", msg.Data)
+	}
+	ts.RequireNoMessageOfType(late, "relay", 50*time.Millisecond)
+
+	// members who were there all along still get everything
+	ts.Send(callee, Message{Type: "retransmit_request", CallID: "retransmit-late", FromSeq: 0})
+	if msg := ts.AssertMessageReceived(callee, "relay", testTimeout); msg.Data != "before the late joiner" {
+		t.Fatalf("callee was first retransmitted %q", msg.Data)
+	}
+}
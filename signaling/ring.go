@@ -0,0 +1,88 @@
+package signaling
+
+import (
+	"sort"
+	"time"
+)
+
+// durationRing keeps the most recent durations in a fixed-size ring buffer
+type durationRing struct {
+	buf  []time.Duration
+	next int
+	full bool
+}
+
+// newDurationRing creates a ring buffer holding up to size durations
+func newDurationRing(size int) *durationRing {
+	return &durationRing{buf: make([]time.Duration, size)}
+}
+
+// add records d, overwriting the oldest value when the ring is full
+func (r *durationRing) add(d time.Duration) {
+	r.buf[r.next] = d
+	r.next = (r.next + 1) % len(r.buf)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// values returns the recorded durations from oldest to newest
+func (r *durationRing) values() []time.Duration {
+	if !r.full {
+		return append([]time.Duration(nil), r.buf[:r.next]...)
+	}
+	return append(append([]time.Duration(nil), r.buf[r.next:]...), r.buf[:r.next]...)
+}
+
+// percentile returns the p-th percentile (0-100) of values using the nearest-rank method
+func percentile(values []time.Duration, p float64) time.Duration {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := int(p/100*float64(len(sorted))+0.5) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+// messageRing keeps the most recent messages in a fixed-size ring buffer
+type messageRing struct {
+	buf  []Message
+	next int
+	full bool
+}
+
+// newMessageRing creates a ring buffer holding up to size messages
+func newMessageRing(size int) *messageRing {
+	return &messageRing{buf: make([]Message, max(size, 1))}
+}
+
+// add records msg, overwriting the oldest message when the ring is full
+func (r *messageRing) add(msg Message) {
+	r.buf[r.next] = msg
+	r.next = (r.next + 1) % len(r.buf)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// since returns the recorded messages with Seq at least seq, oldest first
+func (r *messageRing) since(seq int64) []Message {
+	ordered := r.buf[:r.next]
+	if r.full {
+		ordered = append(append([]Message(nil), r.buf[r.next:]...), r.buf[:r.next]...)
+	}
+	var msgs []Message
+	for _, msg := range ordered {
+		if msg.Seq >= seq {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs
+}
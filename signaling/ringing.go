@@ -0,0 +1,155 @@
+package signaling
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// ringTimeout is how long one idle client is rung before the call moves on to the next
+var ringTimeout = time.Duration(envInt("RING_TIMEOUT_SECONDS", 15)) * time.Second
+
+// ringState tracks which idle client a queued call is ringing, longest idle first
+type ringState struct {
+	caller  *wsConn
+	from    string
+	tried   map[*wsConn]bool // clients rung in this pass over the idle clients
+	rung    map[*wsConn]bool // every client rung for the call, told call_taken when someone accepts it
+	current *wsConn
+	timer   *time.Timer
+}
+
+// Ringing state by call ID
+var (
+	ringing = make(map[string]*ringState)
+	ringMu  sync.Mutex
+)
+
+// startRinging rings the longest idle client for a new incoming call; ringing left over from an earlier caller
+// of the same call ID who has since left the room is replaced
+func startRinging(caller *wsConn, callID, from string) {
+	ringMu.Lock()
+	if r, ok := ringing[callID]; ok {
+		if _, member := roomMembers(callID, r.caller); member {
+			ringMu.Unlock()
+			return
+		}
+		if r.timer != nil {
+			r.timer.Stop()
+		}
+	}
+	ringing[callID] = &ringState{caller: caller, from: from, tried: make(map[*wsConn]bool), rung: make(map[*wsConn]bool)}
+	ringMu.Unlock()
+	ringNext(callID, nil)
+}
+
+// ringNext moves a call on from expected, the client it was ringing, to the next longest idle client.
+// Once every idle client has been tried it starts over; ringing stops when the call leaves the waiting queue.
+// While nobody is idle the call rings no one until ringParkedCalls finds it.
+func ringNext(callID string, expected *wsConn) {
+	ringMu.Lock()
+	r := ringing[callID]
+	if r == nil || r.current != expected {
+		ringMu.Unlock()
+		return
+	}
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	if !callQueued(callID) {
+		delete(ringing, callID)
+		ringMu.Unlock()
+		return
+	}
+	previous := r.current
+	next, idleFor := nextIdleClient(r.caller, r.tried)
+	if next == nil && len(r.tried) > 0 {
+		r.tried = make(map[*wsConn]bool)
+		next, idleFor = nextIdleClient(r.caller, r.tried)
+	}
+	r.current = next
+	if next != nil {
+		r.tried[next] = true
+		r.rung[next] = true
+		r.timer = time.AfterFunc(ringTimeout, func() { ringNext(callID, next) })
+	}
+	from := r.from
+	ringMu.Unlock()
+
+	if previous != nil {
+		if err := previous.WriteJSON(Message{Type: "ring_timeout", CallID: callID}); err != nil {
+			log.Printf("Error sending ring_timeout to %v: %v", previous.RemoteAddr(), err)
+			go cleanupClient(previous)
+		}
+	}
+	if next == nil {
+		log.Printf("No idle clients left to ring for call %s, waiting for one", callID)
+		return
+	}
+	if err := next.WriteJSON(Message{Type: "incoming_call", CallID: callID, From: from}); err != nil {
+		log.Printf("Error sending incoming call to %v: %v", next.RemoteAddr(), err)
+		go cleanupClient(next)
+		go ringNext(callID, next)
+		return
+	}
+	log.Printf("Ringing %v for call %s, idle for %v", next.RemoteAddr(), callID, idleFor.Round(time.Second))
+}
+
+// ringParkedCalls rings the queued calls that found nobody idle, once a client has become idle
+func ringParkedCalls() {
+	ringMu.Lock()
+	var parked []string
+	for callID, r := range ringing {
+		if r.current == nil {
+			parked = append(parked, callID)
+		}
+	}
+	ringMu.Unlock()
+	for _, callID := range parked {
+		ringNext(callID, nil)
+	}
+}
+
+// rungClients returns the clients that have been rung for callID
+func rungClients(callID string) []*wsConn {
+	ringMu.Lock()
+	defer ringMu.Unlock()
+	r := ringing[callID]
+	if r == nil {
+		return nil
+	}
+	rung := make([]*wsConn, 0, len(r.rung))
+	for conn := range r.rung {
+		rung = append(rung, conn)
+	}
+	return rung
+}
+
+// nextIdleClient returns the idle client, other than the caller and those already tried, that has been idle longest
+func nextIdleClient(caller *wsConn, tried map[*wsConn]bool) (*wsConn, time.Duration) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	var best *wsConn
+	var bestSince time.Time
+	for conn := range idleClients {
+		if conn == caller || tried[conn] || blocks(conn, caller) {
+			continue
+		}
+		client, ok := getClient(conn)
+		if !ok {
+			continue
+		}
+		if best == nil || client.idleSince.Before(bestSince) {
+			best, bestSince = conn, client.idleSince
+		}
+	}
+	if best == nil {
+		return nil, 0
+	}
+	return best, time.Since(bestSince)
+}
+
+// handleDeclineCall lets the client being rung pass the call on to the next idle client straight away
+func handleDeclineCall(sender *wsConn, msg Message) {
+	ringNext(msg.CallID, sender)
+}
@@ -0,0 +1,93 @@
+package signaling
+
+import (
+	"testing"
+	"time"
+)
+
+// setRingTimeout replaces RING_TIMEOUT_SECONDS for the rest of the test
+func setRingTimeout(t *testing.T, timeout time.Duration) {
+	previous := ringTimeout
+	ringTimeout = timeout
+	t.Cleanup(func() { ringTimeout = previous })
+}
+
+func TestRingsLongestIdleFirst(t *testing.T) {
+	ts := NewTestServer(t)
+	setRingTimeout(t, time.Minute)
+	first, second := ts.Connect(), ts.Connect()
+	caller := ts.Connect()
+	ts.Send(caller, Message{Type: "incoming_call", CallID: "ring-order", From: "Ann"})
+
+	if msg := ts.AssertMessageReceived(first, "incoming_call", testTimeout); msg.From != "Ann" {
+		t.Fatalf("incoming_call from %q, want Ann", msg.From)
+	}
+	ts.RequireNoMessageOfType(second, "incoming_call", 100*time.Millisecond)
+}
+
+func TestRingMovesOnAfterTimeoutAndDecline(t *testing.T) {
+	ts := NewTestServer(t)
+	setRingTimeout(t, 100*time.Millisecond)
+	first, second, third := ts.Connect(), ts.Connect(), ts.Connect()
+	caller := ts.Connect()
+	ts.Send(caller, Message{Type: "incoming_call", CallID: "ring-next"})
+
+	ts.AssertMessageReceived(first, "incoming_call", testTimeout)
+	ts.AssertMessageReceived(first, "ring_timeout", testTimeout)
+	ts.AssertMessageReceived(second, "incoming_call", testTimeout)
+	ts.Send(second, Message{Type: "decline_call", CallID: "ring-next"})
+	ts.AssertMessageReceived(second, "ring_timeout", testTimeout)
+	ts.AssertMessageReceived(third, "incoming_call", testTimeout)
+}
+
+func TestCallTakenOnlyToRungClients(t *testing.T) {
+	ts := NewTestServer(t)
+	setRingTimeout(t, 300*time.Millisecond)
+	missed, taker, bystander := ts.Connect(), ts.Connect(), ts.Connect()
+	caller := ts.Connect()
+	ts.Send(caller, Message{Type: "incoming_call", CallID: "ring-taken"})
+	ts.Send(caller, Message{Type: "offer", CallID: "ring-taken", Data: sdpData("offer")})
+
+	ts.AssertMessageReceived(missed, "incoming_call", testTimeout)
+	ts.AssertMessageReceived(taker, "incoming_call", testTimeout)
+	ts.Send(taker, Message{Type: "accept_call", CallID: "ring-taken"})
+	ts.AssertMessageReceived(taker, "call_joined", testTimeout)
+
+	if msg := ts.AssertMessageReceived(missed, "call_taken", testTimeout); msg.CallID != "ring-taken" {
+		t.Fatalf("call_taken for %q", msg.CallID)
+	}
+	ts.RequireNoMessageOfType(bystander, "call_taken", 100*time.Millisecond)
+	ts.RequireNoMessageOfType(taker, "call_taken", 0)
+}
+
+func TestQueuedCallRingsAgentThatBecomesIdle(t *testing.T) {
+	ts := NewTestServer(t)
+	setRingTimeout(t, time.Minute)
+	busy, peer := ts.Connect(), ts.Connect()
+	ts.startCall(busy, peer, "ring-busy")
+	ts.waitForRoom("ring-busy", 2)
+
+	// everyone but the caller is in a call, so nobody is rung yet
+	caller := ts.Connect()
+	ts.Send(caller, Message{Type: "incoming_call", CallID: "ring-parked", From: "Ann"})
+	ts.waitForRoom("ring-parked", 1)
+	ts.RequireNoMessageOfType(busy, "incoming_call", 100*time.Millisecond)
+
+	ts.Send(busy, Message{Type: "hangup", CallID: "ring-busy"})
+	if msg := ts.AssertMessageReceived(busy, "incoming_call", testTimeout); msg.CallID != "ring-parked" || msg.From != "Ann" {
+		t.Fatalf("agent back from a call got incoming_call %+v", msg)
+	}
+}
+
+func TestQueuedCallRingsAgentThatConnects(t *testing.T) {
+	ts := NewTestServer(t)
+	setRingTimeout(t, time.Minute)
+	caller := ts.Connect()
+	ts.Send(caller, Message{Type: "incoming_call", CallID: "ring-empty"})
+	ts.waitForRoom("ring-empty", 1)
+
+	agent := ts.Connect()
+	if msg := ts.AssertMessageReceived(agent, "incoming_call", testTimeout); msg.CallID != "ring-empty" {
+		t.Fatalf("new agent got incoming_call %+v", msg)
+	}
+}
@@ -0,0 +1,151 @@
+package signaling
+
+import (
+	"crypto/rand"
+	_ "embed"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// callIDStrategy picks who names new rooms: "client" lets offer and incoming_call name them, falling back to a
+// server_slug name when they send no callId; the server_ modes always name rooms they create themselves
+var callIDStrategy = envString("CALL_ID_STRATEGY", "client")
+
+// callIDStrategies are the valid CALL_ID_STRATEGY values
+var callIDStrategies = map[string]bool{"client": true, "server_uuid": true, "server_slug": true, "server_numeric": true}
+
+// roomCounter numbers rooms under the server_numeric strategy
+var roomCounter atomic.Int64
+
+var (
+	//go:embed words/adjectives.txt
+	adjectiveList string
+	//go:embed words/nouns.txt
+	nounList string
+
+	adjectives = strings.Fields(adjectiveList)
+	nouns      = strings.Fields(nounList)
+)
+
+// roomNameAttempts is how many generated names are tried before giving up on a collision
+const roomNameAttempts = 10
+
+// randomIndex returns a uniformly random integer in [0, n)
+func randomIndex(n int) int {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		log.Printf("Error generating room name: %v", err)
+		return 0
+	}
+	return int(i.Int64())
+}
+
+// randomRoomName returns a human-readable call ID such as purple-tiger-7842
+func randomRoomName() string {
+	return fmt.Sprintf("%s-%s-%04d", adjectives[randomIndex(len(adjectives))], nouns[randomIndex(len(nouns))], randomIndex(10000))
+}
+
+// randomUUID returns a random (version 4) UUID
+func randomUUID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.Printf("Error generating room UUID: %v", err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// generateCallID returns a call ID for a room the server names, in the form CALL_ID_STRATEGY asks for
+func generateCallID() string {
+	switch callIDStrategy {
+	case "server_uuid":
+		return randomUUID()
+	case "server_numeric":
+		return strconv.FormatInt(roomCounter.Add(1), 10)
+	default:
+		return randomRoomName()
+	}
+}
+
+// createNamedRoom creates a room under a fresh generated name and returns it locked, or nil if every attempt collided
+func createNamedRoom() (*Room, string, func()) {
+	for i := 0; i < roomNameAttempts; i++ {
+		name := generateCallID()
+		roomsMu.Lock()
+		if _, taken := rooms[name]; taken {
+			roomsMu.Unlock()
+			continue
+		}
+		room := newRoom()
+		rooms[name] = room
+		room.mu.Lock()
+		return room, name, func() {
+			room.mu.Unlock()
+			roomsMu.Unlock()
+		}
+	}
+	return nil, "", nil
+}
+
+// Reasons lockCallRoom gives no room
+var (
+	errRoomNameUnavailable = errors.New("room_name_unavailable")
+	errNotRoomMember       = errors.New("not_in_call")
+)
+
+// lockCallRoom locks the room sender's offer or incoming_call names, creating it when it does not exist. A new room
+// gets a generated call ID instead of callID when callID is empty or CALL_ID_STRATEGY is a server_ mode; the ID the
+// room has is returned along with whether it was generated. In the server_ modes a callID the sender was given a
+// room for leads back to that room, and an existing room is only entered by its members, so nobody can offer into
+// a room by guessing its ID.
+func lockCallRoom(sender *wsConn, callID string) (room *Room, id string, created, assigned bool, unlock func(), err error) {
+	if callID != "" && callIDStrategy == "client" {
+		room, created, unlock = lockOrCreateRoom(callID)
+		return room, callID, created, false, unlock, nil
+	}
+	client, ok := getClient(sender)
+	if callID != "" && ok {
+		id = callID
+		if assignedID := client.assignedCallID(callID); assignedID != "" {
+			id = assignedID
+		}
+		if room, unlock = lockRoom(id); room != nil {
+			if !room.clients[sender] {
+				unlock()
+				return nil, "", false, false, nil, errNotRoomMember
+			}
+			return room, id, false, false, unlock, nil
+		}
+	}
+	room, id, unlock = createNamedRoom()
+	if room == nil {
+		return nil, "", false, false, nil, errRoomNameUnavailable
+	}
+	if callID != "" && ok {
+		client.mu.Lock()
+		client.assignedCallIDs[callID] = id
+		client.mu.Unlock()
+	}
+	return room, id, true, true, unlock, nil
+}
+
+// assignedCallID returns the call ID of the room the server created for the client when it asked for callID
+func (c *Client) assignedCallID(callID string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.assignedCallIDs[callID]
+}
+
+// sendRoomAssigned tells the creator of a room the call ID the server gave it, and the one it asked for if any
+func sendRoomAssigned(conn *wsConn, callID, requested string) {
+	if err := conn.WriteJSON(Message{Type: "room_assigned", CallID: callID, RequestedCallID: requested}); err != nil {
+		log.Printf("Error sending room_assigned to %v: %v", conn.RemoteAddr(), err)
+		go cleanupClient(conn)
+	}
+}
@@ -0,0 +1,79 @@
+package signaling
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// setCallIDStrategy replaces CALL_ID_STRATEGY for the rest of the test
+func setCallIDStrategy(t *testing.T, strategy string) {
+	previous := callIDStrategy
+	callIDStrategy = strategy
+	t.Cleanup(func() { callIDStrategy = previous })
+}
+
+// roomOffer returns the data of the offer the room for callID holds
+func roomOffer(t *testing.T, callID string) string {
+	t.Helper()
+	room, unlock := rlockRoom(callID)
+	if room == nil {
+		t.Fatalf("no room %s", callID)
+	}
+	defer unlock()
+	return room.offer.Data
+}
+
+func TestServerAssignedRoomKeepsRequestedName(t *testing.T) {
+	ts := NewTestServer(t)
+	setCallIDStrategy(t, "server_numeric")
+	host := ts.Connect()
+	ts.Send(host, Message{Type: "offer", CallID: "assigned-call", Data: sdpData("first offer")})
+	msg := ts.AssertMessageReceived(host, "room_assigned", testTimeout)
+	if msg.RequestedCallID != "assigned-call" || msg.CallID == "" || msg.CallID == "assigned-call" {
+		t.Fatalf("room_assigned %+v", msg)
+	}
+	assigned := msg.CallID
+
+	// offering again under the requested name reaches the same room rather than making another
+	ts.Send(host, Message{Type: "offer", CallID: "assigned-call", Data: sdpData("second offer")})
+	ts.RequireNoMessageOfType(host, "room_assigned", 50*time.Millisecond)
+	if data := roomOffer(t, assigned); !strings.Contains(data, "second offer") {
+		t.Fatalf("room %s holds offer %q", assigned, data)
+	}
+}
+
+func TestServerAssignedRoomRefusesOutsiders(t *testing.T) {
+	ts := NewTestServer(t)
+	setCallIDStrategy(t, "server_numeric")
+	host, guest := ts.Connect(), ts.Connect()
+	ts.Send(host, Message{Type: "offer", Data: sdpData("host offer")})
+	assigned := ts.AssertMessageReceived(host, "room_assigned", testTimeout).CallID
+
+	outsider := ts.Connect()
+	ts.Send(outsider, Message{Type: "offer", CallID: assigned, Data: sdpData("guessed offer")})
+	ts.AssertError(outsider, "not_in_call")
+	if data := roomOffer(t, assigned); !strings.Contains(data, "host offer") {
+		t.Fatalf("outsider replaced the offer with %q", data)
+	}
+
+	// members can still renegotiate
+	ts.Send(guest, Message{Type: "accept_call", CallID: assigned})
+	ts.AssertMessageReceived(guest, "offer", testTimeout)
+	ts.Send(guest, Message{Type: "offer", CallID: assigned, Data: sdpData("guest offer")})
+	ts.RequireNoMessageOfType(guest, "error", 50*time.Millisecond)
+	if data := roomOffer(t, assigned); !strings.Contains(data, "guest offer") {
+		t.Fatalf("member offer left %q", data)
+	}
+}
+
+func TestClientNamedRoomsUnchanged(t *testing.T) {
+	ts := NewTestServer(t)
+	setCallIDStrategy(t, "client")
+	host, other := ts.Connect(), ts.Connect()
+	ts.Send(host, Message{Type: "offer", CallID: "client-named", Data: sdpData("host offer")})
+	ts.waitForRoom("client-named", 1)
+	ts.Send(other, Message{Type: "offer", CallID: "client-named", Data: sdpData("other offer")})
+	ts.waitForRoom("client-named", 2)
+	ts.RequireNoMessageOfType(host, "room_assigned", 50*time.Millisecond)
+}
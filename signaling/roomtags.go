@@ -0,0 +1,151 @@
+package signaling
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxRoomTags bounds how many tags a room can carry
+const maxRoomTags = 5
+
+// roomTagCounts is how many rooms carry each tag, mirrored in the videochat_rooms_by_tag gauge
+var (
+	roomTagCounts   = make(map[string]int)
+	roomTagCountsMu sync.Mutex
+)
+
+// validRoomTag reports whether tag is 1 to 32 letters, digits and hyphens
+func validRoomTag(tag string) bool {
+	if tag == "" || len(tag) > 32 {
+		return false
+	}
+	for _, r := range tag {
+		if !(r == '-' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeRoomTags validates tags and drops duplicates, keeping the first occurrence of each
+func normalizeRoomTags(tags []string) ([]string, bool) {
+	if len(tags) > maxRoomTags {
+		return nil, false
+	}
+	var normalized []string
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		if !validRoomTag(tag) {
+			return nil, false
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized, true
+}
+
+// countRoomTags moves the per-tag room counts from a room's old tags to its new ones
+func countRoomTags(old, tags []string) {
+	roomTagCountsMu.Lock()
+	defer roomTagCountsMu.Unlock()
+	for _, tag := range old {
+		roomTagCounts[tag]--
+		if roomTagCounts[tag] <= 0 {
+			delete(roomTagCounts, tag)
+			roomsByTag.DeleteLabelValues(tag)
+		} else {
+			roomsByTag.WithLabelValues(tag).Set(float64(roomTagCounts[tag]))
+		}
+	}
+	for _, tag := range tags {
+		roomTagCounts[tag]++
+		roomsByTag.WithLabelValues(tag).Set(float64(roomTagCounts[tag]))
+	}
+}
+
+// hasTag reports whether the room carries tag; callers hold r.mu
+func (r *Room) hasTag(tag string) bool {
+	for _, t := range r.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// untagRoom drops a deleted room from the per-tag room counts
+func untagRoom(room *Room) {
+	room.mu.Lock()
+	tags := room.Tags
+	room.Tags = nil
+	room.mu.Unlock()
+	countRoomTags(tags, nil)
+}
+
+// handleTagRoom lets the room host replace the room's tags, which the rooms API can filter by
+func handleTagRoom(sender *wsConn, msg Message) {
+	tags, ok := normalizeRoomTags(msg.Tags)
+	if !ok {
+		sendError(sender, "invalid_tags")
+		return
+	}
+
+	room, unlock := lockRoom(msg.CallID)
+	if room == nil {
+		sendError(sender, "Call not found")
+		return
+	}
+	if room.host != sender {
+		unlock()
+		sendError(sender, "not_host")
+		return
+	}
+	old := room.Tags
+	room.Tags = tags
+	countRoomTags(old, tags)
+	unlock()
+
+	broadcastToRoom(sender, Message{Type: "room_tagged", CallID: msg.CallID, Tags: tags})
+	log.Printf("Host %v tagged room %s with %v", sender.RemoteAddr(), msg.CallID, tags)
+}
+
+// RoomSummary describes a room in the GET /api/v1/rooms response
+type RoomSummary struct {
+	CallID    string    `json:"callId"`
+	Clients   int       `json:"clients"`
+	Tags      []string  `json:"tags"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// handleListRooms lists the rooms, only those carrying the tag query parameter when it is given
+func handleListRooms(w http.ResponseWriter, r *http.Request) {
+	tag := r.URL.Query().Get("tag")
+	if tag != "" && !validRoomTag(tag) {
+		http.Error(w, "Invalid tag", http.StatusBadRequest)
+		return
+	}
+
+	summaries := []RoomSummary{}
+	roomsMu.RLock()
+	for callID, room := range rooms {
+		room.mu.RLock()
+		if tag == "" || room.hasTag(tag) {
+			summaries = append(summaries, RoomSummary{
+				CallID:    callID,
+				Clients:   len(room.clients),
+				Tags:      append([]string{}, room.Tags...),
+				CreatedAt: room.createdAt,
+			})
+		}
+		room.mu.RUnlock()
+	}
+	roomsMu.RUnlock()
+
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].CallID < summaries[j].CallID })
+	writeJSONResponse(w, http.StatusOK, map[string][]RoomSummary{"rooms": summaries})
+}
@@ -0,0 +1,125 @@
+package signaling
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// taggedRoom opens callID with a host that tags it, returning the host
+func (ts *TestServer) taggedRoom(callID string, tags ...string) *websocket.Conn {
+	ts.t.Helper()
+	host := ts.Connect()
+	ts.Send(host, Message{Type: "offer", CallID: callID, Data: sdpData("offer")})
+	ts.waitForRoom(callID, 1)
+	if len(tags) > 0 {
+		ts.Send(host, Message{Type: "tag_room", CallID: callID, Tags: tags})
+		if msg := ts.AssertMessageReceived(host, "room_tagged", testTimeout); strings.Join(msg.Tags, ",") != strings.Join(tags, ",") {
+			ts.t.Fatalf("room_tagged %v, want %v", msg.Tags, tags)
+		}
+	}
+	return host
+}
+
+// listRooms returns the call IDs GET /api/v1/rooms lists for the query
+func (ts *TestServer) listRooms(token, query string) []string {
+	ts.t.Helper()
+	resp := ts.adminRequest("GET", "/api/v1/rooms"+query, token)
+	if resp.StatusCode != http.StatusOK {
+		ts.t.Fatalf("rooms%s status %d", query, resp.StatusCode)
+	}
+	var list struct {
+		Rooms []RoomSummary `json:"rooms"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		ts.t.Fatal(err)
+	}
+	var callIDs []string
+	for _, room := range list.Rooms {
+		callIDs = append(callIDs, room.CallID)
+	}
+	return callIDs
+}
+
+func TestRoomsFilteredByTag(t *testing.T) {
+	ts := NewTestServer(t)
+	setAdminToken(t, "tags-admin")
+	ts.taggedRoom("tags-support-a", "support", "customer-123")
+	supportB := ts.taggedRoom("tags-support-b", "support")
+	salesHost := ts.taggedRoom("tags-sales", "sales")
+	ts.taggedRoom("tags-untagged")
+
+	for query, want := range map[string]string{
+		"?tag=support":      "tags-support-a,tags-support-b",
+		"?tag=customer-123": "tags-support-a",
+		"?tag=sales":        "tags-sales",
+		"?tag=nobody":       "",
+	} {
+		if got := strings.Join(ts.listRooms("tags-admin", query), ","); got != want {
+			t.Errorf("rooms%s lists %q, want %q", query, got, want)
+		}
+	}
+	if all := strings.Join(ts.listRooms("tags-admin", ""), ","); !strings.Contains(all, "tags-support-a,tags-support-b,tags-untagged") {
+		t.Errorf("unfiltered rooms list %s", all)
+	}
+	if resp := ts.adminRequest("GET", "/api/v1/rooms?tag=not+valid", "tags-admin"); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid tag answered %d", resp.StatusCode)
+	}
+	if resp := ts.adminRequest("GET", "/api/v1/rooms?tag=support", ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("rooms list without the admin token answered %d", resp.StatusCode)
+	}
+
+	gauge := func(tag string) float64 { return ts.scrapeMetric(`videochat_rooms_by_tag{tag="` + tag + `"}`) }
+	if support, sales := gauge("support"), gauge("sales"); support != 2 || sales != 1 {
+		t.Fatalf("rooms by tag support=%v sales=%v, want 2 and 1", support, sales)
+	}
+
+	// retagging moves the room between tags, and a deleted room no longer counts
+	ts.Send(supportB, Message{Type: "tag_room", CallID: "tags-support-b", Tags: []string{"sales"}})
+	ts.AssertMessageReceived(supportB, "room_tagged", testTimeout)
+	if support, sales := gauge("support"), gauge("sales"); support != 1 || sales != 2 {
+		t.Fatalf("after retagging support=%v sales=%v, want 1 and 2", support, sales)
+	}
+	salesHost.Close()
+	for deadline := time.Now().Add(testTimeout); gauge("sales") != 1; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("sales rooms gauge %v after a sales room closed, want 1", gauge("sales"))
+		}
+	}
+	if got := strings.Join(ts.listRooms("tags-admin", "?tag=sales"), ","); got != "tags-support-b" {
+		t.Fatalf("sales rooms %q after tags-sales closed", got)
+	}
+}
+
+func TestTagRoomRules(t *testing.T) {
+	ts := NewTestServer(t)
+	host, guest := ts.Connect(), ts.Connect()
+	ts.startCall(host, guest, "tags-rules")
+
+	ts.Send(guest, Message{Type: "tag_room", CallID: "tags-rules", Tags: []string{"support"}})
+	ts.AssertError(guest, "not_host")
+	for _, tags := range [][]string{
+		{"a", "b", "c", "d", "e", "f"},
+		{"has space"},
+		{strings.Repeat("x", 33)},
+		{""},
+	} {
+		ts.Send(host, Message{Type: "tag_room", CallID: "tags-rules", Tags: tags})
+		ts.AssertError(host, "invalid_tags")
+	}
+	room, unlock := rlockRoom("tags-rules")
+	tags := room.Tags
+	unlock()
+	if len(tags) != 0 {
+		t.Fatalf("rejected tag_room left tags %v", tags)
+	}
+
+	ts.Send(host, Message{Type: "tag_room", CallID: "tags-rules", Tags: []string{"support", "vip", "support"}})
+	if msg := ts.AssertMessageReceived(guest, "room_tagged", testTimeout); strings.Join(msg.Tags, ",") != "support,vip" {
+		t.Fatalf("room_tagged %v, want duplicates dropped", msg.Tags)
+	}
+}
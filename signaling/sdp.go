@@ -0,0 +1,39 @@
+package signaling
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/pion/sdp/v3"
+)
+
+// maxSDPLineLength is the longest SDP line accepted in an offer or answer
+const maxSDPLineLength = 1000
+
+// validateSDP checks the RTCSessionDescription JSON carried in an offer or answer's data
+func validateSDP(data string) error {
+	var desc struct {
+		SDP string `json:"sdp"`
+	}
+	if err := json.Unmarshal([]byte(data), &desc); err != nil {
+		return err
+	}
+	for _, line := range strings.Split(desc.SDP, "\n") {
+		if len(line) > maxSDPLineLength {
+			return errors.New("sdp line too long")
+		}
+	}
+
+	var session sdp.SessionDescription
+	if err := session.UnmarshalString(desc.SDP); err != nil {
+		return err
+	}
+	if session.Origin.NetworkType == "" || session.Origin.AddressType == "" || session.Origin.UnicastAddress == "" {
+		return errors.New("sdp has no valid o= line")
+	}
+	if len(session.MediaDescriptions) == 0 {
+		return errors.New("sdp has no m= line")
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package signaling
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sdpWith returns offer data carrying sdp
+func sdpWith(sdp string) string {
+	data, _ := json.Marshal(map[string]string{"type": "offer", "sdp": sdp})
+	return string(data)
+}
+
+// invalidSDP are offers and answers validateSDP must reject
+var invalidSDP = map[string]string{
+	"not json":       "v=0",
+	"empty":          sdpWith(""),
+	"garbage":        sdpWith("hello world"),
+	"no m= line":     sdpWith("v=0\r\no=- 4611731400430051336 2 IN IP4 127.0.0.1\r\ns=-\r\nt=0 0\r\n"),
+	"no o= line":     sdpWith("v=0\r\ns=-\r\nt=0 0\r\nm=audio 9 UDP/TLS/RTP/SAVPF 111\r\n"),
+	"truncated o=":   sdpWith("v=0\r\no=- 4611731400430051336 2\r\ns=-\r\nt=0 0\r\nm=audio 9 UDP/TLS/RTP/SAVPF 111\r\n"),
+	"bad version":    sdpWith(strings.Replace(testSDP, "v=0", "v=x", 1)),
+	"bad media port": sdpWith(strings.Replace(testSDP, "m=audio 9", "m=audio port", 1)),
+	"long line":      sdpWith(testSDP + "a=fingerprint:sha-256 " + strings.Repeat("AB:", 400) + "\r\n"),
+}
+
+func TestValidateSDP(t *testing.T) {
+	if err := validateSDP(sdpData("offer")); err != nil {
+		t.Fatalf("valid sdp rejected: %v", err)
+	}
+	for name, data := range invalidSDP {
+		if err := validateSDP(data); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
+
+func TestInvalidSDPNotStoredOrRelayed(t *testing.T) {
+	ts := NewTestServer(t)
+	caller, callee := ts.Connect(), ts.Connect()
+
+	for name, data := range invalidSDP {
+		ts.Send(caller, Message{Type: "offer", CallID: "sdp-rejected", Data: data})
+		ts.AssertError(caller, "invalid_sdp")
+		if room, unlock := rlockRoom("sdp-rejected"); room != nil {
+			unlock()
+			t.Fatalf("%s: offer created a room", name)
+		}
+	}
+
+	ts.startCall(caller, callee, "sdp-call")
+	ts.Send(caller, Message{Type: "offer", CallID: "sdp-call", Data: invalidSDP["no m= line"]})
+	ts.AssertError(caller, "invalid_sdp")
+	ts.Send(callee, Message{Type: "answer", CallID: "sdp-call", Data: invalidSDP["long line"]})
+	ts.AssertError(callee, "invalid_sdp")
+	ts.RequireNoMessageOfType(caller, "answer", 100*time.Millisecond)
+	ts.RequireNoMessageOfType(callee, "offer", 50*time.Millisecond)
+
+	room, unlock := rlockRoom("sdp-call")
+	stored := room.offer.Data
+	unlock()
+	if stored != sdpData("offer") {
+		t.Fatalf("room offer replaced by rejected sdp: %s", stored)
+	}
+}
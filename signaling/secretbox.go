@@ -0,0 +1,87 @@
+package signaling
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"golang.org/x/crypto/nacl/box"
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+// appLayerEncryption makes every connection negotiate a key and secretbox-encrypt its messages, for clients that cannot use TLS
+var appLayerEncryption = envString("ENABLE_APP_LAYER_ENCRYPTION", "false") == "true"
+
+// keyExchangeTimeout bounds how long a client has to answer the server's public key
+const keyExchangeTimeout = 10 * time.Second
+
+var errSecretboxOpen = errors.New("secretbox: message authentication failed")
+
+// sealedFrame is the JSON wrapper around an encrypted message
+type sealedFrame struct {
+	Box string `json:"box"` // base64 of the 24-byte nonce followed by the secretbox
+}
+
+// exchangeKeys sends the server's X25519 public key as a key_exchange message, reads the client's, and returns the shared key
+func exchangeKeys(conn *websocket.Conn) (*[32]byte, error) {
+	publicKey, privateKey, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.WriteJSON(Message{
+		Type:      "key_exchange",
+		PublicKey: base64.StdEncoding.EncodeToString(publicKey[:]),
+	}); err != nil {
+		return nil, err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(keyExchangeTimeout))
+	var reply Message
+	if err := conn.ReadJSON(&reply); err != nil {
+		return nil, err
+	}
+	peerKey, err := base64.StdEncoding.DecodeString(reply.PublicKey)
+	if reply.Type != "key_exchange" || err != nil || len(peerKey) != 32 {
+		return nil, errors.New("invalid key_exchange reply")
+	}
+
+	var peer, shared [32]byte
+	copy(peer[:], peerKey)
+	box.Precompute(&shared, &peer, privateKey)
+	return &shared, nil
+}
+
+// sealFrame encrypts data under key as a sealedFrame
+func sealFrame(key *[32]byte, data []byte) ([]byte, error) {
+	var nonce [24]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, err
+	}
+	sealed := secretbox.Seal(nonce[:], data, &nonce, key)
+	return json.Marshal(sealedFrame{Box: base64.StdEncoding.EncodeToString(sealed)})
+}
+
+// openFrame decrypts a sealedFrame produced under key
+func openFrame(key *[32]byte, frame []byte) ([]byte, error) {
+	var wrapper sealedFrame
+	if err := json.Unmarshal(frame, &wrapper); err != nil {
+		return nil, err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(wrapper.Box)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < 24 {
+		return nil, errSecretboxOpen
+	}
+	var nonce [24]byte
+	copy(nonce[:], sealed[:24])
+	data, ok := secretbox.Open(nil, sealed[24:], &nonce, key)
+	if !ok {
+		return nil, errSecretboxOpen
+	}
+	return data, nil
+}
@@ -0,0 +1,235 @@
+package signaling
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Errors returned by Server
+var (
+	ErrRoomNotFound = errors.New("room not found")
+	ErrRoomFull     = errors.New("room full")
+)
+
+// SignalingServer is the room API for Go programs that embed the signaling server instead of running it as a process
+type SignalingServer interface {
+	// AddClientToRoom registers an already upgraded connection as a client, puts it in the room and serves its messages
+	AddClientToRoom(callID string, conn *websocket.Conn) error
+	// RemoveRoom closes a room, telling its members with room_closed
+	RemoveRoom(callID string) error
+	// GetRoom describes a room
+	GetRoom(callID string) (RoomInfo, bool)
+	// BroadcastToRoom sends msg to every member of a room
+	BroadcastToRoom(callID string, msg Message) error
+	// SetEventHooks installs callbacks for room and membership changes
+	SetEventHooks(hooks EventHooks)
+}
+
+// RoomInfo describes a room to embedding programs
+type RoomInfo struct {
+	CallID    string      `json:"callId"`
+	ClientIDs []string    `json:"clientIds"`
+	HostID    string      `json:"hostId,omitempty"`
+	Options   RoomOptions `json:"options"`
+	CreatedAt time.Time   `json:"createdAt"`
+}
+
+// Server implements SignalingServer over the process-wide client and room state.
+// Every Server shares that state, so a program normally creates one and calls Start once.
+type Server struct {
+	startOnce sync.Once
+	startErr  error
+}
+
+var _ SignalingServer = (*Server)(nil)
+
+// NewServer returns a SignalingServer sharing state with the /ws handler
+func NewServer() *Server {
+	return &Server{}
+}
+
+// Start checks the configuration, opens the chat store when SQLITE_PATH is set and starts the background
+// cleanup, refresh and sweep loops; only the first call does anything
+func (s *Server) Start() error {
+	s.startOnce.Do(func() { s.startErr = start() })
+	return s.startErr
+}
+
+// start does the work of Start
+func start() error {
+	if !callIDStrategies[callIDStrategy] {
+		return fmt.Errorf("unknown CALL_ID_STRATEGY %q, use client, server_uuid, server_slug or server_numeric", callIDStrategy)
+	}
+	if sqlitePath != "" {
+		db, err := openChatStore(sqlitePath)
+		if err != nil {
+			return fmt.Errorf("opening chat store %s: %w", sqlitePath, err)
+		}
+		chatStore = db
+		go purgeExpiredChat()
+	}
+	if allowICEInjection {
+		log.Printf("ALLOW_ICE_INJECTION is set: clients can inject ICE candidates, do not use this in production")
+	}
+	go cleanupStaleResources()
+	if issuesTURNCredentials() {
+		go refreshExpiringTURNCredentials()
+	}
+	if egressLimit > 0 {
+		go resetEgressWindows()
+	}
+	if headlessService != "" {
+		go refreshPeers()
+	}
+	if introspectURL != "" {
+		go sweepIntrospectionCache()
+	}
+	return nil
+}
+
+// Close closes the chat store opened by Start
+func (s *Server) Close() error {
+	if chatStore == nil {
+		return nil
+	}
+	return chatStore.Close()
+}
+
+// Handler returns the server's HTTP routes: /ws, /ws-events, the REST and admin APIs and /metrics,
+// plus the web client from static when it is not nil, wrapped in the logging and recovery middleware
+func (s *Server) Handler(static http.FileSystem) http.Handler {
+	mux := http.NewServeMux()
+	routes(mux, static)
+	return WithRecovery(WithRequestID(WithLogging(mux)))
+}
+
+// AddClientToRoom registers conn, adds it to the room for callID, creating the room if needed, and serves it in the background; conn is closed if the room is full or a hook rejects it
+func (s *Server) AddClientToRoom(callID string, conn *websocket.Conn) error {
+	ip, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		ip = conn.RemoteAddr().String()
+	}
+	ws := newWSConn(conn, nil)
+	client, _ := registerClient(ws, ip, nil)
+
+	room, created, unlock := lockOrCreateRoom(callID)
+	if err := admitToRoom(callID, room, created, ws); err != nil {
+		unlock()
+		cleanupClient(ws)
+		if errors.Is(err, errRoomFull) {
+			return ErrRoomFull
+		}
+		return err
+	}
+	if created {
+		room.host = ws
+		room.options = RoomOptions{MaxClients: defaultMaxClients}
+	}
+	room.addClient(ws)
+	unlock()
+
+	clientsMu.Lock()
+	client.callIDs[callID] = true
+	delete(idleClients, ws)
+	clientsMu.Unlock()
+
+	announcePeerJoined(ws, callID)
+	pushLayoutHint(callID)
+	go serveClient(ws, client)
+	log.Printf("Added client %v to room %s", ws.RemoteAddr(), callID)
+	return nil
+}
+
+// RemoveRoom deletes the room for callID and sends room_closed to its members, who stay connected
+func (s *Server) RemoveRoom(callID string) error {
+	roomsMu.Lock()
+	room, ok := rooms[callID]
+	if !ok {
+		roomsMu.Unlock()
+		return ErrRoomNotFound
+	}
+	delete(rooms, callID)
+	members := make([]*wsConn, 0, len(room.clients))
+	for member := range room.clients {
+		members = append(members, member)
+	}
+	roomsMu.Unlock()
+	for _, member := range members {
+		leaveQueue(member, callID)
+	}
+
+	clientsMu.Lock()
+	for _, member := range members {
+		if client, ok := getClient(member); ok {
+			delete(client.callIDs, callID)
+			if len(client.callIDs) == 0 {
+				markIdle(member, client)
+			}
+		}
+	}
+	clientsMu.Unlock()
+
+	for _, member := range members {
+		if err := member.WriteJSON(Message{Type: "room_closed", CallID: callID}); err != nil {
+			log.Printf("Error sending room_closed to %v: %v", member.RemoteAddr(), err)
+			go cleanupClient(member)
+		}
+		reportDepartures(departure{callID: callID, clientID: clientID(member), reason: "room_closed"})
+	}
+	reportRoomDeleted(callID, room)
+	requestFeedback(callID, room)
+	log.Printf("Removed room %s with %d members", callID, len(members))
+	return nil
+}
+
+// GetRoom describes the room for callID, reporting false if there is none
+func (s *Server) GetRoom(callID string) (RoomInfo, bool) {
+	room, unlock := rlockRoom(callID)
+	if room == nil {
+		return RoomInfo{}, false
+	}
+	defer unlock()
+	info := RoomInfo{
+		CallID:    callID,
+		ClientIDs: make([]string, 0, len(room.clients)),
+		Options:   room.options,
+		CreatedAt: room.createdAt,
+	}
+	for member := range room.clients {
+		info.ClientIDs = append(info.ClientIDs, clientID(member))
+	}
+	if room.host != nil {
+		info.HostID = clientID(room.host)
+	}
+	return info, true
+}
+
+// BroadcastToRoom sends msg, with its CallID set to callID, to every member of the room and its monitors
+func (s *Server) BroadcastToRoom(callID string, msg Message) error {
+	room, unlock := rlockRoom(callID)
+	if room == nil {
+		return ErrRoomNotFound
+	}
+	members := make([]*wsConn, 0, len(room.clients))
+	for member := range room.clients {
+		members = append(members, member)
+	}
+	unlock()
+
+	msg.CallID = callID
+	for _, member := range members {
+		if err := member.WriteJSON(msg); err != nil {
+			log.Printf("Error broadcasting %s to %v: %v", msg.Type, member.RemoteAddr(), err)
+			go cleanupClient(member)
+		}
+	}
+	copyToMonitors(msg)
+	return nil
+}
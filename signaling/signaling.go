@@ -0,0 +1,1814 @@
+package signaling
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// WebSocket upgrader configuration
+var upgrader = websocket.Upgrader{
+	EnableCompression: true,
+	CheckOrigin: func(r *http.Request) bool {
+		return true // For development only
+	},
+}
+
+// upgradeHeaders are added to every WebSocket upgrade response
+var upgradeHeaders = newUpgradeHeaders()
+
+// newUpgradeHeaders reads CUSTOM_RESPONSE_HEADERS and adds nosniff, which is always sent
+func newUpgradeHeaders() http.Header {
+	headers := envHeaders("CUSTOM_RESPONSE_HEADERS")
+	headers.Set("X-Content-Type-Options", "nosniff")
+	return headers
+}
+
+// Client represents a connected WebSocket client
+type Client struct {
+	conn        *wsConn
+	id          string
+	ip          string
+	connectedAt time.Time
+	callIDs     map[string]bool // rooms the client is in
+	lobbies     map[string]bool // rooms whose lobby the client entered, guarded by clientsMu
+	idleSince   time.Time       // when the client last became idle, guarded by clientsMu
+	monitoring  map[string]bool // rooms the client observes with monitor_room
+	limits      rateLimiter
+	License     License
+	Auth        *AuthClaims // connection token claims, nil when authentication is disabled; guarded by mu once registered
+
+	mu                sync.Mutex // guards the stats below
+	pingTime          time.Time
+	pongLatency       time.Duration
+	lastPongAt        time.Time
+	highRTTCount      int
+	lastMessageAt     time.Time
+	echoDelays        *durationRing
+	e2eeEnabled       bool
+	videoEffect       string
+	backgroundID      string
+	noiseCancellation bool
+	lastTypingAt      time.Time
+	snapshot          string          // latest video_snapshot image, base64
+	blockedUsers      map[string]bool // client IDs whose calls and joins are refused
+	captionLanguage   string          // language of the client's latest caption
+	lang              string          // BCP 47 tag from register, used to translate errors
+	connectionTestAt  time.Time       // when the unanswered connection_test echo was sent
+	connectionTests   *durationRing   // round trips measured by connection_test
+	captionFilter     map[string]bool // caption languages to deliver; nil means all
+
+	feedbackPending map[string]bool // calls the client has been asked to rate
+
+	slowLink    bool // the send queue backed up; cleared after slowLinkRecovery clean writes
+	cleanWrites int  // consecutive writes since slowLink that left the queue short
+
+	Capabilities map[string]bool // optional features from the client's capabilities message; nil means all
+
+	platform   string // web, ios, android, desktop or bot, from client_info
+	appVersion string
+	sdkVersion string
+
+	nonces map[string]time.Time // nonces inside the replay window, with when each may be forgotten
+
+	turnExpiry time.Time // when the TURN credentials last issued to the client expire, zero when none were
+
+	qualityTest *qualityTest // running quality_test_start, nil when none is
+
+	sipBridge bool // registered with the sip_bridge role, receives sip_refer
+
+	assignedCallIDs map[string]string // call ID the client asked for -> ID of the room the server created instead
+}
+
+// Message represents a signaling message
+type Message struct {
+	Type    string `json:"type"`
+	CallID  string `json:"callId,omitempty"`
+	Data    string `json:"data,omitempty"`
+	Code    string `json:"code,omitempty"`
+	From    string `json:"from,omitempty"`
+	Count   int    `json:"count,omitempty"`
+	URL     string `json:"url,omitempty"`
+	Event   string `json:"event,omitempty"`
+	Text    string `json:"text,omitempty"`
+	IsFinal bool   `json:"isFinal,omitempty"`
+	Lang    string `json:"lang,omitempty"`
+	URI     string `json:"uri,omitempty"`
+
+	Language        string      `json:"language,omitempty"`
+	Languages       []string    `json:"languages,omitempty"`
+	Servers         []ICEServer `json:"servers,omitempty"`
+	SpeakerClientID string      `json:"speakerClientId,omitempty"`
+
+	ExpiresAt string `json:"expiresAt,omitempty"`
+	NewToken  string `json:"newToken,omitempty"`
+
+	ParentCallID   string   `json:"parentCallId,omitempty"`
+	BreakoutCallID string   `json:"breakoutCallId,omitempty"`
+	TargetClientID string   `json:"targetClientId,omitempty"`
+	CallIDs        []string `json:"callIds,omitempty"`
+
+	ClientID    string `json:"clientId,omitempty"`
+	To          string `json:"to,omitempty"`
+	PublicKey   string `json:"publicKey,omitempty"`
+	E2EEEnabled bool   `json:"e2eeEnabled,omitempty"`
+
+	Effect       string     `json:"effect,omitempty"`
+	BackgroundID string     `json:"backgroundId,omitempty"`
+	Enabled      *bool      `json:"enabled,omitempty"`
+	Peers        []PeerInfo `json:"peers,omitempty"`
+
+	BackgroundURL string `json:"backgroundUrl,omitempty"`
+	Channel       string `json:"channel,omitempty"`
+	Mandatory     bool   `json:"mandatory,omitempty"`
+
+	Messages []ChatMessage `json:"messages,omitempty"`
+
+	MaxClients int    `json:"maxClients,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+
+	VideoURL    string `json:"videoUrl,omitempty"`
+	MessageText string `json:"message,omitempty"`
+
+	RetentionPolicy string `json:"retentionPolicy,omitempty"`
+
+	Exists      *bool `json:"exists,omitempty"`
+	ClientCount int   `json:"clientCount,omitempty"`
+
+	Level int `json:"level,omitempty"`
+
+	X *float64 `json:"x,omitempty"`
+	Y *float64 `json:"y,omitempty"`
+	Z *float64 `json:"z,omitempty"`
+
+	SessionDurationSeconds int    `json:"sessionDurationSeconds,omitempty"`
+	Rating                 int    `json:"rating,omitempty"`
+	Comment                string `json:"comment,omitempty"`
+	Issue                  string `json:"issue,omitempty"`
+	Details                string `json:"details,omitempty"`
+	RecordedBy             string `json:"recordedBy,omitempty"`
+	Consent                *bool  `json:"consent,omitempty"`
+
+	Question string         `json:"question,omitempty"`
+	Options  []string       `json:"options,omitempty"`
+	Option   string         `json:"option,omitempty"`
+	Results  map[string]int `json:"results,omitempty"`
+
+	ImageData string `json:"imageData,omitempty"`
+
+	Clients      []DebugClient `json:"clients,omitempty"`
+	OfferPresent *bool         `json:"offer_present,omitempty"`
+	Locked       *bool         `json:"locked,omitempty"`
+
+	Emoji     string         `json:"emoji,omitempty"`
+	Reactions map[string]int `json:"reactions,omitempty"`
+
+	MonitorToken string `json:"monitorToken,omitempty"`
+
+	Layout         string `json:"layout,omitempty"`
+	PinnedClientID string `json:"pinnedClientId,omitempty"`
+
+	ParticipantCount int    `json:"participantCount,omitempty"`
+	SuggestedLayout  string `json:"suggestedLayout,omitempty"`
+
+	Payload         string `json:"payload,omitempty"`
+	ServerTimestamp string `json:"serverTimestamp,omitempty"`
+
+	Seq              int64    `json:"seq,omitempty"`
+	AckRequested     bool     `json:"ackRequested,omitempty"`
+	DeliveredTo      []string `json:"deliveredTo,omitempty"`
+	FailedTo         []string `json:"failedTo,omitempty"`
+	FromSeq          int64    `json:"fromSeq,omitempty"`
+	SentAt           string   `json:"sentAt,omitempty"`
+	ServerReceivedAt string   `json:"serverReceivedAt,omitempty"`
+	RelayedAt        string   `json:"relayedAt,omitempty"`
+
+	Lobby bool `json:"lobby,omitempty"`
+
+	Position             int `json:"position,omitempty"`
+	EstimatedWaitSeconds int `json:"estimatedWaitSeconds,omitempty"`
+
+	Direction       string `json:"direction,omitempty"`
+	BitrateEstimate int64  `json:"bitrate_estimate,omitempty"`
+
+	Supported []string `json:"supported,omitempty"`
+	Flag      string   `json:"flag,omitempty"`
+
+	Platform   string `json:"platform,omitempty"`
+	AppVersion string `json:"appVersion,omitempty"`
+	SDKVersion string `json:"sdkVersion,omitempty"`
+
+	Speaker string `json:"speaker,omitempty"`
+	StartMs int64  `json:"startMs,omitempty"`
+	EndMs   int64  `json:"endMs,omitempty"`
+
+	Title          string          `json:"title,omitempty"`
+	SharedDocument *SharedDocument `json:"sharedDocument,omitempty"`
+
+	TargetServer   string `json:"targetServer,omitempty"`
+	MigrationToken string `json:"migrationToken,omitempty"`
+
+	Nonce string `json:"nonce,omitempty"`
+
+	MessagesRelayed int64            `json:"messagesRelayed,omitempty"`
+	BytesRelayed    int64            `json:"bytesRelayed,omitempty"`
+	TopMessageTypes map[string]int64 `json:"topMessageTypes,omitempty"`
+	PeakMsgPerSec   int              `json:"peakMsgPerSec,omitempty"`
+
+	Version          string `json:"version,omitempty"`
+	BuildCommit      string `json:"buildCommit,omitempty"`
+	BuildTime        string `json:"buildTime,omitempty"`
+	ClientVersion    string `json:"clientVersion,omitempty"`
+	MinClientVersion string `json:"minClientVersion,omitempty"`
+
+	Layers []SimulcastLayer `json:"layers,omitempty"`
+	RID    string           `json:"rid,omitempty"`
+
+	Candidate     string `json:"candidate,omitempty"`
+	SDPMid        string `json:"sdpMid,omitempty"`
+	SDPMLineIndex *int   `json:"sdpMLineIndex,omitempty"`
+
+	Duration       int      `json:"duration,omitempty"`
+	Token          string   `json:"token,omitempty"`
+	RTTMs          float64  `json:"rttMs,omitempty"`
+	PacketLoss     *float64 `json:"packetLoss,omitempty"`
+	AvgRTTMs       *float64 `json:"avgRttMs,omitempty"`
+	JitterMs       *float64 `json:"jitterMs,omitempty"`
+	Recommendation string   `json:"recommendation,omitempty"`
+
+	Role       string `json:"role,omitempty"`
+	ReferTo    string `json:"referTo,omitempty"`
+	ReferredBy string `json:"referredBy,omitempty"`
+	StatusCode int    `json:"statusCode,omitempty"` // SIP response code of refer_result; code carries error codes
+
+	Tags []string `json:"tags,omitempty"`
+
+	RequestedCallID string `json:"requestedCallId,omitempty"`
+
+	DurationSeconds int    `json:"durationSeconds,omitempty"`
+	Label           string `json:"label,omitempty"`
+	EndsAt          string `json:"endsAt,omitempty"`
+}
+
+// PeerInfo describes a room member in call_joined
+type PeerInfo struct {
+	ClientID          string `json:"clientId"`
+	VideoEffect       string `json:"videoEffect,omitempty"`
+	NetworkQuality    int    `json:"networkQuality,omitempty"`
+	NoiseCancellation bool   `json:"noiseCancellation,omitempty"`
+
+	Position *Position `json:"position,omitempty"` // latest position_update, for spatial audio
+
+	SimulcastLayers []SimulcastLayer `json:"simulcastLayers,omitempty"` // latest set_simulcast_layers
+}
+
+// RoomOptions are the settings a room is created with
+type RoomOptions struct {
+	MaxClients      int         `json:"maxClients"`                // 0 means unlimited
+	RetentionPolicy string      `json:"retentionPolicy,omitempty"` // how long chat is kept, see retention.go
+	Lobby           bool        `json:"lobby,omitempty"`           // joiners wait until the host or a co-host admits them, see lobby.go
+	LobbyMedia      *LobbyMedia `json:"lobbyMedia,omitempty"`      // shown to lobby waiters, set with set_lobby_media
+}
+
+// defaultMaxClients is the room capacity used when the creator does not ask for one
+var defaultMaxClients = envInt("DEFAULT_ROOM_MAX_CLIENTS", 0)
+
+// newRoomOptions builds the options for a room created by msg
+func newRoomOptions(msg Message) RoomOptions {
+	opts := RoomOptions{MaxClients: defaultMaxClients}
+	if msg.MaxClients > 0 {
+		opts.MaxClients = msg.MaxClients
+	}
+	opts.Lobby = msg.Lobby
+	opts.RetentionPolicy = msg.RetentionPolicy
+	return opts
+}
+
+// Room represents a call session
+type Room struct {
+	mu                  sync.RWMutex // guards the fields below while roomsMu is read-locked
+	options             RoomOptions
+	parentCallID        string // room this is a breakout of, kept open while empty until the parent closes
+	clients             map[*wsConn]bool
+	joinedAt            map[*wsConn]time.Time
+	joinSeq             map[*wsConn]int64  // first sequence number relayed after each member joined
+	memberIDs           map[*wsConn]string // client ID of each member, still known after it disconnects
+	participants        map[*wsConn]bool   // everyone who has been a member, asked for feedback when the room closes
+	host                *wsConn            // member allowed to change room-wide settings
+	cohosts             map[*wsConn]bool   // members the host made co-hosts, who moderate the lobby with it
+	lobby               []*wsConn          // clients waiting to be admitted while options.Lobby is set, oldest first
+	admitted            map[*wsConn]bool   // lobby waiters the host or a co-host let in
+	monitors            map[string]*Client // passive observers by client ID, invisible to clients
+	offer               *Message
+	offerExpiresAt      time.Time
+	offerDeadline       time.Time // when a room created by incoming_call is dropped if no offer has arrived
+	createdAt           time.Time
+	LastActivity        time.Time // when a message was last relayed in the room
+	transcriptions      int
+	lastTranscriptionAt time.Time
+	layout              string
+	pinnedClientID      string
+	reactions           map[string]int  // reaction counts by emoji
+	quality             map[*wsConn]int // latest network_quality level by member
+	ParticipantCount    int             // len(clients) as last announced in layout_hint
+	ActivePoll          *Poll
+	seqNum              atomic.Int64 // sequence number of the last relayed message
+	history             *messageRing // recently relayed messages, for retransmit_request
+
+	RecordingActive bool            // a recording was announced and has not been cancelled
+	ConsentStatus   map[string]bool // recording consent by client ID
+	recordingGen    int             // bumped on every start and cancel, so stale consent timers do nothing
+
+	iceRestarts    int  // ICE restarts started by ice_failed, capped at maxICERestarts
+	restartPending bool // an ICE restart is waiting for its offer, which is relayed to the room
+
+	BackgroundSync *BackgroundSync // latest background shared with background_sync, nil until one is
+
+	positions       map[*wsConn]Position         // latest position_update by member, for spatial audio
+	simulcastLayers map[*wsConn][]SimulcastLayer // latest set_simulcast_layers by member
+
+	SharedDocument *SharedDocument // document being presented with share_document, nil when none is
+	referBridge    string          // client ID of the SIP bridge the room's pending sip_refer went to
+	stats          relayStats      // traffic relayed through stamp, for get_relay_stats
+
+	migratingTo string          // server the room is moving to; relaying stops once set
+	restoredIDs map[string]bool // old client IDs already restored here from migration tokens
+
+	Transcript []TranscriptSegment // final segments from transcribing bots, oldest first
+
+	Tags []string // set by the host with tag_room, for filtering the rooms API
+
+	TimerEndsAt time.Time // end of the countdown started with start_timer, zero when none is running
+	timerLabel  string
+	timerGen    int // bumped on every start and stop, so stale expiry timers do nothing
+}
+
+// newRoom creates an empty room
+func newRoom() *Room {
+	now := time.Now()
+	return &Room{
+		clients:      make(map[*wsConn]bool),
+		cohosts:      make(map[*wsConn]bool),
+		admitted:     make(map[*wsConn]bool),
+		joinedAt:     make(map[*wsConn]time.Time),
+		joinSeq:      make(map[*wsConn]int64),
+		memberIDs:    make(map[*wsConn]string),
+		participants: make(map[*wsConn]bool),
+		history:      newMessageRing(retransmitBuffer),
+		monitors:     make(map[string]*Client),
+		reactions:    make(map[string]int),
+		quality:      make(map[*wsConn]int),
+		positions:    make(map[*wsConn]Position),
+		createdAt:    now,
+		LastActivity: now,
+
+		simulcastLayers: make(map[*wsConn][]SimulcastLayer),
+	}
+}
+
+// errRoomFull is returned when a client tries to enter a room that has reached its member limit
+var errRoomFull = errors.New("room_full")
+
+// IsFull reports whether the room has reached its MaxClients
+func (r *Room) IsFull() bool {
+	return r.options.MaxClients > 0 && len(r.clients) >= r.options.MaxClients
+}
+
+// addClient makes conn a member of the room, remembering when it first joined
+func (r *Room) addClient(conn *wsConn) {
+	if !r.clients[conn] {
+		r.clients[conn] = true
+		r.joinedAt[conn] = time.Now()
+		r.joinSeq[conn] = r.seqNum.Load() + 1
+		r.memberIDs[conn] = clientID(conn)
+		r.participants[conn] = true
+	}
+}
+
+// removeClient drops conn from the room, handing the host role to another member if needed, and returns its client ID
+func (r *Room) removeClient(conn *wsConn) string {
+	id := r.memberIDs[conn]
+	delete(r.clients, conn)
+	delete(r.cohosts, conn)
+	delete(r.admitted, conn)
+	delete(r.joinedAt, conn)
+	delete(r.joinSeq, conn)
+	delete(r.memberIDs, conn)
+	delete(r.quality, conn)
+	delete(r.positions, conn)
+	delete(r.simulcastLayers, conn)
+	if r.host == conn {
+		r.host = nil
+		for client := range r.clients {
+			r.host = client
+			break
+		}
+	}
+	return id
+}
+
+// hasMember reports whether a client with the given ID is in the room
+func (r *Room) hasMember(id string) bool {
+	for client := range r.clients {
+		if clientID(client) == id {
+			return true
+		}
+	}
+	return false
+}
+
+// joinedMessage builds the call_joined message carrying the room's current state for joiner
+func (r *Room) joinedMessage(callID string, joiner *wsConn) Message {
+	var peers []PeerInfo
+	for member := range r.clients {
+		if member == joiner {
+			continue
+		}
+		if client, ok := getClient(member); ok {
+			peer := client.peerInfo()
+			peer.NetworkQuality = r.quality[member]
+			if pos, ok := r.positions[member]; ok {
+				peer.Position = &pos
+			}
+			peer.SimulcastLayers = r.simulcastLayers[member]
+			peers = append(peers, peer)
+		}
+	}
+	reactions := make(map[string]int, len(r.reactions))
+	for emoji, count := range r.reactions {
+		reactions[emoji] = count
+	}
+	return Message{
+		Type:           "call_joined",
+		CallID:         callID,
+		Layout:         r.layout,
+		PinnedClientID: r.pinnedClientID,
+		Peers:          peers,
+		Reactions:      reactions,
+		SharedDocument: r.SharedDocument,
+		EndsAt:         r.timerEndsAt(),
+		Label:          r.timerLabel,
+	}
+}
+
+// timerEndsAt formats the end of the running countdown for call_joined, empty when none is running; callers hold r.mu
+func (r *Room) timerEndsAt() string {
+	if r.TimerEndsAt.IsZero() {
+		return ""
+	}
+	return r.TimerEndsAt.UTC().Format(time.RFC3339)
+}
+
+// activeCalls returns the IDs of the rooms the client is in, sorted; callers hold clientsMu
+func (c *Client) activeCalls() []string {
+	callIDs := make([]string, 0, len(c.callIDs))
+	for callID := range c.callIDs {
+		callIDs = append(callIDs, callID)
+	}
+	sort.Strings(callIDs)
+	return callIDs
+}
+
+// peerInfo describes the client to the other members of its room
+func (c *Client) peerInfo() PeerInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return PeerInfo{
+		ClientID:          c.id,
+		VideoEffect:       c.videoEffect,
+		NoiseCancellation: c.noiseCancellation,
+	}
+}
+
+// offerTTL is how long a stored offer can be accepted or joined
+var offerTTL = time.Duration(envInt("OFFER_TTL_SECONDS", 300)) * time.Second
+
+// offerDeadlineAfter is how long a room created by incoming_call may wait for its offer; 0 waits forever
+var offerDeadlineAfter = time.Duration(envInt("OFFER_DEADLINE_SECONDS", 30)) * time.Second
+
+// offerMissing reports whether the room was created without an offer and none arrived in time
+func (r *Room) offerMissing(now time.Time) bool {
+	return r.offer == nil && !r.offerDeadline.IsZero() && now.After(r.offerDeadline)
+}
+
+// offerExpired reports whether the room holds an unanswered offer that is too old to use;
+// answering an offer clears offerExpiresAt, so the offer of a live call never expires
+func (r *Room) offerExpired() bool {
+	return r.offer != nil && !r.offerExpiresAt.IsZero() && time.Now().After(r.offerExpiresAt)
+}
+
+// lockRoom returns the room for callID with its lock held, or nil if it does not exist.
+// The returned func releases the room and the read lock on the rooms map.
+func lockRoom(callID string) (*Room, func()) {
+	roomsMu.RLock()
+	room, ok := rooms[callID]
+	if !ok {
+		roomsMu.RUnlock()
+		return nil, nil
+	}
+	room.mu.Lock()
+	return room, func() {
+		room.mu.Unlock()
+		roomsMu.RUnlock()
+	}
+}
+
+// rlockRoom is like lockRoom but only takes a read lock on the room
+func rlockRoom(callID string) (*Room, func()) {
+	roomsMu.RLock()
+	room, ok := rooms[callID]
+	if !ok {
+		roomsMu.RUnlock()
+		return nil, nil
+	}
+	room.mu.RLock()
+	return room, func() {
+		room.mu.RUnlock()
+		roomsMu.RUnlock()
+	}
+}
+
+// lockOrCreateRoom is like lockRoom but creates the room when it does not exist
+func lockOrCreateRoom(callID string) (room *Room, created bool, unlock func()) {
+	if room, unlock := lockRoom(callID); room != nil {
+		return room, false, unlock
+	}
+	roomsMu.Lock()
+	room, ok := rooms[callID]
+	if !ok {
+		room = newRoom()
+		rooms[callID] = room
+	}
+	room.mu.Lock()
+	return room, !ok, func() {
+		room.mu.Unlock()
+		roomsMu.Unlock()
+	}
+}
+
+// Global state
+var (
+	clients     sync.Map                 // *wsConn -> *Client
+	clientCount atomic.Int64             // number of entries in clients
+	idleClients = make(map[*wsConn]bool) //clients who are conncected but not in a call
+	rooms       = make(map[string]*Room)
+	clientsMu   sync.Mutex   // guards idleClients and Client.callIDs
+	roomsMu     sync.RWMutex // guards the rooms map, holding it exclusively also excludes all room locks
+	startTime   = time.Now()
+)
+
+// markIdle returns a client to the idle clients that incoming calls ring, ringing calls that found nobody idle
+// from another goroutine since callers hold clientsMu
+func markIdle(ws *wsConn, client *Client) {
+	idleClients[ws] = true
+	client.idleSince = time.Now()
+	go ringParkedCalls()
+}
+
+// getClient looks up the client for a connection
+func getClient(ws *wsConn) (*Client, bool) {
+	v, ok := clients.Load(ws)
+	if !ok {
+		return nil, false
+	}
+	return v.(*Client), true
+}
+
+// newClientID generates a random client identifier
+func newClientID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		log.Printf("Error generating client ID: %v", err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// clientID returns the ID of a connected client, or an empty string if it is gone
+func clientID(ws *wsConn) string {
+	if client, ok := getClient(ws); ok {
+		return client.id
+	}
+	return ""
+}
+
+// userCountDelay is how long a user_count broadcast waits so that a burst of connects and disconnects shares one
+const userCountDelay = 100 * time.Millisecond
+
+// userCountPending is set while a user_count broadcast is scheduled
+var userCountPending atomic.Bool
+
+// broadcastUserCount schedules sending the client count to all clients, unless a broadcast is already scheduled;
+// the count is read when it is sent, so it is never older than the change that asked for it
+func broadcastUserCount() {
+	if !userCountPending.CompareAndSwap(false, true) {
+		return
+	}
+	time.AfterFunc(userCountDelay, func() {
+		userCountPending.Store(false)
+		sendUserCount()
+	})
+}
+
+// sendUserCount sends the current client count to all clients
+func sendUserCount() {
+	count := int(clientCount.Load())
+	var conns []*wsConn
+	clients.Range(func(k, _ interface{}) bool {
+		conns = append(conns, k.(*wsConn))
+		return true
+	})
+
+	for _, ws := range conns {
+		if err := ws.WriteJSON(Message{
+			Type:  "user_count",
+			Count: count,
+		}); err != nil {
+			log.Printf("Error sending user_count to %v: %v", ws.RemoteAddr(), err)
+			go cleanupClient(ws)
+		}
+	}
+	log.Printf("Broadcasted user count: %d", count)
+}
+
+// handleConnections manages WebSocket connections
+func handleConnections(w http.ResponseWriter, r *http.Request) {
+	count := int(clientCount.Load())
+
+	if atCapacity(count) {
+		if peer := nextPeerServer(); peer != "" {
+			target := peerURL(peer, r, false)
+			log.Printf("At capacity (%d clients), redirecting %v to %s", count, r.RemoteAddr, target)
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusTemporaryRedirect)
+			return
+		}
+		log.Printf("At capacity (%d clients), rejecting %v", count, r.RemoteAddr)
+		http.Error(w, "Server at capacity", http.StatusServiceUnavailable)
+		return
+	}
+
+	license, err := parseLicense(r)
+	if err != nil {
+		log.Printf("Rejecting %v with invalid license token: %v", r.RemoteAddr, err)
+		http.Error(w, "Invalid license token", http.StatusUnauthorized)
+		return
+	}
+	auth, err := parseAuth(r)
+	if err != nil {
+		log.Printf("Rejecting %v with invalid token: %v", r.RemoteAddr, err)
+		http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+		return
+	}
+	if !claimSubject(auth) {
+		log.Printf("Rejecting %v: client ID %s is already connected", r.RemoteAddr, auth.Subject)
+		http.Error(w, "Client ID already connected", http.StatusConflict)
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, upgradeHeaders.Clone())
+	if err != nil {
+		log.Printf("Error upgrading connection: %v", err)
+		releaseSubject(auth)
+		return
+	}
+	var key *[32]byte
+	if appLayerEncryption {
+		if key, err = exchangeKeys(conn); err != nil {
+			log.Printf("Key exchange with %v failed: %v", conn.RemoteAddr(), err)
+			releaseSubject(auth)
+			conn.Close()
+			return
+		}
+	}
+	ws := newWSConn(conn, key)
+	ws.forwardedAddr = forwardedAddr(r)
+	client, count := registerClient(ws, remoteIP(r), auth)
+	client.License = license
+
+	if overRedirectThreshold(count) {
+		if peer := nextPeerServer(); peer != "" {
+			peer = peerURL(peer, r, true)
+			if err := ws.WriteJSON(Message{Type: "reconnect_hint", URL: peer}); err != nil {
+				log.Printf("Error sending reconnect_hint to %v: %v", ws.RemoteAddr(), err)
+			} else {
+				log.Printf("Sent reconnect_hint to %v pointing at %s", ws.RemoteAddr(), peer)
+			}
+		}
+	}
+
+	serveClient(ws, client)
+}
+
+// registerClient adds a new connection to the idle clients and returns its client and the new client count
+func registerClient(ws *wsConn, ip string, auth *AuthClaims) (*Client, int) {
+	ws.SetReadDeadline(time.Now().Add(readTimeout()))
+
+	client := &Client{
+		conn:        ws,
+		id:          newClientID(),
+		ip:          ip,
+		connectedAt: time.Now(),
+		lobbies:     make(map[string]bool),
+		callIDs:     make(map[string]bool),
+		monitoring:  make(map[string]bool),
+		Auth:        auth,
+	}
+	if auth != nil {
+		client.id = auth.Subject
+	}
+	client.feedbackPending = make(map[string]bool)
+	client.nonces = make(map[string]time.Time)
+	client.assignedCallIDs = make(map[string]string)
+	client.blockedUsers = make(map[string]bool)
+	client.lastMessageAt = client.connectedAt
+	client.echoDelays = newDurationRing(100)
+	client.connectionTests = newDurationRing(connectionTestSamples)
+	ws.SetPongHandler(func(string) error {
+		client.recordPong()
+		ws.SetReadDeadline(time.Now().Add(readTimeout()))
+		return nil
+	})
+	clients.Store(ws, client)
+	count := int(clientCount.Add(1))
+	clientsMu.Lock()
+	markIdle(ws, client)
+	log.Printf("New client %v connected, total: %d, idle: %d", ws.RemoteAddr(), count, len(idleClients))
+	clientsMu.Unlock()
+
+	sendServerVersion(ws)
+	sendCapabilities(ws)
+	broadcastUserCount()
+	return client, count
+}
+
+// serveClient reads and dispatches a client's messages until it disconnects, then cleans it up
+func serveClient(ws *wsConn, client *Client) {
+	defer cleanupClient(ws)
+
+	for {
+		var msg Message
+		if err := ws.ReadJSON(&msg); err != nil {
+			if websocket.IsCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure, websocket.CloseNoStatusReceived) {
+				log.Printf("Client %v disconnected: %v", ws.RemoteAddr(), err)
+			} else {
+				log.Printf("WebSocket read error for %v: %v", ws.RemoteAddr(), err)
+			}
+			break
+		}
+
+		ws.SetReadDeadline(time.Now().Add(readTimeout()))
+		client.recordMessage()
+		dispatchMessage(ws, msg)
+	}
+}
+
+// dispatchMessage routes a client's message to the handler for its type
+func dispatchMessage(ws *wsConn, msg Message) {
+	client, ok := getClient(ws)
+	if !ok {
+		return
+	}
+	if ok, code := client.checkNonce(msg.Nonce, msg.SentAt); !ok {
+		sendError(ws, code)
+		return
+	}
+	if feature, ok := featureMessages[msg.Type]; ok && !featureEnabled(feature) {
+		sendError(ws, "feature_disabled")
+		return
+	}
+	switch msg.Type {
+	case "offer":
+		handleOffer(ws, msg)
+	case "incoming_call":
+		handleIncomingCall(ws, msg)
+	case "accept_call":
+		handleAcceptCall(ws, msg)
+	case "answer":
+		handleAnswer(ws, msg)
+	case "ice-candidate":
+		handleICECandidate(ws, msg)
+	case "join_call":
+		handleJoinCall(ws, msg)
+	case "hangup":
+		handleHangup(ws, msg.CallID)
+	case "custom_event":
+		handleCustomEvent(ws, msg)
+	case "transcription":
+		handleTranscription(ws, msg)
+	case "transfer_external":
+		handleTransferExternal(ws, msg)
+	case "monitor_room":
+		handleMonitorRoom(ws, msg)
+	case "set_layout":
+		handleSetLayout(ws, msg)
+	case "echo":
+		handleEcho(ws, msg)
+	case "get_invite_link":
+		handleGetInviteLink(ws, msg)
+	case "e2ee_key":
+		handleE2EEKey(ws, msg)
+	case "set_video_effect":
+		handleSetVideoEffect(ws, msg)
+	case "relay":
+		handleRelay(ws, msg)
+	case "reaction":
+		handleReaction(ws, msg)
+	case "lobby_message":
+		handleLobbyMessage(ws, msg)
+	case "admit_from_lobby":
+		handleAdmitFromLobby(ws, msg)
+	case "add_cohost":
+		handleAddCohost(ws, msg)
+	case "network_quality":
+		handleNetworkQuality(ws, msg)
+	case "create_poll":
+		handleCreatePoll(ws, msg)
+	case "vote":
+		handleVote(ws, msg)
+	case "close_poll":
+		handleClosePoll(ws, msg)
+	case "leave_room":
+		handleLeaveRoom(ws, msg)
+	case "typing":
+		handleTyping(ws, msg)
+	case "room_exists":
+		handleRoomExists(ws, msg)
+	case "video_snapshot":
+		handleVideoSnapshot(ws, msg)
+	case "debug_room_dump":
+		handleDebugRoomDump(ws, msg)
+	case "feedback":
+		handleFeedback(ws, msg)
+	case "retransmit_request":
+		handleRetransmitRequest(ws, msg)
+	case "set_noise_cancellation":
+		handleSetNoiseCancellation(ws, msg)
+	case "noise_cancellation_failed":
+		handleNoiseCancellationFailed(ws, msg)
+	case "block_user":
+		handleBlockUser(ws, msg)
+	case "unblock_user":
+		handleUnblockUser(ws, msg)
+	case "caption":
+		handleCaption(ws, msg)
+	case "caption_subscription":
+		handleCaptionSubscription(ws, msg)
+	case "refresh_ice_servers":
+		handleRefreshICEServers(ws, msg)
+	case "create_breakout_rooms":
+		handleCreateBreakoutRooms(ws, msg)
+	case "assign_to_breakout":
+		handleAssignToBreakout(ws, msg)
+	case "register":
+		handleRegister(ws, msg)
+	case "connection_test":
+		handleConnectionTest(ws, msg)
+	case "connection_test_result":
+		handleConnectionTestResult(ws, msg)
+	case "message_lobby":
+		handleMessageLobby(ws, msg)
+	case "report_issue":
+		handleReportIssue(ws, msg)
+	case "recording_started":
+		handleRecordingStarted(ws, msg)
+	case "recording_consent":
+		handleRecordingConsent(ws, msg)
+	case "peer_ping", "peer_pong":
+		handlePeerPing(ws, msg)
+	case "capabilities":
+		handleCapabilities(ws, msg)
+	case "ice_failed":
+		handleICEFailed(ws, msg)
+	case "refresh_token":
+		handleRefreshToken(ws, msg)
+	case "background_sync":
+		handleBackgroundSync(ws, msg)
+	case "decline_call":
+		handleDeclineCall(ws, msg)
+	case "data_relay":
+		handleDataRelay(ws, msg)
+	case "position_update":
+		handlePositionUpdate(ws, msg)
+	case "share_document":
+		handleShareDocument(ws, msg)
+	case "stop_document_share":
+		handleStopDocumentShare(ws, msg)
+	case "get_relay_stats":
+		handleGetRelayStats(ws, msg)
+	case "reset_stats":
+		handleResetStats(ws, msg)
+	case "restore_room":
+		handleRestoreRoom(ws, msg)
+	case "client_info":
+		handleClientInfo(ws, msg)
+	case "transcript_segment":
+		handleTranscriptSegment(ws, msg)
+	case "set_simulcast_layers":
+		handleSetSimulcastLayers(ws, msg)
+	case "request_layer":
+		handleRequestLayer(ws, msg)
+	case "inject_ice_candidate":
+		handleInjectICECandidate(ws, msg)
+	case "quality_test_start":
+		handleQualityTestStart(ws, msg)
+	case "quality_test_echo":
+		handleQualityTestEcho(ws, msg)
+	case "set_lobby_media":
+		handleSetLobbyMedia(ws, msg)
+	case "set_lobby":
+		handleSetLobby(ws, msg)
+	case "sip_refer":
+		handleSIPRefer(ws, msg)
+	case "refer_result":
+		handleReferResult(ws, msg)
+	case "tag_room":
+		handleTagRoom(ws, msg)
+	case "start_timer":
+		handleStartTimer(ws, msg)
+	case "stop_timer":
+		handleStopTimer(ws, msg)
+	default:
+		log.Printf("Unknown message type from %v: %s", ws.RemoteAddr(), msg.Type)
+	}
+}
+
+// cleanupClient removes a client from all state
+func cleanupClient(ws *wsConn) {
+	v, exists := clients.LoadAndDelete(ws)
+	if !exists {
+		log.Printf("Cleanup skipped for %v: not in clients", ws.RemoteAddr())
+		return
+	}
+	client := v.(*Client)
+	releaseSubject(client.claims())
+	remaining := clientCount.Add(-1)
+	pingRTT.DeleteLabelValues(client.id)
+	clientsMu.Lock()
+	callIDs := client.activeCalls()
+	var lobbies []string
+	for callID := range client.lobbies {
+		lobbies = append(lobbies, callID)
+	}
+	observed := append([]string(nil), callIDs...)
+	for callID := range client.monitoring {
+		if !client.callIDs[callID] {
+			observed = append(observed, callID)
+		}
+	}
+	delete(idleClients, ws)
+	log.Printf("Removed client %v, remaining: %d, idle: %d", ws.RemoteAddr(), remaining, len(idleClients))
+	clientsMu.Unlock()
+
+	leaveQueue(ws, "")
+	leaveLobbies(ws, lobbies)
+	for _, callID := range callIDs {
+		leaveRoom(ws, callID, "disconnected")
+	}
+	removeFromRooms(ws, observed)
+
+	if err := ws.Close(); err != nil && !websocket.IsCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+		log.Printf("Error closing WebSocket %v: %v", ws.RemoteAddr(), err)
+	}
+
+	broadcastUserCount()
+}
+
+// removeFromRooms removes a client as a member or monitor of the rooms with the given call IDs
+func removeFromRooms(conn *wsConn, callIDs []string) {
+	notify := make(map[string][]*wsConn)
+	closed := make(map[string]*Room)
+	var departed []departure
+	roomsMu.Lock()
+	for _, callID := range callIDs {
+		room, ok := rooms[callID]
+		if !ok {
+			continue
+		}
+		for id, monitor := range room.monitors {
+			if monitor.conn == conn {
+				delete(room.monitors, id)
+			}
+		}
+		if !room.clients[conn] {
+			continue
+		}
+		departed = append(departed, departure{callID: callID, clientID: room.removeClient(conn), reason: "disconnected"})
+		if len(room.clients) == 0 {
+			delete(rooms, callID)
+			closed[callID] = room
+			log.Printf("Deleted empty room %s, remaining: %d", callID, len(rooms))
+			continue
+		}
+		for client := range room.clients {
+			notify[callID] = append(notify[callID], client)
+		}
+	}
+	remaining := len(rooms)
+	roomsMu.Unlock()
+
+	reportDepartures(departed...)
+	for callID, room := range closed {
+		reportRoomDeleted(callID, room)
+		requestFeedback(callID, room)
+	}
+	for callID, members := range notify {
+		for _, client := range members {
+			if err := client.WriteJSON(Message{
+				Type:   "peer_disconnected",
+				CallID: callID,
+			}); err != nil {
+				log.Printf("Error sending peer_disconnected to %v in room %s: %v", client.RemoteAddr(), callID, err)
+				go cleanupClient(client)
+			}
+		}
+	}
+	for callID := range notify {
+		pushLayoutHint(callID)
+	}
+	log.Printf("Removed %v from %d rooms, remaining: %d", conn.RemoteAddr(), len(callIDs), remaining)
+}
+
+// handleOffer processes offer messages
+func handleOffer(sender *wsConn, msg Message) {
+	if err := validateSDP(msg.Data); err != nil {
+		log.Printf("Rejected %s for call %s from %v: %v", msg.Type, msg.CallID, sender.RemoteAddr(), err)
+		sendError(sender, "invalid_sdp")
+		return
+	}
+	if !validRetentionPolicy(msg.RetentionPolicy) {
+		sendError(sender, "invalid_retention_policy")
+		return
+	}
+	requested := msg.CallID
+	room, callID, created, assigned, unlock, err := lockCallRoom(sender, requested)
+	if err != nil {
+		log.Printf("No room for offer from %v: %v", sender.RemoteAddr(), err)
+		sendError(sender, err.Error())
+		return
+	}
+	msg.CallID = callID
+	if err := admitToRoom(msg.CallID, room, created, sender); err != nil {
+		unlock()
+		log.Printf("Hook rejected %s for call %s from %v: %v", msg.Type, msg.CallID, sender.RemoteAddr(), err)
+		sendError(sender, err.Error())
+		return
+	}
+	if created {
+		room.host = sender
+		room.options = newRoomOptions(msg)
+	}
+	room.offer = &msg
+	room.offerExpiresAt = time.Now().Add(offerTTL)
+	room.offerDeadline = time.Time{}
+	room.LastActivity = time.Now()
+	room.addClient(sender)
+	restarting := room.restartPending
+	room.restartPending = false
+	unlock()
+	if restarting {
+		relayToRoom(sender, msg)
+	}
+	if created {
+		log.Printf("Created room %s", msg.CallID)
+	}
+	if assigned {
+		sendRoomAssigned(sender, msg.CallID, requested)
+	}
+	copyToMonitors(msg)
+	pushLayoutHint(msg.CallID)
+
+	clientsMu.Lock()
+	if client, ok := getClient(sender); ok {
+		client.callIDs[msg.CallID] = true
+		delete(idleClients, sender)
+	}
+	log.Printf("Client %v set callID %s, idle: %d", sender.RemoteAddr(), msg.CallID, len(idleClients))
+	clientsMu.Unlock()
+}
+
+// handleAcceptCall processes call acceptance
+func handleAcceptCall(conn *wsConn, msg Message) {
+	room, unlock := lockRoom(msg.CallID)
+	exists := room != nil
+	var offer *Message
+	var joined Message
+	rejected := ""
+	if exists {
+		switch {
+		case room.offerExpired():
+			rejected = "offer_expired"
+		default:
+			if err := admitToRoom(msg.CallID, room, false, conn); err != nil {
+				rejected = err.Error()
+				break
+			}
+			offer = room.offer
+			room.addClient(conn)
+			joined = room.joinedMessage(msg.CallID, conn)
+		}
+		unlock()
+	}
+
+	if rejected == errBlocked.Error() {
+		sendJoinRejected(conn, msg.CallID)
+		return
+	}
+	if rejected == errInLobby.Error() {
+		enterLobby(conn, msg.CallID)
+		return
+	}
+	if rejected != "" {
+		log.Printf("Client %v could not accept call %s: %s", conn.RemoteAddr(), msg.CallID, rejected)
+		sendError(conn, rejected)
+		return
+	}
+	if !exists || offer == nil {
+		sendError(conn, "Call not found")
+		return
+	}
+
+	clientsMu.Lock()
+	if client, ok := getClient(conn); ok {
+		client.callIDs[msg.CallID] = true
+		delete(idleClients, conn)
+	}
+	clientsMu.Unlock()
+
+	if err := conn.WriteJSON(*offer); err != nil {
+		log.Printf("Error sending offer to %v: %v", conn.RemoteAddr(), err)
+		go cleanupClient(conn)
+		return
+	}
+	if err := conn.WriteJSON(joined); err != nil {
+		log.Printf("Error sending call_joined to %v: %v", conn.RemoteAddr(), err)
+		go cleanupClient(conn)
+		return
+	}
+
+	for _, other := range rungClients(msg.CallID) {
+		if other != conn {
+			if err := other.WriteJSON(Message{
+				Type:   "call_taken",
+				CallID: msg.CallID,
+			}); err != nil {
+				log.Printf("Error sending call_taken to %v: %v", other.RemoteAddr(), err)
+				go cleanupClient(other)
+			}
+		}
+	}
+	admitCall(msg.CallID)
+	sendSnapshots(conn, msg.CallID)
+	announcePeerJoined(conn, msg.CallID)
+	pushLayoutHint(msg.CallID)
+	log.Printf("Client %v accepted call %s", conn.RemoteAddr(), msg.CallID)
+}
+
+// handleAnswer processes answer messages
+func handleAnswer(sender *wsConn, msg Message) {
+	if err := validateSDP(msg.Data); err != nil {
+		log.Printf("Rejected %s for call %s from %v: %v", msg.Type, msg.CallID, sender.RemoteAddr(), err)
+		sendError(sender, "invalid_sdp")
+		return
+	}
+	ackRequested := msg.AckRequested
+	room, unlock := lockRoom(msg.CallID)
+	exists := room != nil
+	var roomClients map[*wsConn]bool
+	if exists && room.migratingTo != "" {
+		unlock()
+		log.Printf("Dropped answer for call %s from %v: room is migrating", msg.CallID, sender.RemoteAddr())
+		return
+	}
+	if exists {
+		if err := admitToRoom(msg.CallID, room, false, sender); err != nil {
+			unlock()
+			log.Printf("Hook rejected %s for call %s from %v: %v", msg.Type, msg.CallID, sender.RemoteAddr(), err)
+			sendError(sender, err.Error())
+			return
+		}
+		room.addClient(sender)
+		room.offerExpiresAt = time.Time{}
+		msg.AckRequested = false
+		msg = room.stamp(msg)
+		roomClients = make(map[*wsConn]bool)
+		for k, v := range room.clients {
+			roomClients[k] = v
+		}
+		unlock()
+	}
+
+	if !exists {
+		log.Printf("No room for answer call %s from %v", msg.CallID, sender.RemoteAddr())
+		return
+	}
+
+	clientsMu.Lock()
+	if client, ok := getClient(sender); ok {
+		client.callIDs[msg.CallID] = true
+		delete(idleClients, sender)
+	}
+	clientsMu.Unlock()
+
+	var receipt deliveryReceipt
+	for client := range roomClients {
+		if client != sender {
+			err := client.WriteJSON(msg)
+			receipt.record(client, err)
+			if err != nil {
+				log.Printf("Error sending answer to %v: %v", client.RemoteAddr(), err)
+				go cleanupClient(client)
+			}
+		}
+	}
+	if ackRequested {
+		receipt.send(sender, msg.CallID, msg.Seq)
+	}
+	copyToMonitors(msg)
+	pushLayoutHint(msg.CallID)
+}
+
+// handleICECandidate processes ICE candidate messages
+func handleICECandidate(sender *wsConn, msg Message) {
+	ackRequested := msg.AckRequested
+	msg.AckRequested = false
+	room, unlock := lockRoom(msg.CallID)
+	exists := room != nil
+	var roomClients map[*wsConn]bool
+	if exists && room.migratingTo != "" {
+		unlock()
+		log.Printf("Dropped ICE candidate for call %s from %v: room is migrating", msg.CallID, sender.RemoteAddr())
+		return
+	}
+	if exists {
+		msg = room.stamp(msg)
+		roomClients = make(map[*wsConn]bool)
+		for k, v := range room.clients {
+			roomClients[k] = v
+		}
+		unlock()
+	}
+
+	if !exists {
+		log.Printf("No room for ICE candidate call %s from %v", msg.CallID, sender.RemoteAddr())
+		return
+	}
+
+	var receipt deliveryReceipt
+	for client := range roomClients {
+		if client != sender {
+			err := client.WriteJSON(msg)
+			receipt.record(client, err)
+			if err != nil {
+				log.Printf("Error sending ICE candidate to %v: %v", client.RemoteAddr(), err)
+				go cleanupClient(client)
+			}
+		}
+	}
+	if ackRequested {
+		receipt.send(sender, msg.CallID, msg.Seq)
+	}
+	copyToMonitors(msg)
+}
+
+// findRoomMember returns the member of the requester's room with the given client ID
+func findRoomMember(callID string, requester *wsConn, id string) *wsConn {
+	members, ok := roomMembers(callID, requester)
+	if !ok {
+		return nil
+	}
+	for _, member := range members {
+		if member != requester && clientID(member) == id {
+			return member
+		}
+	}
+	return nil
+}
+
+// announcePeerJoined tells the rest of the room that a client has joined
+func announcePeerJoined(conn *wsConn, callID string) {
+	client, ok := getClient(conn)
+	if !ok {
+		return
+	}
+	client.mu.Lock()
+	e2ee := client.e2eeEnabled
+	client.mu.Unlock()
+
+	relayToRoom(conn, Message{
+		Type:        "peer_joined",
+		CallID:      callID,
+		ClientID:    client.id,
+		E2EEEnabled: e2ee,
+	})
+}
+
+// sendError sends an error to a client, with code in Code and translated into the client's language in Data
+func sendError(ws *wsConn, code string) {
+	lang := ""
+	if client, ok := getClient(ws); ok {
+		lang = client.language()
+	}
+	if err := ws.WriteJSON(Message{Type: "error", Code: code, Data: translate(lang, code)}); err != nil {
+		log.Printf("Error sending error to %v: %v", ws.RemoteAddr(), err)
+		go cleanupClient(ws)
+	}
+}
+
+// roomMembers returns the clients of a room, reporting false unless member is one of them
+func roomMembers(callID string, member *wsConn) ([]*wsConn, bool) {
+	room, unlock := rlockRoom(callID)
+	if room == nil {
+		return nil, false
+	}
+	defer unlock()
+	if !room.clients[member] {
+		return nil, false
+	}
+	members := make([]*wsConn, 0, len(room.clients))
+	for client := range room.clients {
+		members = append(members, client)
+	}
+	return members, true
+}
+
+// relayToRoom forwards msg to every other member of the sender's room, reporting whether the sender is in it
+func relayToRoom(sender *wsConn, msg Message) bool {
+	return sendToRoom(sender, msg, false)
+}
+
+// broadcastToRoom sends msg to every member of the sender's room including the sender
+func broadcastToRoom(sender *wsConn, msg Message) bool {
+	return sendToRoom(sender, msg, true)
+}
+
+// sendToRoom delivers msg to the members of the sender's room, sending a message_ack back when msg.AckRequested is set
+func sendToRoom(sender *wsConn, msg Message, includeSender bool) bool {
+	room, unlock := lockRoom(msg.CallID)
+	if room == nil || !room.clients[sender] {
+		if room != nil {
+			unlock()
+		}
+		log.Printf("Dropped %s for call %s from %v: not in room", msg.Type, msg.CallID, sender.RemoteAddr())
+		return false
+	}
+	if room.migratingTo != "" {
+		unlock()
+		log.Printf("Dropped %s for call %s from %v: room is migrating", msg.Type, msg.CallID, sender.RemoteAddr())
+		return false
+	}
+	ackRequested := msg.AckRequested
+	msg.AckRequested = false
+	msg = room.stamp(msg)
+	members := make([]*wsConn, 0, len(room.clients))
+	for client := range room.clients {
+		members = append(members, client)
+	}
+	unlock()
+
+	var receipt deliveryReceipt
+	for _, client := range members {
+		if client != sender || includeSender {
+			err := client.WriteJSON(msg)
+			receipt.record(client, err)
+			if err != nil {
+				log.Printf("Error relaying %s to %v: %v", msg.Type, client.RemoteAddr(), err)
+				go cleanupClient(client)
+			}
+		}
+	}
+	if ackRequested {
+		receipt.send(sender, msg.CallID, msg.Seq)
+	}
+	copyToMonitors(msg)
+	return true
+}
+
+// handleCustomEvent relays application-specific events to the room without interpreting them
+func handleCustomEvent(sender *wsConn, msg Message) {
+	if msg.Event == "" || len(msg.Event) > 64 {
+		sendError(sender, "invalid_event")
+		return
+	}
+	if !allowMessage(sender, "custom_event", 5, time.Second) {
+		return
+	}
+	relayToRoom(sender, Message{
+		Type:   "custom_event",
+		CallID: msg.CallID,
+		Event:  msg.Event,
+		Data:   msg.Data,
+	})
+}
+
+// handleTranscription relays live caption text to the room
+func handleTranscription(sender *wsConn, msg Message) {
+	if msg.Text == "" || utf8.RuneCountInString(msg.Text) > 500 {
+		sendError(sender, "invalid_transcription")
+		return
+	}
+	if !allowMessage(sender, "transcription", 4, time.Second) {
+		return
+	}
+	relayed := relayToRoom(sender, Message{
+		Type:    "transcription",
+		CallID:  msg.CallID,
+		From:    clientID(sender),
+		Text:    msg.Text,
+		IsFinal: msg.IsFinal,
+	})
+	if !relayed {
+		return
+	}
+
+	if room, unlock := lockRoom(msg.CallID); room != nil {
+		room.transcriptions++
+		room.lastTranscriptionAt = time.Now()
+		unlock()
+	}
+}
+
+// handleJoinCall processes join call requests
+func handleJoinCall(sender *wsConn, msg Message) {
+	room, unlock := lockRoom(msg.CallID)
+	exists := room != nil
+	var offer *Message
+	var joined Message
+	rejected := ""
+	if exists {
+		switch {
+		case room.offerExpired():
+			rejected = "offer_expired"
+		default:
+			if err := admitToRoom(msg.CallID, room, false, sender); err != nil {
+				rejected = err.Error()
+				break
+			}
+			offer = room.offer
+			room.addClient(sender)
+			joined = room.joinedMessage(msg.CallID, sender)
+		}
+		unlock()
+	}
+
+	if rejected == errBlocked.Error() {
+		sendJoinRejected(sender, msg.CallID)
+		return
+	}
+	if rejected == errInLobby.Error() {
+		enterLobby(sender, msg.CallID)
+		return
+	}
+	if rejected != "" {
+		log.Printf("Client %v could not join call %s: %s", sender.RemoteAddr(), msg.CallID, rejected)
+		sendError(sender, rejected)
+		return
+	}
+	if !exists {
+		sendError(sender, "Call not found")
+		return
+	}
+
+	clientsMu.Lock()
+	if client, ok := getClient(sender); ok {
+		client.callIDs[msg.CallID] = true
+		delete(idleClients, sender)
+	}
+	clientsMu.Unlock()
+
+	if offer != nil {
+		if err := sender.WriteJSON(*offer); err != nil {
+			log.Printf("Error sending offer to %v: %v", sender.RemoteAddr(), err)
+			go cleanupClient(sender)
+			return
+		}
+	}
+	if err := sender.WriteJSON(joined); err != nil {
+		log.Printf("Error sending call_joined to %v: %v", sender.RemoteAddr(), err)
+		go cleanupClient(sender)
+		return
+	}
+	sendChatHistory(sender, msg.CallID)
+	sendSnapshots(sender, msg.CallID)
+	announcePeerJoined(sender, msg.CallID)
+	pushLayoutHint(msg.CallID)
+}
+
+// handleHangup processes hangup requests, which must name the call since a client can be in several
+func handleHangup(sender *wsConn, callID string) {
+	if callID == "" {
+		sendError(sender, "missing_call_id")
+		return
+	}
+	leaveQueue(sender, callID)
+	leaveLobbies(sender, []string{callID})
+	if !leaveRoom(sender, callID, "hangup") {
+		log.Printf("Hangup for non-existent call %s from %v", callID, sender.RemoteAddr())
+	}
+}
+
+// handleLeaveRoom takes a member out of a room while keeping its connection open for another call
+func handleLeaveRoom(sender *wsConn, msg Message) {
+	if _, ok := roomMembers(msg.CallID, sender); !ok {
+		sendError(sender, "not_in_call")
+		return
+	}
+	leaveQueue(sender, msg.CallID)
+	leaveRoom(sender, msg.CallID, "left")
+	log.Printf("Client %v left room %s", sender.RemoteAddr(), msg.CallID)
+}
+
+// leaveRoom removes sender from a room for reason, tells the remaining members and marks sender idle, reporting false if the room does not exist
+func leaveRoom(sender *wsConn, callID, reason string) bool {
+	roomsMu.Lock()
+	room, exists := rooms[callID]
+	var roomClients map[*wsConn]bool
+	var departed []departure
+	closed := false
+	if exists {
+		if room.clients[sender] {
+			departed = append(departed, departure{callID: callID, clientID: room.removeClient(sender), reason: reason})
+		}
+		roomClients = make(map[*wsConn]bool)
+		for k, v := range room.clients {
+			roomClients[k] = v
+		}
+		if len(room.clients) == 0 {
+			delete(rooms, callID)
+			closed = true
+			log.Printf("Deleted empty room %s, remaining: %d", callID, len(rooms))
+		}
+	}
+	roomsMu.Unlock()
+
+	if !exists {
+		return false
+	}
+	reportDepartures(departed...)
+	if closed {
+		reportRoomDeleted(callID, room)
+		requestFeedback(callID, room)
+	}
+
+	for client := range roomClients {
+		if err := client.WriteJSON(Message{
+			Type:   "peer_disconnected",
+			CallID: callID,
+		}); err != nil {
+			log.Printf("Error sending peer_disconnected to %v: %v", client.RemoteAddr(), err)
+			go cleanupClient(client)
+		}
+	}
+	pushLayoutHint(callID)
+
+	clientsMu.Lock()
+	if client, ok := getClient(sender); ok {
+		delete(client.callIDs, callID)
+		if len(client.callIDs) == 0 {
+			markIdle(sender, client)
+			log.Printf("Client %v set to idle, idle: %d", sender.RemoteAddr(), len(idleClients))
+		}
+	}
+	clientsMu.Unlock()
+	return true
+}
+
+// handleIncomingCall processes incoming call notifications
+func handleIncomingCall(sender *wsConn, msg Message) {
+	if !validRetentionPolicy(msg.RetentionPolicy) {
+		sendError(sender, "invalid_retention_policy")
+		return
+	}
+
+	room, callID, created, assigned, unlock, err := lockCallRoom(sender, msg.CallID)
+	if err != nil {
+		log.Printf("No room for incoming call from %v: %v", sender.RemoteAddr(), err)
+		sendError(sender, err.Error())
+		return
+	}
+	if err := admitToRoom(callID, room, created, sender); err != nil {
+		unlock()
+		log.Printf("Hook rejected incoming call %s from %v: %v", callID, sender.RemoteAddr(), err)
+		sendError(sender, err.Error())
+		return
+	}
+	if created {
+		room.host = sender
+		room.options = newRoomOptions(msg)
+		if offerDeadlineAfter > 0 {
+			room.offerDeadline = time.Now().Add(offerDeadlineAfter)
+		}
+	}
+	room.addClient(sender)
+	unlock()
+	if created {
+		log.Printf("Created room %s for incoming call", callID)
+	}
+	if assigned {
+		sendRoomAssigned(sender, callID, msg.CallID)
+	}
+	pushLayoutHint(callID)
+
+	clientsMu.Lock()
+	if client, ok := getClient(sender); ok {
+		client.callIDs[callID] = true
+		delete(idleClients, sender)
+	}
+	clientsMu.Unlock()
+
+	log.Printf("Incoming call %s from %v", callID, sender.RemoteAddr())
+	enqueueCall(sender, callID)
+	startRinging(sender, callID, msg.From)
+}
+
+// handleRoomExists tells a client whether a call ID is in use, without creating the room
+func handleRoomExists(sender *wsConn, msg Message) {
+	exists := false
+	count := 0
+	if room, unlock := rlockRoom(msg.CallID); room != nil {
+		exists = true
+		count = len(room.clients)
+		unlock()
+	}
+
+	if err := sender.WriteJSON(Message{
+		Type:        "room_exists_response",
+		CallID:      msg.CallID,
+		Exists:      &exists,
+		ClientCount: count,
+	}); err != nil {
+		log.Printf("Error sending room_exists_response to %v: %v", sender.RemoteAddr(), err)
+		go cleanupClient(sender)
+	}
+}
+
+// notifyEvicted sends msg to the members of a room deleted by cleanup and returns them to idle
+func notifyEvicted(members []*wsConn, msg Message) {
+	callID := msg.CallID
+	for _, conn := range members {
+		leaveQueue(conn, callID)
+		reportDepartures(departure{callID: callID, clientID: clientID(conn), reason: msg.Type})
+		if err := conn.WriteJSON(msg); err != nil {
+			log.Printf("Error sending %s to %v: %v", msg.Type, conn.RemoteAddr(), err)
+			go cleanupClient(conn)
+		}
+	}
+
+	clientsMu.Lock()
+	for _, conn := range members {
+		if client, ok := getClient(conn); ok {
+			delete(client.callIDs, callID)
+			if len(client.callIDs) == 0 {
+				markIdle(conn, client)
+			}
+		}
+	}
+	clientsMu.Unlock()
+}
+
+// cleanupStaleResources periodically removes stale clients and rooms
+func cleanupStaleResources() {
+	for {
+		time.Sleep(time.Duration(currentCleanupInterval.Load()))
+		cleanupPass()
+	}
+}
+
+// cleanupPass removes stale clients and rooms, closes clients whose token expired, pings every other client and,
+// when the pass was heavy and slow, stretches the interval before the next one
+func cleanupPass() {
+	interval := time.Duration(currentCleanupInterval.Load())
+	start := time.Now()
+	var shrunk []string
+	closed := make(map[string]*Room)
+	unconnected := make(map[string][]*wsConn)
+	timedOut := make(map[string][]*wsConn)
+	deleted := make(map[string]*Room)
+	var departed []departure
+	timeoutReasons := make(map[string]string)
+	roomsMu.Lock()
+	for callID, room := range rooms {
+		if room.offerMissing(start) {
+			for client := range room.clients {
+				unconnected[callID] = append(unconnected[callID], client)
+			}
+			delete(rooms, callID)
+			deleted[callID] = room
+			log.Printf("Deleted room %s: no offer within %v", callID, offerDeadlineAfter)
+			continue
+		}
+		if reason := room.timeoutReason(start); reason != "" {
+			for client := range room.clients {
+				timedOut[callID] = append(timedOut[callID], client)
+			}
+			timeoutReasons[callID] = reason
+			delete(rooms, callID)
+			closed[callID] = room
+			log.Printf("Deleted room %s: %s", callID, reason)
+			continue
+		}
+		for client := range room.clients {
+			if _, exists := getClient(client); !exists {
+				departed = append(departed, departure{callID: callID, clientID: room.removeClient(client), reason: "disconnected"})
+				shrunk = append(shrunk, callID)
+				log.Printf("Removed stale client %v from room %s", client.RemoteAddr(), callID)
+			}
+		}
+		if room.offerExpired() {
+			room.offer = nil
+			log.Printf("Discarded expired offer in room %s", callID)
+		}
+		if len(room.clients) == 0 && !room.awaitingBreakout() {
+			delete(rooms, callID)
+			closed[callID] = room
+			log.Printf("Deleted stale empty room %s", callID)
+		}
+	}
+	roomsMu.Unlock()
+	reportDepartures(departed...)
+	for callID, room := range closed {
+		deleted[callID] = room
+		requestFeedback(callID, room)
+	}
+	for _, callID := range shrunk {
+		pushLayoutHint(callID)
+	}
+	for callID, members := range unconnected {
+		notifyEvicted(members, Message{Type: "call_not_connected", CallID: callID})
+	}
+	for callID, members := range timedOut {
+		notifyEvicted(members, Message{Type: "room_timeout", CallID: callID, Reason: timeoutReasons[callID]})
+	}
+	for callID, room := range deleted {
+		reportRoomDeleted(callID, room)
+	}
+
+	pings := 0
+	clients.Range(func(k, v interface{}) bool {
+		ws := k.(*wsConn)
+		if client := v.(*Client); client.tokenExpired(start) {
+			go expireToken(ws, client)
+			return true
+		}
+		pings++
+		v.(*Client).recordPing(time.Now())
+		if err := ws.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(5*time.Second)); err != nil {
+			log.Printf("Removing stale client %v", ws.RemoteAddr())
+			go cleanupClient(ws)
+		}
+		return true
+	})
+	clientsMu.Lock()
+	log.Printf("Cleanup complete, clients: %d, idle: %d, rooms: %d", clientCount.Load(), len(idleClients), len(rooms))
+	clientsMu.Unlock()
+
+	took := time.Since(start)
+	if next := nextCleanupInterval(interval, pings, took); next != interval {
+		log.Printf("Cleanup interval changed from %v to %v after %d pings in %v", interval, next, pings, took)
+		currentCleanupInterval.Store(int64(next))
+	}
+
+	broadcastUserCount()
+}
+
+// routes registers the HTTP API, the WebSocket endpoint and, when static is not nil, the client assets on mux
+func routes(mux *http.ServeMux, static http.FileSystem) {
+	if static != nil {
+		mux.Handle("/", WithSecurityHeaders(http.FileServer(static)))
+	}
+	mux.HandleFunc("/ws", handleConnections)
+	mux.HandleFunc("GET /ws-events", handleEventStream)
+	mux.HandleFunc("GET /join", handleJoinLink)
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("POST /api/v1/admin/snapshot", requireAdmin(handleAdminSnapshot))
+	mux.HandleFunc("POST /api/v1/admin/migrate-room", requireAdmin(handleMigrateRoom))
+	mux.HandleFunc("GET /api/v1/admin/feature-flags", requireAdmin(handleListFeatureFlags))
+	mux.HandleFunc("POST /api/v1/admin/feature-flags", requireAdmin(handleSetFeatureFlag))
+	mux.HandleFunc("GET /api/v1/analytics/queue", requireAdmin(handleQueueAnalytics))
+	mux.HandleFunc("GET /api/v1/feedback", requireAdmin(handleListFeedback))
+	mux.HandleFunc("GET /api/v1/clients/{clientId}", requireAdmin(handleGetClient))
+	mux.HandleFunc("DELETE /api/v1/clients/{clientId}", requireAdmin(handleEraseClient))
+	mux.HandleFunc("POST /api/v1/poll/connect", handlePollConnect)
+	mux.HandleFunc("POST /api/v1/poll/{clientId}/send", handlePollSend)
+	mux.HandleFunc("GET /api/v1/poll/{clientId}/receive", handlePollReceive)
+	mux.HandleFunc("GET /api/v1/rooms", requireAdmin(handleListRooms))
+	mux.HandleFunc("GET /api/v1/rooms/{callId}/health", handleRoomHealth)
+	mux.HandleFunc("GET /api/v1/rooms/{callId}/transcript", requireAdmin(handleGetTranscript))
+	mux.HandleFunc("GET /api/v1/ice-servers", handleICEServers)
+}
+
+// ListenAddr is the address the standalone server listens on, POD_IP port 8000
+func ListenAddr() string {
+	return podIP + ":8000"
+}
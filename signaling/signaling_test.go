@@ -0,0 +1,220 @@
+package signaling
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+)
+
+// setOfferTTL replaces OFFER_TTL_SECONDS for the rest of the test
+func setOfferTTL(t *testing.T, ttl time.Duration) {
+	previous := offerTTL
+	offerTTL = ttl
+	t.Cleanup(func() { offerTTL = previous })
+}
+
+func TestAnsweredOfferDoesNotExpire(t *testing.T) {
+	ts := NewTestServer(t)
+	setOfferTTL(t, 50*time.Millisecond)
+	caller, callee, joiner := ts.Connect(), ts.Connect(), ts.Connect()
+	ts.startCall(caller, callee, "answered-offer")
+	ts.Send(callee, Message{Type: "answer", CallID: "answered-offer", Data: sdpData("answer")})
+	ts.AssertMessageReceived(caller, "answer", testTimeout)
+
+	time.Sleep(100 * time.Millisecond)
+	ts.Send(joiner, Message{Type: "join_call", CallID: "answered-offer"})
+	ts.AssertMessageReceived(joiner, "offer", testTimeout)
+	ts.AssertMessageReceived(joiner, "call_joined", testTimeout)
+}
+
+func TestUnansweredOfferExpires(t *testing.T) {
+	ts := NewTestServer(t)
+	setOfferTTL(t, 50*time.Millisecond)
+	caller, callee := ts.Connect(), ts.Connect()
+	ts.Send(caller, Message{Type: "offer", CallID: "stale-offer", Data: sdpData("offer")})
+	ts.waitForRoom("stale-offer", 1)
+
+	time.Sleep(100 * time.Millisecond)
+	ts.Send(callee, Message{Type: "accept_call", CallID: "stale-offer"})
+	ts.AssertError(callee, "offer_expired")
+}
+
+func TestDisconnectDropsMonitor(t *testing.T) {
+	previous := monitorToken
+	monitorToken = "monitor-secret"
+	t.Cleanup(func() { monitorToken = previous })
+	ts := NewTestServer(t)
+	caller, callee := ts.Connect(), ts.Connect()
+	ts.startCall(caller, callee, "disconnect-monitored")
+	monitor := ts.ConnectWithClientID("disconnect-monitor")
+	ts.Send(monitor, Message{Type: "monitor_room", CallID: "disconnect-monitored", MonitorToken: "monitor-secret"})
+	ts.AssertMessageReceived(monitor, "monitoring", testTimeout)
+
+	// a monitor is in no call, so only the rooms it monitors say where to look for it
+	monitor.Close()
+	for deadline := time.Now().Add(testTimeout); ; time.Sleep(5 * time.Millisecond) {
+		room, unlock := rlockRoom("disconnect-monitored")
+		monitors, members := len(room.monitors), len(room.clients)
+		unlock()
+		if monitors == 0 {
+			if members != 2 {
+				t.Fatalf("room has %d members after its monitor left", members)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("disconnected monitor still watching the room")
+		}
+	}
+	ts.RequireNoMessageOfType(caller, "peer_disconnected", 50*time.Millisecond)
+}
+
+func TestClientRegistryStaysConsistent(t *testing.T) {
+	ts := NewTestServer(t)
+	baseline := clientCount.Load()
+	conns := make([]*websocket.Conn, 20)
+	tokens := make([]string, len(conns))
+	for i := range tokens {
+		tokens[i] = ts.Token(fmt.Sprintf("registry-%d", i), jwt.MapClaims{"rooms": "*"})
+	}
+	var wg sync.WaitGroup
+	for i := range conns {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			conn, err := ts.dial(url.Values{"token": {tokens[i]}}, nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			conns[i] = conn
+		}(i)
+	}
+	wg.Wait()
+	if t.Failed() {
+		t.FailNow()
+	}
+	ts.startCall(conns[0], conns[1], "registry-call")
+
+	registered, idle := registryCounts()
+	if got := clientCount.Load() - baseline; got != 20 || registered != 20 {
+		t.Fatalf("clientCount grew by %d with %d clients registered, want 20", got, registered)
+	}
+	if idle != 18 {
+		t.Fatalf("%d of the clients idle, want 18 with two in a call", idle)
+	}
+
+	for _, conn := range conns[:10] {
+		conn.Close()
+	}
+	for deadline := time.Now().Add(testTimeout); clientCount.Load()-baseline > 10 && time.Now().Before(deadline); {
+		time.Sleep(5 * time.Millisecond)
+	}
+	registered, idle = registryCounts()
+	if got := clientCount.Load() - baseline; got != 10 || registered != 10 || idle != 10 {
+		t.Fatalf("after 10 disconnects clientCount grew by %d, %d registered and %d idle, want 10 each", got, registered, idle)
+	}
+}
+
+// registryCounts reports how many of the clients with IDs registry-0 to registry-19 are registered and idle
+func registryCounts() (registered, idle int) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	clients.Range(func(k, v interface{}) bool {
+		if strings.HasPrefix(v.(*Client).id, "registry-") {
+			registered++
+			if idleClients[k.(*wsConn)] {
+				idle++
+			}
+		}
+		return true
+	})
+	return registered, idle
+}
+
+func TestRoomLocksAreIndependent(t *testing.T) {
+	ts := NewTestServer(t)
+	first, second, third := ts.Connect(), ts.Connect(), ts.Connect()
+	ts.Send(first, Message{Type: "offer", CallID: "lock-first", Data: sdpData("offer")})
+	ts.Send(second, Message{Type: "offer", CallID: "lock-second", Data: sdpData("offer")})
+	ts.waitForRoom("lock-first", 1)
+	ts.waitForRoom("lock-second", 1)
+
+	held, unlock := lockRoom("lock-first")
+	if held == nil {
+		t.Fatal("lock-first does not exist")
+	}
+	defer unlock()
+	ts.Send(third, Message{Type: "accept_call", CallID: "lock-second"})
+	ts.AssertMessageReceived(third, "offer", testTimeout)
+	ts.Send(third, Message{Type: "answer", CallID: "lock-second", Data: sdpData("answer")})
+	ts.AssertMessageReceived(second, "answer", testTimeout)
+}
+
+func TestDisconnectOnlyTellsOwnRoom(t *testing.T) {
+	ts := NewTestServer(t)
+	caller, callee := ts.Connect(), ts.Connect()
+	otherCaller, otherCallee := ts.Connect(), ts.Connect()
+	ts.startCall(caller, callee, "disconnect-own-room")
+	ts.startCall(otherCaller, otherCallee, "disconnect-other-room")
+
+	callee.Close()
+	ts.AssertMessageReceived(caller, "peer_disconnected", testTimeout)
+	ts.RequireNoMessageOfType(otherCaller, "peer_disconnected", 100*time.Millisecond)
+	ts.RequireNoMessageOfType(otherCallee, "peer_disconnected", 100*time.Millisecond)
+}
+
+func TestRoomCapacity(t *testing.T) {
+	for _, maxClients := range []int{1, 2, 4} {
+		t.Run(fmt.Sprint(maxClients), func(t *testing.T) {
+			ts := NewTestServer(t)
+			callID := fmt.Sprintf("capacity-%d", maxClients)
+			host := ts.Connect()
+			ts.Send(host, Message{Type: "offer", CallID: callID, Data: sdpData("offer"), MaxClients: maxClients})
+			ts.waitForRoom(callID, 1)
+			for i := 1; i < maxClients; i++ {
+				member := ts.Connect()
+				ts.Send(member, Message{Type: "join_call", CallID: callID})
+				ts.AssertMessageReceived(member, "call_joined", testTimeout)
+			}
+
+			// every way into a room is refused once it is full
+			for _, msg := range []Message{
+				{Type: "join_call", CallID: callID},
+				{Type: "accept_call", CallID: callID},
+				{Type: "answer", CallID: callID, Data: sdpData("answer")},
+				{Type: "offer", CallID: callID, Data: sdpData("offer")},
+				{Type: "incoming_call", CallID: callID},
+			} {
+				late := ts.Connect()
+				ts.Send(late, msg)
+				ts.AssertError(late, "room_full")
+			}
+			if room, unlock := rlockRoom(callID); room != nil {
+				defer unlock()
+				if len(room.clients) != maxClients {
+					t.Fatalf("room has %d members, want %d", len(room.clients), maxClients)
+				}
+			}
+		})
+	}
+}
+
+func TestAnswerFromOutsiderInFullRoomNotRelayed(t *testing.T) {
+	ts := NewTestServer(t)
+	caller, callee, outsider := ts.Connect(), ts.Connect(), ts.Connect()
+	ts.Send(caller, Message{Type: "offer", CallID: "full-answer", Data: sdpData("offer"), MaxClients: 2})
+	ts.waitForRoom("full-answer", 1)
+	ts.Send(callee, Message{Type: "accept_call", CallID: "full-answer"})
+	ts.AssertMessageReceived(caller, "peer_joined", testTimeout)
+
+	ts.Send(outsider, Message{Type: "answer", CallID: "full-answer", Data: sdpData("answer")})
+	ts.AssertError(outsider, "room_full")
+	ts.RequireNoMessageOfType(caller, "answer", 100*time.Millisecond)
+}
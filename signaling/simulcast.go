@@ -0,0 +1,94 @@
+package signaling
+
+import (
+	"log"
+	"time"
+)
+
+// maxSimulcastLayers bounds the layers one set_simulcast_layers can announce
+const maxSimulcastLayers = 4
+
+// SimulcastLayer is one encoding of a sender's simulcast video, identified by its RTP stream ID
+type SimulcastLayer struct {
+	RID    string `json:"rid"`
+	Active bool   `json:"active"`
+}
+
+// validRID reports whether rid is a usable RTP stream ID (RFC 8851), at most 16 characters
+func validRID(rid string) bool {
+	if rid == "" || len(rid) > 16 {
+		return false
+	}
+	for _, r := range rid {
+		if !(r == '-' || r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// validSimulcastLayers reports whether layers is a non-empty list of distinct, valid layers
+func validSimulcastLayers(layers []SimulcastLayer) bool {
+	if len(layers) == 0 || len(layers) > maxSimulcastLayers {
+		return false
+	}
+	seen := make(map[string]bool, len(layers))
+	for _, layer := range layers {
+		if !validRID(layer.RID) || seen[layer.RID] {
+			return false
+		}
+		seen[layer.RID] = true
+	}
+	return true
+}
+
+// handleSetSimulcastLayers records the simulcast layers a client is sending and relays them to the room
+func handleSetSimulcastLayers(sender *wsConn, msg Message) {
+	if !validSimulcastLayers(msg.Layers) {
+		sendError(sender, "invalid_simulcast_layers")
+		return
+	}
+	if !allowMessage(sender, "set_simulcast_layers", 5, time.Second) {
+		return
+	}
+	if !relayToRoom(sender, Message{
+		Type:     "set_simulcast_layers",
+		CallID:   msg.CallID,
+		ClientID: clientID(sender),
+		Layers:   msg.Layers,
+	}) {
+		return
+	}
+
+	if room, unlock := lockRoom(msg.CallID); room != nil {
+		if room.clients[sender] {
+			room.simulcastLayers[sender] = msg.Layers
+		}
+		unlock()
+	}
+}
+
+// handleRequestLayer asks the member named by from to send the requester its rid layer
+func handleRequestLayer(sender *wsConn, msg Message) {
+	if !validRID(msg.RID) {
+		sendError(sender, "invalid_layer")
+		return
+	}
+	if !allowMessage(sender, "request_layer", 10, time.Second) {
+		return
+	}
+	target := findRoomMember(msg.CallID, sender, msg.From)
+	if target == nil {
+		sendError(sender, "peer_not_found")
+		return
+	}
+	if err := target.WriteJSON(Message{
+		Type:     "request_layer",
+		CallID:   msg.CallID,
+		ClientID: clientID(sender),
+		RID:      msg.RID,
+	}); err != nil {
+		log.Printf("Error sending request_layer to %v: %v", target.RemoteAddr(), err)
+		go cleanupClient(target)
+	}
+}
@@ -0,0 +1,65 @@
+package signaling
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSimulcastLayersRelayedAndRemembered(t *testing.T) {
+	ts := NewTestServer(t)
+	sender, viewer := ts.ConnectWithClientID("simulcast-sender"), ts.ConnectWithClientID("simulcast-viewer")
+	ts.startCall(sender, viewer, "simulcast-call")
+
+	layers := []SimulcastLayer{{RID: "q", Active: true}, {RID: "h", Active: true}, {RID: "f", Active: false}}
+	ts.Send(sender, Message{Type: "set_simulcast_layers", CallID: "simulcast-call", Layers: layers})
+	if msg := ts.AssertMessageReceived(viewer, "set_simulcast_layers", testTimeout); msg.ClientID != "simulcast-sender" || !slices.Equal(msg.Layers, layers) {
+		t.Fatalf("set_simulcast_layers %+v", msg)
+	}
+	// the sender's messages are handled in order, so the layers are stored once echo is answered
+	ts.Send(sender, Message{Type: "echo", Seq: 1})
+	ts.AssertMessageReceived(sender, "echo_reply", testTimeout)
+
+	joiner := ts.Connect()
+	ts.Send(joiner, Message{Type: "join_call", CallID: "simulcast-call"})
+	joined := ts.AssertMessageReceived(joiner, "call_joined", testTimeout)
+	found := false
+	for _, peer := range joined.Peers {
+		if peer.ClientID == "simulcast-sender" {
+			found = true
+			if !slices.Equal(peer.SimulcastLayers, layers) {
+				t.Fatalf("call_joined has layers %+v for the sender", peer.SimulcastLayers)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("call_joined peers %+v miss the sender", joined.Peers)
+	}
+
+	ts.Send(viewer, Message{Type: "request_layer", CallID: "simulcast-call", From: "simulcast-sender", RID: "h"})
+	if msg := ts.AssertMessageReceived(sender, "request_layer", testTimeout); msg.ClientID != "simulcast-viewer" || msg.RID != "h" {
+		t.Fatalf("request_layer %+v", msg)
+	}
+	ts.RequireNoMessageOfType(joiner, "request_layer", 50*time.Millisecond)
+}
+
+func TestSimulcastLayerRules(t *testing.T) {
+	ts := NewTestServer(t)
+	sender, viewer := ts.Connect(), ts.Connect()
+	ts.startCall(sender, viewer, "simulcast-rules")
+
+	for _, layers := range [][]SimulcastLayer{
+		nil,
+		{{RID: "a"}, {RID: "b"}, {RID: "c"}, {RID: "d"}, {RID: "e"}},
+		{{RID: "h"}, {RID: "h"}},
+		{{RID: "not a rid"}},
+		{{RID: "abcdefghijklmnopq"}},
+	} {
+		ts.Send(sender, Message{Type: "set_simulcast_layers", CallID: "simulcast-rules", Layers: layers})
+		ts.AssertError(sender, "invalid_simulcast_layers")
+	}
+	ts.Send(viewer, Message{Type: "request_layer", CallID: "simulcast-rules", From: "nobody", RID: "h"})
+	ts.AssertError(viewer, "peer_not_found")
+	ts.Send(viewer, Message{Type: "request_layer", CallID: "simulcast-rules", From: "nobody", RID: "h!"})
+	ts.AssertError(viewer, "invalid_layer")
+}
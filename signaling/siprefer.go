@@ -0,0 +1,139 @@
+package signaling
+
+import (
+	"crypto/subtle"
+	"log"
+	"time"
+)
+
+// sipBridgeRole is the register role, and when connection tokens are in use the token role, of SIP bridge clients
+const sipBridgeRole = "sip_bridge"
+
+// sipBridgeSecret must be sent as the token of a sip_bridge register on servers without connection tokens,
+// no client can register as a SIP bridge there when it is empty
+var sipBridgeSecret = envString("SIP_BRIDGE_SECRET", "")
+
+// isSIPBridge reports whether the client registered as a SIP bridge
+func (c *Client) isSIPBridge() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sipBridge
+}
+
+// findSIPBridge returns a connected SIP bridge client, or nil when none is connected
+func findSIPBridge() *Client {
+	var found *Client
+	clients.Range(func(_, v interface{}) bool {
+		if client := v.(*Client); client.isSIPBridge() {
+			found = client
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// registerSIPBridge marks the client as a SIP bridge, which needs the sip_bridge token role when tokens are in use
+// and SIP_BRIDGE_SECRET as secret otherwise
+func registerSIPBridge(client *Client, secret string) bool {
+	if auth := client.claims(); auth != nil {
+		if auth.Role != sipBridgeRole {
+			return false
+		}
+	} else if sipBridgeSecret == "" || subtle.ConstantTimeCompare([]byte(secret), []byte(sipBridgeSecret)) != 1 {
+		return false
+	}
+	client.mu.Lock()
+	client.sipBridge = true
+	client.mu.Unlock()
+	log.Printf("Client %s registered as a SIP bridge", client.id)
+	return true
+}
+
+// handleSIPRefer forwards a SIP REFER from a room member to a connected SIP bridge, which transfers the call
+func handleSIPRefer(sender *wsConn, msg Message) {
+	if validateTransferURI(msg.ReferTo) != nil {
+		sendError(sender, "invalid_uri")
+		return
+	}
+	if msg.ReferredBy != "" && validateTransferURI(msg.ReferredBy) != nil {
+		sendError(sender, "invalid_uri")
+		return
+	}
+	if !allowMessage(sender, "sip_refer", 1, time.Second) {
+		return
+	}
+	if _, ok := roomMembers(msg.CallID, sender); !ok {
+		sendError(sender, "not_in_call")
+		return
+	}
+	bridge := findSIPBridge()
+	if bridge == nil {
+		sendError(sender, "sip_bridge_unavailable")
+		return
+	}
+	room, unlock := lockRoom(msg.CallID)
+	if room == nil {
+		sendError(sender, "Call not found")
+		return
+	}
+	room.referBridge = bridge.id
+	unlock()
+	if err := bridge.conn.WriteJSON(Message{
+		Type:       "sip_refer",
+		CallID:     msg.CallID,
+		From:       clientID(sender),
+		ReferTo:    msg.ReferTo,
+		ReferredBy: msg.ReferredBy,
+	}); err != nil {
+		log.Printf("Error sending sip_refer to %v: %v", bridge.conn.RemoteAddr(), err)
+		go cleanupClient(bridge.conn)
+		sendError(sender, "sip_bridge_unavailable")
+		return
+	}
+	audit("sip_refer", msg.CallID, clientID(sender), map[string]interface{}{"referTo": msg.ReferTo})
+}
+
+// handleReferResult relays a SIP bridge's response to the sip_refer it was sent for a call to the members of the call
+func handleReferResult(sender *wsConn, msg Message) {
+	client, ok := getClient(sender)
+	if !ok {
+		return
+	}
+	if !client.isSIPBridge() {
+		sendError(sender, "not_sip_bridge")
+		return
+	}
+	if msg.StatusCode < 100 || msg.StatusCode > 699 {
+		sendError(sender, "invalid_refer_result")
+		return
+	}
+	room, unlock := lockRoom(msg.CallID)
+	if room == nil {
+		sendError(sender, "Call not found")
+		return
+	}
+	if room.referBridge != client.id {
+		unlock()
+		sendError(sender, "no_pending_refer")
+		return
+	}
+	// provisional responses leave the REFER pending for the final one
+	if msg.StatusCode >= 200 {
+		room.referBridge = ""
+	}
+	members := make([]*wsConn, 0, len(room.clients))
+	for member := range room.clients {
+		members = append(members, member)
+	}
+	unlock()
+
+	result := Message{Type: "refer_result", CallID: msg.CallID, StatusCode: msg.StatusCode}
+	for _, member := range members {
+		if err := member.WriteJSON(result); err != nil {
+			log.Printf("Error sending refer_result to %v: %v", member.RemoteAddr(), err)
+			go cleanupClient(member)
+		}
+	}
+	log.Printf("SIP bridge %s answered REFER for call %s with %d", client.id, msg.CallID, msg.StatusCode)
+}
@@ -0,0 +1,95 @@
+package signaling
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+)
+
+// setSIPBridgeSecret replaces SIP_BRIDGE_SECRET for the rest of the test
+func setSIPBridgeSecret(t *testing.T, secret string) {
+	previous := sipBridgeSecret
+	sipBridgeSecret = secret
+	t.Cleanup(func() { sipBridgeSecret = previous })
+}
+
+// referCall starts callID between two members and has the caller send a sip_refer, which bridge must receive
+func (ts *TestServer) referCall(bridge *websocket.Conn, callID string) (caller, callee *websocket.Conn) {
+	ts.t.Helper()
+	caller, callee = ts.Connect(), ts.Connect()
+	ts.startCall(caller, callee, callID)
+	ts.Send(caller, Message{Type: "sip_refer", CallID: callID, ReferTo: "sip:desk@example.com"})
+	if msg := ts.AssertMessageReceived(bridge, "sip_refer", testTimeout); msg.CallID != callID || msg.ReferTo != "sip:desk@example.com" {
+		ts.t.Fatalf("bridge got sip_refer %+v", msg)
+	}
+	return caller, callee
+}
+
+func TestSIPBridgeNeedsSecretWithoutTokens(t *testing.T) {
+	ts := NewTestServer(t)
+	jwtSecret = ""
+	t.Cleanup(func() { jwtSecret = testJWTSecret })
+	impostor := ts.Dial(url.Values{})
+
+	setSIPBridgeSecret(t, "")
+	ts.Send(impostor, Message{Type: "register", Role: sipBridgeRole})
+	ts.AssertError(impostor, "invalid_role")
+	setSIPBridgeSecret(t, "bridge-secret")
+	ts.Send(impostor, Message{Type: "register", Role: sipBridgeRole, Token: "guessed"})
+	ts.AssertError(impostor, "invalid_role")
+	if findSIPBridge() != nil {
+		t.Fatal("a rejected register made a SIP bridge")
+	}
+
+	bridge := ts.Dial(url.Values{})
+	ts.Send(bridge, Message{Type: "register", Role: sipBridgeRole, Token: "bridge-secret"})
+	ts.referCall(bridge, "sip-secret-call")
+}
+
+func TestSIPBridgeNeedsTokenRole(t *testing.T) {
+	ts := NewTestServer(t)
+	setSIPBridgeSecret(t, "bridge-secret")
+	// the secret is for servers without tokens; with them only the token role counts
+	impostor := ts.ConnectWithClientID("sip-impostor")
+	ts.Send(impostor, Message{Type: "register", Role: sipBridgeRole, Token: "bridge-secret"})
+	ts.AssertError(impostor, "invalid_role")
+
+	bridge := ts.Dial(url.Values{"token": {ts.Token("sip-bridge", jwt.MapClaims{"rooms": "*", "role": sipBridgeRole})}})
+	ts.Send(bridge, Message{Type: "register", Role: sipBridgeRole})
+	ts.referCall(bridge, "sip-role-call")
+}
+
+func TestReferResultNeedsPendingRefer(t *testing.T) {
+	ts := NewTestServer(t)
+	bridge := ts.Dial(url.Values{"token": {ts.Token("sip-results-bridge", jwt.MapClaims{"rooms": "*", "role": sipBridgeRole})}})
+	ts.Send(bridge, Message{Type: "register", Role: sipBridgeRole})
+	quiet, quietPeer := ts.Connect(), ts.Connect()
+	ts.startCall(quiet, quietPeer, "sip-quiet-call")
+
+	// a result for a call nobody referred reaches nobody
+	ts.Send(bridge, Message{Type: "refer_result", CallID: "sip-quiet-call", StatusCode: 200})
+	ts.AssertError(bridge, "no_pending_refer")
+	ts.RequireNoMessageOfType(quiet, "refer_result", 50*time.Millisecond)
+
+	caller, callee := ts.referCall(bridge, "sip-results-call")
+	ts.Send(bridge, Message{Type: "refer_result", CallID: "sip-results-call", StatusCode: 100})
+	for _, member := range []*websocket.Conn{caller, callee} {
+		if msg := ts.AssertMessageReceived(member, "refer_result", testTimeout); msg.StatusCode != 100 {
+			t.Fatalf("refer_result %+v", msg)
+		}
+	}
+	ts.Send(bridge, Message{Type: "refer_result", CallID: "sip-results-call", StatusCode: 202})
+	if msg := ts.AssertMessageReceived(caller, "refer_result", testTimeout); msg.StatusCode != 202 {
+		t.Fatalf("refer_result %+v", msg)
+	}
+	// the final response settled the refer
+	ts.Send(bridge, Message{Type: "refer_result", CallID: "sip-results-call", StatusCode: 603})
+	ts.AssertError(bridge, "no_pending_refer")
+	ts.RequireNoMessageOfType(caller, "refer_result", 50*time.Millisecond)
+
+	ts.Send(caller, Message{Type: "refer_result", CallID: "sip-results-call", StatusCode: 200})
+	ts.AssertError(caller, "not_sip_bridge")
+}
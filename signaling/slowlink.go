@@ -0,0 +1,79 @@
+package signaling
+
+import (
+	"log"
+	"time"
+)
+
+// slowLinkBacklog is how many messages left queued after a write mark a client's downstream link as slow
+var slowLinkBacklog = envInt("SLOW_LINK_BACKLOG", sendQueueSize/4)
+
+// slowLinkRecovery is how many consecutive writes that leave the queue below slowLinkBacklog clear the flag
+const slowLinkRecovery = 3
+
+// checkBacklog updates the client's slow-link flag after the writer sent n bytes in took,
+// telling the client and its room peers when the link first turns slow
+func (c *wsConn) checkBacklog(n int, took time.Duration) {
+	client, ok := getClient(c)
+	if !ok {
+		return
+	}
+	backlog := len(c.queue.high) + len(c.queue.low)
+
+	client.mu.Lock()
+	turnedSlow := false
+	if backlog >= slowLinkBacklog {
+		client.cleanWrites = 0
+		turnedSlow = !client.slowLink
+		client.slowLink = true
+	} else if client.slowLink {
+		client.cleanWrites++
+		if client.cleanWrites >= slowLinkRecovery {
+			client.slowLink = false
+			client.cleanWrites = 0
+		}
+	}
+	client.mu.Unlock()
+
+	if turnedSlow {
+		var bitrate int64
+		if took > 0 {
+			bitrate = int64(float64(n*8) / took.Seconds())
+		}
+		log.Printf("Client %s has a slow link: %d messages queued, about %d bit/s", client.id, backlog, bitrate)
+		go notifySlowLink(client, bitrate)
+	}
+}
+
+// isSlowLink reports whether the client's send queue is currently backed up
+func (c *Client) isSlowLink() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.slowLink
+}
+
+// notifySlowLink sends slow_link to the client and every member of the rooms it is in
+func notifySlowLink(client *Client, bitrate int64) {
+	clientsMu.Lock()
+	callIDs := client.activeCalls()
+	clientsMu.Unlock()
+
+	recipients := map[*wsConn]bool{client.conn: true}
+	for _, callID := range callIDs {
+		members, _ := roomMembers(callID, client.conn)
+		for _, member := range members {
+			recipients[member] = true
+		}
+	}
+	for conn := range recipients {
+		if err := conn.WriteJSON(Message{
+			Type:            "slow_link",
+			ClientID:        client.id,
+			Direction:       "downstream",
+			BitrateEstimate: bitrate,
+		}); err != nil {
+			log.Printf("Error sending slow_link to %v: %v", conn.RemoteAddr(), err)
+			go cleanupClient(conn)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package signaling
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// fillOutbox backs up the server's send queue to the client with id by holding its socket while more
+// than slowLinkBacklog messages are queued, then lets the writer drain them
+func fillOutbox(t *testing.T, id string) {
+	t.Helper()
+	conn := findClient(id).conn
+	conn.writeMu.Lock()
+	if err := conn.WriteJSON(Message{Type: "relay", Seq: 0}); err != nil {
+		t.Fatal(err)
+	}
+	for len(conn.queue.low) > 0 {
+		runtime.Gosched()
+	}
+	for seq := int64(1); seq <= int64(slowLinkBacklog)+10; seq++ {
+		if err := conn.WriteJSON(Message{Type: "relay", Seq: seq}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	conn.writeMu.Unlock()
+}
+
+func TestSlowLinkWhenOutboxFills(t *testing.T) {
+	// one message per write, so every write is a chance to see the backlog
+	setBatchWriteDelay(t, 0)
+	ts := NewTestServer(t)
+	alice, bob := ts.ConnectWithClientID("slow-alice"), ts.ConnectWithClientID("slow-bob")
+	ts.startCall(alice, bob, "slow-call")
+	ts.waitForRoom("slow-call", 2)
+	outsider := ts.Connect()
+
+	fillOutbox(t, "slow-bob")
+	for _, conn := range []*websocket.Conn{bob, alice} {
+		msg := ts.AssertMessageReceived(conn, "slow_link", testTimeout)
+		if msg.ClientID != "slow-bob" || msg.Direction != "downstream" || msg.BitrateEstimate <= 0 {
+			t.Fatalf("slow_link %+v", msg)
+		}
+	}
+	ts.RequireNoMessageOfType(outsider, "slow_link", 50*time.Millisecond)
+
+	// draining the queue is many more than slowLinkRecovery clean writes
+	bobClient := findClient("slow-bob")
+	for deadline := time.Now().Add(testTimeout); bobClient.isSlowLink(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("slow link never cleared once the outbox drained")
+		}
+	}
+	ts.RequireNoMessageOfType(alice, "slow_link", 50*time.Millisecond)
+}
+
+func TestSlowLinkNeedsConsecutiveCleanWrites(t *testing.T) {
+	ts := NewTestServer(t)
+	ts.ConnectWithClientID("slow-flaky")
+	client := findClient("slow-flaky")
+	client.mu.Lock()
+	client.slowLink = true
+	client.mu.Unlock()
+
+	// the queue stays empty, so each check is a clean write
+	for i := 1; i < slowLinkRecovery; i++ {
+		client.conn.checkBacklog(0, 0)
+		if !client.isSlowLink() {
+			t.Fatalf("slow link cleared after %d clean writes, want %d", i, slowLinkRecovery)
+		}
+	}
+	client.conn.checkBacklog(0, 0)
+	if client.isSlowLink() {
+		t.Fatalf("slow link still set after %d clean writes", slowLinkRecovery)
+	}
+}
@@ -0,0 +1,96 @@
+package signaling
+
+import (
+	"encoding/base64"
+	"log"
+	"time"
+)
+
+// maxSnapshotBytes is the largest decoded video_snapshot image accepted
+const maxSnapshotBytes = 50 << 10
+
+// findClient returns the connected client with the given ID
+func findClient(id string) *Client {
+	var found *Client
+	clients.Range(func(_, v interface{}) bool {
+		if client := v.(*Client); client.id == id {
+			found = client
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// handleVideoSnapshot sends a preview frame from a room member to one client, who need not have joined yet
+func handleVideoSnapshot(sender *wsConn, msg Message) {
+	image, err := base64.StdEncoding.DecodeString(msg.ImageData)
+	if err != nil || len(image) == 0 || len(image) > maxSnapshotBytes {
+		sendError(sender, "invalid_snapshot")
+		return
+	}
+	if !allowMessage(sender, "video_snapshot", 1, 5*time.Second) {
+		return
+	}
+	client, ok := getClient(sender)
+	if !ok {
+		return
+	}
+	if _, ok := roomMembers(msg.CallID, sender); !ok {
+		sendError(sender, "not_in_call")
+		return
+	}
+	target := findClient(msg.To)
+	if target == nil || target == client {
+		sendError(sender, "peer_not_found")
+		return
+	}
+
+	client.mu.Lock()
+	client.snapshot = msg.ImageData
+	client.mu.Unlock()
+
+	if err := target.conn.WriteJSON(Message{
+		Type:      "video_snapshot",
+		CallID:    msg.CallID,
+		From:      client.id,
+		To:        msg.To,
+		ImageData: msg.ImageData,
+	}); err != nil {
+		log.Printf("Error relaying video_snapshot to %v: %v", target.conn.RemoteAddr(), err)
+		go cleanupClient(target.conn)
+	}
+}
+
+// sendSnapshots gives a new member the latest video_snapshot of everyone else in the room
+func sendSnapshots(conn *wsConn, callID string) {
+	members, ok := roomMembers(callID, conn)
+	if !ok {
+		return
+	}
+	for _, member := range members {
+		if member == conn {
+			continue
+		}
+		client, ok := getClient(member)
+		if !ok {
+			continue
+		}
+		client.mu.Lock()
+		snapshot := client.snapshot
+		client.mu.Unlock()
+		if snapshot == "" {
+			continue
+		}
+		if err := conn.WriteJSON(Message{
+			Type:      "video_snapshot",
+			CallID:    callID,
+			From:      client.id,
+			ImageData: snapshot,
+		}); err != nil {
+			log.Printf("Error sending video_snapshot to %v: %v", conn.RemoteAddr(), err)
+			go cleanupClient(conn)
+			return
+		}
+	}
+}
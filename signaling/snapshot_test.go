@@ -0,0 +1,67 @@
+package signaling
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+// snapshotImage returns a base64 image that decodes to size bytes
+func snapshotImage(size int) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0xff}, size))
+}
+
+func TestVideoSnapshotSizeAndRateLimit(t *testing.T) {
+	ts := NewTestServer(t)
+	caller, callee, bystander := ts.ConnectWithClientID("snapshot-caller"), ts.ConnectWithClientID("snapshot-callee"), ts.Connect()
+	ts.Send(caller, Message{Type: "offer", CallID: "snapshot-preview", Data: sdpData("offer")})
+	ts.waitForRoom("snapshot-preview", 1)
+
+	for name, image := range map[string]string{
+		"too large":  snapshotImage(maxSnapshotBytes + 1),
+		"not base64": "not*base64",
+		"empty":      "",
+	} {
+		ts.Send(caller, Message{Type: "video_snapshot", CallID: "snapshot-preview", To: "snapshot-callee", ImageData: image})
+		if msg := ts.AssertMessageReceived(caller, "error", testTimeout); msg.Code != "invalid_snapshot" {
+			t.Fatalf("%s image: got error %q", name, msg.Code)
+		}
+	}
+
+	largest := snapshotImage(maxSnapshotBytes)
+	ts.Send(caller, Message{Type: "video_snapshot", CallID: "snapshot-preview", To: "snapshot-callee", ImageData: largest})
+	if msg := ts.AssertMessageReceived(callee, "video_snapshot", testTimeout); msg.From != "snapshot-caller" || msg.ImageData != largest {
+		t.Fatalf("callee got a snapshot from %q of %d bytes", msg.From, len(msg.ImageData))
+	}
+	ts.Send(caller, Message{Type: "video_snapshot", CallID: "snapshot-preview", To: "snapshot-callee", ImageData: snapshotImage(10)})
+	ts.AssertError(caller, "rate_limited")
+	ts.RequireNoMessageOfType(callee, "video_snapshot", 100*time.Millisecond)
+	ts.RequireNoMessageOfType(bystander, "video_snapshot", 50*time.Millisecond)
+}
+
+func TestVideoSnapshotRules(t *testing.T) {
+	ts := NewTestServer(t)
+	caller, outsider := ts.ConnectWithClientID("snapshot-rules-caller"), ts.Connect()
+	ts.Send(caller, Message{Type: "offer", CallID: "snapshot-rules", Data: sdpData("offer")})
+	ts.waitForRoom("snapshot-rules", 1)
+
+	ts.Send(outsider, Message{Type: "video_snapshot", CallID: "snapshot-rules", To: "snapshot-rules-caller", ImageData: snapshotImage(10)})
+	ts.AssertError(outsider, "not_in_call")
+	ts.Send(caller, Message{Type: "video_snapshot", CallID: "snapshot-rules", To: "snapshot-rules-caller", ImageData: snapshotImage(10)})
+	ts.AssertError(caller, "peer_not_found")
+}
+
+func TestVideoSnapshotGivenToNewJoiner(t *testing.T) {
+	ts := NewTestServer(t)
+	caller, callee, joiner := ts.ConnectWithClientID("snapshot-host"), ts.ConnectWithClientID("snapshot-first"), ts.Connect()
+	ts.startCall(caller, callee, "snapshot-join")
+	image := snapshotImage(100)
+	ts.Send(caller, Message{Type: "video_snapshot", CallID: "snapshot-join", To: "snapshot-first", ImageData: image})
+	ts.AssertMessageReceived(callee, "video_snapshot", testTimeout)
+
+	ts.Send(joiner, Message{Type: "join_call", CallID: "snapshot-join"})
+	if msg := ts.AssertMessageReceived(joiner, "video_snapshot", testTimeout); msg.From != "snapshot-host" || msg.ImageData != image {
+		t.Fatalf("joiner got snapshot %+v", msg)
+	}
+}
@@ -0,0 +1,528 @@
+package signaling
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+)
+
+// testJWTSecret signs the connection tokens test clients connect with, so the test picks their client IDs
+const testJWTSecret = "signaling-test-secret"
+
+// testTimeout is how long tests wait for a message they expect
+const testTimeout = 2 * time.Second
+
+// testSDP is a minimal session description that passes validateSDP
+const testSDP = "v=0\r\no=- 4611731400430051336 2 IN IP4 127.0.0.1\r\ns=-\r\nt=0 0\r\n" +
+	"m=audio 9 UDP/TLS/RTP/SAVPF 111\r\nc=IN IP4 0.0.0.0\r\na=rtpmap:111 opus/48000/2\r\n"
+
+// sdpData returns the Data of an offer or answer carrying testSDP
+func sdpData(kind string) string {
+	data, _ := json.Marshal(map[string]string{"type": kind, "sdp": testSDP})
+	return string(data)
+}
+
+// TestServer runs the signaling server on an httptest.Server and reads every connection it opens in the background,
+// so tests can wait for messages with a timeout without breaking the connection. It reaches into the package's
+// state; videochattesting offers the same helpers to other packages through the exported API.
+type TestServer struct {
+	t      testing.TB
+	server *httptest.Server
+
+	mu     sync.Mutex
+	inbox  map[*websocket.Conn]*inbox
+	serial int
+}
+
+// inbox queues the messages read from one connection; it never blocks the reader, so a test that ignores
+// a connection cannot make the server's send queue for it back up
+type inbox struct {
+	mu       sync.Mutex
+	messages []Message
+	closed   bool
+	arrived  chan struct{} // signalled after messages are queued or the connection fails
+}
+
+func newInbox() *inbox {
+	return &inbox{arrived: make(chan struct{}, 1)}
+}
+
+// push queues msgs for the test to receive
+func (in *inbox) push(msgs ...Message) {
+	in.mu.Lock()
+	in.messages = append(in.messages, msgs...)
+	in.mu.Unlock()
+	in.signal()
+}
+
+// close records that the connection failed once every queued message has been received
+func (in *inbox) close() {
+	in.mu.Lock()
+	in.closed = true
+	in.mu.Unlock()
+	in.signal()
+}
+
+func (in *inbox) signal() {
+	select {
+	case in.arrived <- struct{}{}:
+	default:
+	}
+}
+
+// next returns the oldest queued message, waiting until deadline fires; ok is false if the connection
+// failed or the deadline passed first, with closed telling which
+func (in *inbox) next(deadline <-chan time.Time) (msg Message, ok, closed bool) {
+	for {
+		in.mu.Lock()
+		if len(in.messages) > 0 {
+			msg = in.messages[0]
+			in.messages = in.messages[1:]
+			in.mu.Unlock()
+			return msg, true, false
+		}
+		closed = in.closed
+		in.mu.Unlock()
+		if closed {
+			return Message{}, false, true
+		}
+		select {
+		case <-in.arrived:
+		case <-deadline:
+			return Message{}, false, false
+		}
+	}
+}
+
+// drain discards every queued message
+func (in *inbox) drain() {
+	in.mu.Lock()
+	in.messages = nil
+	in.mu.Unlock()
+}
+
+// NewTestServer starts a signaling server for t with connection tokens turned on; it is closed,
+// along with every connection opened through it, when the test ends
+func NewTestServer(t testing.TB) *TestServer {
+	t.Helper()
+	baseline := clientCount.Load()
+	previousSecret := jwtSecret
+	jwtSecret = testJWTSecret
+
+	ts := &TestServer{t: t, inbox: make(map[*websocket.Conn]*inbox)}
+	ts.server = httptest.NewServer(NewServer().Handler(nil))
+	t.Cleanup(func() {
+		ts.mu.Lock()
+		for conn := range ts.inbox {
+			conn.Close()
+		}
+		ts.mu.Unlock()
+		// the next test starts once the server has cleaned up every client of this one
+		for deadline := time.Now().Add(testTimeout); clientCount.Load() > baseline && time.Now().Before(deadline); {
+			time.Sleep(5 * time.Millisecond)
+		}
+		ts.server.Close()
+		jwtSecret = previousSecret
+	})
+	return ts
+}
+
+// URL returns the server's address with an http scheme
+func (ts *TestServer) URL() string {
+	return ts.server.URL
+}
+
+// Connect opens a WebSocket connection as a new client with a generated ID
+func (ts *TestServer) Connect() *websocket.Conn {
+	ts.t.Helper()
+	return ts.ConnectWithClientID(ts.nextClientID())
+}
+
+// nextClientID returns a client ID no other connection of this test has used
+func (ts *TestServer) nextClientID() string {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.serial++
+	return fmt.Sprintf("%s-%d", strings.ReplaceAll(ts.t.Name(), "/", "-"), ts.serial)
+}
+
+// ConnectWithClientID opens a WebSocket connection as the client id, with a token granting every room
+func (ts *TestServer) ConnectWithClientID(id string) *websocket.Conn {
+	ts.t.Helper()
+	return ts.Dial(url.Values{"token": {ts.Token(id, jwt.MapClaims{"rooms": "*"})}})
+}
+
+// Token signs a connection token for the client id with any extra claims
+func (ts *TestServer) Token(id string, claims jwt.MapClaims) string {
+	ts.t.Helper()
+	all := jwt.MapClaims{"sub": id, "exp": time.Now().Add(time.Hour).Unix()}
+	for k, v := range claims {
+		all[k] = v
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, all).SignedString([]byte(testJWTSecret))
+	if err != nil {
+		ts.t.Fatalf("signing token for %s: %v", id, err)
+	}
+	return token
+}
+
+// Dial opens a WebSocket connection to /ws with query and waits until the server has registered it
+func (ts *TestServer) Dial(query url.Values) *websocket.Conn {
+	ts.t.Helper()
+	return ts.DialHeader(query, nil)
+}
+
+// DialHeader is Dial with extra request headers, such as a license token
+func (ts *TestServer) DialHeader(query url.Values, header http.Header) *websocket.Conn {
+	ts.t.Helper()
+	conn, err := ts.dial(query, header)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	return conn
+}
+
+// dial is DialHeader returning its failure rather than ending the test, for use off the test goroutine
+func (ts *TestServer) dial(query url.Values, header http.Header) (*websocket.Conn, error) {
+	conn, resp, err := websocket.DefaultDialer.Dial(ts.wsURL(query), header)
+	if err != nil {
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		return nil, fmt.Errorf("dialing signaling server: %v (status %d)", err, status)
+	}
+	ts.track(conn)
+	if _, err := ts.receive(conn, "capabilities", testTimeout); err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// track starts reading conn into a new inbox
+func (ts *TestServer) track(conn *websocket.Conn) {
+	in := newInbox()
+	ts.mu.Lock()
+	ts.inbox[conn] = in
+	ts.mu.Unlock()
+	go readMessages(conn, in)
+}
+
+// DialStatus attempts a WebSocket connection to /ws with query and returns the HTTP status of a refused upgrade, or 101
+func (ts *TestServer) DialStatus(query url.Values) int {
+	ts.t.Helper()
+	conn, resp, err := websocket.DefaultDialer.Dial(ts.wsURL(query), nil)
+	if err == nil {
+		conn.Close()
+		return http.StatusSwitchingProtocols
+	}
+	if resp == nil {
+		ts.t.Fatalf("dialing signaling server: %v", err)
+	}
+	return resp.StatusCode
+}
+
+// wsURL returns the ws:// URL of /ws with query
+func (ts *TestServer) wsURL(query url.Values) string {
+	return "ws" + strings.TrimPrefix(ts.server.URL, "http") + "/ws?" + query.Encode()
+}
+
+// readMessages queues every message received on conn, unpacking batched frames, until conn fails; user_count,
+// which every connect and disconnect broadcasts to every client, is dropped
+func readMessages(conn *websocket.Conn, in *inbox) {
+	defer in.close()
+	for {
+		_, frame, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var batch []Message
+		if len(frame) > 0 && frame[0] == '[' {
+			if json.Unmarshal(frame, &batch) != nil {
+				continue
+			}
+		} else {
+			var msg Message
+			if json.Unmarshal(frame, &msg) != nil {
+				continue
+			}
+			batch = []Message{msg}
+		}
+		kept := batch[:0]
+		for _, msg := range batch {
+			if msg.Type != "user_count" {
+				kept = append(kept, msg)
+			}
+		}
+		if len(kept) > 0 {
+			in.push(kept...)
+		}
+	}
+}
+
+// Send writes msg to the server on conn
+func (ts *TestServer) Send(conn *websocket.Conn, msg Message) {
+	ts.t.Helper()
+	if err := conn.WriteJSON(msg); err != nil {
+		ts.t.Fatalf("sending %s: %v", msg.Type, err)
+	}
+}
+
+// AssertMessageReceived waits up to timeout for a message of msgType on conn, skipping any others, and returns it
+func (ts *TestServer) AssertMessageReceived(conn *websocket.Conn, msgType string, timeout time.Duration) Message {
+	ts.t.Helper()
+	msg, err := ts.receive(conn, msgType, timeout)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	return msg
+}
+
+// receive is AssertMessageReceived returning its failure rather than ending the test, for use off the test goroutine
+func (ts *TestServer) receive(conn *websocket.Conn, msgType string, timeout time.Duration) (Message, error) {
+	in, err := ts.inboxFor(conn)
+	if err != nil {
+		return Message{}, err
+	}
+	deadline := time.After(timeout)
+	var skipped []string
+	for {
+		msg, ok, closed := in.next(deadline)
+		switch {
+		case ok && msg.Type == msgType:
+			return msg, nil
+		case ok:
+			skipped = append(skipped, msg.Type+describeError(msg))
+		case closed:
+			return Message{}, fmt.Errorf("connection closed waiting for %s, got %v", msgType, skipped)
+		default:
+			return Message{}, fmt.Errorf("no %s within %v, got %v", msgType, timeout, skipped)
+		}
+	}
+}
+
+// AssertError waits for an error message on conn and checks its code
+func (ts *TestServer) AssertError(conn *websocket.Conn, code string) {
+	ts.t.Helper()
+	if msg := ts.AssertMessageReceived(conn, "error", testTimeout); msg.Code != code {
+		ts.t.Fatalf("got error %q, want %q", msg.Code, code)
+	}
+}
+
+// RequireNoMessage fails if conn receives anything within duration
+func (ts *TestServer) RequireNoMessage(conn *websocket.Conn, duration time.Duration) {
+	ts.t.Helper()
+	ts.RequireNoMessageOfType(conn, "", duration)
+}
+
+// RequireNoMessageOfType fails if conn receives a message of msgType, or of any type if msgType is empty, within duration
+func (ts *TestServer) RequireNoMessageOfType(conn *websocket.Conn, msgType string, duration time.Duration) {
+	ts.t.Helper()
+	in, err := ts.inboxFor(conn)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	deadline := time.After(duration)
+	for {
+		msg, ok, _ := in.next(deadline)
+		if !ok {
+			return
+		}
+		if msgType == "" || msg.Type == msgType {
+			ts.t.Fatalf("unexpected %s%s", msg.Type, describeError(msg))
+		}
+	}
+}
+
+// Drain discards every message conn has received so far
+func (ts *TestServer) Drain(conn *websocket.Conn) {
+	ts.t.Helper()
+	in, err := ts.inboxFor(conn)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	in.drain()
+}
+
+// inboxFor returns the messages received on conn, which must have been opened by ts
+func (ts *TestServer) inboxFor(conn *websocket.Conn) (*inbox, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	in, ok := ts.inbox[conn]
+	if !ok {
+		return nil, fmt.Errorf("connection was not opened by the test server")
+	}
+	return in, nil
+}
+
+// describeError adds the code of an error message to its type in failure output
+func describeError(msg Message) string {
+	if msg.Type == "error" {
+		return "(" + msg.Code + ")"
+	}
+	return ""
+}
+
+// startCall has caller create callID with an offer and callee accept it, returning once callee has the offer
+func (ts *TestServer) startCall(caller, callee *websocket.Conn, callID string) {
+	ts.t.Helper()
+	ts.Send(caller, Message{Type: "offer", CallID: callID, Data: sdpData("offer")})
+	ts.waitForRoom(callID, 1)
+	ts.Send(callee, Message{Type: "accept_call", CallID: callID})
+	ts.AssertMessageReceived(callee, "offer", testTimeout)
+}
+
+// waitForRoom waits until the room for callID has at least members clients
+func (ts *TestServer) waitForRoom(callID string, members int) {
+	ts.t.Helper()
+	if err := awaitRoom(callID, members); err != nil {
+		ts.t.Fatal(err)
+	}
+}
+
+// awaitRoom is waitForRoom returning its failure rather than ending the test, for use off the test goroutine
+func awaitRoom(callID string, members int) error {
+	for deadline := time.Now().Add(testTimeout); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if room, unlock := rlockRoom(callID); room != nil {
+			n := len(room.clients)
+			unlock()
+			if n >= members {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("room %s never reached %d members", callID, members)
+}
+
+func TestConnectReceivesServerVersion(t *testing.T) {
+	ts := NewTestServer(t)
+	conn, _, err := websocket.DefaultDialer.Dial(ts.wsURL(url.Values{"token": {ts.Token("version-check", jwt.MapClaims{"rooms": "*"})}}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.track(conn)
+	if msg := ts.AssertMessageReceived(conn, "server_version", testTimeout); msg.Version == "" {
+		t.Fatalf("server_version has no version: %+v", msg)
+	}
+}
+
+func TestConnectRequiresToken(t *testing.T) {
+	ts := NewTestServer(t)
+	if status := ts.DialStatus(nil); status != http.StatusUnauthorized {
+		t.Fatalf("got status %d without a token, want 401", status)
+	}
+}
+
+func TestOfferCreatesRoom(t *testing.T) {
+	ts := NewTestServer(t)
+	caller, observer := ts.Connect(), ts.Connect()
+	ts.Send(caller, Message{Type: "offer", CallID: "helper-offer", Data: sdpData("offer")})
+	ts.waitForRoom("helper-offer", 1)
+
+	ts.Send(observer, Message{Type: "room_exists", CallID: "helper-offer"})
+	msg := ts.AssertMessageReceived(observer, "room_exists_response", testTimeout)
+	if msg.Exists == nil || !*msg.Exists || msg.ClientCount != 1 {
+		t.Fatalf("room_exists_response %+v, want an existing room with 1 client", msg)
+	}
+}
+
+func TestRoomExistsUnknownCall(t *testing.T) {
+	ts := NewTestServer(t)
+	conn := ts.Connect()
+	ts.Send(conn, Message{Type: "room_exists", CallID: "helper-nobody-here"})
+	msg := ts.AssertMessageReceived(conn, "room_exists_response", testTimeout)
+	if msg.Exists == nil || *msg.Exists {
+		t.Fatalf("room_exists_response %+v, want exists false", msg)
+	}
+}
+
+func TestAcceptCallDeliversOfferAndJoined(t *testing.T) {
+	ts := NewTestServer(t)
+	caller, callee := ts.Connect(), ts.Connect()
+	ts.startCall(caller, callee, "helper-accept")
+	if msg := ts.AssertMessageReceived(callee, "call_joined", testTimeout); msg.CallID != "helper-accept" {
+		t.Fatalf("call_joined for %q", msg.CallID)
+	}
+}
+
+func TestPeerJoinedCarriesClientID(t *testing.T) {
+	ts := NewTestServer(t)
+	caller, callee := ts.Connect(), ts.ConnectWithClientID("callee-alice")
+	ts.startCall(caller, callee, "helper-peer-joined")
+	if msg := ts.AssertMessageReceived(caller, "peer_joined", testTimeout); msg.ClientID != "callee-alice" {
+		t.Fatalf("peer_joined from %q, want callee-alice", msg.ClientID)
+	}
+}
+
+func TestAnswerAndICECandidateRelayed(t *testing.T) {
+	ts := NewTestServer(t)
+	caller, callee := ts.Connect(), ts.Connect()
+	ts.startCall(caller, callee, "helper-answer")
+
+	ts.Send(callee, Message{Type: "answer", CallID: "helper-answer", Data: sdpData("answer")})
+	ts.AssertMessageReceived(caller, "answer", testTimeout)
+	ts.Send(caller, Message{Type: "ice-candidate", CallID: "helper-answer", Data: `{"candidate":"candidate:1 1 udp 1 10.0.0.1 5000 typ host"}`})
+	if msg := ts.AssertMessageReceived(callee, "ice-candidate", testTimeout); !strings.Contains(msg.Data, "10.0.0.1") {
+		t.Fatalf("ice-candidate data %q", msg.Data)
+	}
+	ts.RequireNoMessageOfType(caller, "ice-candidate", 100*time.Millisecond)
+}
+
+func TestHangupTellsPeer(t *testing.T) {
+	ts := NewTestServer(t)
+	caller, callee := ts.Connect(), ts.Connect()
+	ts.startCall(caller, callee, "helper-hangup")
+	ts.Send(callee, Message{Type: "hangup", CallID: "helper-hangup"})
+	ts.AssertMessageReceived(caller, "peer_disconnected", testTimeout)
+}
+
+func TestHangupWithoutCallID(t *testing.T) {
+	ts := NewTestServer(t)
+	conn := ts.Connect()
+	ts.Send(conn, Message{Type: "hangup"})
+	ts.AssertError(conn, "missing_call_id")
+}
+
+func TestLeaveRoomWhenNotInCall(t *testing.T) {
+	ts := NewTestServer(t)
+	conn := ts.Connect()
+	ts.Send(conn, Message{Type: "leave_room", CallID: "helper-not-joined"})
+	ts.AssertError(conn, "not_in_call")
+}
+
+func TestEchoReply(t *testing.T) {
+	ts := NewTestServer(t)
+	conn := ts.Connect()
+	ts.Send(conn, Message{Type: "echo", Seq: 7})
+	if msg := ts.AssertMessageReceived(conn, "echo_reply", testTimeout); msg.Seq != 7 || msg.ServerReceivedAt == "" {
+		t.Fatalf("echo_reply %+v", msg)
+	}
+	ts.RequireNoMessage(conn, 50*time.Millisecond)
+}
+
+func TestDisconnectClosesTwoPartyCallForPeer(t *testing.T) {
+	ts := NewTestServer(t)
+	caller, callee := ts.Connect(), ts.Connect()
+	ts.startCall(caller, callee, "helper-disconnect")
+	callee.Close()
+	ts.AssertMessageReceived(caller, "peer_disconnected", testTimeout)
+}
+
+func TestIdleClientGetsNoCallTraffic(t *testing.T) {
+	ts := NewTestServer(t)
+	caller, callee, bystander := ts.Connect(), ts.Connect(), ts.Connect()
+	ts.startCall(caller, callee, "helper-bystander")
+	ts.Drain(bystander)
+	ts.Send(callee, Message{Type: "answer", CallID: "helper-bystander", Data: sdpData("answer")})
+	ts.AssertMessageReceived(caller, "answer", testTimeout)
+	ts.RequireNoMessage(bystander, 100*time.Millisecond)
+}
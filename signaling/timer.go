@@ -0,0 +1,95 @@
+package signaling
+
+import (
+	"log"
+	"time"
+	"unicode/utf8"
+)
+
+// maxTimerDuration bounds the countdown start_timer can set
+const maxTimerDuration = 24 * time.Hour
+
+// handleStartTimer lets the room host start a countdown every participant sees, replacing any running one
+func handleStartTimer(sender *wsConn, msg Message) {
+	duration := time.Duration(msg.DurationSeconds) * time.Second
+	if duration <= 0 || duration > maxTimerDuration || utf8.RuneCountInString(msg.Label) > 100 {
+		sendError(sender, "invalid_timer")
+		return
+	}
+
+	room, unlock := lockRoom(msg.CallID)
+	if room == nil {
+		sendError(sender, "Call not found")
+		return
+	}
+	if room.host != sender {
+		unlock()
+		sendError(sender, "not_host")
+		return
+	}
+	room.TimerEndsAt = time.Now().Add(duration)
+	room.timerLabel = msg.Label
+	room.timerGen++
+	gen := room.timerGen
+	endsAt := room.TimerEndsAt.UTC().Format(time.RFC3339)
+	unlock()
+
+	broadcastToRoom(sender, Message{Type: "timer_started", CallID: msg.CallID, EndsAt: endsAt, Label: msg.Label})
+	time.AfterFunc(duration, func() { expireTimer(msg.CallID, gen) })
+	log.Printf("Host %v started a %v timer in room %s", sender.RemoteAddr(), duration, msg.CallID)
+}
+
+// handleStopTimer lets the room host cancel the running countdown early
+func handleStopTimer(sender *wsConn, msg Message) {
+	room, unlock := lockRoom(msg.CallID)
+	if room == nil {
+		sendError(sender, "Call not found")
+		return
+	}
+	if room.host != sender {
+		unlock()
+		sendError(sender, "not_host")
+		return
+	}
+	if room.TimerEndsAt.IsZero() {
+		unlock()
+		sendError(sender, "no_active_timer")
+		return
+	}
+	label := room.timerLabel
+	room.TimerEndsAt = time.Time{}
+	room.timerLabel = ""
+	room.timerGen++
+	unlock()
+
+	broadcastToRoom(sender, Message{Type: "timer_stopped", CallID: msg.CallID, Label: label})
+	log.Printf("Host %v stopped the timer in room %s", sender.RemoteAddr(), msg.CallID)
+}
+
+// expireTimer tells the room its countdown gen has run out, unless it was stopped or replaced since
+func expireTimer(callID string, gen int) {
+	room, unlock := lockRoom(callID)
+	if room == nil {
+		return
+	}
+	if room.timerGen != gen || room.TimerEndsAt.IsZero() {
+		unlock()
+		return
+	}
+	label := room.timerLabel
+	room.TimerEndsAt = time.Time{}
+	room.timerLabel = ""
+	members := make([]*wsConn, 0, len(room.clients))
+	for member := range room.clients {
+		members = append(members, member)
+	}
+	unlock()
+
+	log.Printf("Timer in room %s expired", callID)
+	for _, member := range members {
+		if err := member.WriteJSON(Message{Type: "timer_expired", CallID: callID, Label: label}); err != nil {
+			log.Printf("Error sending timer_expired to %v: %v", member.RemoteAddr(), err)
+			go cleanupClient(member)
+		}
+	}
+}
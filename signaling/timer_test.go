@@ -0,0 +1,62 @@
+package signaling
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestTimerExpires(t *testing.T) {
+	ts := NewTestServer(t)
+	host, member := ts.Connect(), ts.Connect()
+	ts.startCall(host, member, "timer-expires")
+
+	ts.Send(host, Message{Type: "start_timer", CallID: "timer-expires", DurationSeconds: 1, Label: "break"})
+	for _, conn := range []*websocket.Conn{host, member} {
+		if msg := ts.AssertMessageReceived(conn, "timer_started", testTimeout); msg.EndsAt == "" || msg.Label != "break" {
+			t.Fatalf("timer_started %+v", msg)
+		}
+	}
+
+	late := ts.Connect()
+	ts.Send(late, Message{Type: "join_call", CallID: "timer-expires"})
+	if msg := ts.AssertMessageReceived(late, "call_joined", testTimeout); msg.EndsAt == "" || msg.Label != "break" {
+		t.Fatalf("call_joined %+v carries no timer", msg)
+	}
+
+	for _, conn := range []*websocket.Conn{host, member, late} {
+		if msg := ts.AssertMessageReceived(conn, "timer_expired", 2*time.Second); msg.Label != "break" {
+			t.Fatalf("timer_expired %+v", msg)
+		}
+	}
+	ts.Send(host, Message{Type: "stop_timer", CallID: "timer-expires"})
+	ts.AssertError(host, "no_active_timer")
+}
+
+func TestStoppedTimerDoesNotExpire(t *testing.T) {
+	ts := NewTestServer(t)
+	host, member := ts.Connect(), ts.Connect()
+	ts.startCall(host, member, "timer-stopped")
+
+	ts.Send(host, Message{Type: "start_timer", CallID: "timer-stopped", DurationSeconds: 1})
+	ts.AssertMessageReceived(member, "timer_started", testTimeout)
+	ts.Send(host, Message{Type: "stop_timer", CallID: "timer-stopped"})
+	ts.AssertMessageReceived(member, "timer_stopped", testTimeout)
+	ts.RequireNoMessageOfType(member, "timer_expired", 1500*time.Millisecond)
+}
+
+func TestTimerRules(t *testing.T) {
+	ts := NewTestServer(t)
+	host, member := ts.Connect(), ts.Connect()
+	ts.startCall(host, member, "timer-rules")
+
+	ts.Send(member, Message{Type: "start_timer", CallID: "timer-rules", DurationSeconds: 60})
+	ts.AssertError(member, "not_host")
+	ts.Send(host, Message{Type: "start_timer", CallID: "timer-rules"})
+	ts.AssertError(host, "invalid_timer")
+	ts.Send(host, Message{Type: "start_timer", CallID: "timer-rules", DurationSeconds: int(maxTimerDuration/time.Second) + 1})
+	ts.AssertError(host, "invalid_timer")
+	ts.Send(member, Message{Type: "stop_timer", CallID: "timer-rules"})
+	ts.AssertError(member, "not_host")
+}
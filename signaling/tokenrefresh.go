@@ -0,0 +1,93 @@
+package signaling
+
+import (
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// closeTokenRejected is the WebSocket close code sent when refresh_token fails or the token expires, in the
+// application range
+const closeTokenRejected = 4001
+
+// tokenExpired reports whether the client's token, or the last one it refreshed to, has passed its exp
+func (c *Client) tokenExpired(now time.Time) bool {
+	claims := c.claims()
+	return claims != nil && !claims.ExpiresAt.IsZero() && now.After(claims.ExpiresAt)
+}
+
+// expireToken closes the connection of a client whose token expired without a refresh
+func expireToken(ws *wsConn, client *Client) {
+	log.Printf("Closing client %s: its token expired", client.id)
+	audit("token_expired", "", client.id, nil)
+	closeRejected(ws, "token expired")
+}
+
+// handleRefreshToken replaces the client's token claims with those of a newer token for the same subject,
+// closing the connection when the new token is invalid, for someone else, or no longer grants a room the client is in
+func handleRefreshToken(sender *wsConn, msg Message) {
+	if !allowMessage(sender, "refresh_token", 5, time.Minute) {
+		return
+	}
+	client, ok := getClient(sender)
+	if !ok {
+		return
+	}
+	current := client.claims()
+	if current == nil {
+		sendError(sender, "token_refresh_unavailable")
+		return
+	}
+
+	claims, err := parseToken(msg.NewToken)
+	if err != nil {
+		rejectTokenRefresh(sender, client, "invalid token: "+err.Error())
+		return
+	}
+	if claims.Subject != current.Subject {
+		rejectTokenRefresh(sender, client, "token is for a different sub")
+		return
+	}
+	clientsMu.Lock()
+	callIDs := client.activeCalls()
+	clientsMu.Unlock()
+	for _, callID := range callIDs {
+		if !claims.allows(callID) {
+			rejectTokenRefresh(sender, client, "token does not grant room "+callID)
+			return
+		}
+	}
+
+	client.mu.Lock()
+	client.Auth = claims
+	client.mu.Unlock()
+	audit("token_refreshed", "", client.id, map[string]interface{}{"expiresAt": claims.ExpiresAt})
+	log.Printf("Client %s refreshed its token, now expiring %s", client.id, claims.ExpiresAt.Format(time.RFC3339))
+
+	if err := sender.WriteJSON(Message{
+		Type:      "token_refreshed",
+		ExpiresAt: claims.ExpiresAt.UTC().Format(time.RFC3339),
+	}); err != nil {
+		log.Printf("Error sending token_refreshed to %v: %v", sender.RemoteAddr(), err)
+		go cleanupClient(sender)
+	}
+}
+
+// rejectTokenRefresh closes the connection of a client whose refresh_token failed with closeTokenRejected
+func rejectTokenRefresh(ws *wsConn, client *Client, reason string) {
+	log.Printf("Rejected token refresh from client %s: %s", client.id, reason)
+	audit("token_refresh_rejected", "", client.id, map[string]interface{}{"reason": reason})
+	closeRejected(ws, "token refresh rejected")
+}
+
+// closeRejected closes a connection with closeTokenRejected and text, or just drops a long-poll client
+func closeRejected(ws *wsConn, text string) {
+	if ws.poll == nil {
+		closeMsg := websocket.FormatCloseMessage(closeTokenRejected, text)
+		if err := ws.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(5*time.Second)); err != nil {
+			log.Printf("Error sending close to %v: %v", ws.RemoteAddr(), err)
+		}
+	}
+	go cleanupClient(ws)
+}
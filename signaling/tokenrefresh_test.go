@@ -0,0 +1,150 @@
+package signaling
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+)
+
+// awaitCloseCode reads conn until the server closes it and returns the close code
+func awaitCloseCode(t *testing.T, conn *websocket.Conn) int {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(testTimeout))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			if closeErr, ok := err.(*websocket.CloseError); ok {
+				return closeErr.Code
+			}
+			t.Fatalf("connection failed without a close frame: %v", err)
+		}
+	}
+}
+
+func TestTokenRefreshed(t *testing.T) {
+	ts := NewTestServer(t)
+	conn, peer := ts.ConnectWithClientID("refresh-client"), ts.Connect()
+	ts.startCall(conn, peer, "refresh-call")
+
+	expiresAt := time.Now().Add(2 * time.Hour).Truncate(time.Second)
+	ts.Send(conn, Message{Type: "refresh_token", NewToken: ts.Token("refresh-client", jwt.MapClaims{"rooms": []string{"refresh-call"}, "exp": expiresAt.Unix()})})
+	if msg := ts.AssertMessageReceived(conn, "token_refreshed", testTimeout); msg.ExpiresAt != expiresAt.UTC().Format(time.RFC3339) {
+		t.Fatalf("token_refreshed expiresAt %q, want %s", msg.ExpiresAt, expiresAt.UTC().Format(time.RFC3339))
+	}
+	if claims := findClient("refresh-client").claims(); !claims.ExpiresAt.Equal(expiresAt) || len(claims.Rooms) != 1 {
+		t.Fatalf("client claims %+v after refresh", claims)
+	}
+	ts.Send(conn, Message{Type: "relay", CallID: "refresh-call", Data: "still connected"})
+	ts.AssertMessageReceived(peer, "relay", testTimeout)
+}
+
+func TestTokenRefreshRejected(t *testing.T) {
+	tests := []struct {
+		name  string
+		token func(ts *TestServer) string
+	}{
+		{"malformed", func(*TestServer) string { return "not-a-jwt" }},
+		{"expired", func(ts *TestServer) string {
+			return ts.Token("refresh-rejected", jwt.MapClaims{"rooms": "*", "exp": time.Now().Add(-time.Minute).Unix()})
+		}},
+		{"wrong signature", func(*TestServer) string {
+			token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+				"sub": "refresh-rejected", "rooms": "*", "exp": time.Now().Add(time.Hour).Unix(),
+			}).SignedString([]byte("some other secret"))
+			return token
+		}},
+		{"different sub", func(ts *TestServer) string { return ts.Token("refresh-impostor", jwt.MapClaims{"rooms": "*"}) }},
+		{"room no longer granted", func(ts *TestServer) string {
+			return ts.Token("refresh-rejected", jwt.MapClaims{"rooms": []string{"some-other-call"}})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := NewTestServer(t)
+			// dialled without the test server's reader, so the close frame reaches the test
+			conn, _, err := websocket.DefaultDialer.Dial(ts.wsURL(url.Values{"token": {ts.Token("refresh-rejected", jwt.MapClaims{"rooms": "*"})}}), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { conn.Close() })
+			if err := conn.WriteJSON(Message{Type: "offer", CallID: "refresh-rejected-call", Data: sdpData("offer")}); err != nil {
+				t.Fatal(err)
+			}
+			ts.waitForRoom("refresh-rejected-call", 1)
+
+			if err := conn.WriteJSON(Message{Type: "refresh_token", NewToken: tt.token(ts)}); err != nil {
+				t.Fatal(err)
+			}
+			if code := awaitCloseCode(t, conn); code != closeTokenRejected {
+				t.Fatalf("closed with %d, want %d", code, closeTokenRejected)
+			}
+			for deadline := time.Now().Add(testTimeout); findClient("refresh-rejected") != nil; time.Sleep(5 * time.Millisecond) {
+				if time.Now().After(deadline) {
+					t.Fatal("rejected client never cleaned up")
+				}
+			}
+		})
+	}
+}
+
+func TestTokenRefreshRateLimited(t *testing.T) {
+	ts := NewTestServer(t)
+	conn := ts.ConnectWithClientID("refresh-often")
+	for i := 0; i < 5; i++ {
+		ts.Send(conn, Message{Type: "refresh_token", NewToken: ts.Token("refresh-often", jwt.MapClaims{"rooms": "*"})})
+		ts.AssertMessageReceived(conn, "token_refreshed", testTimeout)
+	}
+	ts.Send(conn, Message{Type: "refresh_token", NewToken: ts.Token("refresh-often", jwt.MapClaims{"rooms": "*"})})
+	ts.AssertError(conn, "rate_limited")
+}
+
+func TestTokenRefreshWithoutAuthentication(t *testing.T) {
+	ts := NewTestServer(t)
+	jwtSecret = ""
+	t.Cleanup(func() { jwtSecret = testJWTSecret })
+	conn := ts.Dial(url.Values{})
+	ts.Send(conn, Message{Type: "refresh_token", NewToken: "anything"})
+	ts.AssertError(conn, "token_refresh_unavailable")
+}
+
+func TestExpiredTokenClosesConnection(t *testing.T) {
+	ts := NewTestServer(t)
+	exp := time.Now().Add(time.Second).Unix()
+	conn, _, err := websocket.DefaultDialer.Dial(ts.wsURL(url.Values{"token": {ts.Token("expiring-client", jwt.MapClaims{"rooms": "*", "exp": exp})}}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	// the capabilities greeting follows registration
+	if _, _, err := conn.ReadMessage(); err != nil {
+		t.Fatal(err)
+	}
+
+	cleanupPass()
+	if findClient("expiring-client") == nil {
+		t.Fatal("client closed before its token expired")
+	}
+	time.Sleep(time.Until(time.Unix(exp, 0)) + 10*time.Millisecond)
+	cleanupPass()
+	if code := awaitCloseCode(t, conn); code != closeTokenRejected {
+		t.Fatalf("close code %d once the token expired, want %d", code, closeTokenRejected)
+	}
+}
+
+func TestRefreshedTokenKeepsConnectionOpen(t *testing.T) {
+	ts := NewTestServer(t)
+	exp := time.Now().Add(time.Second).Unix()
+	conn := ts.Dial(url.Values{"token": {ts.Token("refreshing-client", jwt.MapClaims{"rooms": "*", "exp": exp})}})
+	ts.Send(conn, Message{Type: "refresh_token", NewToken: ts.Token("refreshing-client", jwt.MapClaims{"rooms": "*"})})
+	ts.AssertMessageReceived(conn, "token_refreshed", testTimeout)
+
+	time.Sleep(time.Until(time.Unix(exp, 0)) + 10*time.Millisecond)
+	cleanupPass()
+	ts.Send(conn, Message{Type: "echo", Data: "still here"})
+	ts.AssertMessageReceived(conn, "echo_reply", testTimeout)
+	if findClient("refreshing-client") == nil {
+		t.Fatal("client closed after refreshing its token")
+	}
+}
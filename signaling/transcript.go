@@ -0,0 +1,100 @@
+package signaling
+
+import (
+	"log"
+	"net/http"
+	"time"
+	"unicode/utf8"
+)
+
+// maxTranscriptSegments bounds how much of a meeting one room's transcript keeps
+const maxTranscriptSegments = 10000
+
+// TranscriptSegment is one finished stretch of speech from a transcribing bot
+type TranscriptSegment struct {
+	Speaker string `json:"speaker"`
+	Text    string `json:"text"`
+	StartMs int64  `json:"startMs"`
+	EndMs   int64  `json:"endMs"`
+	BotID   string `json:"botId"`
+}
+
+// RoomTranscript is the response of GET /api/v1/rooms/{callId}/transcript
+type RoomTranscript struct {
+	CallID   string              `json:"callId"`
+	Segments []TranscriptSegment `json:"segments"`
+}
+
+// isBot reports whether the client declared the bot platform and, when connection tokens are in use, holds the bot role
+func (c *Client) isBot() bool {
+	if c.platformName() != "bot" {
+		return false
+	}
+	auth := c.claims()
+	return auth == nil || auth.Role == "bot"
+}
+
+// handleTranscriptSegment lets a transcribing bot add a final segment to the room's transcript and relay it to the room
+func handleTranscriptSegment(sender *wsConn, msg Message) {
+	client, ok := getClient(sender)
+	if !ok {
+		return
+	}
+	if !client.isBot() {
+		sendError(sender, "not_bot")
+		return
+	}
+	if msg.Text == "" || utf8.RuneCountInString(msg.Text) > 2000 || len(msg.Speaker) > 64 ||
+		msg.StartMs < 0 || msg.EndMs < msg.StartMs {
+		sendError(sender, "invalid_transcript_segment")
+		return
+	}
+	if !allowMessage(sender, "transcript_segment", 10, time.Second) {
+		return
+	}
+	room, unlock := lockRoom(msg.CallID)
+	if room == nil || !room.clients[sender] {
+		if room != nil {
+			unlock()
+		}
+		sendError(sender, "not_in_call")
+		return
+	}
+	if len(room.Transcript) >= maxTranscriptSegments {
+		unlock()
+		sendError(sender, "transcript_full")
+		return
+	}
+	room.Transcript = append(room.Transcript, TranscriptSegment{
+		Speaker: msg.Speaker,
+		Text:    msg.Text,
+		StartMs: msg.StartMs,
+		EndMs:   msg.EndMs,
+		BotID:   client.id,
+	})
+	unlock()
+
+	relayToRoom(sender, Message{
+		Type:    "transcript_segment",
+		CallID:  msg.CallID,
+		From:    client.id,
+		Speaker: msg.Speaker,
+		Text:    msg.Text,
+		StartMs: msg.StartMs,
+		EndMs:   msg.EndMs,
+	})
+	log.Printf("Bot %s added transcript segment %d-%dms in room %s", client.id, msg.StartMs, msg.EndMs, msg.CallID)
+}
+
+// handleGetTranscript returns a room's transcript so far
+func handleGetTranscript(w http.ResponseWriter, r *http.Request) {
+	callID := r.PathValue("callId")
+	room, unlock := rlockRoom(callID)
+	if room == nil {
+		http.Error(w, "Call not found", http.StatusNotFound)
+		return
+	}
+	transcript := RoomTranscript{CallID: callID, Segments: append([]TranscriptSegment{}, room.Transcript...)}
+	unlock()
+	writeJSONResponse(w, http.StatusOK, transcript)
+}
@@ -0,0 +1,77 @@
+package signaling
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+)
+
+// connectBot connects a client with the bot role and platform, then joins it to callID
+func (ts *TestServer) connectBot(id, callID string) *websocket.Conn {
+	ts.t.Helper()
+	bot := ts.Dial(url.Values{"token": {ts.Token(id, jwt.MapClaims{"rooms": "*", "role": "bot"})}})
+	ts.Send(bot, Message{Type: "client_info", Platform: "bot"})
+	ts.Send(bot, Message{Type: "join_call", CallID: callID})
+	ts.AssertMessageReceived(bot, "call_joined", testTimeout)
+	return bot
+}
+
+func TestTranscriptCollected(t *testing.T) {
+	ts := NewTestServer(t)
+	setAdminToken(t, "transcript-admin")
+	caller, callee := ts.Connect(), ts.Connect()
+	ts.startCall(caller, callee, "transcript-call")
+	bot := ts.connectBot("transcript-bot", "transcript-call")
+
+	ts.Send(bot, Message{Type: "transcript_segment", CallID: "transcript-call", Speaker: "caller", Text: "Shall we start?", StartMs: 1000, EndMs: 3000})
+	for _, conn := range []*websocket.Conn{caller, callee} {
+		if msg := ts.AssertMessageReceived(conn, "transcript_segment", testTimeout); msg.From != "transcript-bot" || msg.Text != "Shall we start?" || msg.EndMs != 3000 {
+			t.Fatalf("transcript_segment %+v", msg)
+		}
+	}
+
+	resp := ts.adminRequest("GET", "/api/v1/rooms/transcript-call/transcript", "transcript-admin")
+	var transcript RoomTranscript
+	if err := json.NewDecoder(resp.Body).Decode(&transcript); err != nil {
+		t.Fatal(err)
+	}
+	want := TranscriptSegment{Speaker: "caller", Text: "Shall we start?", StartMs: 1000, EndMs: 3000, BotID: "transcript-bot"}
+	if len(transcript.Segments) != 1 || transcript.Segments[0] != want {
+		t.Fatalf("transcript %+v", transcript)
+	}
+	if resp := ts.adminRequest("GET", "/api/v1/rooms/transcript-call/transcript", ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("transcript without the admin token answered %d", resp.StatusCode)
+	}
+}
+
+func TestTranscriptSegmentRules(t *testing.T) {
+	ts := NewTestServer(t)
+	caller, callee := ts.Connect(), ts.Connect()
+	ts.startCall(caller, callee, "transcript-rules")
+	bot := ts.connectBot("transcript-rules-bot", "transcript-rules")
+
+	// the bot platform without the bot role is not enough
+	ts.Send(caller, Message{Type: "client_info", Platform: "bot"})
+	ts.Send(caller, Message{Type: "transcript_segment", CallID: "transcript-rules", Text: "hello", EndMs: 10})
+	ts.AssertError(caller, "not_bot")
+
+	for _, msg := range []Message{
+		{Type: "transcript_segment", CallID: "transcript-rules", EndMs: 10},
+		{Type: "transcript_segment", CallID: "transcript-rules", Text: strings.Repeat("a", 2001)},
+		{Type: "transcript_segment", CallID: "transcript-rules", Text: "hello", Speaker: strings.Repeat("s", 65)},
+		{Type: "transcript_segment", CallID: "transcript-rules", Text: "hello", StartMs: 20, EndMs: 10},
+		{Type: "transcript_segment", CallID: "transcript-rules", Text: "hello", StartMs: -1},
+	} {
+		ts.Send(bot, msg)
+		ts.AssertError(bot, "invalid_transcript_segment")
+	}
+	ts.Send(bot, Message{Type: "transcript_segment", CallID: "transcript-elsewhere", Text: "hello"})
+	ts.AssertError(bot, "not_in_call")
+	ts.RequireNoMessageOfType(callee, "transcript_segment", 50*time.Millisecond)
+}
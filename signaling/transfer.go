@@ -0,0 +1,71 @@
+package signaling
+
+import (
+	"errors"
+	"log"
+	"regexp"
+	"time"
+)
+
+// sipBridgeURL receives external transfer requests, transfers are disabled when it is empty
+var sipBridgeURL = envString("SIP_BRIDGE_URL", "")
+
+var (
+	sipURIPattern = regexp.MustCompile(`^sip:([^@\s;]+@)?[A-Za-z0-9][A-Za-z0-9.-]*(:[0-9]{1,5})?(;\S*)?$`)
+	telURIPattern = regexp.MustCompile(`^tel:\+?[0-9][0-9().-]*(;\S*)?$`)
+)
+
+// externalTransfer is the payload POSTed to the SIP bridge
+type externalTransfer struct {
+	CallID       string    `json:"callId"`
+	URI          string    `json:"uri"`
+	RequestedBy  string    `json:"requestedBy"`
+	Participants []string  `json:"participants"`
+	RequestedAt  time.Time `json:"requestedAt"`
+}
+
+// validateTransferURI checks that uri is a sip: or tel: URI
+func validateTransferURI(uri string) error {
+	if len(uri) > 256 {
+		return errors.New("uri too long")
+	}
+	if sipURIPattern.MatchString(uri) || telURIPattern.MatchString(uri) {
+		return nil
+	}
+	return errors.New("uri must be a sip: or tel: URI")
+}
+
+// handleTransferExternal hands a call over to the SIP bridge for PSTN/SIP delivery
+func handleTransferExternal(sender *wsConn, msg Message) {
+	if sipBridgeURL == "" {
+		sendError(sender, "transfer_unavailable")
+		return
+	}
+	if err := validateTransferURI(msg.URI); err != nil {
+		log.Printf("Rejected transfer of call %s from %v: %v", msg.CallID, sender.RemoteAddr(), err)
+		sendError(sender, "invalid_uri")
+		return
+	}
+
+	members, ok := roomMembers(msg.CallID, sender)
+	if !ok {
+		sendError(sender, "Call not found")
+		return
+	}
+	participants := make([]string, 0, len(members))
+	for _, member := range members {
+		if id := clientID(member); id != "" {
+			participants = append(participants, id)
+		}
+	}
+
+	broadcastToRoom(sender, Message{Type: "transfer_initiated", CallID: msg.CallID, URI: msg.URI})
+	postWebhook(sipBridgeURL, externalTransfer{
+		CallID:       msg.CallID,
+		URI:          msg.URI,
+		RequestedBy:  clientID(sender),
+		Participants: participants,
+		RequestedAt:  time.Now(),
+	})
+	log.Printf("Call %s transferred to %s by %v", msg.CallID, msg.URI, sender.RemoteAddr())
+}
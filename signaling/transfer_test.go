@@ -0,0 +1,112 @@
+package signaling
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestValidateTransferURI(t *testing.T) {
+	tests := []struct {
+		uri   string
+		valid bool
+	}{
+		{"sip:+15551234567@pbx.example.com", true},
+		{"sip:alice@pbx.example.com:5060", true},
+		{"sip:pbx.example.com", true},
+		{"sip:bob@10.0.0.1;transport=tls", true},
+		{"tel:+1-555-123-4567", true},
+		{"tel:5551234;phone-context=example.com", true},
+		{"", false},
+		{"sip:", false},
+		{"sip:@pbx.example.com", false},
+		{"sip:alice@-pbx.example.com", false},
+		{"sip:alice@pbx.example.com:506000", false},
+		{"sip:alice bob@pbx.example.com", false},
+		{"tel:", false},
+		{"tel:call-me", false},
+		{"https://pbx.example.com", false},
+		{"SIP:alice@pbx.example.com", false},
+		{"sip:" + strings.Repeat("a", 240) + "@pbx.example.com", false},
+	}
+	for _, tt := range tests {
+		if err := validateTransferURI(tt.uri); (err == nil) != tt.valid {
+			t.Errorf("validateTransferURI(%q) = %v, want valid %v", tt.uri, err, tt.valid)
+		}
+	}
+}
+
+// setSIPBridge points transfers at a test bridge that hands every request it receives to the returned channel
+func setSIPBridge(t *testing.T) <-chan externalTransfer {
+	transfers := make(chan externalTransfer, 1)
+	bridge := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var transfer externalTransfer
+		if err := json.NewDecoder(r.Body).Decode(&transfer); err != nil {
+			t.Errorf("decoding transfer request: %v", err)
+		}
+		transfers <- transfer
+	}))
+	t.Cleanup(bridge.Close)
+	previous := sipBridgeURL
+	sipBridgeURL = bridge.URL
+	t.Cleanup(func() { sipBridgeURL = previous })
+	return transfers
+}
+
+func TestTransferExternalNotifiesRoomAndBridge(t *testing.T) {
+	transfers := setSIPBridge(t)
+	ts := NewTestServer(t)
+	caller, callee := ts.ConnectWithClientID("transfer-caller"), ts.ConnectWithClientID("transfer-callee")
+	ts.startCall(caller, callee, "transfer-call")
+
+	ts.Send(caller, Message{Type: "transfer_external", CallID: "transfer-call", URI: "sip:+15551234567@pbx.example.com"})
+	for _, conn := range []*websocket.Conn{caller, callee} {
+		if msg := ts.AssertMessageReceived(conn, "transfer_initiated", testTimeout); msg.URI != "sip:+15551234567@pbx.example.com" {
+			t.Fatalf("transfer_initiated uri %q", msg.URI)
+		}
+	}
+
+	select {
+	case transfer := <-transfers:
+		sort.Strings(transfer.Participants)
+		if transfer.CallID != "transfer-call" || transfer.URI != "sip:+15551234567@pbx.example.com" || transfer.RequestedBy != "transfer-caller" {
+			t.Fatalf("bridge got %+v", transfer)
+		}
+		if strings.Join(transfer.Participants, ",") != "transfer-callee,transfer-caller" {
+			t.Fatalf("bridge got participants %v", transfer.Participants)
+		}
+		if transfer.RequestedAt.IsZero() {
+			t.Fatal("bridge got no requestedAt")
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("bridge never received the transfer")
+	}
+}
+
+func TestTransferExternalRejections(t *testing.T) {
+	ts := NewTestServer(t)
+	caller, callee, outsider := ts.Connect(), ts.Connect(), ts.Connect()
+	ts.startCall(caller, callee, "transfer-reject")
+
+	ts.Send(caller, Message{Type: "transfer_external", CallID: "transfer-reject", URI: "sip:alice@pbx.example.com"})
+	ts.AssertError(caller, "transfer_unavailable")
+
+	transfers := setSIPBridge(t)
+	ts.Send(caller, Message{Type: "transfer_external", CallID: "transfer-reject", URI: "https://pbx.example.com"})
+	ts.AssertError(caller, "invalid_uri")
+	ts.Send(outsider, Message{Type: "transfer_external", CallID: "transfer-reject", URI: "sip:alice@pbx.example.com"})
+	ts.AssertError(outsider, "Call not found")
+
+	ts.RequireNoMessageOfType(callee, "transfer_initiated", 100*time.Millisecond)
+	select {
+	case transfer := <-transfers:
+		t.Fatalf("bridge received rejected transfer %+v", transfer)
+	default:
+	}
+}
@@ -0,0 +1,74 @@
+package signaling
+
+import (
+	"log"
+	"strconv"
+	"strings"
+)
+
+// Build information, set at build time with
+// -ldflags "-X vc_server/signaling.Version=1.2.3 -X vc_server/signaling.BuildCommit=abc123 -X vc_server/signaling.BuildTime=2024-01-01"
+var (
+	Version     = "dev"
+	BuildCommit = ""
+	BuildTime   = ""
+)
+
+// minClientVersion is the oldest clientVersion register accepts without answering upgrade_required; empty disables the check
+var minClientVersion = envString("MIN_CLIENT_VERSION", "")
+
+// parseVersion splits a version such as "v1.2.3-beta" into its numeric parts, ignoring any pre-release suffix
+func parseVersion(v string) ([]int, bool) {
+	v = strings.TrimPrefix(v, "v")
+	v, _, _ = strings.Cut(v, "-")
+	if v == "" {
+		return nil, false
+	}
+	var parts []int
+	for _, field := range strings.Split(v, ".") {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		parts = append(parts, n)
+	}
+	return parts, true
+}
+
+// versionOlder reports whether version v is older than min; versions that do not parse count as older
+func versionOlder(v, min string) bool {
+	have, ok := parseVersion(v)
+	if !ok {
+		return true
+	}
+	want, ok := parseVersion(min)
+	if !ok {
+		return false
+	}
+	for i := 0; i < len(have) || i < len(want); i++ {
+		var h, w int
+		if i < len(have) {
+			h = have[i]
+		}
+		if i < len(want) {
+			w = want[i]
+		}
+		if h != w {
+			return h < w
+		}
+	}
+	return false
+}
+
+// sendServerVersion tells a newly connected client which server build it is talking to
+func sendServerVersion(ws *wsConn) {
+	if err := ws.WriteJSON(Message{
+		Type:        "server_version",
+		Version:     Version,
+		BuildCommit: BuildCommit,
+		BuildTime:   BuildTime,
+	}); err != nil {
+		log.Printf("Error sending server_version to %v: %v", ws.RemoteAddr(), err)
+		go cleanupClient(ws)
+	}
+}
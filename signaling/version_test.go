@@ -0,0 +1,48 @@
+package signaling
+
+import (
+	"testing"
+	"time"
+)
+
+// setMinClientVersion replaces MIN_CLIENT_VERSION for the rest of the test
+func setMinClientVersion(t *testing.T, min string) {
+	previous := minClientVersion
+	minClientVersion = min
+	t.Cleanup(func() { minClientVersion = previous })
+}
+
+func TestVersionOlder(t *testing.T) {
+	for _, tt := range []struct {
+		version, min string
+		older        bool
+	}{
+		{"1.2.3", "1.2.3", false},
+		{"1.2.2", "1.2.3", true},
+		{"1.10.0", "1.9.0", false},
+		{"v2.0", "1.9.9", false},
+		{"1.2", "1.2.0", false},
+		{"1.2.3-beta", "1.2.3", false},
+		{"1.x", "1.0.0", true},
+		{"", "1.0.0", true},
+	} {
+		if got := versionOlder(tt.version, tt.min); got != tt.older {
+			t.Errorf("versionOlder(%q, %q) = %t, want %t", tt.version, tt.min, got, tt.older)
+		}
+	}
+}
+
+func TestRegisterOldClientVersion(t *testing.T) {
+	ts := NewTestServer(t)
+	setMinClientVersion(t, "2.1.0")
+	old, current, unversioned := ts.Connect(), ts.Connect(), ts.Connect()
+
+	ts.Send(old, Message{Type: "register", ClientVersion: "2.0.9"})
+	if msg := ts.AssertMessageReceived(old, "upgrade_required", testTimeout); msg.MinClientVersion != "2.1.0" {
+		t.Fatalf("upgrade_required %+v", msg)
+	}
+	ts.Send(current, Message{Type: "register", ClientVersion: "v2.1.0"})
+	ts.RequireNoMessageOfType(current, "upgrade_required", 50*time.Millisecond)
+	ts.Send(unversioned, Message{Type: "register"})
+	ts.RequireNoMessageOfType(unversioned, "upgrade_required", 50*time.Millisecond)
+}
@@ -0,0 +1,340 @@
+// Package videochattesting runs a signaling server for tests of programs that talk to or embed it
+package videochattesting
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+
+	"vc_server/signaling"
+)
+
+// jwtSecret signs the connection tokens test clients connect with, so tests pick their client IDs
+const jwtSecret = "videochattesting-secret"
+
+// Timeout is how long Connect, StartCall, WaitForRoom and AssertError wait for the server
+const Timeout = 2 * time.Second
+
+// sdp is a minimal session description the server accepts
+const sdp = "v=0\r\no=- 4611731400430051336 2 IN IP4 127.0.0.1\r\ns=-\r\nt=0 0\r\n" +
+	"m=audio 9 UDP/TLS/RTP/SAVPF 111\r\nc=IN IP4 0.0.0.0\r\na=rtpmap:111 opus/48000/2\r\n"
+
+// SDPData returns the Data of an offer or answer, as kind says, carrying a session description the server accepts
+func SDPData(kind string) string {
+	data, _ := json.Marshal(map[string]string{"type": kind, "sdp": sdp})
+	return string(data)
+}
+
+// TestServer runs the signaling server on an httptest.Server and reads every connection it opens in the background,
+// so tests can wait for messages with a timeout without breaking the connection
+type TestServer struct {
+	t         testing.TB
+	signaling *signaling.Server
+	server    *httptest.Server
+
+	mu     sync.Mutex
+	inbox  map[*websocket.Conn]*inbox
+	serial int
+}
+
+// NewTestServer starts a signaling server for t with connection tokens turned on; it is closed,
+// along with every connection opened through it, when the test ends.
+// Every server shares the process's client and room state, so tests using it should not run in parallel.
+func NewTestServer(t testing.TB) *TestServer {
+	t.Helper()
+	server := signaling.NewServer()
+	previousSecret := server.SetJWTSecret(jwtSecret)
+
+	ts := &TestServer{t: t, signaling: server, inbox: make(map[*websocket.Conn]*inbox)}
+	ts.server = httptest.NewServer(server.Handler(nil))
+	t.Cleanup(func() {
+		ts.mu.Lock()
+		inboxes := make([]*inbox, 0, len(ts.inbox))
+		for conn, in := range ts.inbox {
+			conn.Close()
+			inboxes = append(inboxes, in)
+		}
+		ts.mu.Unlock()
+		for _, in := range inboxes {
+			<-in.done
+		}
+		ts.server.Close()
+		server.SetJWTSecret(previousSecret)
+	})
+	return ts
+}
+
+// URL returns the server's address with an http scheme
+func (ts *TestServer) URL() string {
+	return ts.server.URL
+}
+
+// Connect opens a WebSocket connection as a new client with a generated ID
+func (ts *TestServer) Connect() *websocket.Conn {
+	ts.t.Helper()
+	return ts.ConnectWithClientID(ts.nextClientID())
+}
+
+// nextClientID returns a client ID no other connection of this test has used
+func (ts *TestServer) nextClientID() string {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.serial++
+	return fmt.Sprintf("%s-%d", strings.ReplaceAll(ts.t.Name(), "/", "-"), ts.serial)
+}
+
+// ConnectWithClientID opens a WebSocket connection as the client id, with a token granting every room
+func (ts *TestServer) ConnectWithClientID(id string) *websocket.Conn {
+	ts.t.Helper()
+	return ts.Dial(url.Values{"token": {ts.Token(id, jwt.MapClaims{"rooms": "*"})}})
+}
+
+// Token signs a connection token for the client id with any extra claims, such as rooms or role
+func (ts *TestServer) Token(id string, claims jwt.MapClaims) string {
+	ts.t.Helper()
+	all := jwt.MapClaims{"sub": id, "exp": time.Now().Add(time.Hour).Unix()}
+	for k, v := range claims {
+		all[k] = v
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, all).SignedString([]byte(jwtSecret))
+	if err != nil {
+		ts.t.Fatalf("signing token for %s: %v", id, err)
+	}
+	return token
+}
+
+// Dial opens a WebSocket connection to /ws with query and waits until the server has registered it
+func (ts *TestServer) Dial(query url.Values) *websocket.Conn {
+	ts.t.Helper()
+	wsURL := "ws" + strings.TrimPrefix(ts.server.URL, "http") + "/ws?" + query.Encode()
+	conn, resp, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		ts.t.Fatalf("dialing signaling server: %v (status %d)", err, status)
+	}
+	in := newInbox()
+	ts.mu.Lock()
+	ts.inbox[conn] = in
+	ts.mu.Unlock()
+	go in.read(conn)
+	ts.AssertMessageReceived(conn, "capabilities", Timeout)
+	return conn
+}
+
+// StartCall has caller create callID with an offer and callee accept it, returning once callee has the offer
+func (ts *TestServer) StartCall(caller, callee *websocket.Conn, callID string) {
+	ts.t.Helper()
+	ts.Send(caller, signaling.Message{Type: "offer", CallID: callID, Data: SDPData("offer")})
+	ts.WaitForRoom(callID, 1)
+	ts.Send(callee, signaling.Message{Type: "accept_call", CallID: callID})
+	ts.AssertMessageReceived(callee, "offer", Timeout)
+}
+
+// WaitForRoom waits until the room for callID has at least members clients
+func (ts *TestServer) WaitForRoom(callID string, members int) {
+	ts.t.Helper()
+	for deadline := time.Now().Add(Timeout); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if room, ok := ts.signaling.GetRoom(callID); ok && len(room.ClientIDs) >= members {
+			return
+		}
+	}
+	ts.t.Fatalf("room %s never had %d clients", callID, members)
+}
+
+// Send writes msg to the server on conn
+func (ts *TestServer) Send(conn *websocket.Conn, msg signaling.Message) {
+	ts.t.Helper()
+	if err := conn.WriteJSON(msg); err != nil {
+		ts.t.Fatalf("sending %s: %v", msg.Type, err)
+	}
+}
+
+// AssertMessageReceived waits up to timeout for a message of msgType on conn, skipping any others, and returns it
+func (ts *TestServer) AssertMessageReceived(conn *websocket.Conn, msgType string, timeout time.Duration) signaling.Message {
+	ts.t.Helper()
+	in := ts.inboxFor(conn)
+	deadline := time.After(timeout)
+	var skipped []string
+	for {
+		msg, ok, closed := in.next(deadline)
+		switch {
+		case ok && msg.Type == msgType:
+			return msg
+		case ok:
+			skipped = append(skipped, msg.Type+describeError(msg))
+		case closed:
+			ts.t.Fatalf("connection closed waiting for %s, got %v", msgType, skipped)
+		default:
+			ts.t.Fatalf("no %s within %v, got %v", msgType, timeout, skipped)
+		}
+	}
+}
+
+// AssertError waits for an error message on conn and checks its code
+func (ts *TestServer) AssertError(conn *websocket.Conn, code string) {
+	ts.t.Helper()
+	if msg := ts.AssertMessageReceived(conn, "error", Timeout); msg.Code != code {
+		ts.t.Fatalf("got error %q, want %q", msg.Code, code)
+	}
+}
+
+// RequireNoMessage fails if conn receives anything within duration
+func (ts *TestServer) RequireNoMessage(conn *websocket.Conn, duration time.Duration) {
+	ts.t.Helper()
+	ts.RequireNoMessageOfType(conn, "", duration)
+}
+
+// RequireNoMessageOfType fails if conn receives a message of msgType, or of any type if msgType is empty, within duration
+func (ts *TestServer) RequireNoMessageOfType(conn *websocket.Conn, msgType string, duration time.Duration) {
+	ts.t.Helper()
+	in := ts.inboxFor(conn)
+	deadline := time.After(duration)
+	for {
+		msg, ok, _ := in.next(deadline)
+		if !ok {
+			return
+		}
+		if msgType == "" || msg.Type == msgType {
+			ts.t.Fatalf("unexpected %s%s", msg.Type, describeError(msg))
+		}
+	}
+}
+
+// Drain discards every message conn has received so far
+func (ts *TestServer) Drain(conn *websocket.Conn) {
+	ts.t.Helper()
+	ts.inboxFor(conn).drain()
+}
+
+// inboxFor returns the messages received on conn, which must have been opened by ts
+func (ts *TestServer) inboxFor(conn *websocket.Conn) *inbox {
+	ts.t.Helper()
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	in, ok := ts.inbox[conn]
+	if !ok {
+		ts.t.Fatal("connection was not opened by the test server")
+	}
+	return in
+}
+
+// describeError adds the code of an error message to its type in failure output
+func describeError(msg signaling.Message) string {
+	if msg.Type == "error" {
+		return "(" + msg.Code + ")"
+	}
+	return ""
+}
+
+// inbox queues the messages read from one connection; it never blocks the reader, so a test that ignores
+// a connection cannot make the server's send queue for it back up
+type inbox struct {
+	mu       sync.Mutex
+	messages []signaling.Message
+	closed   bool
+	arrived  chan struct{} // signalled after messages are queued or the connection fails
+	done     chan struct{} // closed once the reader has stopped
+}
+
+func newInbox() *inbox {
+	return &inbox{arrived: make(chan struct{}, 1), done: make(chan struct{})}
+}
+
+// read queues every message received on conn, unpacking batched frames, until conn fails; user_count,
+// which every connect and disconnect broadcasts to every client, is dropped
+func (in *inbox) read(conn *websocket.Conn) {
+	defer close(in.done)
+	defer in.close()
+	for {
+		_, frame, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var batch []signaling.Message
+		if len(frame) > 0 && frame[0] == '[' {
+			if json.Unmarshal(frame, &batch) != nil {
+				continue
+			}
+		} else {
+			var msg signaling.Message
+			if json.Unmarshal(frame, &msg) != nil {
+				continue
+			}
+			batch = []signaling.Message{msg}
+		}
+		kept := batch[:0]
+		for _, msg := range batch {
+			if msg.Type != "user_count" {
+				kept = append(kept, msg)
+			}
+		}
+		if len(kept) > 0 {
+			in.push(kept...)
+		}
+	}
+}
+
+// push queues msgs for the test to receive
+func (in *inbox) push(msgs ...signaling.Message) {
+	in.mu.Lock()
+	in.messages = append(in.messages, msgs...)
+	in.mu.Unlock()
+	in.signal()
+}
+
+// close records that the connection failed once every queued message has been received
+func (in *inbox) close() {
+	in.mu.Lock()
+	in.closed = true
+	in.mu.Unlock()
+	in.signal()
+}
+
+func (in *inbox) signal() {
+	select {
+	case in.arrived <- struct{}{}:
+	default:
+	}
+}
+
+// next returns the oldest queued message, waiting until deadline fires; ok is false if the connection
+// failed or the deadline passed first, with closed telling which
+func (in *inbox) next(deadline <-chan time.Time) (msg signaling.Message, ok, closed bool) {
+	for {
+		in.mu.Lock()
+		if len(in.messages) > 0 {
+			msg = in.messages[0]
+			in.messages = in.messages[1:]
+			in.mu.Unlock()
+			return msg, true, false
+		}
+		closed = in.closed
+		in.mu.Unlock()
+		if closed {
+			return signaling.Message{}, false, true
+		}
+		select {
+		case <-in.arrived:
+		case <-deadline:
+			return signaling.Message{}, false, false
+		}
+	}
+}
+
+// drain discards every queued message
+func (in *inbox) drain() {
+	in.mu.Lock()
+	in.messages = nil
+	in.mu.Unlock()
+}
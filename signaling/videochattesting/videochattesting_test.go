@@ -0,0 +1,107 @@
+package videochattesting_test
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"vc_server/signaling"
+	"vc_server/signaling/videochattesting"
+)
+
+func TestConnectedClientsCanCall(t *testing.T) {
+	ts := videochattesting.NewTestServer(t)
+	caller, callee := ts.Connect(), ts.Connect()
+	ts.StartCall(caller, callee, "helper-call")
+
+	ts.Send(callee, signaling.Message{Type: "answer", CallID: "helper-call", Data: videochattesting.SDPData("answer")})
+	if msg := ts.AssertMessageReceived(caller, "answer", videochattesting.Timeout); msg.CallID != "helper-call" {
+		t.Fatalf("caller got answer %+v", msg)
+	}
+}
+
+func TestConnectWithClientIDNamesTheClient(t *testing.T) {
+	ts := videochattesting.NewTestServer(t)
+	host, named := ts.Connect(), ts.ConnectWithClientID("helper-named")
+	ts.StartCall(host, named, "helper-named-call")
+
+	late := ts.Connect()
+	ts.Send(late, signaling.Message{Type: "join_call", CallID: "helper-named-call"})
+	joined := ts.AssertMessageReceived(late, "call_joined", videochattesting.Timeout)
+	if !slices.ContainsFunc(joined.Peers, func(p signaling.PeerInfo) bool { return p.ClientID == "helper-named" }) {
+		t.Fatalf("call_joined peers %+v", joined.Peers)
+	}
+}
+
+func TestAssertMessageReceivedSkipsOtherTypes(t *testing.T) {
+	ts := videochattesting.NewTestServer(t)
+	conn := ts.Connect()
+	ts.Send(conn, signaling.Message{Type: "offer", CallID: "helper-skip", Data: "not sdp"})
+	ts.Send(conn, signaling.Message{Type: "room_exists", CallID: "helper-skip"})
+	msg := ts.AssertMessageReceived(conn, "room_exists_response", videochattesting.Timeout)
+	if msg.Exists == nil || *msg.Exists {
+		t.Fatalf("room_exists_response %+v", msg)
+	}
+	ts.RequireNoMessage(conn, 50*time.Millisecond)
+}
+
+func TestAssertErrorChecksCode(t *testing.T) {
+	ts := videochattesting.NewTestServer(t)
+	conn := ts.Connect()
+	ts.Send(conn, signaling.Message{Type: "offer", CallID: "helper-error", Data: "not sdp"})
+	ts.AssertError(conn, "invalid_sdp")
+}
+
+func TestRequireNoMessageOnQuietConnection(t *testing.T) {
+	ts := videochattesting.NewTestServer(t)
+	ts.RequireNoMessage(ts.Connect(), 50*time.Millisecond)
+}
+
+func TestRequireNoMessageOfTypeIgnoresOthers(t *testing.T) {
+	ts := videochattesting.NewTestServer(t)
+	conn := ts.Connect()
+	ts.Send(conn, signaling.Message{Type: "room_exists", CallID: "helper-other"})
+	ts.RequireNoMessageOfType(conn, "offer", 50*time.Millisecond)
+}
+
+func TestDrainDiscardsReceived(t *testing.T) {
+	ts := videochattesting.NewTestServer(t)
+	conn := ts.Connect()
+	ts.Send(conn, signaling.Message{Type: "room_exists", CallID: "helper-drain"})
+	ts.AssertMessageReceived(conn, "room_exists_response", videochattesting.Timeout)
+	ts.Send(conn, signaling.Message{Type: "room_exists", CallID: "helper-drain"})
+	time.Sleep(50 * time.Millisecond)
+	ts.Drain(conn)
+	ts.RequireNoMessage(conn, 50*time.Millisecond)
+}
+
+func TestTokenClaimsApply(t *testing.T) {
+	ts := videochattesting.NewTestServer(t)
+	conn := ts.Dial(url.Values{"token": {ts.Token("helper-limited", jwt.MapClaims{"rooms": []string{"helper-allowed"}})}})
+	ts.Send(conn, signaling.Message{Type: "offer", CallID: "helper-denied", Data: videochattesting.SDPData("offer")})
+	ts.AssertError(conn, "room_forbidden")
+	ts.Send(conn, signaling.Message{Type: "offer", CallID: "helper-allowed", Data: videochattesting.SDPData("offer")})
+	ts.WaitForRoom("helper-allowed", 1)
+}
+
+func TestServersComeAndGo(t *testing.T) {
+	var previous string
+	for i := 0; i < 3; i++ {
+		t.Run("server", func(t *testing.T) {
+			if previous != "" {
+				if _, err := http.Get(previous + "/metrics"); err == nil {
+					t.Fatal("the previous test's server is still serving")
+				}
+			}
+			ts := videochattesting.NewTestServer(t)
+			previous = ts.URL()
+			conn := ts.Connect()
+			ts.Send(conn, signaling.Message{Type: "room_exists", CallID: "helper-servers"})
+			ts.AssertMessageReceived(conn, "room_exists_response", videochattesting.Timeout)
+		})
+	}
+}
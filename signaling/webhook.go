@@ -0,0 +1,81 @@
+package signaling
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Webhook delivery configuration
+var (
+	webhookTLSPin          = envString("WEBHOOK_TLS_PIN_SHA256", "") // base64 SHA-256 of the public key receivers must present; empty disables pinning
+	webhookMaxIdleConns    = envInt("WEBHOOK_MAX_IDLE_CONNS", 100)
+	webhookIdleConnTimeout = time.Duration(envInt("WEBHOOK_IDLE_CONN_TIMEOUT", 90)) * time.Second
+)
+
+// errWebhookPinMismatch is returned when a webhook receiver's key does not match webhookTLSPin
+var errWebhookPinMismatch = errors.New("webhook certificate does not match WEBHOOK_TLS_PIN_SHA256")
+
+// webhookClient delivers every webhook over one pool of keep-alive connections, checking webhookTLSPin on HTTPS connections
+var webhookClient = &http.Client{Timeout: 10 * time.Second, Transport: newWebhookTransport(webhookTLSPin)}
+
+// newWebhookTransport returns a pooling transport for webhook deliveries that, when pin is set,
+// only accepts servers whose leaf public key hashes to pin
+func newWebhookTransport(pin string) http.RoundTripper {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = webhookMaxIdleConns
+	transport.MaxIdleConnsPerHost = webhookMaxIdleConns
+	transport.IdleConnTimeout = webhookIdleConnTimeout
+	if pin == "" {
+		return transport
+	}
+	transport.TLSClientConfig = &tls.Config{
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			return verifyPin(cs, pin)
+		},
+	}
+	return transport
+}
+
+// verifyPin checks the SHA-256 of the peer's leaf public key against pin
+func verifyPin(cs tls.ConnectionState, pin string) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errWebhookPinMismatch
+	}
+	sum := sha256.Sum256(cs.PeerCertificates[0].RawSubjectPublicKeyInfo)
+	if base64.StdEncoding.EncodeToString(sum[:]) != pin {
+		log.Printf("Rejecting webhook connection to %s: certificate pin mismatch", cs.ServerName)
+		return errWebhookPinMismatch
+	}
+	return nil
+}
+
+// postWebhook POSTs payload as JSON to url in the background
+func postWebhook(url string, payload interface{}) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Error encoding webhook payload for %s: %v", url, err)
+		return
+	}
+
+	go func() {
+		resp, err := webhookClient.Post(url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			log.Printf("Error delivering webhook to %s: %v", url, err)
+			return
+		}
+		// drain what is left of the body so the connection goes back to the pool
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			log.Printf("Webhook %s responded with %s", url, resp.Status)
+		}
+	}()
+}
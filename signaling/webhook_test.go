@@ -0,0 +1,176 @@
+package signaling
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newWebhookReceiver starts an HTTPS receiver with a self-signed certificate that hands every request
+// body it gets to the returned channel, and returns the base64 SHA-256 of its public key
+func newWebhookReceiver(t *testing.T) (*httptest.Server, string, <-chan string) {
+	bodies := make(chan string, 1)
+	receiver := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("reading webhook body: %v", err)
+		}
+		bodies <- string(body)
+	}))
+	t.Cleanup(receiver.Close)
+	sum := sha256.Sum256(receiver.Certificate().RawSubjectPublicKeyInfo)
+	return receiver, base64.StdEncoding.EncodeToString(sum[:]), bodies
+}
+
+// setWebhookPin delivers webhooks for the rest of the test through a transport pinned to pin that trusts
+// receiver's self-signed certificate
+func setWebhookPin(t *testing.T, receiver *httptest.Server, pin string) {
+	transport := newWebhookTransport(pin).(*http.Transport)
+	roots := x509.NewCertPool()
+	roots.AddCert(receiver.Certificate())
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = receiver.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+	}
+	transport.TLSClientConfig.RootCAs = roots
+	previous := webhookClient
+	webhookClient = &http.Client{Timeout: testTimeout, Transport: transport}
+	t.Cleanup(func() {
+		transport.CloseIdleConnections()
+		webhookClient = previous
+	})
+}
+
+func TestWebhookDeliveredToPinnedReceiver(t *testing.T) {
+	receiver, pin, bodies := newWebhookReceiver(t)
+	setWebhookPin(t, receiver, pin)
+
+	postWebhook(receiver.URL, map[string]string{"event": "pinned"})
+	select {
+	case body := <-bodies:
+		if body != `{"event":"pinned"}` {
+			t.Fatalf("receiver got %s", body)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("pinned receiver never got the webhook")
+	}
+}
+
+func TestWebhookRefusedOnPinMismatch(t *testing.T) {
+	receiver, _, bodies := newWebhookReceiver(t)
+	other := sha256.Sum256([]byte("some other key"))
+	setWebhookPin(t, receiver, base64.StdEncoding.EncodeToString(other[:]))
+
+	resp, err := webhookClient.Post(receiver.URL, "application/json", strings.NewReader(`{}`))
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("webhook delivered to a receiver presenting the wrong key")
+	}
+	if !errors.Is(err, errWebhookPinMismatch) {
+		t.Fatalf("delivery failed with %v, want %v", err, errWebhookPinMismatch)
+	}
+
+	postWebhook(receiver.URL, map[string]string{"event": "mismatch"})
+	select {
+	case body := <-bodies:
+		t.Fatalf("receiver with the wrong key got %s", body)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestWebhookUnpinnedWhenPinEmpty(t *testing.T) {
+	if transport := newWebhookTransport("").(*http.Transport); transport.TLSClientConfig != nil && transport.TLSClientConfig.VerifyConnection != nil {
+		t.Fatal("transport without a pin checks connections")
+	}
+	receiver, _, bodies := newWebhookReceiver(t)
+	setWebhookPin(t, receiver, "")
+
+	postWebhook(receiver.URL, map[string]string{"event": "unpinned"})
+	select {
+	case <-bodies:
+	case <-time.After(testTimeout):
+		t.Fatal("receiver never got the unpinned webhook")
+	}
+}
+
+// countingTransport wraps a transport, counting the connections its requests got and signalling on done
+// when each delivery has finished with its response
+type countingTransport struct {
+	inner       http.RoundTripper
+	mu          sync.Mutex
+	connections int
+	reused      int
+	done        chan struct{}
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	trace := &httptrace.ClientTrace{GotConn: func(info httptrace.GotConnInfo) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if info.Reused {
+			c.reused++
+		} else {
+			c.connections++
+		}
+	}}
+	resp, err := c.inner.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	if err != nil {
+		c.done <- struct{}{}
+		return nil, err
+	}
+	resp.Body = &signallingBody{ReadCloser: resp.Body, done: c.done}
+	return resp, nil
+}
+
+// signallingBody is a response body that signals done when it is closed
+type signallingBody struct {
+	io.ReadCloser
+	done chan<- struct{}
+}
+
+func (b *signallingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.done <- struct{}{}
+	return err
+}
+
+func TestWebhookConnectionsReused(t *testing.T) {
+	transport := newWebhookTransport("").(*http.Transport)
+	if transport.MaxIdleConnsPerHost != webhookMaxIdleConns || transport.IdleConnTimeout != webhookIdleConnTimeout {
+		t.Fatalf("transport keeps %d idle connections per host for %v", transport.MaxIdleConnsPerHost, transport.IdleConnTimeout)
+	}
+	counting := &countingTransport{inner: transport, done: make(chan struct{}, 1)}
+	previous := webhookClient
+	webhookClient = &http.Client{Timeout: testTimeout, Transport: counting}
+	t.Cleanup(func() {
+		transport.CloseIdleConnections()
+		webhookClient = previous
+	})
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	t.Cleanup(receiver.Close)
+
+	const deliveries = 5
+	for i := 0; i < deliveries; i++ {
+		postWebhook(receiver.URL, map[string]int{"delivery": i})
+		select {
+		case <-counting.done:
+		case <-time.After(testTimeout):
+			t.Fatalf("delivery %d never finished", i)
+		}
+	}
+	counting.mu.Lock()
+	defer counting.mu.Unlock()
+	if counting.connections != 1 || counting.reused != deliveries-1 {
+		t.Fatalf("%d deliveries opened %d connections and reused %d, want 1 and %d", deliveries, counting.connections, counting.reused, deliveries-1)
+	}
+}